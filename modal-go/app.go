@@ -2,21 +2,69 @@ package modal
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"iter"
+	"maps"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
 // App references a deployed Modal App.
+//
+// An App is safe for concurrent use, and may be kept for the lifetime of a
+// service: it is not tied to the cancellation of the context it was looked
+// up with. Use WithContext to make calls with a request's context.
+//
+// Objects created through an App, such as Sandboxes and the Secrets holding
+// their EnvVars, are created in the App's environment. Objects looked up
+// separately, such as Volumes and Secrets passed in SandboxOptions, keep the
+// environment they were looked up in.
 type App struct {
 	AppId string
-	ctx   context.Context
+	ctx   context.Context // carries the App's environment, see WithEnvironment
+	name  string
+
+	defaults *atomic.Pointer[SandboxDefaults] // set by SetDefaults, shared by WithContext
+	limiter  *sandboxLimiter                  // set by SetLimits, shared by WithContext
+}
+
+func newApp(ctx context.Context, appId string) *App {
+	return &App{AppId: appId, ctx: ctx, defaults: new(atomic.Pointer[SandboxDefaults]), limiter: newSandboxLimiter()}
+}
+
+// WithContext returns a copy of the App whose calls use ctx, for example to
+// apply a request's deadline and cancellation. Sandboxes, Images, and event
+// streams created through the copy also use ctx. The copy shares the App's
+// defaults, and stays in the App's environment even if ctx sets another one
+// with WithEnvironment.
+func (app *App) WithContext(ctx context.Context) (*App, error) {
+	ctx, err := handleContext(ctx, app.ctx)
+	if err != nil {
+		return nil, err
+	}
+	c := *app
+	c.ctx = ctx
+	return &c, nil
 }
 
 // LookupOptions are options for finding deployed Modal objects.
+//
+// Object names passed to lookup functions may also be qualified as
+// "environment/name" to reference objects shared from another environment in
+// the workspace of the client's credentials.
 type LookupOptions struct {
 	Environment     string
 	CreateIfMissing bool
@@ -27,6 +75,11 @@ type DeleteOptions struct {
 	Environment string // Environment to delete the object from.
 }
 
+// ListOptions are options for listing objects in an environment.
+type ListOptions struct {
+	Environment string // Environment to list the objects of.
+}
+
 // EphemeralOptions are options for creating a temporary, nameless object.
 type EphemeralOptions struct {
 	Environment string // Environment to create the object in.
@@ -34,19 +87,172 @@ type EphemeralOptions struct {
 
 // SandboxOptions are options for creating a Modal Sandbox.
 type SandboxOptions struct {
-	CPU              float64            // CPU request in physical cores.
-	Memory           int                // Memory request in MiB.
+	CPU              CPUCores           // CPU request in physical cores.
+	Memory           MemoryMiB          // Memory request in MiB.
+	GPU              string             // GPU request as type and optional count, such as "A100" or "H100:2".
+	EphemeralDiskMB  DiskMiB            // Ephemeral disk request in MiB, defaults to Modal's default disk size.
 	Timeout          time.Duration      // Maximum duration for the Sandbox.
 	Command          []string           // Command to run in the Sandbox on startup.
 	Volumes          map[string]*Volume // Mount points for Volumes.
-	EncryptedPorts   []int              // List of encrypted ports to tunnel into the sandbox, with TLS encryption.
-	H2Ports          []int              // List of encrypted ports to tunnel into the sandbox, using HTTP/2.
-	UnencryptedPorts []int              // List of ports to tunnel into the sandbox without encryption.
+	Ports            []PortSpec         // Ports to tunnel into the sandbox, see Sandbox.Tunnels().
+	EncryptedPorts   []int              // Shorthand for Ports with TLS encryption.
+	H2Ports          []int              // Shorthand for Ports with PortSpec.H2 set.
+	UnencryptedPorts []int              // Shorthand for Ports with PortSpec.Unencrypted set.
+	HealthCheck      *HealthCheck       // Liveness probe run periodically, see Sandbox.Health().
+	Stdout           StdioBehavior      // Whether to pipe or ignore the entrypoint's stdout, defaults to Pipe.
+	Stderr           StdioBehavior      // Whether to pipe or ignore the entrypoint's stderr, defaults to Pipe.
+	Secrets          []*Secret          // Secrets to inject as environment variables.
+	EnvVars          map[string]string  // Environment variables to set in the Sandbox.
+	Regions          []string           // Regions to run the Sandbox in, defaults to any region.
+	Runtime          SandboxRuntime     // Container runtime isolating the Sandbox, defaults to Modal's choice.
+	// Arch is the CPU architecture in GOARCH form. Only "amd64" is supported
+	// for now, and other values fail with an UnsupportedArchError instead of
+	// silently running on amd64.
+	Arch string
+	// Entrypoint is run with Command appended as its arguments, like
+	// `docker run --entrypoint`. Modal does not run the ENTRYPOINT of
+	// registry Images, so without it Command runs on its own.
+	Entrypoint []string
+	// Processes started alongside the main command, see
+	// Sandbox.BackgroundProcess(). Modal has no sidecar containers, so these
+	// are processes in the Sandbox's own container: they cannot use another
+	// Image, and are not isolated from the main command.
+	BackgroundProcesses []BackgroundProcess
+	// IdempotencyKey is sent to Modal as the idempotency key of the create
+	// request, in place of a random key per call, so that Modal can return
+	// the Sandbox created by an earlier call with the same key, such as one
+	// that timed out, instead of a duplicate. Use a unique value, such as a
+	// UUID, per Sandbox. The SDK does not verify that Modal deduplicates the
+	// request. When it returns a Sandbox already created in this process with
+	// the same key, CreateSandbox returns that Sandbox, and does not set its
+	// tags, start its background processes or health check, or count it
+	// against the App's limits again.
+	IdempotencyKey string
+	// KeepOnCancel keeps a Sandbox running when the App's context is
+	// cancelled after Modal created it but before CreateSandbox returned. By
+	// default it is terminated, since the caller never receives it.
+	KeepOnCancel bool
+	// Detach runs the Sandbox independently of the caller, for jobs that
+	// should keep running after the program that started them exits. The
+	// Sandbox is kept if the App's context is cancelled during creation, as
+	// with KeepOnCancel, its methods use a context that is never cancelled,
+	// and Group.CreateSandbox does not terminate it when the Group fails.
+	// Use AdoptSandbox to reconnect to it later by ID.
+	//
+	// Apps found with AppLookup are not ephemeral: Modal does not stop them,
+	// or their Sandboxes, when the client disconnects, so a Sandbox only
+	// stops when its command exits, it is terminated, or its Timeout passes.
+	// Set a Timeout for long jobs, since Modal's default is 5 minutes.
+	Detach bool
+	// Tags are key/value labels set on the Sandbox, such as a team or cost
+	// center. They are reported in Sandbox listings and cost estimates, and
+	// can be filtered on with App.ListSandboxes.
+	Tags map[string]string
+}
+
+// PortSpec is a port in a Sandbox that is reachable through a tunnel.
+type PortSpec struct {
+	Port int `json:"port"` // Port the Sandbox listens on.
+	// Unencrypted exposes the port as raw TCP, without TLS termination, in
+	// addition to the TLS endpoint. See Tunnel.TCPSocket().
+	Unencrypted bool `json:"unencrypted,omitempty"`
+	H2          bool `json:"h2,omitempty"` // Serve the TLS endpoint over HTTP/2.
+}
+
+// portSpecs returns the ports to tunnel, from Ports and the shorthand fields.
+func (options *SandboxOptions) portSpecs() []PortSpec {
+	ports := slices.Clone(options.Ports)
+	for _, port := range options.EncryptedPorts {
+		ports = append(ports, PortSpec{Port: port})
+	}
+	for _, port := range options.H2Ports {
+		ports = append(ports, PortSpec{Port: port, H2: true})
+	}
+	for _, port := range options.UnencryptedPorts {
+		ports = append(ports, PortSpec{Port: port, Unencrypted: true})
+	}
+	return ports
+}
+
+// supportedArchs are the CPU architectures that Modal runs Sandboxes on. Modal
+// only has amd64 workers, and Sandbox definitions have no field to request
+// another architecture.
+var supportedArchs = []string{"amd64"}
+
+// SandboxRuntime is the container runtime that isolates a Sandbox from its
+// host.
+type SandboxRuntime string
+
+const (
+	// RuntimeGVisor runs the Sandbox in gVisor (runsc), which intercepts
+	// system calls in a user-space kernel. It is the isolation to use for
+	// untrusted code. Setting it explicitly pins it, regardless of the
+	// default chosen by Modal.
+	RuntimeGVisor SandboxRuntime = "gvisor"
+	// RuntimeRunc runs the Sandbox in runc, with less overhead but weaker
+	// isolation. Modal only permits it for some workspaces, and otherwise
+	// rejects the Sandbox.
+	RuntimeRunc SandboxRuntime = "runc"
+)
+
+// BackgroundProcess is a process started alongside a Sandbox's main command,
+// such as a proxy or log agent.
+//
+// Modal runs each Sandbox as a single container, so background processes are
+// not sidecar containers with their own Image: they run in the Sandbox's
+// Image, as the same user as the main command, and share its filesystem,
+// Volumes, network, and resources. They are not restarted if they exit.
+// CreateSandbox starts them with Exec once the Sandbox is running, so their
+// start time is not part of the Sandbox creation time reported to Metrics.
+type BackgroundProcess struct {
+	Name    string            `json:"name"`               // Unique name, used to look up the process.
+	Command []string          `json:"command"`            // Command to run.
+	Workdir string            `json:"workdir,omitempty"`  // Working directory, defaults to that of the Image.
+	EnvVars map[string]string `json:"env_vars,omitempty"` // Environment variables set for this process only.
+}
+
+// SandboxDefaults are defaults applied to every Sandbox created in an App,
+// see App.SetDefaults.
+type SandboxDefaults struct {
+	Image   *Image             // Image used when CreateSandbox is called with a nil image.
+	CPU     CPUCores           // CPU request in physical cores, if not set per Sandbox.
+	Memory  MemoryMiB          // Memory request in MiB, if not set per Sandbox.
+	Timeout time.Duration      // Maximum duration for the Sandbox, if not set per Sandbox.
+	Secrets []*Secret          // Secrets injected in addition to any set per Sandbox.
+	EnvVars map[string]string  // Environment variables, overridden by those set per Sandbox.
+	Volumes map[string]*Volume // Volume mounts, overridden by those set per Sandbox.
+	Regions []string           // Regions to run in, if not set per Sandbox.
+	Runtime SandboxRuntime     // Container runtime, if not set per Sandbox.
+	Tags    map[string]string  // Tags, overridden by those set per Sandbox.
 }
 
 // ImageFromRegistryOptions are options for creating an Image from a registry.
 type ImageFromRegistryOptions struct {
-	Secret *Secret // Secret for private registry authentication.
+	Secret  *Secret       // Secret for private registry authentication.
+	Timeout time.Duration // Maximum time to wait for the Image build, defaults to no limit.
+	// BuilderVersion is the image builder version to build with, such as
+	// "2024.04", see ClientOptions.ImageBuilderVersion for the default.
+	BuilderVersion string
+}
+
+// ImageFromGoBinaryOptions are options for creating an Image from a Go package.
+type ImageFromGoBinaryOptions struct {
+	BaseImage  string        // Registry tag of the base image, defaults to "alpine:3.21".
+	Dir        string        // Directory to build from, defaults to the current directory.
+	BuildFlags []string      // Extra flags passed to `go build`, such as "-ldflags=-s -w".
+	Timeout    time.Duration // Maximum time to wait for the Image build, defaults to no limit.
+	// BuilderVersion is the image builder version to build with, see
+	// ImageFromRegistryOptions.BuilderVersion.
+	BuilderVersion string
+}
+
+// ImageForRuntimeOptions are options for creating an Image for a language
+// runtime.
+type ImageForRuntimeOptions struct {
+	Timeout time.Duration // Maximum time to wait for the Image build, defaults to no limit.
+	// BuilderVersion is the image builder version to build with, see
+	// ImageFromRegistryOptions.BuilderVersion.
+	BuilderVersion string
 }
 
 // AppLookup looks up an existing App, or creates an empty one.
@@ -59,6 +265,11 @@ func AppLookup(ctx context.Context, name string, options *LookupOptions) (*App,
 	if err != nil {
 		return nil, err
 	}
+	var environment string
+	name, environment, err = resolveObjectRef(name, "App", options.Environment)
+	if err != nil {
+		return nil, err
+	}
 
 	creationType := pb.ObjectCreationType_OBJECT_CREATION_TYPE_UNSPECIFIED
 	if options.CreateIfMissing {
@@ -67,7 +278,7 @@ func AppLookup(ctx context.Context, name string, options *LookupOptions) (*App,
 
 	resp, err := client.AppGetOrCreate(ctx, pb.AppGetOrCreateRequest_builder{
 		AppName:            name,
-		EnvironmentName:    environmentName(options.Environment),
+		EnvironmentName:    environmentName(ctx, environment),
 		ObjectCreationType: creationType,
 	}.Build())
 
@@ -78,21 +289,351 @@ func AppLookup(ctx context.Context, name string, options *LookupOptions) (*App,
 		return nil, err
 	}
 
-	return &App{AppId: resp.GetAppId(), ctx: ctx}, nil
+	ctx = WithEnvironment(context.WithoutCancel(ctx), environmentName(ctx, environment))
+	app := newApp(ctx, resp.GetAppId())
+	app.name = name
+	return app, nil
 }
 
-// CreateSandbox creates a new Sandbox in the App with the specified image and options.
-func (app *App) CreateSandbox(image *Image, options *SandboxOptions) (*Sandbox, error) {
+// DefaultAppName is the name of the App that DefaultApp returns.
+const DefaultAppName = "libmodal-sandboxes"
+
+// defaultApps caches the App returned by DefaultApp per environment, until
+// the client is reinitialized.
+var defaultApps sync.Map
+
+// DefaultApp returns the App named DefaultAppName in the environment of ctx,
+// creating it if it doesn't exist, for scripts that don't need their own App.
+// It is looked up once per environment and client.
+func DefaultApp(ctx context.Context) (*App, error) {
+	environment := environmentName(ctx, "")
+	if app, ok := defaultApps.Load(environment); ok {
+		return app.(*App), nil
+	}
+	app, err := AppLookup(ctx, DefaultAppName, &LookupOptions{Environment: environment, CreateIfMissing: true})
+	if err != nil {
+		return nil, err
+	}
+	cached, _ := defaultApps.LoadOrStore(environment, app)
+	return cached.(*App), nil
+}
+
+// RunSandbox creates a Sandbox in the DefaultApp, with ctx, like
+// App.CreateSandbox. Build image with the DefaultApp too, for example:
+//
+//	app, _ := modal.DefaultApp(ctx)
+//	image, _ := app.ImageFromRegistry("alpine:3.21", nil)
+//	sb, err := modal.RunSandbox(ctx, image, &modal.SandboxOptions{Command: []string{"echo", "hi"}})
+//
+// The Sandbox's methods also use ctx, see App.WithContext.
+func RunSandbox(ctx context.Context, image *Image, options *SandboxOptions) (*Sandbox, error) {
+	app, err := DefaultApp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	app, err = app.WithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return app.CreateSandbox(image, options)
+}
+
+// ObjectId returns the App's ID, see Object.
+func (app *App) ObjectId() string {
+	return app.AppId
+}
+
+// Name returns the name the App was looked up by.
+func (app *App) Name() string {
+	return app.name
+}
+
+// Environment returns the name of the environment the App is in, or an empty
+// string for the workspace's default environment.
+func (app *App) Environment() string {
+	return environmentName(app.ctx, "")
+}
+
+// IsHydrated reports whether the App has an ID, see Object.
+func (app *App) IsHydrated() bool {
+	return app.AppId != ""
+}
+
+// Hydrate makes the App ready for use, see Object.
+func (app *App) Hydrate(ctx context.Context) error {
+	if app.defaults == nil {
+		app.defaults = &atomic.Pointer[SandboxDefaults]{}
+	}
+	if app.limiter == nil {
+		app.limiter = newSandboxLimiter()
+	}
+	return hydrate(ctx, "App", app.AppId, &app.ctx)
+}
+
+// AppInfo describes an App in a listing, see AppList.
+type AppInfo struct {
+	AppId        string
+	Name         string    // Deployment name, empty for ephemeral Apps.
+	Description  string    // Description set when the App was created.
+	State        string    // Such as "deployed", "ephemeral", or "stopped".
+	CreatedAt    time.Time // Creation time of the App.
+	StoppedAt    time.Time // Time the App stopped, zero if it is running.
+	RunningTasks int       // Number of containers currently running.
+}
+
+// AppList returns an iterator over the Apps in an environment. The listing
+// is fetched when iteration begins.
+func AppList(ctx context.Context, options *ListOptions) iter.Seq2[AppInfo, error] {
+	if options == nil {
+		options = &ListOptions{}
+	}
+	return func(yield func(AppInfo, error) bool) {
+		ctx, err := clientContext(ctx)
+		if err != nil {
+			yield(AppInfo{}, err)
+			return
+		}
+		resp, err := client.AppList(ctx, pb.AppListRequest_builder{
+			EnvironmentName: environmentName(ctx, options.Environment),
+		}.Build())
+		if err != nil {
+			yield(AppInfo{}, err)
+			return
+		}
+		for _, item := range resp.GetApps() {
+			info := AppInfo{
+				AppId:        item.GetAppId(),
+				Name:         item.GetName(),
+				Description:  item.GetDescription(),
+				State:        strings.ToLower(strings.TrimPrefix(item.GetState().String(), "APP_STATE_")),
+				CreatedAt:    time.Unix(0, int64(item.GetCreatedAt()*1e9)),
+				RunningTasks: int(item.GetNRunningTasks()),
+			}
+			if item.GetStoppedAt() != 0 {
+				info.StoppedAt = time.Unix(0, int64(item.GetStoppedAt()*1e9))
+			}
+			if !yield(info, nil) {
+				return
+			}
+		}
+	}
+}
+
+// AppDeployment is an entry in the deployment history of an App.
+type AppDeployment struct {
+	Version         int       // Version number of the deployment.
+	Tag             string    // Tag the deployment was created with, may be empty.
+	DeployedAt      time.Time // Time of the deployment.
+	DeployedBy      string    // Name of the user or token that deployed.
+	ClientVersion   string    // Version of the Modal client used to deploy.
+	RollbackVersion int       // Version that was restored, if this deployment is a rollback.
+	RollbackAllowed bool      // Whether the App can be rolled back to this version.
+}
+
+// DashboardURL returns a link to the App in the Modal web UI.
+func (app *App) DashboardURL() string {
+	return dashboardURL(app.AppId)
+}
+
+// DeploymentHistory returns the deployments of the App, most recent first.
+func (app *App) DeploymentHistory() ([]AppDeployment, error) {
+	resp, err := client.AppDeploymentHistory(app.ctx, pb.AppDeploymentHistoryRequest_builder{
+		AppId: app.AppId,
+	}.Build())
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]AppDeployment, 0, len(resp.GetAppDeploymentHistories()))
+	for _, d := range resp.GetAppDeploymentHistories() {
+		history = append(history, AppDeployment{
+			Version:         int(d.GetVersion()),
+			Tag:             d.GetTag(),
+			DeployedAt:      time.Unix(0, int64(d.GetDeployedAt()*1e9)),
+			DeployedBy:      d.GetDeployedBy(),
+			ClientVersion:   d.GetClientVersion(),
+			RollbackVersion: int(d.GetRollbackVersion()),
+			RollbackAllowed: d.GetRollbackAllowed(),
+		})
+	}
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].Version > history[j].Version
+	})
+	return history, nil
+}
+
+// Rollback redeploys the App at a previous version from its deployment
+// history. A negative version rolls back to the deployment before the
+// current one.
+func (app *App) Rollback(version int) error {
+	_, err := client.AppRollback(app.ctx, pb.AppRollbackRequest_builder{
+		AppId:   app.AppId,
+		Version: int32(version),
+	}.Build())
+	if status, ok := status.FromError(err); ok && status.Code() == codes.NotFound {
+		return NotFoundError{fmt.Sprintf("version %d of app %s not found", version, app.AppId)}
+	}
+	return err
+}
+
+// SetDefaults sets defaults for Sandboxes subsequently created in the App.
+// Options passed to CreateSandbox take precedence, while maps are merged and
+// Secrets are combined. Sandboxes being created concurrently may use either
+// the previous or the new defaults.
+func (app *App) SetDefaults(defaults SandboxDefaults) {
+	app.defaults.Store(&defaults)
+}
+
+// withDefaults returns image and options with the App's defaults applied.
+func (app *App) withDefaults(image *Image, options *SandboxOptions) (*Image, *SandboxOptions) {
 	if options == nil {
 		options = &SandboxOptions{}
 	}
+	d := app.defaults.Load()
+	if d == nil {
+		return image, options
+	}
+	if image == nil {
+		image = d.Image
+	}
+	merged := *options
+	if merged.CPU == 0 {
+		merged.CPU = d.CPU
+	}
+	if merged.Memory == 0 {
+		merged.Memory = d.Memory
+	}
+	if merged.Timeout == 0 {
+		merged.Timeout = d.Timeout
+	}
+	if len(merged.Regions) == 0 {
+		merged.Regions = d.Regions
+	}
+	if merged.Runtime == "" {
+		merged.Runtime = d.Runtime
+	}
+	merged.Secrets = append(slices.Clone(d.Secrets), options.Secrets...)
+	if len(d.EnvVars) > 0 {
+		merged.EnvVars = maps.Clone(d.EnvVars)
+		maps.Copy(merged.EnvVars, options.EnvVars)
+	}
+	if len(d.Volumes) > 0 {
+		merged.Volumes = maps.Clone(d.Volumes)
+		maps.Copy(merged.Volumes, options.Volumes)
+	}
+	if len(d.Tags) > 0 {
+		merged.Tags = maps.Clone(d.Tags)
+		maps.Copy(merged.Tags, options.Tags)
+	}
+	return image, &merged
+}
+
+// CreateSandbox creates a new Sandbox in the App with the specified image and options.
+//
+// Defaults set with SetDefaults are applied first, and image may be nil if a
+// default Image is set. If limits are set with SetLimits, CreateSandbox first
+// waits until they allow another Sandbox.
+//
+// If the App's context is cancelled after Modal created the Sandbox, it is
+// terminated unless options.KeepOnCancel is set, and the context's error is
+// returned. If the Sandbox is kept or could not be terminated, the error is an
+// OrphanedSandboxError with its ID. A cancellation while the create request is
+// in flight leaves no ID to clean up: retry with the same
+// options.IdempotencyKey to recover the Sandbox.
+func (app *App) CreateSandbox(image *Image, options *SandboxOptions) (*Sandbox, error) {
+	image, options = app.withDefaults(image, options)
+	return app.startSandbox(image, options)
+}
+
+// createdSandboxes holds the Sandboxes created in this process with an
+// idempotency key, by key, until they are terminated.
+var createdSandboxes sync.Map
+
+// startSandbox creates a Sandbox within the App's limits, and runs the steps
+// that follow its creation.
+func (app *App) startSandbox(image *Image, options *SandboxOptions) (*Sandbox, error) {
+	// A Sandbox created in this process with the same idempotency key holds
+	// a slot already.
+	var existing *Sandbox
+	if options.IdempotencyKey != "" {
+		if v, ok := createdSandboxes.Load(options.IdempotencyKey); ok {
+			existing = v.(*Sandbox)
+		}
+	}
+	var release func()
+	if existing == nil {
+		var err error
+		if release, err = app.limiter.acquire(app.ctx); err != nil {
+			return nil, err
+		}
+	}
+	start := time.Now()
+	sb, err := app.createSandbox(image, options)
+	currentMetrics().ObserveSandboxCreate(time.Since(start), err)
+	if err != nil {
+		if release != nil {
+			release()
+		}
+		return nil, err
+	}
+	if existing != nil {
+		if existing.SandboxId == sb.SandboxId {
+			// Modal returned the Sandbox of the earlier call, which already
+			// ran the steps below.
+			return existing, nil
+		}
+		if release, err = app.limiter.acquire(app.ctx); err != nil {
+			return nil, sb.discard(err, options.KeepOnCancel || options.Detach)
+		}
+	}
+	if options.IdempotencyKey != "" {
+		sb.idempotencyKey = options.IdempotencyKey
+		createdSandboxes.Store(options.IdempotencyKey, sb)
+	}
+	if release != nil {
+		sb.release = release
+		sb.watchRelease()
+	}
+	if options.HealthCheck != nil {
+		sb.health = startHealthMonitor(sb, *options.HealthCheck)
+	}
+	// fail cleans up the Sandbox after a step following its creation failed.
+	fail := func(err error) (*Sandbox, error) {
+		sb.forgetIdempotencyKey()
+		if ctxErr := app.ctx.Err(); ctxErr != nil {
+			return nil, sb.discard(ctxErr, options.KeepOnCancel || options.Detach)
+		}
+		return nil, sb.discard(err, false)
+	}
+	if len(options.Tags) > 0 {
+		if err := sb.SetTags(options.Tags); err != nil {
+			return fail(err)
+		}
+	}
+	if len(options.BackgroundProcesses) > 0 {
+		if err := sb.startBackgroundProcesses(options.BackgroundProcesses); err != nil {
+			return fail(err)
+		}
+	}
+	if err := app.ctx.Err(); err != nil {
+		return fail(err)
+	}
+	return sb, nil
+}
+
+func (app *App) createSandbox(image *Image, options *SandboxOptions) (*Sandbox, error) {
+	if err := ValidateSandboxOptions(image, options); err != nil {
+		return nil, err
+	}
 
+	// Fields built from maps are sorted, so that equal options make equal
+	// requests.
 	var volumeMounts []*pb.VolumeMount
 	if options.Volumes != nil {
 		volumeMounts = make([]*pb.VolumeMount, 0, len(options.Volumes))
-		for mountPath, volume := range options.Volumes {
+		for _, mountPath := range slices.Sorted(maps.Keys(options.Volumes)) {
 			volumeMounts = append(volumeMounts, pb.VolumeMount_builder{
-				VolumeId:               volume.VolumeId,
+				VolumeId:               options.Volumes[mountPath].VolumeId,
 				MountPath:              mountPath,
 				AllowBackgroundCommits: true,
 				ReadOnly:               false,
@@ -101,23 +642,15 @@ func (app *App) CreateSandbox(image *Image, options *SandboxOptions) (*Sandbox,
 	}
 
 	var openPorts []*pb.PortSpec
-	for _, port := range options.EncryptedPorts {
-		openPorts = append(openPorts, pb.PortSpec_builder{
-			Port:        uint32(port),
-			Unencrypted: false,
-		}.Build())
-	}
-	for _, port := range options.H2Ports {
-		openPorts = append(openPorts, pb.PortSpec_builder{
-			Port:        uint32(port),
-			Unencrypted: false,
-			TunnelType:  pb.TunnelType_TUNNEL_TYPE_H2.Enum(),
-		}.Build())
-	}
-	for _, port := range options.UnencryptedPorts {
+	for _, port := range options.portSpecs() {
+		var tunnelType *pb.TunnelType
+		if port.H2 {
+			tunnelType = pb.TunnelType_TUNNEL_TYPE_H2.Enum()
+		}
 		openPorts = append(openPorts, pb.PortSpec_builder{
-			Port:        uint32(port),
-			Unencrypted: true,
+			Port:        uint32(port.Port),
+			Unencrypted: port.Unencrypted,
+			TunnelType:  tunnelType,
 		}.Build())
 	}
 
@@ -128,32 +661,230 @@ func (app *App) CreateSandbox(image *Image, options *SandboxOptions) (*Sandbox,
 		}.Build()
 	}
 
-	createResp, err := client.SandboxCreate(app.ctx, pb.SandboxCreateRequest_builder{
-		AppId: app.AppId,
-		Definition: pb.Sandbox_builder{
-			EntrypointArgs: options.Command,
-			ImageId:        image.ImageId,
-			TimeoutSecs:    uint32(options.Timeout.Seconds()),
-			NetworkAccess: pb.NetworkAccess_builder{
-				NetworkAccessType: pb.NetworkAccess_OPEN,
-			}.Build(),
-			Resources: pb.Resources_builder{
-				MilliCpu: uint32(1000 * options.CPU),
-				MemoryMb: uint32(options.Memory),
-			}.Build(),
-			VolumeMounts: volumeMounts,
-			OpenPorts:    portSpecs,
-		}.Build(),
-	}.Build())
+	secretIds, err := secretIdsWithEnv(app.ctx, options.Secrets, options.EnvVars)
+	if err != nil {
+		return nil, err
+	}
+
+	var schedulerPlacement *pb.SchedulerPlacement
+	if len(options.Regions) > 0 {
+		schedulerPlacement = pb.SchedulerPlacement_builder{Regions: options.Regions}.Build()
+	}
 
+	gpuConfig, err := parseGPUConfig(options.GPU)
+	if err != nil {
+		return nil, err
+	}
+	milliCPU, err := options.CPU.milli()
+	if err != nil {
+		return nil, err
+	}
+	memoryMiB, err := mebibytes("Memory", int(options.Memory))
+	if err != nil {
+		return nil, err
+	}
+	diskMiB, err := mebibytes("EphemeralDiskMB", int(options.EphemeralDiskMB))
 	if err != nil {
 		return nil, err
 	}
 
-	return newSandbox(app.ctx, createResp.GetSandboxId()), nil
+	var runtime *string
+	if options.Runtime != "" {
+		runtime = (*string)(&options.Runtime)
+	}
+
+	definition := pb.Sandbox_builder{
+		EntrypointArgs: append(slices.Clone(options.Entrypoint), options.Command...),
+		ImageId:        image.ImageId,
+		TimeoutSecs:    timeoutSecs(options.Timeout),
+		NetworkAccess: pb.NetworkAccess_builder{
+			NetworkAccessType: pb.NetworkAccess_OPEN,
+		}.Build(),
+		Resources: pb.Resources_builder{
+			MilliCpu:        milliCPU,
+			MemoryMb:        memoryMiB,
+			GpuConfig:       gpuConfig,
+			EphemeralDiskMb: diskMiB,
+		}.Build(),
+		VolumeMounts:       volumeMounts,
+		OpenPorts:          portSpecs,
+		SecretIds:          secretIds,
+		SchedulerPlacement: schedulerPlacement,
+		Runtime:            runtime,
+	}.Build()
+	createResp, err := client.SandboxCreate(app.ctx, pb.SandboxCreateRequest_builder{
+		AppId:           app.AppId,
+		EnvironmentName: app.Environment(),
+		Definition:      definition,
+	}.Build(), withIdempotencyKey(options.IdempotencyKey))
+
+	if err != nil {
+		return nil, parseResourcesError(err, options)
+	}
+
+	ctx := app.ctx
+	if options.Detach {
+		ctx = context.WithoutCancel(ctx)
+	}
+	sb := newSandboxWithStdio(ctx, createResp.GetSandboxId(), options.Stdout, options.Stderr)
+	sb.detached = options.Detach
+	sb.definitionHash = definitionHash(definition, options.EnvVars)
+	sb.definition = newSandboxDefinition(image, options)
+	return sb, nil
+}
+
+// definitionHash returns a hex SHA-256 hash of a Sandbox definition that is
+// the same for equal options. EnvVars are passed in an ephemeral Secret with a
+// new ID per Sandbox, the last of the definition's Secrets, so its ID is left
+// out and envVars are hashed in sorted order instead.
+func definitionHash(definition *pb.Sandbox, envVars map[string]string) string {
+	if len(envVars) > 0 {
+		definition = proto.Clone(definition).(*pb.Sandbox)
+		secretIds := definition.GetSecretIds()
+		definition.SetSecretIds(secretIds[:len(secretIds)-1])
+	}
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(definition)
+	if err != nil {
+		panic(err) // a built message always marshals
+	}
+	h := sha256.New()
+	h.Write(data)
+	for _, name := range slices.Sorted(maps.Keys(envVars)) {
+		fmt.Fprintf(h, "\x00%s=%s", name, envVars[name])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// parseGPUConfig parses a GPU request such as "A100" or "H100:2". It returns
+// nil for an empty request.
+func parseGPUConfig(gpu string) (*pb.GPUConfig, error) {
+	if gpu == "" {
+		return nil, nil
+	}
+	gpuType, countStr, hasCount := strings.Cut(gpu, ":")
+	count := 1
+	if hasCount {
+		var err error
+		if count, err = strconv.Atoi(countStr); err != nil || count < 1 {
+			return nil, InvalidError{fmt.Sprintf("invalid GPU count in %q, expected a positive integer", gpu)}
+		}
+		if err := GPUCount(count).Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if gpuType == "" {
+		return nil, InvalidError{fmt.Sprintf("invalid GPU %q, expected a type such as \"A100\"", gpu)}
+	}
+	return pb.GPUConfig_builder{
+		GpuType: strings.ToUpper(gpuType),
+		Count:   uint32(count),
+	}.Build(), nil
+}
+
+// Maximum lifetime of a Sandbox.
+const maxSandboxTimeout = 24 * time.Hour
+
+// ValidateSandboxOptions runs the local checks that CreateSandbox performs on
+// image and options before making any request. It does not contact Modal.
+//
+// It checks the shape of resource requests, timeout, port numbers, Volume
+// mount points, background processes, health check, architecture, runtime,
+// and stdio behaviors. It is not a dry run: whether the Image, Volumes, and
+// Secrets exist, whether the GPU type is available, and workspace limits such
+// as quotas are only checked by Modal when the Sandbox is created, so
+// CreateSandbox may still fail.
+func ValidateSandboxOptions(image *Image, options *SandboxOptions) error {
+	if image == nil || image.ImageId == "" {
+		return InvalidError{"image must not be nil"}
+	}
+	if options == nil {
+		return nil
+	}
+	if err := options.CPU.Validate(); err != nil {
+		return err
+	}
+	if err := options.Memory.Validate(); err != nil {
+		return err
+	}
+	if err := options.EphemeralDiskMB.Validate(); err != nil {
+		return err
+	}
+	if _, err := parseGPUConfig(options.GPU); err != nil {
+		return err
+	}
+	if err := validateTags(options.Tags); err != nil {
+		return err
+	}
+	if options.Timeout < 0 || options.Timeout > maxSandboxTimeout {
+		return InvalidError{fmt.Sprintf("Timeout must be between 0 and %v, got %v", maxSandboxTimeout, options.Timeout)}
+	}
+
+	ports := map[int]bool{}
+	for _, spec := range options.portSpecs() {
+		port := spec.Port
+		if port < 1 || port > 65535 {
+			return InvalidError{fmt.Sprintf("port %d is out of range 1-65535", port)}
+		}
+		if ports[port] {
+			return InvalidError{fmt.Sprintf("port %d is specified more than once", port)}
+		}
+		ports[port] = true
+		if spec.H2 && spec.Unencrypted {
+			return InvalidError{fmt.Sprintf("port %d cannot be both H2 and Unencrypted", port)}
+		}
+	}
+
+	for mountPath, volume := range options.Volumes {
+		if volume == nil {
+			return InvalidError{fmt.Sprintf("Volume for mount point %s must not be nil", mountPath)}
+		}
+		if !path.IsAbs(mountPath) || path.Clean(mountPath) == "/" {
+			return InvalidError{fmt.Sprintf("Volume mount point must be an absolute path other than /, got %q", mountPath)}
+		}
+	}
+
+	processNames := map[string]bool{}
+	for _, process := range options.BackgroundProcesses {
+		if process.Name == "" || processNames[process.Name] {
+			return InvalidError{fmt.Sprintf("background process names must be unique and non-empty, got %q", process.Name)}
+		}
+		processNames[process.Name] = true
+		if len(process.Command) == 0 {
+			return InvalidError{fmt.Sprintf("command of background process %q must not be empty", process.Name)}
+		}
+	}
+
+	if options.HealthCheck != nil && len(options.HealthCheck.Command) == 0 {
+		return InvalidError{"HealthCheck.Command must not be empty"}
+	}
+	if options.Arch != "" && !slices.Contains(supportedArchs, options.Arch) {
+		return UnsupportedArchError{
+			Exception: fmt.Sprintf("Sandboxes cannot run on %s, supported architectures are %v", options.Arch, supportedArchs),
+			Arch:      options.Arch,
+		}
+	}
+	if options.Runtime != "" && options.Runtime != RuntimeGVisor && options.Runtime != RuntimeRunc {
+		return InvalidError{fmt.Sprintf("invalid Sandbox runtime: %q", options.Runtime)}
+	}
+	for _, behavior := range []StdioBehavior{options.Stdout, options.Stderr} {
+		if behavior != "" && behavior != Pipe && behavior != Ignore {
+			return InvalidError{fmt.Sprintf("invalid stdio behavior: %q", behavior)}
+		}
+	}
+	return nil
 }
 
 // ImageFromRegistry creates an Image from a registry tag.
+//
+// The build is waited on until it completes, options.Timeout elapses, or the
+// App's context is cancelled. In the latter two cases an ImageBuildError with
+// the partial build logs is returned. Modal has no API to abort an Image
+// build, so the build itself keeps running remotely and can be joined again by
+// a later call.
+//
+// If the base image cannot be pulled, for example because the registry
+// rejects the credentials, an ImagePullError is returned with a hint on which
+// keys the Secret should have.
 func (app *App) ImageFromRegistry(tag string, options *ImageFromRegistryOptions) (*Image, error) {
 	if options == nil {
 		options = &ImageFromRegistryOptions{}
@@ -165,23 +896,81 @@ func (app *App) ImageFromRegistry(tag string, options *ImageFromRegistryOptions)
 			SecretId:         options.Secret.SecretId,
 		}.Build()
 	}
-	return fromRegistryInternal(app, tag, imageRegistryConfig)
+	return fromRegistryInternal(app, tag, imageRegistryConfig, options.Timeout, options.BuilderVersion)
 }
 
 // ImageFromAwsEcr creates an Image from an AWS ECR tag.
+//
+// The build is waited on without a time limit; see ImageFromRegistry.
 func (app *App) ImageFromAwsEcr(tag string, secret *Secret) (*Image, error) {
 	imageRegistryConfig := pb.ImageRegistryConfig_builder{
 		RegistryAuthType: pb.RegistryAuthType_REGISTRY_AUTH_TYPE_AWS,
 		SecretId:         secret.SecretId,
 	}.Build()
-	return fromRegistryInternal(app, tag, imageRegistryConfig)
+	return fromRegistryInternal(app, tag, imageRegistryConfig, 0, "")
 }
 
 // ImageFromGcpArtifactRegistry creates an Image from a GCP Artifact Registry tag.
+//
+// The build is waited on without a time limit; see ImageFromRegistry.
 func (app *App) ImageFromGcpArtifactRegistry(tag string, secret *Secret) (*Image, error) {
 	imageRegistryConfig := pb.ImageRegistryConfig_builder{
 		RegistryAuthType: pb.RegistryAuthType_REGISTRY_AUTH_TYPE_GCP,
 		SecretId:         secret.SecretId,
 	}.Build()
-	return fromRegistryInternal(app, tag, imageRegistryConfig)
+	return fromRegistryInternal(app, tag, imageRegistryConfig, 0, "")
+}
+
+// ImageFromGoBinary creates an Image that runs a Go program from the local
+// module. The package at pkgPath is cross-compiled for linux/amd64 with
+// CGO disabled, copied onto the base image at /usr/local/bin/app, and set as
+// the image's entrypoint. A Go toolchain must be available on the PATH, and
+// the base image must provide chmod.
+func (app *App) ImageFromGoBinary(pkgPath string, options *ImageFromGoBinaryOptions) (*Image, error) {
+	if options == nil {
+		options = &ImageFromGoBinaryOptions{}
+	}
+	baseImage := options.BaseImage
+	if baseImage == "" {
+		baseImage = "alpine:3.21"
+	}
+
+	binary, err := crossCompileGo(app.ctx, options.Dir, pkgPath, options.BuildFlags)
+	if err != nil {
+		return nil, err
+	}
+	return buildImage(app, pb.Image_builder{
+		DockerfileCommands: []string{
+			`FROM ` + baseImage,
+			`COPY app ` + goBinaryPath,
+			`RUN chmod +x ` + goBinaryPath,
+			`ENTRYPOINT ["` + goBinaryPath + `"]`,
+		},
+		ContextFiles: []*pb.ImageContextFile{
+			pb.ImageContextFile_builder{Filename: "app", Data: binary}.Build(),
+		},
+	}.Build(), options.Timeout, options.BuilderVersion)
+}
+
+// ImageForRuntime creates an Image with a language runtime and packages
+// installed, for running code in Sandboxes without writing a Dockerfile.
+// runtime is a language and version, one of "pythonX.Y", "nodeN", or "goX.Y",
+// such as "python3.12", "node20", or "go1.23". packages are installed with
+// the runtime's package manager:
+//
+//   - Python packages with pip, such as "numpy" or "pandas==2.2.3".
+//   - Node.js packages globally with npm, and resolvable by require.
+//   - Go modules with go get, into a module at /app, the working directory.
+//
+// Images are cached by their definition, so later calls with the same
+// runtime and packages do not rebuild.
+func (app *App) ImageForRuntime(runtime string, packages []string, options *ImageForRuntimeOptions) (*Image, error) {
+	if options == nil {
+		options = &ImageForRuntimeOptions{}
+	}
+	commands, err := runtimeDockerfile(runtime, packages)
+	if err != nil {
+		return nil, err
+	}
+	return buildImage(app, pb.Image_builder{DockerfileCommands: commands}.Build(), options.Timeout, options.BuilderVersion)
 }
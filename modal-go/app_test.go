@@ -0,0 +1,417 @@
+package modal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+	"github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestValidateSandboxOptions(t *testing.T) {
+	g := gomega.NewWithT(t)
+	image := &Image{ImageId: "im-123"}
+
+	g.Expect(ValidateSandboxOptions(image, nil)).To(gomega.Succeed())
+	g.Expect(ValidateSandboxOptions(image, &SandboxOptions{
+		CPU:             2,
+		Memory:          1024,
+		Timeout:         time.Hour,
+		EncryptedPorts:  []int{8080},
+		Ports:           []PortSpec{{Port: 8081, H2: true}, {Port: 8082, Unencrypted: true}},
+		Volumes:         map[string]*Volume{"/data": {VolumeId: "vo-123"}},
+		Runtime:         RuntimeGVisor,
+		GPU:             "a100:2",
+		EphemeralDiskMB: 10240,
+	})).To(gomega.Succeed())
+
+	invalid := []*SandboxOptions{
+		{CPU: -1},
+		{CPU: 0.0001},
+		{Memory: -1},
+		{EphemeralDiskMB: -1},
+		{GPU: "H100:9"},
+		{Timeout: 25 * time.Hour},
+		{EncryptedPorts: []int{0}},
+		{EncryptedPorts: []int{8080}, UnencryptedPorts: []int{8080}},
+		{Ports: []PortSpec{{Port: 8080}}, H2Ports: []int{8080}},
+		{Ports: []PortSpec{{Port: 8080, H2: true, Unencrypted: true}}},
+		{Volumes: map[string]*Volume{"data": {VolumeId: "vo-123"}}},
+		{Volumes: map[string]*Volume{"/": {VolumeId: "vo-123"}}},
+		{Volumes: map[string]*Volume{"/data": nil}},
+		{HealthCheck: &HealthCheck{}},
+		{Stdout: "devnull"},
+		{Runtime: "kata"},
+		{GPU: "A100:0"},
+		{GPU: "A100:two"},
+		{GPU: ":2"},
+		{Tags: map[string]string{"": "x"}},
+		{BackgroundProcesses: []BackgroundProcess{{Name: "proxy"}}},
+		{BackgroundProcesses: []BackgroundProcess{{Command: []string{"true"}}}},
+		{BackgroundProcesses: []BackgroundProcess{{Name: "a", Command: []string{"true"}}, {Name: "a", Command: []string{"true"}}}},
+	}
+	for _, options := range invalid {
+		err := ValidateSandboxOptions(image, options)
+		g.Expect(err).To(gomega.BeAssignableToTypeOf(InvalidError{}), "options: %+v", options)
+	}
+
+	g.Expect(ValidateSandboxOptions(nil, nil)).To(gomega.BeAssignableToTypeOf(InvalidError{}))
+
+	g.Expect(ValidateSandboxOptions(image, &SandboxOptions{Arch: "amd64"})).To(gomega.Succeed())
+	err := ValidateSandboxOptions(image, &SandboxOptions{Arch: "arm64"})
+	g.Expect(err).To(gomega.Equal(UnsupportedArchError{
+		Exception: "Sandboxes cannot run on arm64, supported architectures are [amd64]",
+		Arch:      "arm64",
+	}))
+}
+
+func TestParseGPUConfig(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	config, err := parseGPUConfig("")
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(config).To(gomega.BeNil())
+
+	config, err = parseGPUConfig("t4")
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(config.GetGpuType()).To(gomega.Equal("T4"))
+	g.Expect(config.GetCount()).To(gomega.Equal(uint32(1)))
+
+	config, err = parseGPUConfig("H100:8")
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(config.GetGpuType()).To(gomega.Equal("H100"))
+	g.Expect(config.GetCount()).To(gomega.Equal(uint32(8)))
+}
+
+func TestAppSandboxDefaults(t *testing.T) {
+	g := gomega.NewWithT(t)
+	defaultImage := &Image{ImageId: "im-default"}
+	shared := &Volume{VolumeId: "vo-shared"}
+
+	app := newApp(nil, "ap-123")
+	app.SetDefaults(SandboxDefaults{
+		Image:   defaultImage,
+		Memory:  512,
+		EnvVars: map[string]string{"A": "default", "B": "default"},
+		Volumes: map[string]*Volume{"/shared": shared},
+		Regions: []string{"us-east"},
+		Runtime: RuntimeGVisor,
+		Tags:    map[string]string{"team": "search"},
+	})
+
+	image, options := app.withDefaults(nil, &SandboxOptions{
+		Memory:  1024,
+		EnvVars: map[string]string{"B": "override"},
+		Tags:    map[string]string{"job": "index"},
+	})
+	g.Expect(image).To(gomega.Equal(defaultImage))
+	g.Expect(options.Memory).To(gomega.Equal(MemoryMiB(1024)))
+	g.Expect(options.EnvVars).To(gomega.Equal(map[string]string{"A": "default", "B": "override"}))
+	g.Expect(options.Volumes).To(gomega.HaveKeyWithValue("/shared", shared))
+	g.Expect(options.Regions).To(gomega.Equal([]string{"us-east"}))
+	g.Expect(options.Runtime).To(gomega.Equal(RuntimeGVisor))
+	g.Expect(options.Tags).To(gomega.Equal(map[string]string{"team": "search", "job": "index"}))
+
+	// Explicit images take precedence over the default.
+	other := &Image{ImageId: "im-other"}
+	image, _ = app.withDefaults(other, nil)
+	g.Expect(image).To(gomega.Equal(other))
+}
+
+func TestDashboardURL(t *testing.T) {
+	g := gomega.NewWithT(t)
+	saved := clientProfile
+	defer func() { clientProfile = saved }()
+
+	clientProfile.ServerURL = "https://api.modal.com:443"
+	g.Expect((&App{AppId: "ap-123"}).DashboardURL()).To(gomega.Equal("https://modal.com/id/ap-123"))
+	g.Expect((&Sandbox{SandboxId: "sb-123"}).DashboardURL()).To(gomega.Equal("https://modal.com/id/sb-123"))
+
+	clientProfile.ServerURL = "https://api.dev.example.com"
+	g.Expect((&FunctionCall{FunctionCallId: "fc-123"}).DashboardURL()).To(gomega.Equal("https://dev.example.com/id/fc-123"))
+
+	clientProfile.ServerURL = "http://localhost:8889"
+	g.Expect((&App{AppId: "ap-123"}).DashboardURL()).To(gomega.Equal("https://modal.com/id/ap-123"))
+}
+
+func TestAppWithContext(t *testing.T) {
+	g := gomega.NewWithT(t)
+	saved := clientProfile
+	defer func() { clientProfile = saved }()
+	clientProfile.TokenId, clientProfile.TokenSecret = "ak-123", "as-123"
+
+	app := newApp(context.Background(), "ap-123")
+	ctx, cancel := context.WithCancel(context.Background())
+	scoped, err := app.WithContext(ctx)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	cancel()
+	g.Expect(scoped.ctx.Err()).To(gomega.Equal(context.Canceled))
+	g.Expect(app.ctx.Err()).ToNot(gomega.HaveOccurred())
+
+	// Defaults are shared with the copy.
+	app.SetDefaults(SandboxDefaults{Memory: 512})
+	_, options := scoped.withDefaults(nil, nil)
+	g.Expect(options.Memory).To(gomega.Equal(MemoryMiB(512)))
+}
+
+func TestCreateSandboxCancelled(t *testing.T) {
+	g := gomega.NewWithT(t)
+	var cancel context.CancelFunc
+	var terminated []string
+	var terminateErr error
+	fake := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		switch method {
+		case "/modal.client.ModalClient/SandboxCreate":
+			cancel() // the caller gives up while the response is on its way
+			proto.Merge(reply.(proto.Message), pb.SandboxCreateResponse_builder{SandboxId: "sb-123"}.Build())
+		case "/modal.client.ModalClient/SandboxTerminate":
+			g.Expect(ctx.Err()).ShouldNot(gomega.HaveOccurred())
+			terminated = append(terminated, req.(*pb.SandboxTerminateRequest).GetSandboxId())
+			return terminateErr
+		}
+		return nil
+	}
+	useFakeClient(t, fake)
+	image := &Image{ImageId: "im-123"}
+	quiet := &SandboxOptions{Stdout: Ignore, Stderr: Ignore} // no log streams outliving the fake client
+
+	newCancelledApp := func() *App {
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		return newApp(ctx, "ap-123")
+	}
+
+	// By default the Sandbox is terminated.
+	sb, err := newCancelledApp().CreateSandbox(image, quiet)
+	g.Expect(sb).To(gomega.BeNil())
+	g.Expect(err).To(gomega.MatchError(context.Canceled))
+	g.Expect(terminated).To(gomega.Equal([]string{"sb-123"}))
+
+	// With KeepOnCancel, its ID is returned.
+	terminated = nil
+	_, err = newCancelledApp().CreateSandbox(image, &SandboxOptions{Stdout: Ignore, Stderr: Ignore, KeepOnCancel: true})
+	var orphaned OrphanedSandboxError
+	g.Expect(errors.As(err, &orphaned)).To(gomega.BeTrue())
+	g.Expect(orphaned.SandboxId).To(gomega.Equal("sb-123"))
+	g.Expect(errors.Is(err, context.Canceled)).To(gomega.BeTrue())
+	g.Expect(terminated).To(gomega.BeEmpty())
+
+	// So with Detach.
+	_, err = newCancelledApp().CreateSandbox(image, &SandboxOptions{Stdout: Ignore, Stderr: Ignore, Detach: true})
+	g.Expect(errors.As(err, &orphaned)).To(gomega.BeTrue())
+	g.Expect(orphaned.SandboxId).To(gomega.Equal("sb-123"))
+	g.Expect(terminated).To(gomega.BeEmpty())
+
+	// If termination fails, its ID is returned.
+	terminateErr = status.Error(codes.PermissionDenied, "oops")
+	_, err = newCancelledApp().CreateSandbox(image, quiet)
+	g.Expect(errors.As(err, &orphaned)).To(gomega.BeTrue())
+	g.Expect(orphaned.SandboxId).To(gomega.Equal("sb-123"))
+	g.Expect(err.Error()).To(gomega.ContainSubstring("oops"))
+	g.Expect(errors.Is(err, context.Canceled)).To(gomega.BeTrue())
+	g.Expect(terminated).To(gomega.Equal([]string{"sb-123"}))
+}
+
+func TestCreateSandboxIdempotencyKey(t *testing.T) {
+	g := gomega.NewWithT(t)
+	var mu sync.Mutex
+	tagged, waited := 0, 0
+	finish := make(chan struct{}) // Sandboxes run until it is closed
+	fake := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		switch method {
+		case "/modal.client.ModalClient/SandboxCreate":
+			// Modal returns the same Sandbox for the same key.
+			proto.Merge(reply.(proto.Message), pb.SandboxCreateResponse_builder{SandboxId: "sb-123"}.Build())
+		case "/modal.client.ModalClient/SandboxTagsSet":
+			tagged++
+		case "/modal.client.ModalClient/SandboxWait":
+			<-finish
+			mu.Lock()
+			waited++
+			mu.Unlock()
+			proto.Merge(reply.(proto.Message), pb.SandboxWaitResponse_builder{
+				Result: pb.GenericResult_builder{Status: pb.GenericResult_GENERIC_STATUS_SUCCESS}.Build(),
+			}.Build())
+		}
+		return nil
+	}
+	useFakeClient(t, fake)
+	app := newApp(context.Background(), "ap-123")
+	app.SetLimits(AppLimits{MaxConcurrentSandboxes: 1})
+	options := &SandboxOptions{Tags: map[string]string{"team": "ml"}, IdempotencyKey: "create-1", Stdout: Ignore, Stderr: Ignore}
+
+	sb, err := app.CreateSandbox(&Image{ImageId: "im-123"}, options)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(tagged).To(gomega.Equal(1))
+
+	// A repeated key returns the same Sandbox, without setting its tags
+	// again or waiting for another slot of the App's limits.
+	again, err := app.CreateSandbox(&Image{ImageId: "im-123"}, options)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(again).To(gomega.BeIdenticalTo(sb))
+	g.Expect(tagged).To(gomega.Equal(1))
+
+	// Once terminated, the Sandbox is no longer returned for its key.
+	g.Expect(sb.Terminate()).To(gomega.Succeed())
+	again, err = app.CreateSandbox(&Image{ImageId: "im-123"}, options)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(again).NotTo(gomega.BeIdenticalTo(sb))
+	g.Expect(tagged).To(gomega.Equal(2))
+	g.Expect(again.Terminate()).To(gomega.Succeed())
+
+	close(finish)
+	g.Eventually(func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return waited
+	}).Should(gomega.Equal(2)) // no waits outliving the fake client
+}
+
+func TestCreateSandboxDeterministic(t *testing.T) {
+	g := gomega.NewWithT(t)
+	var requests []*pb.SandboxCreateRequest
+	secrets := 0
+	fake := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		switch method {
+		case "/modal.client.ModalClient/SecretGetOrCreate":
+			secrets++
+			proto.Merge(reply.(proto.Message), pb.SecretGetOrCreateResponse_builder{SecretId: fmt.Sprintf("st-%d", secrets)}.Build())
+		case "/modal.client.ModalClient/SandboxCreate":
+			requests = append(requests, req.(*pb.SandboxCreateRequest))
+			proto.Merge(reply.(proto.Message), pb.SandboxCreateResponse_builder{SandboxId: "sb-123"}.Build())
+		}
+		return nil
+	}
+	useFakeClient(t, fake)
+	app := newApp(context.Background(), "ap-123")
+	image := &Image{ImageId: "im-123"}
+
+	volumes := map[string]*Volume{}
+	for _, mountPath := range []string{"/e", "/a", "/d", "/b", "/c"} {
+		volumes[mountPath] = &Volume{VolumeId: "vo" + mountPath}
+	}
+	newOptions := func(env string) *SandboxOptions {
+		return &SandboxOptions{
+			Volumes: volumes,
+			EnvVars: map[string]string{"A": "1", "B": env},
+			Stdout:  Ignore,
+			Stderr:  Ignore,
+		}
+	}
+	var hashes []string
+	for _, env := range []string{"2", "2", "3"} {
+		sb, err := app.CreateSandbox(image, newOptions(env))
+		g.Expect(err).ShouldNot(gomega.HaveOccurred())
+		hashes = append(hashes, sb.DefinitionHash())
+	}
+
+	var mountPaths []string
+	for _, mount := range requests[0].GetDefinition().GetVolumeMounts() {
+		mountPaths = append(mountPaths, mount.GetMountPath())
+	}
+	g.Expect(mountPaths).To(gomega.Equal([]string{"/a", "/b", "/c", "/d", "/e"}))
+
+	// The ephemeral Secret for EnvVars differs, but the hash does not.
+	g.Expect(requests[0].GetDefinition().GetSecretIds()).To(gomega.Equal([]string{"st-1"}))
+	g.Expect(requests[1].GetDefinition().GetSecretIds()).To(gomega.Equal([]string{"st-2"}))
+	g.Expect(hashes[0]).To(gomega.HaveLen(64))
+	g.Expect(hashes[1]).To(gomega.Equal(hashes[0]))
+	g.Expect(hashes[2]).NotTo(gomega.Equal(hashes[0]))
+	g.Expect(newSandboxWithStdio(context.Background(), "sb-123", Ignore, Ignore).DefinitionHash()).To(gomega.BeEmpty())
+}
+
+func TestAppEnvironment(t *testing.T) {
+	g := gomega.NewWithT(t)
+	environments := map[string]string{}
+	fake := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		method = method[strings.LastIndex(method, "/")+1:]
+		if r, ok := req.(interface{ GetEnvironmentName() string }); ok {
+			environments[method] = r.GetEnvironmentName()
+		}
+		switch method {
+		case "AppGetOrCreate":
+			proto.Merge(reply.(proto.Message), pb.AppGetOrCreateResponse_builder{AppId: "ap-123"}.Build())
+		case "SecretGetOrCreate":
+			proto.Merge(reply.(proto.Message), pb.SecretGetOrCreateResponse_builder{SecretId: "st-123"}.Build())
+		case "SandboxCreate":
+			proto.Merge(reply.(proto.Message), pb.SandboxCreateResponse_builder{SandboxId: "sb-123"}.Build())
+		}
+		return nil
+	}
+	useFakeClient(t, fake)
+	clientProfile.Environment = "main"
+
+	app, err := AppLookup(context.Background(), "my-app", &LookupOptions{Environment: "staging"})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(app.Environment()).To(gomega.Equal("staging"))
+
+	// Objects created through the App, even with another context, are in its
+	// environment.
+	scoped, err := app.WithContext(WithEnvironment(context.Background(), "dev"))
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	_, err = scoped.CreateSandbox(&Image{ImageId: "im-123"}, &SandboxOptions{
+		EnvVars: map[string]string{"A": "1"},
+		Tags:    map[string]string{"team": "search"},
+		Stdout:  Ignore,
+		Stderr:  Ignore,
+	})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(environments).To(gomega.Equal(map[string]string{
+		"AppGetOrCreate":    "staging",
+		"SecretGetOrCreate": "staging",
+		"SandboxCreate":     "staging",
+		"SandboxTagsSet":    "staging",
+	}))
+
+	// Without an environment, the App is in the profile's.
+	app, err = AppLookup(context.Background(), "my-app", nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(app.Environment()).To(gomega.Equal("main"))
+}
+
+func TestRunSandbox(t *testing.T) {
+	g := gomega.NewWithT(t)
+	var lookups []*pb.AppGetOrCreateRequest
+	var created []string
+	fake := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		switch method {
+		case "/modal.client.ModalClient/AppGetOrCreate":
+			lookups = append(lookups, req.(*pb.AppGetOrCreateRequest))
+			proto.Merge(reply.(proto.Message), pb.AppGetOrCreateResponse_builder{AppId: "ap-default"}.Build())
+		case "/modal.client.ModalClient/SandboxCreate":
+			created = append(created, req.(*pb.SandboxCreateRequest).GetAppId())
+			proto.Merge(reply.(proto.Message), pb.SandboxCreateResponse_builder{SandboxId: "sb-123"}.Build())
+		}
+		return nil
+	}
+	useFakeClient(t, fake)
+	image := &Image{ImageId: "im-123"}
+	quiet := &SandboxOptions{Stdout: Ignore, Stderr: Ignore} // no log streams outliving the fake client
+
+	// The App is looked up once, and created if missing.
+	for range 2 {
+		sb, err := RunSandbox(context.Background(), image, quiet)
+		g.Expect(err).ShouldNot(gomega.HaveOccurred())
+		g.Expect(sb.SandboxId).To(gomega.Equal("sb-123"))
+	}
+	g.Expect(created).To(gomega.Equal([]string{"ap-default", "ap-default"}))
+	g.Expect(lookups).To(gomega.HaveLen(1))
+	g.Expect(lookups[0].GetAppName()).To(gomega.Equal(DefaultAppName))
+	g.Expect(lookups[0].GetObjectCreationType()).To(gomega.Equal(pb.ObjectCreationType_OBJECT_CREATION_TYPE_CREATE_IF_MISSING))
+
+	// Other environments have their own.
+	_, err := DefaultApp(WithEnvironment(context.Background(), "staging"))
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(lookups).To(gomega.HaveLen(2))
+	g.Expect(lookups[1].GetEnvironmentName()).To(gomega.Equal("staging"))
+}
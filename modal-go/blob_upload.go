@@ -0,0 +1,201 @@
+package modal
+
+// Uploads of blobs to storage, in parts for large blobs.
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+)
+
+// Number of parts of a multipart blob upload sent at once.
+const blobUploadConcurrency = 8
+
+// blobUploadPolicy retries uploads to storage after transient failures.
+var blobUploadPolicy = RetryPolicy{
+	MaxRetries: defaultRetryAttempts,
+	BaseDelay:  defaultRetryBaseDelay,
+	MaxDelay:   defaultRetryMaxDelay,
+	Multiplier: defaultRetryBackoffMul,
+	Retryable:  isRetryableBlobUpload,
+}
+
+// blobStatusError is an unsuccessful HTTP response from blob storage.
+type blobStatusError struct {
+	status string
+	code   int
+}
+
+func (e blobStatusError) Error() string {
+	return "blob storage returned " + e.status
+}
+
+// isRetryableBlobUpload reports whether a request to blob storage may succeed
+// if retried: server errors, throttling, and network errors.
+func isRetryableBlobUpload(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var statusErr blobStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.code >= 500 || statusErr.code == http.StatusTooManyRequests
+	}
+	return true
+}
+
+// blobUploadFrom uploads size bytes of r, with the given MD5 and SHA-256
+// digests, to storage and returns the blob's ID. Modal asks for large blobs
+// to be sent in parts, which are uploaded concurrently and each retried on
+// transient failures. If set, progress is called with the number of bytes of
+// each part once it is uploaded, possibly concurrently.
+func blobUploadFrom(ctx context.Context, r io.ReaderAt, size int64, md5sum, sha256sum []byte, progress func(n int64)) (string, error) {
+	contentMd5 := base64.StdEncoding.EncodeToString(md5sum)
+	resp, err := client.BlobCreate(ctx, pb.BlobCreateRequest_builder{
+		ContentMd5:          contentMd5,
+		ContentSha256Base64: base64.StdEncoding.EncodeToString(sha256sum),
+		ContentLength:       size,
+	}.Build())
+	if err != nil {
+		return "", fmt.Errorf("failed to create blob: %w", err)
+	}
+
+	switch resp.WhichUploadTypeOneof() {
+	case pb.BlobCreateResponse_Multipart_case:
+		if err := multipartUpload(ctx, r, size, resp.GetMultipart(), progress); err != nil {
+			return "", err
+		}
+		return resp.GetBlobId(), nil
+
+	case pb.BlobCreateResponse_UploadUrl_case:
+		err := retry(ctx, blobUploadPolicy, func(ctx context.Context) error {
+			_, err := blobPut(ctx, resp.GetUploadUrl(), io.NewSectionReader(r, 0, size), size, contentMd5)
+			return err
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to upload blob: %w", err)
+		}
+		// Skip client-side ETag header validation for now (MD5 checksum).
+		if progress != nil {
+			progress(size)
+		}
+		return resp.GetBlobId(), nil
+
+	default:
+		return "", fmt.Errorf("missing upload URL in BlobCreate response")
+	}
+}
+
+// multipartUpload uploads r in the parts described by upload, and then
+// combines them into the blob.
+func multipartUpload(ctx context.Context, r io.ReaderAt, size int64, upload *pb.MultiPartUpload, progress func(n int64)) error {
+	partLength := upload.GetPartLength()
+	urls := upload.GetUploadUrls()
+	if partLength <= 0 || int64(len(urls)) != (size+partLength-1)/partLength {
+		return fmt.Errorf("invalid multipart upload of %d bytes in %d parts of %d bytes", size, len(urls), partLength)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	etags := make([]string, len(urls))
+	inFlight := make(chan struct{}, blobUploadConcurrency)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+	for i, url := range urls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			inFlight <- struct{}{}
+			defer func() { <-inFlight }()
+			if ctx.Err() != nil {
+				return // another part failed
+			}
+			offset := int64(i) * partLength
+			length := min(partLength, size-offset)
+			err := retry(ctx, blobUploadPolicy, func(ctx context.Context) error {
+				etag, err := blobPut(ctx, url, io.NewSectionReader(r, offset, length), length, "")
+				etags[i] = etag
+				return err
+			})
+			if err == nil && etags[i] == "" {
+				err = errors.New("missing ETag in response")
+			}
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = fmt.Errorf("failed to upload part %d of %d: %w", i+1, len(urls), err)
+				}
+				mu.Unlock()
+				cancel()
+				return
+			}
+			if progress != nil {
+				progress(length)
+			}
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var body strings.Builder
+	body.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	body.WriteString(`<CompleteMultipartUpload xmlns="http://s3.amazonaws.com/doc/2006-03-01/">` + "\n")
+	for i, etag := range etags {
+		fmt.Fprintf(&body, "<Part>\n<PartNumber>%d</PartNumber>\n<ETag>\"%s\"</ETag>\n</Part>\n", i+1, etag)
+	}
+	body.WriteString("</CompleteMultipartUpload>")
+	err := retry(ctx, blobUploadPolicy, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, "POST", upload.GetCompletionUrl(), strings.NewReader(body.String()))
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return blobStatusError{status: resp.Status, code: resp.StatusCode}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+	return nil
+}
+
+// blobPut uploads body to url, and returns the ETag of the stored data,
+// without quotes.
+func blobPut(ctx context.Context, url string, body io.Reader, length int64, contentMd5 string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "PUT", url, body)
+	if err != nil {
+		return "", fmt.Errorf("failed to create upload request: %w", err)
+	}
+	req.ContentLength = length
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if contentMd5 != "" {
+		req.Header.Set("Content-MD5", contentMd5)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", blobStatusError{status: resp.Status, code: resp.StatusCode}
+	}
+	return strings.Trim(resp.Header.Get("ETag"), `"`), nil
+}
@@ -0,0 +1,92 @@
+package modal
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+	"github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestBlobUploadMultipart(t *testing.T) {
+	g := gomega.NewWithT(t)
+	data := bytes.Repeat([]byte("0123456789"), 25) // 250 bytes in parts of 100
+	var mu sync.Mutex
+	parts := map[string][]byte{}
+	failed := false
+	var completion string
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case "PUT":
+			if r.URL.Path == "/part/2" && !failed {
+				failed = true // the first attempt at a part fails, and is retried
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			parts[r.URL.Path] = body
+			sum := md5.Sum(body)
+			w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+		case "POST":
+			completion = string(body)
+		}
+	}))
+	defer storage.Close()
+
+	fake := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if method == "/modal.client.ModalClient/BlobCreate" {
+			g.Expect(req.(*pb.BlobCreateRequest).GetContentLength()).To(gomega.Equal(int64(len(data))))
+			proto.Merge(reply.(proto.Message), pb.BlobCreateResponse_builder{
+				BlobId: "bl-123",
+				Multipart: pb.MultiPartUpload_builder{
+					PartLength:    100,
+					UploadUrls:    []string{storage.URL + "/part/1", storage.URL + "/part/2", storage.URL + "/part/3"},
+					CompletionUrl: storage.URL + "/complete",
+				}.Build(),
+			}.Build())
+		}
+		return nil
+	}
+	useFakeClient(t, fake)
+
+	md5sum, sha256sum := md5.Sum(data), sha256.Sum256(data)
+	var progressMu sync.Mutex
+	var uploaded int64
+	blobId, err := blobUploadFrom(context.Background(), bytes.NewReader(data), int64(len(data)), md5sum[:], sha256sum[:], func(n int64) {
+		progressMu.Lock()
+		uploaded += n
+		progressMu.Unlock()
+	})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(blobId).To(gomega.Equal("bl-123"))
+	g.Expect(uploaded).To(gomega.Equal(int64(len(data))))
+	g.Expect(parts["/part/1"]).To(gomega.Equal(data[:100]))
+	g.Expect(parts["/part/2"]).To(gomega.Equal(data[100:200]))
+	g.Expect(parts["/part/3"]).To(gomega.Equal(data[200:]))
+	for i, part := range [][]byte{data[:100], data[100:200], data[200:]} {
+		sum := md5.Sum(part)
+		g.Expect(completion).To(gomega.ContainSubstring(fmt.Sprintf("<PartNumber>%d</PartNumber>\n<ETag>\"%s\"</ETag>", i+1, hex.EncodeToString(sum[:]))))
+	}
+	g.Expect(strings.Count(completion, "<Part>")).To(gomega.Equal(3))
+}
+
+func TestBlobUploadMultipartMismatch(t *testing.T) {
+	g := gomega.NewWithT(t)
+	upload := pb.MultiPartUpload_builder{PartLength: 100, UploadUrls: []string{"http://unused"}}.Build()
+	err := multipartUpload(context.Background(), bytes.NewReader(nil), 250, upload, nil)
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("invalid multipart upload")))
+}
@@ -3,10 +3,13 @@ package modal
 // Client construction, auth, timeout, and retry logic for Modal.
 
 import (
+	"cmp"
 	"context"
 	"crypto/tls"
 	"fmt"
-	"os"
+	"log/slog"
+	"net"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -38,13 +41,30 @@ type retryCallOption struct {
 	additionalCodes []codes.Code
 }
 
+// idempotencyKeyCallOption sets the idempotency key of an RPC, which is
+// otherwise random, and shared by its retries.
+type idempotencyKeyCallOption struct {
+	grpc.EmptyCallOption
+	key string
+}
+
+// withIdempotencyKey returns a call option that sets the idempotency key of an
+// RPC, if key is not empty.
+func withIdempotencyKey(key string) grpc.CallOption {
+	if key == "" {
+		return grpc.EmptyCallOption{}
+	}
+	return idempotencyKeyCallOption{key: key}
+}
+
 const (
-	apiEndpoint            = "api.modal.com:443"
-	maxMessageSize         = 100 * 1024 * 1024 // 100 MB
-	defaultRetryAttempts   = 3
-	defaultRetryBaseDelay  = 100 * time.Millisecond
-	defaultRetryMaxDelay   = 1 * time.Second
-	defaultRetryBackoffMul = 2.0
+	apiEndpoint             = "api.modal.com:443"
+	defaultMaxMessageSize   = 100 * 1024 * 1024 // 100 MB
+	defaultRetryAttempts    = 3
+	defaultRetryBaseDelay   = 100 * time.Millisecond
+	defaultRetryMaxDelay    = 1 * time.Second
+	defaultRetryBackoffMul  = 2.0
+	defaultStreamReconnects = 10
 )
 
 var retryableGrpcStatusCodes = map[codes.Code]struct{}{
@@ -64,6 +84,46 @@ func isRetryableGrpc(err error) bool {
 	return false
 }
 
+// maxStreamReconnects is the number of consecutive times a streaming read is
+// resumed after transient errors before giving up.
+var maxStreamReconnects = defaultStreamReconnects
+
+// maxRecvMsgSize and maxSendMsgSize limit the size of gRPC messages.
+var (
+	maxRecvMsgSize = defaultMaxMessageSize
+	maxSendMsgSize = defaultMaxMessageSize
+)
+
+// streamReconnector decides when a streaming read should be resumed from its
+// last-seen position after a transient error.
+type streamReconnector struct {
+	remaining int
+	delay     time.Duration
+}
+
+func newStreamReconnector() *streamReconnector {
+	return &streamReconnector{remaining: maxStreamReconnects, delay: defaultRetryBaseDelay}
+}
+
+// retry reports whether to reconnect after err, backing off before returning.
+func (r *streamReconnector) retry(ctx context.Context, err error) bool {
+	if !isRetryableGrpc(err) || r.remaining <= 0 {
+		return false
+	}
+	if sleepCtx(ctx, r.delay) != nil {
+		return false
+	}
+	r.remaining--
+	r.delay = min(r.delay*defaultRetryBackoffMul, defaultRetryMaxDelay)
+	return true
+}
+
+// progress restores the reconnect budget after data has been received.
+func (r *streamReconnector) progress() {
+	r.remaining = maxStreamReconnects
+	r.delay = defaultRetryBaseDelay
+}
+
 // defaultConfig caches the parsed ~/.modal.toml contents (may be empty).
 var defaultConfig config
 
@@ -79,16 +139,33 @@ var client pb.ModalClientClient
 // clients is a map of server URL to input-plane client.
 var inputPlaneClients = map[string]pb.ModalClientClient{}
 
+// unaryInterceptors and streamInterceptors are added by InitializeClient after
+// the SDK's own interceptors.
+var (
+	unaryInterceptors  []grpc.UnaryClientInterceptor
+	streamInterceptors []grpc.StreamClientInterceptor
+)
+
+// insecureSkipVerify and dialer are set by InitializeClient to connect to
+// test servers.
+var (
+	insecureSkipVerify bool
+	dialer             func(ctx context.Context, addr string) (net.Conn, error)
+)
+
+// transport is the protocol of new clients.
+var transport = TransportGRPC
+
 // authToken is the auth token received from the control plane on the first request, and sent with all
 // subsequent requests to both the control plane and the input plane.
 var authToken string
 
 func init() {
 	defaultConfig, _ = readConfigFile()
-	defaultProfile = getProfile(os.Getenv("MODAL_PROFILE"))
+	defaultProfile = getProfile(getenv("MODAL_PROFILE"))
 	clientProfile = defaultProfile
 	var err error
-	_, client, err = newClient(clientProfile)
+	client, err = newClient(clientProfile)
 	if err != nil {
 		panic(fmt.Sprintf("failed to initialize Modal client at startup: %v", err))
 	}
@@ -99,6 +176,67 @@ type ClientOptions struct {
 	TokenId     string
 	TokenSecret string
 	Environment string // optional, defaults to the profile's environment
+
+	// ImageBuilderVersion is the image builder version Images are built
+	// with, such as "2024.10", unless set per Image. It defaults to the
+	// profile's, or MODAL_IMAGE_BUILDER_VERSION, else to the setting of the
+	// App's environment. Pin it to match the version of pipelines that build
+	// the same Images with the Python SDK.
+	ImageBuilderVersion string
+
+	// ServerURL is the URL of the Modal API, defaults to the profile's, such
+	// as "https://api.modal.com:443". Use an "http://" URL to connect to a
+	// plaintext server, such as a stub Modal server in integration tests.
+	ServerURL string
+	// Insecure disables verification of the server's TLS certificate for
+	// "https://" server URLs, for test servers with self-signed certificates.
+	// Never set it when connecting to Modal.
+	Insecure bool
+	// Dialer, if set, opens the connections to the server instead of the
+	// default TCP dialer, for example to serve a stub server from an
+	// in-memory listener. addr is the host and port of the server URL.
+	Dialer func(ctx context.Context, addr string) (net.Conn, error)
+	// Transport is the protocol used to talk to the server, defaults to
+	// TransportGRPC. TransportGRPCWeb works on networks that block gRPC, but
+	// the server at ServerURL must accept gRPC-Web, for example through a
+	// proxy such as Envoy with its gRPC-Web filter.
+	Transport Transport
+
+	// MaxStreamReconnects is the number of consecutive times a streaming read,
+	// such as Sandbox logs or exec output, is resumed after transient network
+	// errors before failing. Defaults to 10; negative disables reconnects.
+	MaxStreamReconnects int
+
+	// MaxRecvMsgSize and MaxSendMsgSize are the largest gRPC messages, in
+	// bytes, that the client receives and sends. Larger messages fail with a
+	// ResourceExhausted error. Both default to 100 MiB. These and
+	// BlobThreshold keep the values of an earlier InitializeClient call when
+	// unset.
+	MaxRecvMsgSize int
+	MaxSendMsgSize int
+	// BlobThreshold is the size in bytes above which Function arguments and
+	// Volume file contents are uploaded as a blob instead of being sent
+	// inline in the request. Defaults to 2 MiB. It must not exceed
+	// MaxSendMsgSize, and Modal may reject inline payloads above the default.
+	BlobThreshold int
+
+	// Logger receives a debug-level record for each unary RPC, with its
+	// method, status, duration, and request and response messages. Fields
+	// holding tokens, secrets, passwords, and environment variables are
+	// redacted, and bytes fields are logged as their length. Defaults to nil,
+	// which disables RPC logging.
+	Logger *slog.Logger
+	// RedactFields are extra path.Match patterns for proto field names, in
+	// snake_case, to redact from RPC logs, such as "*_id" or "command".
+	RedactFields []string
+
+	// UnaryInterceptors and StreamInterceptors are added to the gRPC
+	// connections to Modal after the SDK's own interceptors, so they see each
+	// attempt of a retried RPC, with auth headers set. Use them to record or
+	// replay RPCs in tests, see the modal-go/rpcreplay package, or to inject
+	// faults, see the modal-go/modaltest package.
+	UnaryInterceptors  []grpc.UnaryClientInterceptor
+	StreamInterceptors []grpc.StreamClientInterceptor
 }
 
 // InitializeClient updates the global Modal client configuration with the provided options.
@@ -106,13 +244,42 @@ type ClientOptions struct {
 // This function is useful when you want to set the client options programmatically. It
 // should be called once at the start of your application.
 func InitializeClient(options ClientOptions) error {
+	recvSize := cmp.Or(options.MaxRecvMsgSize, maxRecvMsgSize)
+	sendSize := cmp.Or(options.MaxSendMsgSize, maxSendMsgSize)
+	blobThreshold := cmp.Or(options.BlobThreshold, maxObjectSizeBytes)
+	if recvSize < 0 || sendSize < 0 || blobThreshold < 0 {
+		return InvalidError{"message size limits must not be negative"}
+	}
+	if options.Transport != "" && options.Transport != TransportGRPC && options.Transport != TransportGRPCWeb {
+		return InvalidError{fmt.Sprintf("invalid transport: %q", options.Transport)}
+	}
+	if blobThreshold > sendSize {
+		return InvalidError{fmt.Sprintf("BlobThreshold (%d) must not exceed MaxSendMsgSize (%d)", blobThreshold, sendSize)}
+	}
+
 	mergedProfile := defaultProfile
 	mergedProfile.TokenId = options.TokenId
 	mergedProfile.TokenSecret = options.TokenSecret
 	mergedProfile.Environment = firstNonEmpty(options.Environment, mergedProfile.Environment)
+	mergedProfile.ServerURL = firstNonEmpty(options.ServerURL, mergedProfile.ServerURL)
+	mergedProfile.ImageBuilderVersion = firstNonEmpty(options.ImageBuilderVersion, mergedProfile.ImageBuilderVersion)
 	clientProfile = mergedProfile
+	maxStreamReconnects = defaultStreamReconnects
+	if options.MaxStreamReconnects != 0 {
+		maxStreamReconnects = max(options.MaxStreamReconnects, 0)
+	}
+	maxRecvMsgSize, maxSendMsgSize, maxObjectSizeBytes = recvSize, sendSize, blobThreshold
+	insecureSkipVerify, dialer = options.Insecure, options.Dialer
+	environmentBuilderVersions.Clear()
+	defaultApps.Clear()
+	transport = cmp.Or(options.Transport, TransportGRPC)
+	unaryInterceptors = slices.Clone(options.UnaryInterceptors)
+	streamInterceptors = slices.Clone(options.StreamInterceptors)
+	if err := setRPCLogging(options.Logger, options.RedactFields); err != nil {
+		return err
+	}
 	var err error
-	_, client, err = newClient(mergedProfile)
+	client, err = newClient(mergedProfile)
 	return err
 }
 
@@ -124,7 +291,7 @@ func getOrCreateInputPlaneClient(serverURL string) (pb.ModalClientClient, error)
 
 	profile := clientProfile
 	profile.ServerURL = serverURL
-	_, client, err := newClient(profile)
+	client, err := newClient(profile)
 	if err != nil {
 		return nil, err
 	}
@@ -132,38 +299,53 @@ func getOrCreateInputPlaneClient(serverURL string) (pb.ModalClientClient, error)
 	return client, nil
 }
 
-// newClient dials the given server URL with auth/timeout/retry interceptors installed.
-// It returns (conn, stub). Close the conn when done.
-func newClient(profile Profile) (*grpc.ClientConn, pb.ModalClientClient, error) {
+// newClient returns a client for the given server URL, with the SDK's
+// auth/timeout/retry interceptors installed.
+func newClient(profile Profile) (pb.ModalClientClient, error) {
 	var target string
 	var creds credentials.TransportCredentials
 	if after, ok := strings.CutPrefix(profile.ServerURL, "https://"); ok {
 		target = after
-		creds = credentials.NewTLS(&tls.Config{})
+		creds = credentials.NewTLS(&tls.Config{InsecureSkipVerify: insecureSkipVerify})
 	} else if after, ok := strings.CutPrefix(profile.ServerURL, "http://"); ok {
 		target = after
 		creds = insecure.NewCredentials()
 	} else {
-		return nil, nil, status.Errorf(codes.InvalidArgument, "invalid server URL: %s", profile.ServerURL)
+		return nil, status.Errorf(codes.InvalidArgument, "invalid server URL: %s", profile.ServerURL)
 	}
 
-	conn, err := grpc.NewClient(
-		target,
+	sdkInterceptors := []grpc.UnaryClientInterceptor{
+		metricsInterceptor(),
+		loggingInterceptor(),
+		authTokenInterceptor(),
+		retryInterceptor(),
+		timeoutInterceptor(),
+	}
+	if transport == TransportGRPCWeb {
+		conn := newGRPCWebConn(profile.ServerURL, append(sdkInterceptors, unaryInterceptors...), streamInterceptors)
+		return pb.NewModalClientClient(conn), nil
+	}
+
+	dialOptions := []grpc.DialOption{
 		grpc.WithTransportCredentials(creds),
 		grpc.WithDefaultCallOptions(
-			grpc.MaxCallRecvMsgSize(maxMessageSize),
-			grpc.MaxCallSendMsgSize(maxMessageSize),
+			grpc.MaxCallRecvMsgSize(maxRecvMsgSize),
+			grpc.MaxCallSendMsgSize(maxSendMsgSize),
 		),
-		grpc.WithChainUnaryInterceptor(
-			authTokenInterceptor(),
-			retryInterceptor(),
-			timeoutInterceptor(),
-		),
-	)
+		grpc.WithChainUnaryInterceptor(sdkInterceptors...),
+		grpc.WithChainUnaryInterceptor(unaryInterceptors...),
+		grpc.WithChainStreamInterceptor(streamInterceptors...),
+	}
+	if dialer != nil {
+		// Pass the address to the dialer as is, rather than resolving it.
+		target = "passthrough:///" + target
+		dialOptions = append(dialOptions, grpc.WithContextDialer(dialer))
+	}
+	conn, err := grpc.NewClient(target, dialOptions...)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
-	return conn, pb.NewModalClientClient(conn), nil
+	return pb.NewModalClientClient(conn), nil
 }
 
 // clientContext returns a context with the default profile's auth headers.
@@ -222,19 +404,23 @@ func timeoutInterceptor() grpc.UnaryClientInterceptor {
 		inv grpc.UnaryInvoker,
 		opts ...grpc.CallOption,
 	) error {
-		// pick the first TimeoutCallOption, if any
+		// pick the first TimeoutCallOption, if any, else the context's timeout
+		var timeout time.Duration
 		for _, o := range opts {
 			if to, ok := o.(timeoutCallOption); ok && to.timeout > 0 {
-				// honour an existing, *earlier* deadline if present
-				if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= to.timeout {
-					break
-				}
-				var cancel context.CancelFunc
-				ctx, cancel = context.WithTimeout(ctx, to.timeout)
-				defer cancel()
+				timeout = to.timeout
 				break
 			}
 		}
+		if timeout == 0 {
+			timeout = rpcTimeout(ctx, req)
+		}
+		// honour an existing, *earlier* deadline if present
+		if deadline, ok := ctx.Deadline(); timeout > 0 && (!ok || time.Until(deadline) > timeout) {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
 		return inv(ctx, method, req, reply, cc, opts...)
 	}
 }
@@ -255,6 +441,24 @@ func retryInterceptor() grpc.UnaryClientInterceptor {
 		factor := defaultRetryBackoffMul
 		retryable := retryableGrpcStatusCodes
 
+		// override from the context's policy
+		var isRetryable func(err error) bool
+		if p, ok := ctx.Value(retryPolicyKey{}).(RetryPolicy); ok {
+			if p.MaxRetries != 0 {
+				retries = max(p.MaxRetries, 0)
+			}
+			if p.BaseDelay != 0 {
+				baseDelay = p.BaseDelay
+			}
+			if p.MaxDelay != 0 {
+				maxDelay = p.MaxDelay
+			}
+			if p.Multiplier != 0 {
+				factor = p.Multiplier
+			}
+			isRetryable = p.Retryable
+		}
+
 		// override from call-options (first one wins)
 		for _, o := range opts {
 			if rc, ok := o.(retryCallOption); ok {
@@ -284,38 +488,45 @@ func retryInterceptor() grpc.UnaryClientInterceptor {
 		}
 
 		idempotency := uuid.NewString()
+		for _, o := range opts {
+			if ik, ok := o.(idempotencyKeyCallOption); ok {
+				idempotency = ik.key
+				break
+			}
+		}
 		start := time.Now()
-		delay := baseDelay
-
-		for attempt := 0; attempt <= retries; attempt++ {
+		attempt := 0
+
+		policy := RetryPolicy{
+			MaxRetries: retries,
+			BaseDelay:  baseDelay,
+			MaxDelay:   maxDelay,
+			Multiplier: factor,
+			Retryable: func(err error) bool {
+				if isRetryable != nil {
+					return isRetryable(err)
+				}
+				st, ok := status.FromError(err)
+				if !ok {
+					return false // unexpected, non-gRPC error
+				}
+				_, ok = retryable[st.Code()]
+				return ok
+			},
+			onRetry: func(err error) {
+				currentMetrics().RPCRetry(method, status.Code(err).String())
+			},
+		}
+		return retry(ctx, policy, func(ctx context.Context) error {
 			aCtx := metadata.AppendToOutgoingContext(
 				ctx,
 				"x-idempotency-key", idempotency,
 				"x-retry-attempt", strconv.Itoa(attempt),
 				"x-retry-delay", strconv.FormatFloat(time.Since(start).Seconds(), 'f', 3, 64),
 			)
-
-			err := inv(aCtx, method, req, reply, cc, opts...)
-			if err == nil {
-				return nil
-			}
-
-			if st, ok := status.FromError(err); ok { // gRPC error
-				if _, ok := retryable[st.Code()]; !ok || attempt == retries {
-					return err
-				}
-			} else { // Unexpected, non-gRPC error
-				return err
-			}
-
-			if sleepCtx(ctx, delay) != nil {
-				return err // ctx cancelled or deadline exceeded
-			}
-
-			// exponential back-off
-			delay = min(delay*time.Duration(factor), maxDelay)
-		}
-		return nil // unreachable
+			attempt++
+			return inv(aCtx, method, req, reply, cc, opts...)
+		})
 	}
 }
 
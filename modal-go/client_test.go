@@ -0,0 +1,201 @@
+package modal
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+	"github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestStreamReconnector(t *testing.T) {
+	g := gomega.NewWithT(t)
+	ctx := context.Background()
+	unavailable := status.Error(codes.Unavailable, "connection reset")
+
+	r := &streamReconnector{remaining: 2}
+	g.Expect(r.retry(ctx, status.Error(codes.NotFound, "no such sandbox"))).To(gomega.BeFalse())
+	g.Expect(r.retry(ctx, unavailable)).To(gomega.BeTrue())
+	g.Expect(r.retry(ctx, unavailable)).To(gomega.BeTrue())
+	g.Expect(r.retry(ctx, unavailable)).To(gomega.BeFalse())
+
+	// Receiving data restores the budget.
+	r.progress()
+	g.Expect(r.retry(ctx, unavailable)).To(gomega.BeTrue())
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	g.Expect(r.retry(cancelled, unavailable)).To(gomega.BeFalse())
+}
+
+func TestInitializeClientMessageSizes(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	err := InitializeClient(ClientOptions{MaxSendMsgSize: -1})
+	g.Expect(err).To(gomega.BeAssignableToTypeOf(InvalidError{}))
+
+	err = InitializeClient(ClientOptions{MaxSendMsgSize: 1024, BlobThreshold: 2048})
+	g.Expect(err).To(gomega.BeAssignableToTypeOf(InvalidError{}))
+	g.Expect(err.Error()).To(gomega.ContainSubstring("BlobThreshold"))
+
+	// Invalid options leave the client configuration unchanged.
+	g.Expect(maxSendMsgSize).To(gomega.Equal(defaultMaxMessageSize))
+	g.Expect(maxObjectSizeBytes).To(gomega.Equal(defaultMaxObjectSizeBytes))
+}
+
+func TestInitializeClientKeepsMessageSizes(t *testing.T) {
+	g := gomega.NewWithT(t)
+	savedProfile, savedClient := clientProfile, client
+	defer func() {
+		clientProfile, client = savedProfile, savedClient
+		maxRecvMsgSize, maxSendMsgSize = defaultMaxMessageSize, defaultMaxMessageSize
+		maxObjectSizeBytes = defaultMaxObjectSizeBytes
+	}()
+
+	err := InitializeClient(ClientOptions{MaxRecvMsgSize: 1 << 30, BlobThreshold: 1 << 20})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	// Initializing again without the limits keeps them, while new values
+	// replace them.
+	err = InitializeClient(ClientOptions{TokenId: "token-id", TokenSecret: "token-secret", MaxSendMsgSize: 1 << 25})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(maxRecvMsgSize).To(gomega.Equal(1 << 30))
+	g.Expect(maxSendMsgSize).To(gomega.Equal(1 << 25))
+	g.Expect(maxObjectSizeBytes).To(gomega.Equal(1 << 20))
+}
+
+func TestInitializeClientInterceptors(t *testing.T) {
+	g := gomega.NewWithT(t)
+	savedProfile, savedClient := clientProfile, client
+	defer func() {
+		clientProfile, client = savedProfile, savedClient
+		unaryInterceptors = nil
+	}()
+
+	var methods []string
+	replay := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		methods = append(methods, method)
+		proto.Merge(reply.(proto.Message), pb.AppGetOrCreateResponse_builder{AppId: "ap-replayed"}.Build())
+		return nil
+	}
+	err := InitializeClient(ClientOptions{
+		TokenId:           "token-id",
+		TokenSecret:       "token-secret",
+		UnaryInterceptors: []grpc.UnaryClientInterceptor{replay},
+	})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	app, err := AppLookup(context.Background(), "libmodal-test", nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(app.AppId).To(gomega.Equal("ap-replayed"))
+	g.Expect(methods).To(gomega.Equal([]string{"/modal.client.ModalClient/AppGetOrCreate"}))
+}
+
+// stubModalServer is a Modal server that only answers AppGetOrCreate.
+type stubModalServer struct {
+	pb.UnimplementedModalClientServer
+}
+
+func (stubModalServer) AppGetOrCreate(ctx context.Context, req *pb.AppGetOrCreateRequest) (*pb.AppGetOrCreateResponse, error) {
+	return pb.AppGetOrCreateResponse_builder{AppId: "ap-stub"}.Build(), nil
+}
+
+func TestInitializeClientTestServer(t *testing.T) {
+	g := gomega.NewWithT(t)
+	savedProfile, savedClient := clientProfile, client
+	defer func() {
+		clientProfile, client = savedProfile, savedClient
+		insecureSkipVerify, dialer = false, nil
+	}()
+
+	// A self-signed certificate, as test servers typically have.
+	tlsServer := httptest.NewUnstartedServer(nil)
+	tlsServer.StartTLS()
+	cert := tlsServer.TLS.Certificates[0]
+	tlsServer.Close()
+
+	for _, tc := range []struct {
+		serverURL string
+		creds     credentials.TransportCredentials
+	}{
+		{"http://stub:443", insecure.NewCredentials()},
+		{"https://stub:443", credentials.NewServerTLSFromCert(&cert)},
+	} {
+		listener := bufconn.Listen(1024 * 1024)
+		server := grpc.NewServer(grpc.Creds(tc.creds))
+		pb.RegisterModalClientServer(server, stubModalServer{})
+		go server.Serve(listener)
+		defer server.Stop()
+
+		err := InitializeClient(ClientOptions{
+			TokenId:     "token-id",
+			TokenSecret: "token-secret",
+			ServerURL:   tc.serverURL,
+			Insecure:    true,
+			Dialer: func(ctx context.Context, addr string) (net.Conn, error) {
+				return listener.DialContext(ctx)
+			},
+		})
+		g.Expect(err).ShouldNot(gomega.HaveOccurred())
+		g.Expect(clientProfile.ServerURL).To(gomega.Equal(tc.serverURL))
+
+		app, err := AppLookup(context.Background(), "libmodal-test", nil)
+		g.Expect(err).ShouldNot(gomega.HaveOccurred(), tc.serverURL)
+		g.Expect(app.AppId).To(gomega.Equal("ap-stub"))
+	}
+}
+
+func TestRetryInterceptorIdempotencyKey(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	var keys []string
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		keys = append(keys, md.Get("x-idempotency-key")...)
+		if len(keys) == 1 {
+			return status.Error(codes.Unavailable, "connection reset")
+		}
+		return nil
+	}
+	interceptor := retryInterceptor()
+
+	err := interceptor(context.Background(), "/modal.client.ModalClient/SandboxCreate", nil, nil, nil, invoker, withIdempotencyKey("create-1"))
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(keys).To(gomega.Equal([]string{"create-1", "create-1"}))
+
+	keys = nil
+	err = interceptor(context.Background(), "/modal.client.ModalClient/SandboxCreate", nil, nil, nil, invoker, withIdempotencyKey(""))
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(keys).To(gomega.HaveLen(2))
+	g.Expect(keys[0]).To(gomega.Equal(keys[1]))
+	g.Expect(keys[0]).NotTo(gomega.Equal("create-1"))
+}
+
+// useFakeClient initializes the client with interceptor, which fakes the
+// responses of unary RPCs, and restores the client when the test ends.
+func useFakeClient(t *testing.T, interceptor grpc.UnaryClientInterceptor) {
+	savedProfile, savedClient := clientProfile, client
+	t.Cleanup(func() {
+		clientProfile, client = savedProfile, savedClient
+		unaryInterceptors = nil
+	})
+	err := InitializeClient(ClientOptions{
+		TokenId:           "token-id",
+		TokenSecret:       "token-secret",
+		UnaryInterceptors: []grpc.UnaryClientInterceptor{interceptor},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -33,10 +33,15 @@ func ClsLookup(ctx context.Context, appName string, name string, options *Lookup
 	if err != nil {
 		return nil, err
 	}
+	var environment string
+	appName, environment, err = resolveObjectRef(appName, "App", options.Environment)
+	if err != nil {
+		return nil, err
+	}
 
 	cls := Cls{
 		methodNames: []string{},
-		ctx:         ctx,
+		ctx:         context.WithoutCancel(ctx),
 	}
 
 	// Find class service function metadata. Service functions are used to implement class methods,
@@ -45,7 +50,7 @@ func ClsLookup(ctx context.Context, appName string, name string, options *Lookup
 	serviceFunction, err := client.FunctionGet(ctx, pb.FunctionGetRequest_builder{
 		AppName:         appName,
 		ObjectTag:       serviceFunctionName,
-		EnvironmentName: environmentName(options.Environment),
+		EnvironmentName: environmentName(ctx, environment),
 	}.Build())
 
 	if status, ok := status.FromError(err); ok && status.Code() == codes.NotFound {
@@ -83,6 +88,18 @@ func ClsLookup(ctx context.Context, appName string, name string, options *Lookup
 	return &cls, nil
 }
 
+// WithContext returns a copy of the Cls whose calls use ctx, for example to
+// apply a request's deadline and cancellation.
+func (c *Cls) WithContext(ctx context.Context) (*Cls, error) {
+	ctx, err := handleContext(ctx, c.ctx)
+	if err != nil {
+		return nil, err
+	}
+	copied := *c
+	copied.ctx = ctx
+	return &copied, nil
+}
+
 // Instance creates a new instance of the class with the provided parameters.
 func (c *Cls) Instance(params map[string]any) (*ClsInstance, error) {
 	var functionId string
@@ -0,0 +1,148 @@
+package modal
+
+// Codecs for converting typed Go values to and from the bytes stored in Modal objects.
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// Codec serializes values of type T for storage in Modal objects, such as
+// Queue items and Dict values. Values written by one codec must be read with
+// the same codec.
+type Codec[T any] interface {
+	Encode(v T) ([]byte, error)
+	Decode(data []byte) (T, error)
+}
+
+// PickleCodec returns a Codec that stores values in the Python pickle format,
+// making them readable from the Modal Python SDK.
+//
+// Decoded numbers are converted to T when T is a numeric type, since pickled
+// integers and floats are decoded as int64 and float64. Decode returns an
+// InvalidError if a number does not fit in T, or if T is an integer type and
+// the number has a fractional part.
+func PickleCodec[T any]() Codec[T] {
+	return pickleCodec[T]{}
+}
+
+type pickleCodec[T any] struct{}
+
+func (pickleCodec[T]) Encode(v T) ([]byte, error) {
+	b, err := pickleSerialize(v)
+	if err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func (pickleCodec[T]) Decode(data []byte) (T, error) {
+	var zero T
+	v, err := pickleDeserialize(data)
+	if err != nil {
+		return zero, err
+	}
+	if t, ok := v.(T); ok {
+		return t, nil
+	}
+	target := reflect.TypeFor[T]()
+	rv := reflect.ValueOf(v)
+	if rv.IsValid() && isNumericKind(rv.Kind()) && isNumericKind(target.Kind()) {
+		if !convertsExactly(rv, target) {
+			return zero, InvalidError{fmt.Sprintf("cannot decode %v into %s without overflow or truncation", v, target)}
+		}
+		return rv.Convert(target).Interface().(T), nil
+	}
+	return zero, InvalidError{fmt.Sprintf("cannot decode value of type %T into %s", v, target)}
+}
+
+// convertsExactly reports whether the number rv converts to the numeric type
+// target without overflowing, or losing a fractional part or the precision of
+// an integer. Floats may be rounded to float32 if they are within its range.
+func convertsExactly(rv reflect.Value, target reflect.Type) bool {
+	zero := reflect.Zero(target)
+	switch k := rv.Kind(); {
+	case rv.CanInt():
+		i := rv.Int()
+		switch {
+		case zero.CanInt():
+			return !zero.OverflowInt(i)
+		case zero.CanUint():
+			return i >= 0 && !zero.OverflowUint(uint64(i))
+		default:
+			f := rv.Convert(target).Float()
+			return f >= -(1<<63) && f < 1<<63 && int64(f) == i
+		}
+	case rv.CanUint():
+		u := rv.Uint()
+		switch {
+		case zero.CanInt():
+			return u <= math.MaxInt64 && !zero.OverflowInt(int64(u))
+		case zero.CanUint():
+			return !zero.OverflowUint(u)
+		default:
+			f := rv.Convert(target).Float()
+			return f < 1<<64 && uint64(f) == u
+		}
+	case k == reflect.Float32 || k == reflect.Float64:
+		f := rv.Float()
+		switch {
+		case zero.CanInt():
+			return f == math.Trunc(f) && f >= -(1<<63) && f < 1<<63 && !zero.OverflowInt(int64(f))
+		case zero.CanUint():
+			return f == math.Trunc(f) && f >= 0 && f < 1<<64 && !zero.OverflowUint(uint64(f))
+		default:
+			return math.IsNaN(f) || math.IsInf(f, 0) || !zero.OverflowFloat(f)
+		}
+	}
+	return false
+}
+
+func isNumericKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// JSONCodec returns a Codec that stores values as JSON documents, wrapped in a
+// pickled Python string. Use it for structs and other types that have no
+// pickle representation; Python readers can decode items with json.loads().
+func JSONCodec[T any]() Codec[T] {
+	return jsonCodec[T]{}
+}
+
+type jsonCodec[T any] struct{}
+
+func (jsonCodec[T]) Encode(v T) ([]byte, error) {
+	doc, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding JSON: %w", err)
+	}
+	b, err := pickleSerialize(string(doc))
+	if err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func (jsonCodec[T]) Decode(data []byte) (T, error) {
+	var out T
+	v, err := pickleDeserialize(data)
+	if err != nil {
+		return out, err
+	}
+	doc, ok := v.(string)
+	if !ok {
+		return out, InvalidError{fmt.Sprintf("expected JSON string, got value of type %T", v)}
+	}
+	if err := json.Unmarshal([]byte(doc), &out); err != nil {
+		return out, fmt.Errorf("error decoding JSON: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,72 @@
+package modal
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+func TestPickleCodecNumericConversion(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	codec := PickleCodec[int]()
+	data, err := codec.Encode(42)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	v, err := codec.Decode(data)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(v).To(gomega.Equal(42))
+
+	_, err = PickleCodec[string]().Decode(data)
+	g.Expect(err).Should(gomega.MatchError(gomega.ContainSubstring("cannot decode value of type int64 into string")))
+
+	encode := func(v any) []byte {
+		data, err := PickleCodec[any]().Encode(v)
+		g.Expect(err).ShouldNot(gomega.HaveOccurred())
+		return data
+	}
+	f, err := PickleCodec[float32]().Decode(encode(1.5))
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(f).To(gomega.Equal(float32(1.5)))
+	u, err := PickleCodec[uint8]().Decode(encode(255))
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(u).To(gomega.Equal(uint8(255)))
+	i, err := PickleCodec[int]().Decode(encode(2.0))
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(i).To(gomega.Equal(2))
+
+	// Numbers that would overflow or be truncated are rejected.
+	_, err = PickleCodec[int8]().Decode(encode(300))
+	g.Expect(err).To(gomega.BeAssignableToTypeOf(InvalidError{}))
+	_, err = PickleCodec[uint]().Decode(encode(-1))
+	g.Expect(err).To(gomega.BeAssignableToTypeOf(InvalidError{}))
+	_, err = PickleCodec[int]().Decode(encode(2.5))
+	g.Expect(err).To(gomega.BeAssignableToTypeOf(InvalidError{}))
+	_, err = PickleCodec[int64]().Decode(encode(1e19))
+	g.Expect(err).To(gomega.BeAssignableToTypeOf(InvalidError{}))
+	_, err = PickleCodec[float32]().Decode(encode(1e39))
+	g.Expect(err).To(gomega.BeAssignableToTypeOf(InvalidError{}))
+	_, err = PickleCodec[float64]().Decode(encode(1<<53 + 1))
+	g.Expect(err).To(gomega.BeAssignableToTypeOf(InvalidError{}))
+}
+
+func TestJSONCodecRoundTrip(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	type job struct {
+		Name     string `json:"name"`
+		Priority int    `json:"priority"`
+	}
+	codec := JSONCodec[job]()
+	data, err := codec.Encode(job{Name: "build", Priority: 2})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	raw, err := pickleDeserialize(data)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(raw).To(gomega.Equal(`{"name":"build","priority":2}`))
+
+	v, err := codec.Decode(data)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(v).To(gomega.Equal(job{Name: "build", Priority: 2}))
+}
@@ -4,11 +4,17 @@ package modal
 // from ~/.modal.toml or environment variables.
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 
+	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
 	"github.com/pelletier/go-toml/v2"
 )
 
@@ -48,9 +54,22 @@ func readConfigFile() (config, error) {
 		return nil, fmt.Errorf("reading %s: %w", path, err)
 	}
 
+	cfg, err := parseConfig(content)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// parseConfig parses the contents of a config file. Files edited on Windows
+// may start with a UTF-8 byte order mark and end lines with CRLF, which would
+// otherwise end up in multi-line string values.
+func parseConfig(content []byte) (config, error) {
+	content = bytes.TrimPrefix(content, []byte("\ufeff"))
+	content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
 	var cfg config
 	if err := toml.Unmarshal(content, &cfg); err != nil {
-		return nil, fmt.Errorf("parsing %s: %w", path, err)
+		return nil, err
 	}
 	return cfg, nil
 }
@@ -75,11 +94,11 @@ func getProfile(name string) Profile {
 	}
 
 	// Env-vars override file values.
-	serverURL := firstNonEmpty(os.Getenv("MODAL_SERVER_URL"), raw.ServerURL, "https://api.modal.com:443")
-	tokenId := firstNonEmpty(os.Getenv("MODAL_TOKEN_ID"), raw.TokenId)
-	tokenSecret := firstNonEmpty(os.Getenv("MODAL_TOKEN_SECRET"), raw.TokenSecret)
-	environment := firstNonEmpty(os.Getenv("MODAL_ENVIRONMENT"), raw.Environment)
-	imageBuilderVersion := firstNonEmpty(os.Getenv("MODAL_IMAGE_BUILDER_VERSION"), raw.ImageBuilderVersion)
+	serverURL := firstNonEmpty(getenv("MODAL_SERVER_URL"), raw.ServerURL, "https://api.modal.com:443")
+	tokenId := firstNonEmpty(getenv("MODAL_TOKEN_ID"), raw.TokenId)
+	tokenSecret := firstNonEmpty(getenv("MODAL_TOKEN_SECRET"), raw.TokenSecret)
+	environment := firstNonEmpty(getenv("MODAL_ENVIRONMENT"), raw.Environment)
+	imageBuilderVersion := firstNonEmpty(getenv("MODAL_IMAGE_BUILDER_VERSION"), raw.ImageBuilderVersion)
 
 	return Profile{
 		ServerURL:           serverURL,
@@ -90,6 +109,12 @@ func getProfile(name string) Profile {
 	}
 }
 
+// getenv returns an environment variable without surrounding whitespace, such
+// as a trailing carriage return from a .env file with CRLF line endings.
+func getenv(key string) string {
+	return strings.TrimSpace(os.Getenv(key))
+}
+
 func firstNonEmpty(values ...string) string {
 	for _, v := range values {
 		if v != "" {
@@ -99,10 +124,53 @@ func firstNonEmpty(values ...string) string {
 	return ""
 }
 
-func environmentName(environment string) string {
-	return firstNonEmpty(environment, clientProfile.Environment)
+// environmentName returns the environment to use: the given one, else the one
+// set on ctx with WithEnvironment, else the profile's.
+func environmentName(ctx context.Context, environment string) string {
+	var ctxEnvironment string
+	if ctx != nil { // handles created as struct literals have no context
+		ctxEnvironment, _ = ctx.Value(environmentKey{}).(string)
+	}
+	return firstNonEmpty(environment, ctxEnvironment, clientProfile.Environment)
+}
+
+// defaultImageBuilderVersion is used when neither the client nor the
+// environment sets an image builder version.
+const defaultImageBuilderVersion = "2024.10"
+
+// environmentBuilderVersions caches the image builder version setting of
+// environments by name.
+var environmentBuilderVersions sync.Map
+
+// imageBuilderVersion returns the image builder version to build with: the
+// given one, else the client's, else the setting of the environment, as
+// configured in the Modal dashboard.
+func imageBuilderVersion(ctx context.Context, environment, version string) (string, error) {
+	if version := firstNonEmpty(version, clientProfile.ImageBuilderVersion); version != "" {
+		return version, nil
+	}
+	if cached, ok := environmentBuilderVersions.Load(environment); ok {
+		return cached.(string), nil
+	}
+	resp, err := client.EnvironmentGetOrCreate(ctx, pb.EnvironmentGetOrCreateRequest_builder{
+		DeploymentName: environment,
+	}.Build())
+	if err != nil {
+		return "", err
+	}
+	version = firstNonEmpty(resp.GetMetadata().GetSettings().GetImageBuilderVersion(), defaultImageBuilderVersion)
+	environmentBuilderVersions.Store(environment, version)
+	return version, nil
 }
 
-func imageBuilderVersion(version string) string {
-	return firstNonEmpty(version, clientProfile.ImageBuilderVersion, "2024.10")
+// dashboardURL returns a link to the page of a Modal object in the web UI.
+// The web UI redirects /id/ links to the object's page in its workspace.
+func dashboardURL(objectId string) string {
+	base := "https://modal.com"
+	if u, err := url.Parse(clientProfile.ServerURL); err == nil {
+		if host, ok := strings.CutPrefix(u.Hostname(), "api."); ok {
+			base = "https://" + host
+		}
+	}
+	return base + "/id/" + objectId
 }
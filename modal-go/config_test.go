@@ -0,0 +1,35 @@
+package modal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+func TestParseConfig(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	unix := "[default]\ntoken_id = \"ak-123\"\ntoken_secret = \"as-456\"\nactive = true\n\n[staging]\nimage_builder_version = '''\n2024.10\n'''\n"
+	want := config{
+		"default": {TokenId: "ak-123", TokenSecret: "as-456", Active: true},
+		"staging": {ImageBuilderVersion: "2024.10\n"},
+	}
+	cfg, err := parseConfig([]byte(unix))
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(cfg).To(gomega.Equal(want))
+
+	// As saved by Notepad on Windows.
+	windows := "\ufeff" + strings.ReplaceAll(unix, "\n", "\r\n")
+	cfg, err = parseConfig([]byte(windows))
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(cfg).To(gomega.Equal(want))
+}
+
+func TestGetenv(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	t.Setenv("MODAL_TOKEN_ID", "ak-123\r")
+	g.Expect(getenv("MODAL_TOKEN_ID")).To(gomega.Equal("ak-123"))
+}
@@ -0,0 +1,62 @@
+package modal
+
+// Overrides of client settings carried by contexts, so that middleware can set
+// them for all calls made under a context.
+
+import (
+	"context"
+	"time"
+)
+
+type (
+	environmentKey struct{}
+	retryPolicyKey struct{}
+	rpcTimeoutKey  struct{}
+)
+
+// WithEnvironment returns a copy of ctx under which calls use environment
+// when their Environment option is not set, instead of the profile's
+// environment.
+//
+// Objects keep the context they were looked up with, so an App, Volume, or
+// other object looked up under ctx stays in environment.
+func WithEnvironment(ctx context.Context, environment string) context.Context {
+	return context.WithValue(ctx, environmentKey{}, environment)
+}
+
+// WithRetryPolicy returns a copy of ctx under which RPCs are retried
+// according to policy instead of the SDK's default policy. Zero fields of
+// policy take the defaults, and policy.Retryable, if set, replaces the check
+// for retryable gRPC status codes. A few RPCs that the SDK retries in a
+// specific way keep their own policy.
+//
+// Calls made by objects, such as a Sandbox, use the context of the App or
+// lookup they came from.
+func WithRetryPolicy(ctx context.Context, policy RetryPolicy) context.Context {
+	return context.WithValue(ctx, retryPolicyKey{}, policy)
+}
+
+// WithRPCTimeout returns a copy of ctx under which each attempt of an RPC
+// fails with DeadlineExceeded after timeout, and is retried according to the
+// retry policy. Long-polling RPCs, such as the ones behind Sandbox.Wait, are
+// given the time they ask Modal to wait on top of timeout. Output streams are
+// not limited.
+//
+// Calls made by objects, such as a Sandbox, use the context of the App or
+// lookup they came from.
+func WithRPCTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, rpcTimeoutKey{}, timeout)
+}
+
+// rpcTimeout returns the timeout of an attempt of an RPC with request req set
+// by WithRPCTimeout, or zero if there is none.
+func rpcTimeout(ctx context.Context, req any) time.Duration {
+	timeout, _ := ctx.Value(rpcTimeoutKey{}).(time.Duration)
+	if timeout <= 0 {
+		return 0
+	}
+	if poll, ok := req.(interface{ GetTimeout() float32 }); ok {
+		timeout += time.Duration(float64(poll.GetTimeout()) * float64(time.Second))
+	}
+	return timeout
+}
@@ -0,0 +1,85 @@
+package modal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+	"github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestWithEnvironment(t *testing.T) {
+	g := gomega.NewWithT(t)
+	saved := clientProfile
+	defer func() { clientProfile = saved }()
+	clientProfile.Environment = "main"
+
+	ctx := context.Background()
+	g.Expect(environmentName(ctx, "")).To(gomega.Equal("main"))
+	ctx = WithEnvironment(ctx, "staging")
+	g.Expect(environmentName(ctx, "")).To(gomega.Equal("staging"))
+	g.Expect(environmentName(ctx, "dev")).To(gomega.Equal("dev"))
+}
+
+func TestWithRetryPolicy(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	attempts := 0
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		attempts++
+		return status.Error(codes.NotFound, "not yet")
+	}
+	interceptor := retryInterceptor()
+
+	err := interceptor(context.Background(), "/modal.client.ModalClient/SandboxWait", nil, nil, nil, invoker)
+	g.Expect(status.Code(err)).To(gomega.Equal(codes.NotFound))
+	g.Expect(attempts).To(gomega.Equal(1))
+
+	attempts = 0
+	ctx := WithRetryPolicy(context.Background(), RetryPolicy{
+		MaxRetries: 2,
+		BaseDelay:  time.Millisecond,
+		Retryable:  func(err error) bool { return status.Code(err) == codes.NotFound },
+	})
+	err = interceptor(ctx, "/modal.client.ModalClient/SandboxWait", nil, nil, nil, invoker)
+	g.Expect(status.Code(err)).To(gomega.Equal(codes.NotFound))
+	g.Expect(attempts).To(gomega.Equal(3))
+}
+
+func TestWithRPCTimeout(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	var remaining time.Duration
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		remaining = 0
+		if deadline, ok := ctx.Deadline(); ok {
+			remaining = time.Until(deadline)
+		}
+		return nil
+	}
+	interceptor := timeoutInterceptor()
+
+	g.Expect(interceptor(context.Background(), "/modal.client.ModalClient/SandboxTerminate", nil, nil, nil, invoker)).To(gomega.Succeed())
+	g.Expect(remaining).To(gomega.BeZero())
+
+	ctx := WithRPCTimeout(context.Background(), 5*time.Second)
+	g.Expect(interceptor(ctx, "/modal.client.ModalClient/SandboxTerminate", &pb.SandboxTerminateRequest{}, nil, nil, invoker)).To(gomega.Succeed())
+	g.Expect(remaining).To(gomega.BeNumerically("~", 5*time.Second, time.Second))
+
+	// Long polls get their own timeout on top.
+	req := pb.SandboxWaitRequest_builder{Timeout: 55}.Build()
+	g.Expect(interceptor(ctx, "/modal.client.ModalClient/SandboxWait", req, nil, nil, invoker)).To(gomega.Succeed())
+	g.Expect(remaining).To(gomega.BeNumerically("~", 60*time.Second, time.Second))
+
+	// An earlier deadline is kept.
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	g.Expect(interceptor(ctx, "/modal.client.ModalClient/SandboxTerminate", nil, nil, nil, invoker)).To(gomega.Succeed())
+	g.Expect(remaining).To(gomega.BeNumerically("<=", time.Second))
+}
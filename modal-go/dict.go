@@ -0,0 +1,337 @@
+package modal
+
+// Dict object, to be used with Modal Dicts.
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+)
+
+// DictPutOptions are options for Dict.Put.
+type DictPutOptions struct {
+	SkipIfExists bool // leave the existing value in place if the key is present
+}
+
+// Dict is a distributed key-value store for sharing data in Modal apps.
+//
+// Keys and values are pickled, so they are compatible with the Modal Python
+// SDK. Keys must be hashable in Python, such as strings, numbers, or bytes.
+type Dict struct {
+	DictId    string
+	cancel    context.CancelFunc // only for ephemeral dicts
+	ephemeral bool
+	ctx       context.Context
+
+	name        string // deployment name, empty for ephemeral dicts
+	environment string
+}
+
+// ObjectId returns the Dict's ID, see Object.
+func (d *Dict) ObjectId() string {
+	return d.DictId
+}
+
+// WithContext returns a copy of the Dict whose calls use ctx, for example to
+// apply a request's deadline and cancellation.
+func (d *Dict) WithContext(ctx context.Context) (*Dict, error) {
+	ctx, err := handleContext(ctx, d.ctx)
+	if err != nil {
+		return nil, err
+	}
+	c := *d
+	c.ctx = ctx
+	return &c, nil
+}
+
+// Name returns the name the Dict was looked up by, or an empty string for
+// ephemeral dicts.
+func (d *Dict) Name() string {
+	return d.name
+}
+
+// Environment returns the name of the environment the Dict is in, see
+// Object.
+func (d *Dict) Environment() string {
+	return firstNonEmpty(d.environment, environmentName(d.ctx, ""))
+}
+
+// IsHydrated reports whether the Dict has an ID, see Object.
+func (d *Dict) IsHydrated() bool {
+	return d.DictId != ""
+}
+
+// Hydrate makes the Dict ready for use, see Object.
+func (d *Dict) Hydrate(ctx context.Context) error {
+	return hydrate(ctx, "Dict", d.DictId, &d.ctx)
+}
+
+// DictEphemeral creates a nameless, temporary dict. Caller must CloseEphemeral.
+func DictEphemeral(ctx context.Context, options *EphemeralOptions) (*Dict, error) {
+	if options == nil {
+		options = &EphemeralOptions{}
+	}
+	var err error
+	ctx, err = clientContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.DictGetOrCreate(ctx, pb.DictGetOrCreateRequest_builder{
+		ObjectCreationType: pb.ObjectCreationType_OBJECT_CREATION_TYPE_EPHEMERAL,
+		EnvironmentName:    environmentName(ctx, options.Environment),
+	}.Build())
+	if err != nil {
+		return nil, err
+	}
+
+	heartbeatCtx, cancel := context.WithCancel(ctx)
+	d := &Dict{DictId: resp.GetDictId(), cancel: cancel, ephemeral: true, ctx: ctx, environment: environmentName(ctx, options.Environment)}
+
+	go func() {
+		defer heartbeatStarted()()
+		t := time.NewTicker(ephemeralObjectHeartbeatSleep)
+		defer t.Stop()
+		for {
+			select {
+			case <-heartbeatCtx.Done():
+				return
+			case <-t.C:
+				_, _ = client.DictHeartbeat(heartbeatCtx, pb.DictHeartbeatRequest_builder{
+					DictId: d.DictId,
+				}.Build()) // ignore errors – next call will retry or context will cancel
+			}
+		}
+	}()
+
+	return d, nil
+}
+
+// CloseEphemeral deletes an ephemeral dict, only used with DictEphemeral.
+func (d *Dict) CloseEphemeral() {
+	if d.ephemeral {
+		d.cancel() // will stop heartbeat
+	} else {
+		panic(fmt.Sprintf("dict %s is not ephemeral", d.DictId))
+	}
+}
+
+// DictLookup returns a handle to a (possibly new) dict by deployment name.
+func DictLookup(ctx context.Context, name string, options *LookupOptions) (*Dict, error) {
+	if options == nil {
+		options = &LookupOptions{}
+	}
+	var err error
+	ctx, err = clientContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var environment string
+	name, environment, err = resolveObjectRef(name, "Dict", options.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	creationType := pb.ObjectCreationType_OBJECT_CREATION_TYPE_UNSPECIFIED
+	if options.CreateIfMissing {
+		creationType = pb.ObjectCreationType_OBJECT_CREATION_TYPE_CREATE_IF_MISSING
+	}
+
+	resp, err := client.DictGetOrCreate(ctx, pb.DictGetOrCreateRequest_builder{
+		DeploymentName:     name,
+		EnvironmentName:    environmentName(ctx, environment),
+		ObjectCreationType: creationType,
+	}.Build())
+	if err != nil {
+		return nil, err
+	}
+	return &Dict{ctx: context.WithoutCancel(ctx), DictId: resp.GetDictId(), name: name, environment: environmentName(ctx, environment)}, nil
+}
+
+// DictDelete removes a dict by name.
+func DictDelete(ctx context.Context, name string, options *DeleteOptions) error {
+	if options == nil {
+		options = &DeleteOptions{}
+	}
+	d, err := DictLookup(ctx, name, &LookupOptions{Environment: options.Environment})
+	if err != nil {
+		return err
+	}
+	_, err = client.DictDelete(d.ctx, pb.DictDeleteRequest_builder{DictId: d.DictId}.Build())
+	return err
+}
+
+func serializeDictKey(key any) ([]byte, error) {
+	b, err := pickleSerialize(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize dict key: %w", err)
+	}
+	return b.Bytes(), nil
+}
+
+// getRaw returns the serialized value for a key, and whether it was found.
+func (d *Dict) getRaw(key any) ([]byte, bool, error) {
+	k, err := serializeDictKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+	resp, err := client.DictGet(d.ctx, pb.DictGetRequest_builder{DictId: d.DictId, Key: k}.Build())
+	if err != nil {
+		return nil, false, err
+	}
+	return resp.GetValue(), resp.GetFound(), nil
+}
+
+// popRaw removes a key, returning its serialized value and whether it was found.
+func (d *Dict) popRaw(key any) ([]byte, bool, error) {
+	k, err := serializeDictKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+	resp, err := client.DictPop(d.ctx, pb.DictPopRequest_builder{DictId: d.DictId, Key: k}.Build())
+	if err != nil {
+		return nil, false, err
+	}
+	return resp.GetValue(), resp.GetFound(), nil
+}
+
+// putRaw writes already serialized values, returning whether any key was
+// written. With ifNotExists, no key is written if one of them already exists.
+func (d *Dict) putRaw(entries []*pb.DictEntry, ifNotExists bool) (bool, error) {
+	resp, err := client.DictUpdate(d.ctx, pb.DictUpdateRequest_builder{
+		DictId:      d.DictId,
+		Updates:     entries,
+		IfNotExists: ifNotExists,
+	}.Build())
+	if err != nil {
+		return false, err
+	}
+	return resp.GetCreated(), nil
+}
+
+func deserializeDictValue(raw []byte, found bool, err error) (any, bool, error) {
+	if err != nil || !found {
+		return nil, false, err
+	}
+	v, err := pickleDeserialize(raw)
+	if err != nil {
+		return nil, false, err
+	}
+	return v, true, nil
+}
+
+// Get returns the value for a key, and whether the key was present.
+func (d *Dict) Get(key any) (any, bool, error) {
+	return deserializeDictValue(d.getRaw(key))
+}
+
+// Put sets the value for a key.
+//
+// With options.SkipIfExists, an existing value is left in place, and Put
+// returns false. Otherwise it returns true.
+func (d *Dict) Put(key, value any, options *DictPutOptions) (bool, error) {
+	if options == nil {
+		options = &DictPutOptions{}
+	}
+	k, err := serializeDictKey(key)
+	if err != nil {
+		return false, err
+	}
+	v, err := pickleSerialize(value)
+	if err != nil {
+		return false, err
+	}
+	created, err := d.putRaw([]*pb.DictEntry{pb.DictEntry_builder{Key: k, Value: v.Bytes()}.Build()}, options.SkipIfExists)
+	if err != nil {
+		return false, err
+	}
+	return created || !options.SkipIfExists, nil
+}
+
+// Update sets the values for several keys at once.
+func (d *Dict) Update(values map[any]any) error {
+	entries := make([]*pb.DictEntry, 0, len(values))
+	for key, value := range values {
+		k, err := serializeDictKey(key)
+		if err != nil {
+			return err
+		}
+		v, err := pickleSerialize(value)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, pb.DictEntry_builder{Key: k, Value: v.Bytes()}.Build())
+	}
+	_, err := d.putRaw(entries, false)
+	return err
+}
+
+// Pop removes a key, returning its value and whether the key was present.
+func (d *Dict) Pop(key any) (any, bool, error) {
+	return deserializeDictValue(d.popRaw(key))
+}
+
+// Contains returns whether a key is present in the dict.
+func (d *Dict) Contains(key any) (bool, error) {
+	k, err := serializeDictKey(key)
+	if err != nil {
+		return false, err
+	}
+	resp, err := client.DictContains(d.ctx, pb.DictContainsRequest_builder{DictId: d.DictId, Key: k}.Build())
+	if err != nil {
+		return false, err
+	}
+	return resp.GetFound(), nil
+}
+
+// Len returns the number of keys in the dict.
+func (d *Dict) Len() (int, error) {
+	resp, err := client.DictLen(d.ctx, pb.DictLenRequest_builder{DictId: d.DictId}.Build())
+	if err != nil {
+		return 0, err
+	}
+	return int(resp.GetLen()), nil
+}
+
+// Clear removes all keys from the dict.
+func (d *Dict) Clear() error {
+	_, err := client.DictClear(d.ctx, pb.DictClearRequest_builder{DictId: d.DictId}.Build())
+	return err
+}
+
+// DictStats describes the contents of a Dict.
+type DictStats struct {
+	Len       int       // Number of keys.
+	CreatedAt time.Time // Creation time of the Dict, zero for ephemeral dicts.
+}
+
+// Stats returns the size and metadata of the dict.
+//
+// Like Queue.Stats, this lists all Dicts in the environment for named dicts,
+// so its cost grows with the number of Dicts in the environment.
+func (d *Dict) Stats() (*DictStats, error) {
+	n, err := d.Len()
+	if err != nil {
+		return nil, err
+	}
+	stats := &DictStats{Len: n}
+	if d.name == "" {
+		return stats, nil
+	}
+
+	resp, err := client.DictList(d.ctx, pb.DictListRequest_builder{
+		EnvironmentName: d.environment,
+	}.Build())
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range resp.GetDicts() {
+		if info.GetName() == d.name {
+			stats.CreatedAt = time.Unix(0, int64(info.GetCreatedAt()*1e9))
+			break
+		}
+	}
+	return stats, nil
+}
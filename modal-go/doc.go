@@ -23,6 +23,14 @@
 //
 // See `config.go` for the resolution logic.
 //
+// # Contexts
+//
+// Handles returned by lookups, such as App, Volume, and Function, keep the
+// values of the context they were looked up with, but not its deadline or
+// cancellation, so they can be cached by long-lived services. Use their
+// WithContext methods, such as App.WithContext and Sandbox.WithContext, to
+// bound individual calls with a request's deadline or cancellation.
+//
 // # Stability
 //
 // `libmodal` is **alpha** software; the API may change without notice until
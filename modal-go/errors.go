@@ -47,6 +47,29 @@ func (e InvalidError) Error() string {
 	return "InvalidError: " + e.Exception
 }
 
+// InvalidNameError is returned when an object name does not follow Modal's
+// naming rules. It is checked client-side, before any request is made.
+type InvalidNameError struct {
+	Exception  string
+	Name       string
+	ObjectType string
+}
+
+func (e InvalidNameError) Error() string {
+	return "InvalidNameError: " + e.Exception
+}
+
+// SecretMissingKeysError is returned when a Secret does not contain all of the
+// keys requested with SecretFromNameOptions.RequiredKeys.
+type SecretMissingKeysError struct {
+	Exception   string
+	MissingKeys []string
+}
+
+func (e SecretMissingKeysError) Error() string {
+	return "SecretMissingKeysError: " + e.Exception
+}
+
 // QueueEmptyError is returned when an operation is attempted on an empty queue.
 type QueueEmptyError struct {
 	Exception string
@@ -65,6 +88,36 @@ func (e QueueFullError) Error() string {
 	return "QueueFullError: " + e.Exception
 }
 
+// QueueItemError describes the failure of one item in a bulk Queue operation.
+type QueueItemError struct {
+	Exception string
+	Partition string
+	Index     int    // Index of the item in its partition's values, or -1 if the whole partition failed.
+	Data      []byte // Serialized item, for items removed from the Queue that could not be deserialized.
+
+	cause error
+}
+
+func (e QueueItemError) Error() string {
+	return "QueueItemError: " + e.Exception
+}
+
+// Unwrap returns the underlying error for the item.
+func (e QueueItemError) Unwrap() error {
+	return e.cause
+}
+
+// QueueBatchError is returned by bulk Queue operations across partitions when
+// some items failed. Items not listed were processed successfully.
+type QueueBatchError struct {
+	Exception string
+	Items     []QueueItemError
+}
+
+func (e QueueBatchError) Error() string {
+	return "QueueBatchError: " + e.Exception
+}
+
 // SandboxFilesystemError is returned when an operation is attempted on a full queue.
 type SandboxFilesystemError struct {
 	Exception string
@@ -82,3 +135,137 @@ type SandboxTimeoutError struct {
 func (e SandboxTimeoutError) Error() string {
 	return "SandboxTimeoutError: " + e.Exception
 }
+
+// ImageBuildError is returned when waiting on an Image build is interrupted by
+// a timeout or context cancellation. Logs holds the build output received so far.
+type ImageBuildError struct {
+	Exception string
+	ImageId   string
+	Logs      string
+
+	cause error
+}
+
+func (e ImageBuildError) Error() string {
+	if e.Logs == "" {
+		return "ImageBuildError: " + e.Exception
+	}
+	return "ImageBuildError: " + e.Exception + "\nBuild logs:\n" + e.Logs
+}
+
+// Unwrap returns the context error that interrupted the build, if any.
+func (e ImageBuildError) Unwrap() error {
+	return e.cause
+}
+
+// ImagePullError is returned when building an Image from a registry fails
+// because its base image could not be pulled, such as for a registry
+// authentication failure. Exception includes a hint on how to fix it.
+type ImagePullError struct {
+	Exception string
+	ImageId   string
+	Tag       string           // Tag of the base image, such as "ghcr.io/org/app:1.2".
+	Registry  string           // Registry host, such as "ghcr.io", or "docker.io" for Docker Hub.
+	Reason    ImagePullReason  // Why the pull failed.
+	AuthType  RegistryAuthType // How the pull authenticated to the registry.
+}
+
+func (e ImagePullError) Error() string {
+	return "ImagePullError: " + e.Exception
+}
+
+// InvalidResourcesError is returned when Modal rejects the resources requested
+// for a Sandbox. Min and Max are the valid range reported by Modal, or zero
+// when the rejection does not state a bound.
+type InvalidResourcesError struct {
+	Exception string
+	Field     string  // "CPU", "Memory", "EphemeralDiskMB", or "GPU".
+	Requested float64 // Requested amount, in physical cores for CPU, MiB for Memory and EphemeralDiskMB, and GPUs for GPU.
+	Min       float64
+	Max       float64
+
+	cause error
+}
+
+func (e InvalidResourcesError) Error() string {
+	return "InvalidResourcesError: " + e.Exception
+}
+
+// Unwrap returns the gRPC error that Modal responded with.
+func (e InvalidResourcesError) Unwrap() error {
+	return e.cause
+}
+
+// OrphanedSandboxError is returned by CreateSandbox when creation was
+// interrupted after Modal created the Sandbox, and the Sandbox is still
+// running, either because SandboxOptions.KeepOnCancel is set or because it
+// could not be terminated. Use SandboxId to reach or terminate it.
+type OrphanedSandboxError struct {
+	Exception string
+	SandboxId string
+
+	cause error
+}
+
+func (e OrphanedSandboxError) Error() string {
+	return "OrphanedSandboxError: " + e.Exception
+}
+
+// Unwrap returns the error that interrupted creation, such as
+// context.Canceled.
+func (e OrphanedSandboxError) Unwrap() error {
+	return e.cause
+}
+
+// UnsupportedArchError is returned when a Sandbox requests a CPU architecture
+// that Modal has no workers for.
+type UnsupportedArchError struct {
+	Exception string
+	Arch      string // The requested architecture, such as "arm64".
+}
+
+func (e UnsupportedArchError) Error() string {
+	return "UnsupportedArchError: " + e.Exception
+}
+
+// FileBatchError is returned by Volume.Upload with UploadOptions.ContinueOnError
+// when some of the files could not be uploaded.
+type FileBatchError struct {
+	Exception string
+	Failed    []FileResult // Results of the files that failed.
+}
+
+func (e FileBatchError) Error() string {
+	return "FileBatchError: " + e.Exception
+}
+
+// Unwrap returns the errors of the files that failed.
+func (e FileBatchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failed))
+	for _, result := range e.Failed {
+		errs = append(errs, result.Err)
+	}
+	return errs
+}
+
+// SandboxBatchError is returned by CreateSandboxBatcher.Flush when some of
+// the Sandboxes of a batch could not be created.
+type SandboxBatchError struct {
+	Exception string
+	Errors    []error // By index in the batch, nil for Sandboxes that were created.
+}
+
+func (e SandboxBatchError) Error() string {
+	return "SandboxBatchError: " + e.Exception
+}
+
+// Unwrap returns the errors of the Sandboxes that could not be created.
+func (e SandboxBatchError) Unwrap() []error {
+	var errs []error
+	for _, err := range e.Errors {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
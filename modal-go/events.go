@@ -0,0 +1,142 @@
+package modal
+
+// Lifecycle event streams for Apps and Sandboxes.
+
+import (
+	"context"
+	"io"
+	"iter"
+	"strings"
+	"time"
+
+	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+)
+
+// TaskState is the state of a container running an App's Functions or a
+// Sandbox, such as "queued", "loading_image", "active", or "completed".
+type TaskState string
+
+// Common task states.
+const (
+	TaskStateQueued       TaskState = "queued"
+	TaskStateLoadingImage TaskState = "loading_image"
+	TaskStateActive       TaskState = "active"
+	TaskStateCompleted    TaskState = "completed"
+	TaskStatePreempted    TaskState = "preempted"
+)
+
+func taskStateFromProto(state pb.TaskState) TaskState {
+	return TaskState(strings.ToLower(strings.TrimPrefix(state.String(), "TASK_STATE_")))
+}
+
+// LifecycleEventType is the kind of event reported by App.Events and
+// Sandbox.Events.
+type LifecycleEventType string
+
+// Kinds of lifecycle events.
+const (
+	// A container changed state, see LifecycleEvent.State.
+	LifecycleTaskState LifecycleEventType = "TaskState"
+	// The App stopped, or the Sandbox finished. This is the last event.
+	LifecycleDone LifecycleEventType = "Done"
+)
+
+// LifecycleEvent is a state change of an App or Sandbox.
+type LifecycleEvent struct {
+	Type       LifecycleEventType
+	EntryId    string    // Position in the stream, to resume with EventsOptions.AfterEntryId.
+	TaskId     string    // Container the event is about, if any.
+	FunctionId string    // Function running in the container, if any.
+	State      TaskState // New state, for LifecycleTaskState events.
+	Time       time.Time // Time of the event, zero for LifecycleDone events.
+}
+
+// EventsOptions are options for App.Events and Sandbox.Events.
+type EventsOptions struct {
+	// AfterEntryId resumes a stream after the event with this EntryId.
+	// Defaults to the start of the retained history.
+	AfterEntryId string
+}
+
+// Events returns an iterator over lifecycle events of the App's containers,
+// ending with a LifecycleDone event when the App stops.
+//
+// The stream is resumed from the last received event after transient
+// errors, up to ClientOptions.MaxStreamReconnects consecutive times. A
+// LifecycleTaskState event with TaskStateCompleted does not say whether the
+// container succeeded.
+func (app *App) Events(options *EventsOptions) iter.Seq2[LifecycleEvent, error] {
+	return lifecycleEvents(app.ctx, pb.AppGetLogsRequest_builder{AppId: app.AppId}, false, options)
+}
+
+// Events returns an iterator over lifecycle events of the Sandbox, ending
+// with a LifecycleDone event when the Sandbox finishes. Use Wait for its exit
+// code. Streams are resumed as for App.Events.
+func (sb *Sandbox) Events(options *EventsOptions) iter.Seq2[LifecycleEvent, error] {
+	return lifecycleEvents(sb.ctx, pb.AppGetLogsRequest_builder{SandboxId: sb.SandboxId}, true, options)
+}
+
+// lifecycleEvents polls AppGetLogs for task state changes, resuming from the
+// last entry ID on each call. The stream ends when the App is done, or with
+// doneOnEof, at the end of the logs of a single Sandbox.
+func lifecycleEvents(ctx context.Context, req pb.AppGetLogsRequest_builder, doneOnEof bool, options *EventsOptions) iter.Seq2[LifecycleEvent, error] {
+	if options == nil {
+		options = &EventsOptions{}
+	}
+	return func(yield func(LifecycleEvent, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		req := req // each iteration starts from options.AfterEntryId
+		req.Timeout = 55
+		req.LastEntryId = options.AfterEntryId
+		reconnector := newStreamReconnector()
+		for {
+			stream, err := client.AppGetLogs(ctx, req.Build())
+			if err != nil {
+				if reconnector.retry(ctx, err) {
+					continue
+				}
+				yield(LifecycleEvent{}, err)
+				return
+			}
+			for {
+				batch, err := stream.Recv()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					if reconnector.retry(ctx, err) {
+						break
+					}
+					yield(LifecycleEvent{}, err)
+					return
+				}
+				reconnector.progress()
+				if batch.GetEntryId() != "" {
+					req.LastEntryId = batch.GetEntryId()
+				}
+				for _, item := range batch.GetItems() {
+					if item.GetTaskState() == pb.TaskState_TASK_STATE_UNSPECIFIED {
+						continue
+					}
+					event := LifecycleEvent{
+						Type:       LifecycleTaskState,
+						EntryId:    batch.GetEntryId(),
+						TaskId:     batch.GetTaskId(),
+						FunctionId: batch.GetFunctionId(),
+						State:      taskStateFromProto(item.GetTaskState()),
+						Time:       time.Unix(0, int64(item.GetTimestamp()*1e9)),
+					}
+					if !yield(event, nil) {
+						return
+					}
+				}
+				if batch.GetAppDone() || (doneOnEof && batch.GetEof()) {
+					yield(LifecycleEvent{Type: LifecycleDone, EntryId: batch.GetEntryId()}, nil)
+					return
+				}
+			}
+		}
+	}
+}
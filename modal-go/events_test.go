@@ -0,0 +1,17 @@
+package modal
+
+import (
+	"testing"
+
+	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+	"github.com/onsi/gomega"
+)
+
+func TestTaskStateFromProto(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	g.Expect(taskStateFromProto(pb.TaskState_TASK_STATE_ACTIVE)).To(gomega.Equal(TaskStateActive))
+	g.Expect(taskStateFromProto(pb.TaskState_TASK_STATE_LOADING_IMAGE)).To(gomega.Equal(TaskStateLoadingImage))
+	g.Expect(taskStateFromProto(pb.TaskState_TASK_STATE_WORKER_ASSIGNED)).To(gomega.Equal(TaskState("worker_assigned")))
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+
+	"github.com/modal-labs/libmodal/modal-go"
+)
+
+func main() {
+	ctx := context.Background()
+
+	app, err := modal.AppLookup(ctx, "libmodal-example", &modal.LookupOptions{CreateIfMissing: true})
+	if err != nil {
+		log.Fatalf("Failed to lookup or create app: %v", err)
+	}
+
+	// Run from the modal-go directory, so the package path resolves.
+	image, err := app.ImageFromGoBinary("./examples/sandbox-go-binary/worker", nil)
+	if err != nil {
+		log.Fatalf("Failed to create image from Go binary: %v", err)
+	}
+
+	sb, err := app.CreateSandbox(image, nil)
+	if err != nil {
+		log.Fatalf("Failed to create sandbox: %v", err)
+	}
+	log.Printf("sandbox: %s\n", sb.SandboxId)
+
+	output, err := io.ReadAll(sb.Stdout)
+	if err != nil {
+		log.Fatalf("Failed to read from sandbox stdout: %v", err)
+	}
+
+	log.Printf("output: %s\n", string(output))
+}
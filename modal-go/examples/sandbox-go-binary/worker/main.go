@@ -0,0 +1,11 @@
+// Program run inside the Sandbox by the sandbox-go-binary example.
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func main() {
+	fmt.Printf("hello from %s/%s\n", runtime.GOOS, runtime.GOARCH)
+}
@@ -7,10 +7,8 @@ import (
 	"context"
 	"crypto/md5"
 	"crypto/sha256"
-	"encoding/base64"
 	"errors"
 	"fmt"
-	"net/http"
 	"time"
 
 	pickle "github.com/kisielk/og-rek"
@@ -20,7 +18,10 @@ import (
 )
 
 // From: modal/_utils/blob_utils.py
-const maxObjectSizeBytes int = 2 * 1024 * 1024 // 2 MiB
+const defaultMaxObjectSizeBytes int = 2 * 1024 * 1024 // 2 MiB
+
+// maxObjectSizeBytes is the size above which payloads are uploaded as blobs.
+var maxObjectSizeBytes = defaultMaxObjectSizeBytes
 
 // From: modal-client/modal/_utils/function_utils.py
 const outputsTimeout time.Duration = time.Second * 55
@@ -37,6 +38,7 @@ type Function struct {
 	FunctionId    string
 	MethodName    *string // used for class methods
 	inputPlaneUrl string  // if empty, use control plane
+	webUrl        string  // empty unless the Function is a web endpoint
 	ctx           context.Context
 }
 
@@ -50,11 +52,16 @@ func FunctionLookup(ctx context.Context, appName string, name string, options *L
 	if err != nil {
 		return nil, err
 	}
+	var environment string
+	appName, environment, err = resolveObjectRef(appName, "App", options.Environment)
+	if err != nil {
+		return nil, err
+	}
 
 	resp, err := client.FunctionGet(ctx, pb.FunctionGetRequest_builder{
 		AppName:         appName,
 		ObjectTag:       name,
-		EnvironmentName: environmentName(options.Environment),
+		EnvironmentName: environmentName(ctx, environment),
 	}.Build())
 
 	if status, ok := status.FromError(err); ok && status.Code() == codes.NotFound {
@@ -64,13 +71,19 @@ func FunctionLookup(ctx context.Context, appName string, name string, options *L
 		return nil, err
 	}
 
-	var inputPlaneUrl string
+	var inputPlaneUrl, webUrl string
 	if meta := resp.GetHandleMetadata(); meta != nil {
 		if url := meta.GetInputPlaneUrl(); url != "" {
 			inputPlaneUrl = url
 		}
-	}
-	return &Function{FunctionId: resp.GetFunctionId(), inputPlaneUrl: inputPlaneUrl, ctx: ctx}, nil
+		webUrl = meta.GetWebUrl()
+	}
+	return &Function{
+		FunctionId:    resp.GetFunctionId(),
+		inputPlaneUrl: inputPlaneUrl,
+		webUrl:        webUrl,
+		ctx:           context.WithoutCancel(ctx),
+	}, nil
 }
 
 // Serialize Go data types to the Python pickle format.
@@ -96,6 +109,18 @@ func pickleDeserialize(buffer []byte) (any, error) {
 	return result, nil
 }
 
+// WithContext returns a copy of the Function whose calls use ctx, for example
+// to apply a request's deadline and cancellation.
+func (f *Function) WithContext(ctx context.Context) (*Function, error) {
+	ctx, err := handleContext(ctx, f.ctx)
+	if err != nil {
+		return nil, err
+	}
+	c := *f
+	c.ctx = ctx
+	return &c, nil
+}
+
 // Serializes inputs, make a function call and return its ID
 func (f *Function) createInput(args []any, kwargs map[string]any) (*pb.FunctionInput, error) {
 	payload, err := pickleSerialize(pickle.Tuple{args, kwargs})
@@ -179,41 +204,5 @@ func (f *Function) Spawn(args []any, kwargs map[string]any) (*FunctionCall, erro
 func blobUpload(ctx context.Context, data []byte) (string, error) {
 	md5sum := md5.Sum(data)
 	sha256sum := sha256.Sum256(data)
-	contentMd5 := base64.StdEncoding.EncodeToString(md5sum[:])
-	contentSha256 := base64.StdEncoding.EncodeToString(sha256sum[:])
-
-	resp, err := client.BlobCreate(ctx, pb.BlobCreateRequest_builder{
-		ContentMd5:          contentMd5,
-		ContentSha256Base64: contentSha256,
-		ContentLength:       int64(len(data)),
-	}.Build())
-	if err != nil {
-		return "", fmt.Errorf("failed to create blob: %w", err)
-	}
-
-	switch resp.WhichUploadTypeOneof() {
-	case pb.BlobCreateResponse_Multipart_case:
-		return "", fmt.Errorf("function input size exceeds multipart upload threshold, unsupported by this SDK version")
-
-	case pb.BlobCreateResponse_UploadUrl_case:
-		req, err := http.NewRequest("PUT", resp.GetUploadUrl(), bytes.NewReader(data))
-		if err != nil {
-			return "", fmt.Errorf("failed to create upload request: %w", err)
-		}
-		req.Header.Set("Content-Type", "application/octet-stream")
-		req.Header.Set("Content-MD5", contentMd5)
-		uploadResp, err := http.DefaultClient.Do(req)
-		if err != nil {
-			return "", fmt.Errorf("failed to upload blob: %w", err)
-		}
-		defer uploadResp.Body.Close()
-		if uploadResp.StatusCode < 200 || uploadResp.StatusCode >= 300 {
-			return "", fmt.Errorf("failed blob upload: %s", uploadResp.Status)
-		}
-		// Skip client-side ETag header validation for now (MD5 checksum).
-		return resp.GetBlobId(), nil
-
-	default:
-		return "", fmt.Errorf("missing upload URL in BlobCreate response")
-	}
+	return blobUploadFrom(ctx, bytes.NewReader(data), int64(len(data)), md5sum[:], sha256sum[:], nil)
 }
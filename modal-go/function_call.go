@@ -3,9 +3,12 @@ package modal
 import (
 	"context"
 	"fmt"
+	"io"
+	"reflect"
 	"time"
 
 	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+	"google.golang.org/grpc"
 )
 
 // FunctionCall references a Modal Function Call. Function Calls are
@@ -25,28 +28,155 @@ func FunctionCallFromId(ctx context.Context, functionCallId string) (*FunctionCa
 	}
 	functionCall := FunctionCall{
 		FunctionCallId: functionCallId,
-		ctx:            ctx,
+		ctx:            context.WithoutCancel(ctx),
 	}
 	return &functionCall, nil
 }
 
+// defaultFunctionCallDict is the Dict that FunctionCall.Persist records
+// names in by default.
+const defaultFunctionCallDict = "libmodal-function-calls"
+
+// FunctionCallStoreOptions are options for FunctionCall.Persist and
+// FunctionCallFromName.
+type FunctionCallStoreOptions struct {
+	// Dict maps names to FunctionCall IDs. Defaults to the Dict named
+	// "libmodal-function-calls" in Environment, created if missing.
+	Dict        *Dict
+	Environment string
+}
+
+// functionCallDict returns the Dict of options, or looks up the default one.
+func functionCallDict(ctx context.Context, options *FunctionCallStoreOptions) (*Dict, error) {
+	if options.Dict != nil {
+		return options.Dict, nil
+	}
+	return DictLookup(ctx, defaultFunctionCallDict, &LookupOptions{Environment: options.Environment, CreateIfMissing: true})
+}
+
+// WithContext returns a copy of the FunctionCall whose calls use ctx, for example to
+// apply a request's deadline and cancellation.
+func (fc *FunctionCall) WithContext(ctx context.Context) (*FunctionCall, error) {
+	ctx, err := handleContext(ctx, fc.ctx)
+	if err != nil {
+		return nil, err
+	}
+	c := *fc
+	c.ctx = ctx
+	return &c, nil
+}
+
+// Persist records the FunctionCall under name, such as a job ID, so that
+// FunctionCallFromName can find it after the process restarts. Persisting
+// the same FunctionCall under a name again succeeds, so retries are safe, but
+// a name recorded for another FunctionCall returns an InvalidError.
+//
+// For at-least-once job systems, look the name up with FunctionCallFromName
+// before spawning, and spawn and persist only if it is not found.
+func (fc *FunctionCall) Persist(name string, options *FunctionCallStoreOptions) error {
+	if options == nil {
+		options = &FunctionCallStoreOptions{}
+	}
+	dict, err := functionCallDict(fc.ctx, options)
+	if err != nil {
+		return err
+	}
+	created, err := dict.Put(name, fc.FunctionCallId, &DictPutOptions{SkipIfExists: true})
+	if err != nil || created {
+		return err
+	}
+	existing, found, err := dict.Get(name)
+	if err != nil {
+		return err
+	}
+	if found && existing != fc.FunctionCallId {
+		return InvalidError{fmt.Sprintf("name %q is already recorded for FunctionCall %v", name, existing)}
+	}
+	if !found { // removed in the meantime
+		_, err = dict.Put(name, fc.FunctionCallId, nil)
+	}
+	return err
+}
+
+// FunctionCallFromName returns the FunctionCall recorded under name by
+// FunctionCall.Persist, or a NotFoundError if there is none.
+func FunctionCallFromName(ctx context.Context, name string, options *FunctionCallStoreOptions) (*FunctionCall, error) {
+	if options == nil {
+		options = &FunctionCallStoreOptions{}
+	}
+	dict, err := functionCallDict(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	value, found, err := dict.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	functionCallId, ok := value.(string)
+	if !found || !ok {
+		return nil, NotFoundError{fmt.Sprintf("no FunctionCall recorded under name %q", name)}
+	}
+	return FunctionCallFromId(ctx, functionCallId)
+}
+
 // FunctionCallGetOptions are options for getting outputs from Function Calls.
 type FunctionCallGetOptions struct {
 	// Timeout specifies the maximum duration to wait for the output.
 	// If nil, no timeout is applied. If set to 0, it will check if the function
 	// call is already completed.
 	Timeout *time.Duration
+	// CancelOnCtxDone cancels the FunctionCall on Modal if the context is done
+	// before the output arrives, instead of leaving it running.
+	CancelOnCtxDone bool
 }
 
 // Get waits for the output of a FunctionCall.
 // If timeout > 0, the operation will be cancelled after the specified duration.
 func (fc *FunctionCall) Get(options *FunctionCallGetOptions) (any, error) {
+	return fc.get(fc.ctx, options)
+}
+
+// GetContext is like Get, but stops waiting when ctx is done. With
+// options.CancelOnCtxDone, the FunctionCall is then also cancelled on Modal.
+func (fc *FunctionCall) GetContext(ctx context.Context, options *FunctionCallGetOptions) (any, error) {
+	ctx, err := clientContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return fc.get(ctx, options)
+}
+
+func (fc *FunctionCall) get(ctx context.Context, options *FunctionCallGetOptions) (any, error) {
 	if options == nil {
 		options = &FunctionCallGetOptions{}
 	}
-	ctx := fc.ctx
 	invocation := controlPlaneInvocationFromFunctionCallId(ctx, fc.FunctionCallId)
-	return invocation.awaitOutput(options.Timeout)
+	result, err := invocation.awaitOutput(options.Timeout)
+	if err != nil && ctx.Err() != nil {
+		if options.CancelOnCtxDone {
+			if cancelErr := fc.cancelAfterCtxDone(ctx); cancelErr != nil {
+				return nil, fmt.Errorf("%w (and failed to cancel FunctionCall: %v)", ctx.Err(), cancelErr)
+			}
+		}
+		return nil, ctx.Err()
+	}
+	return result, err
+}
+
+// cancelAfterCtxDone cancels the FunctionCall using a context detached from
+// ctx, which is already done.
+func (fc *FunctionCall) cancelAfterCtxDone(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+	_, err := client.FunctionCallCancel(ctx, pb.FunctionCallCancelRequest_builder{
+		FunctionCallId: fc.FunctionCallId,
+	}.Build())
+	return err
+}
+
+// DashboardURL returns a link to the FunctionCall in the Modal web UI.
+func (fc *FunctionCall) DashboardURL() string {
+	return dashboardURL(fc.FunctionCallId)
 }
 
 // FunctionCallCancelOptions are options for cancelling Function Calls.
@@ -69,3 +199,154 @@ func (fc *FunctionCall) Cancel(options *FunctionCallCancelOptions) error {
 
 	return nil
 }
+
+// StreamGenerator returns a reader over the data yielded by a generator
+// FunctionCall. Each item the generator yields must be bytes or str.
+//
+// It does not stream the return value of a regular Function, even a large
+// one: use Get for those. For such calls, Read returns an InvalidError once
+// the call has finished.
+//
+// Items are fetched from Modal one at a time as the reader is consumed, so
+// outputs much larger than memory can be copied to a file or network
+// connection. The reader returns io.EOF once the generator has finished and
+// all items were read.
+func (fc *FunctionCall) StreamGenerator() io.ReadCloser {
+	ctx, cancel := context.WithCancel(fc.ctx)
+	return &dataOutReader{
+		ctx:            ctx,
+		cancel:         cancel,
+		functionCallId: fc.FunctionCallId,
+		reconnector:    newStreamReconnector(),
+	}
+}
+
+// dataOutReader reads the data-out chunks of a generator FunctionCall.
+type dataOutReader struct {
+	ctx            context.Context
+	cancel         context.CancelFunc
+	functionCallId string
+	stream         grpc.ServerStreamingClient[pb.DataChunk]
+	lastIndex      uint64
+	itemsTotal     *uint64 // set once the generator has finished
+	reconnector    *streamReconnector
+	buf            []byte
+	err            error
+}
+
+func (r *dataOutReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.err = r.next()
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// next fetches the next chunk into the buffer, or returns io.EOF when done.
+func (r *dataOutReader) next() error {
+	if r.itemsTotal != nil && r.lastIndex >= *r.itemsTotal {
+		return io.EOF
+	}
+	if r.stream == nil {
+		stream, err := client.FunctionCallGetDataOut(r.ctx, pb.FunctionCallGetDataRequest_builder{
+			FunctionCallId: r.functionCallId,
+			LastIndex:      r.lastIndex,
+		}.Build())
+		if err != nil {
+			if r.reconnector.retry(r.ctx, err) {
+				return nil
+			}
+			return fmt.Errorf("error getting data stream: %w", err)
+		}
+		r.stream = stream
+	}
+
+	chunk, err := r.stream.Recv()
+	if err != nil {
+		r.stream = nil
+		if err == io.EOF {
+			// The server closes the stream periodically; check whether the
+			// generator finished before reconnecting.
+			return r.checkDone()
+		}
+		if r.reconnector.retry(r.ctx, err) {
+			return nil
+		}
+		return fmt.Errorf("error getting data stream: %w", err)
+	}
+	r.reconnector.progress()
+	if chunk.GetIndex() <= r.lastIndex {
+		return nil // duplicate delivery after a reconnect
+	}
+
+	var data []byte
+	if chunk.HasDataBlobId() {
+		data, err = blobDownload(r.ctx, chunk.GetDataBlobId())
+		if err != nil {
+			return err
+		}
+	} else {
+		data = chunk.GetData()
+	}
+	item, err := deserializeDataFormat(data, chunk.GetDataFormat())
+	if err != nil {
+		return err
+	}
+	r.buf, err = streamItemBytes(item)
+	if err != nil {
+		return err
+	}
+	r.lastIndex = chunk.GetIndex()
+	return nil
+}
+
+// checkDone looks up the generator's final output without blocking.
+func (r *dataOutReader) checkDone() error {
+	if r.itemsTotal != nil {
+		return nil
+	}
+	invocation := controlPlaneInvocationFromFunctionCallId(r.ctx, r.functionCallId)
+	output, err := invocation.getOutput(0)
+	if err != nil || output == nil {
+		return err
+	}
+	result, err := processResult(r.ctx, output.GetResult(), output.GetDataFormat())
+	if err != nil {
+		return err
+	}
+	done, ok := result.(*pb.GeneratorDone)
+	if !ok {
+		return InvalidError{fmt.Sprintf("FunctionCall %s is not a generator", r.functionCallId)}
+	}
+	itemsTotal := done.GetItemsTotal()
+	r.itemsTotal = &itemsTotal
+	return nil
+}
+
+// Close stops fetching data. It does not cancel the FunctionCall.
+func (r *dataOutReader) Close() error {
+	r.cancel()
+	if r.err == nil {
+		r.err = io.ErrClosedPipe
+	}
+	return nil
+}
+
+// streamItemBytes converts an item yielded by a generator to raw bytes.
+func streamItemBytes(item any) ([]byte, error) {
+	switch v := item.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	}
+	// Pickled Python bytes may decode to a named string type.
+	if rv := reflect.ValueOf(item); rv.Kind() == reflect.String {
+		return []byte(rv.String()), nil
+	}
+	return nil, InvalidError{fmt.Sprintf("generator yielded value of type %T, expected bytes or str", item)}
+}
@@ -0,0 +1,56 @@
+package modal
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+	"github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestFunctionCallPersist(t *testing.T) {
+	g := gomega.NewWithT(t)
+	var lookups []string
+	entries := map[string][]byte{}
+	fake := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		switch req := req.(type) {
+		case *pb.DictGetOrCreateRequest:
+			lookups = append(lookups, req.GetDeploymentName())
+			proto.Merge(reply.(proto.Message), pb.DictGetOrCreateResponse_builder{DictId: "di-123"}.Build())
+		case *pb.DictUpdateRequest:
+			created := false
+			for _, entry := range req.GetUpdates() {
+				if _, ok := entries[string(entry.GetKey())]; !ok || !req.GetIfNotExists() {
+					entries[string(entry.GetKey())] = entry.GetValue()
+					created = true
+				}
+			}
+			proto.Merge(reply.(proto.Message), pb.DictUpdateResponse_builder{Created: created}.Build())
+		case *pb.DictGetRequest:
+			value, found := entries[string(req.GetKey())]
+			proto.Merge(reply.(proto.Message), pb.DictGetResponse_builder{Value: value, Found: found}.Build())
+		}
+		return nil
+	}
+	useFakeClient(t, fake)
+	ctx := context.Background()
+
+	_, err := FunctionCallFromName(ctx, "job-1", nil)
+	g.Expect(err).To(gomega.BeAssignableToTypeOf(NotFoundError{}))
+
+	fc, err := FunctionCallFromId(ctx, "fc-1")
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(fc.Persist("job-1", nil)).To(gomega.Succeed())
+	g.Expect(fc.Persist("job-1", nil)).To(gomega.Succeed()) // retried
+
+	other, err := FunctionCallFromId(ctx, "fc-2")
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(other.Persist("job-1", nil)).To(gomega.BeAssignableToTypeOf(InvalidError{}))
+
+	fc, err = FunctionCallFromName(ctx, "job-1", nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(fc.FunctionCallId).To(gomega.Equal("fc-1"))
+	g.Expect(lookups).To(gomega.HaveEach(defaultFunctionCallDict))
+}
@@ -0,0 +1,73 @@
+package modal
+
+// Calling Modal Functions that are deployed as web endpoints.
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// WebURL returns the URL of the Function's web endpoint, or an empty string
+// if the Function is not a web endpoint.
+func (f *Function) WebURL() string {
+	return f.webUrl
+}
+
+// FunctionHTTPClientOptions are options for Function.HTTPClient.
+type FunctionHTTPClientOptions struct {
+	// TokenId and TokenSecret are a proxy auth token ("wk-..." and "ws-..."),
+	// sent to endpoints that require proxy auth. Optional otherwise.
+	TokenId     string
+	TokenSecret string
+	// Transport makes the underlying requests, defaults to
+	// http.DefaultTransport.
+	Transport http.RoundTripper
+}
+
+// HTTPClient returns an HTTP client for calling the Function's web endpoint,
+// which adds the proxy auth token to requests for the endpoint's host. Other
+// hosts, such as redirect targets, never receive the token.
+func (f *Function) HTTPClient(options *FunctionHTTPClientOptions) (*http.Client, error) {
+	if options == nil {
+		options = &FunctionHTTPClientOptions{}
+	}
+	if f.webUrl == "" {
+		return nil, InvalidError{fmt.Sprintf("Function %s is not a web endpoint", f.FunctionId)}
+	}
+	if (options.TokenId == "") != (options.TokenSecret == "") {
+		return nil, InvalidError{"TokenId and TokenSecret must be set together"}
+	}
+	u, err := url.Parse(f.webUrl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid web URL %q: %w", f.webUrl, err)
+	}
+	transport := options.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &http.Client{Transport: &proxyAuthTransport{
+		base:        transport,
+		host:        u.Host,
+		tokenId:     options.TokenId,
+		tokenSecret: options.TokenSecret,
+	}}, nil
+}
+
+// proxyAuthTransport adds proxy auth headers to requests for one host.
+type proxyAuthTransport struct {
+	base        http.RoundTripper
+	host        string
+	tokenId     string
+	tokenSecret string
+}
+
+func (t *proxyAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.tokenId == "" || req.URL.Host != t.host {
+		return t.base.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Modal-Key", t.tokenId)
+	req.Header.Set("Modal-Secret", t.tokenSecret)
+	return t.base.RoundTrip(req)
+}
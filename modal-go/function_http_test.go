@@ -0,0 +1,43 @@
+package modal
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+func TestFunctionHTTPClient(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	echoKey := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Header.Get("Modal-Key")+":"+r.Header.Get("Modal-Secret"))
+	})
+	endpoint := httptest.NewServer(echoKey)
+	defer endpoint.Close()
+	other := httptest.NewServer(echoKey)
+	defer other.Close()
+
+	_, err := (&Function{FunctionId: "fu-123"}).HTTPClient(nil)
+	g.Expect(err).To(gomega.BeAssignableToTypeOf(InvalidError{}))
+
+	f := &Function{FunctionId: "fu-123", webUrl: endpoint.URL}
+	_, err = f.HTTPClient(&FunctionHTTPClientOptions{TokenId: "wk-123"})
+	g.Expect(err).To(gomega.BeAssignableToTypeOf(InvalidError{}))
+
+	client, err := f.HTTPClient(&FunctionHTTPClientOptions{TokenId: "wk-123", TokenSecret: "ws-456"})
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	get := func(url string) string {
+		resp, err := client.Get(url)
+		g.Expect(err).ToNot(gomega.HaveOccurred())
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		g.Expect(err).ToNot(gomega.HaveOccurred())
+		return string(body)
+	}
+	g.Expect(get(f.WebURL() + "/predict")).To(gomega.Equal("wk-123:ws-456"))
+	g.Expect(get(other.URL)).To(gomega.Equal(":"))
+}
@@ -0,0 +1,189 @@
+package modal
+
+// Structured concurrency for Sandboxes, in the style of errgroup.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Group runs functions that create Sandboxes and run commands in them, and
+// cleans up after the first failure: it cancels the Group's context and
+// terminates every Sandbox created through it, so a failed fan-out does not
+// leave Sandboxes running. The parent context being cancelled cleans up the
+// same way.
+//
+//	group, ctx := modal.NewGroup(ctx)
+//	for _, shard := range shards {
+//		group.Go(func(ctx context.Context) error {
+//			sb, err := group.CreateSandbox(app, image, nil)
+//			if err != nil {
+//				return err
+//			}
+//			defer sb.Terminate()
+//			_, err = group.Exec(sb, []string{"./process", shard}, modal.ExecOptions{})
+//			return err
+//		})
+//	}
+//	err := group.Wait()
+//
+// Unlike errgroup, Wait does not cancel the context when every function
+// succeeds, since Sandboxes created through the Group keep using it. Such
+// Sandboxes are left running, and can be returned to the caller.
+type Group struct {
+	ctx     context.Context
+	cancel  context.CancelCauseFunc
+	stop    func() bool   // stops the cleanup registered on ctx
+	cleaned chan struct{} // closed once the cleanup has finished
+	wg      sync.WaitGroup
+	waited  sync.Once // stops the cleanup once, on the first call to Wait
+	stopped bool      // whether Wait stopped the cleanup before it started
+
+	mu        sync.Mutex
+	err       error // first error returned by a function
+	failed    bool  // set once the cleanup has started
+	sandboxes []*Sandbox
+	orphans   []error // Sandboxes that could not be terminated
+}
+
+// NewGroup returns a new Group, and a context derived from ctx that is
+// cancelled when a function in the Group fails.
+func NewGroup(ctx context.Context) (*Group, context.Context) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	g := &Group{ctx: ctx, cancel: cancel, cleaned: make(chan struct{})}
+	g.stop = context.AfterFunc(ctx, g.cleanup)
+	return g, ctx
+}
+
+// Go runs fn in a new goroutine with the Group's context. The first error
+// returned by a function cancels the context and terminates the Group's
+// Sandboxes, and is returned by Wait.
+func (g *Group) Go(fn func(ctx context.Context) error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if err := fn(g.ctx); err != nil {
+			g.mu.Lock()
+			if g.err == nil {
+				g.err = err
+			}
+			g.mu.Unlock()
+			g.cancel(err)
+		}
+	}()
+}
+
+// CreateSandbox creates a Sandbox in app with the Group's context, like
+// App.CreateSandbox, and adds it to the Group, unless SandboxOptions.Detach is
+// set.
+func (g *Group) CreateSandbox(app *App, image *Image, options *SandboxOptions) (*Sandbox, error) {
+	app, err := app.WithContext(g.ctx)
+	if err != nil {
+		return nil, err
+	}
+	sb, err := app.CreateSandbox(image, options)
+	if err != nil {
+		return nil, err
+	}
+	if sb.detached {
+		return sb, nil
+	}
+	if err := g.Add(sb); err != nil {
+		return nil, err
+	}
+	return sb, nil
+}
+
+// Add adds a Sandbox created elsewhere to the Group, so it is terminated if
+// the Group fails. If the Group has already failed, the Sandbox is terminated
+// right away, and the context's cause is returned.
+func (g *Group) Add(sb *Sandbox) error {
+	g.mu.Lock()
+	if !g.failed {
+		g.sandboxes = append(g.sandboxes, sb)
+		g.mu.Unlock()
+		return nil
+	}
+	g.mu.Unlock()
+	g.terminate(sb)
+	return context.Cause(g.ctx)
+}
+
+// Exec runs command in sb, like Sandbox.Exec, and waits for it to exit in a
+// function of the Group, which fails if the command exits with a non-zero
+// code. The process's output can be read as usual.
+func (g *Group) Exec(sb *Sandbox, command []string, opts ExecOptions) (*ContainerProcess, error) {
+	cp, err := sb.Exec(command, opts)
+	if err != nil {
+		return nil, err
+	}
+	g.Go(func(ctx context.Context) error {
+		exitCode, err := cp.Wait()
+		if err != nil {
+			return err
+		}
+		if exitCode != 0 {
+			return fmt.Errorf("command %q in Sandbox %s exited with code %d", command, sb.SandboxId, exitCode)
+		}
+		return nil
+	})
+	return cp, nil
+}
+
+// Wait waits for all functions in the Group to return, and for the cleanup if
+// the Group failed. It returns the first error returned by a function, or the
+// cause of the parent context's cancellation, joined with an
+// OrphanedSandboxError for each Sandbox that could not be terminated. Later
+// calls return the same result.
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.waited.Do(func() { g.stopped = g.stop() })
+	if g.stopped {
+		return nil // not cancelled, nothing to clean up
+	}
+	<-g.cleaned
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	err := g.err
+	if err == nil {
+		err = context.Cause(g.ctx)
+	}
+	if len(g.orphans) == 0 {
+		return err
+	}
+	return errors.Join(append([]error{err}, g.orphans...)...)
+}
+
+// cleanup terminates the Group's Sandboxes once its context is cancelled.
+func (g *Group) cleanup() {
+	defer close(g.cleaned)
+	g.mu.Lock()
+	g.failed = true
+	sandboxes := g.sandboxes
+	g.sandboxes = nil
+	g.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, sb := range sandboxes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g.terminate(sb)
+		}()
+	}
+	wg.Wait()
+}
+
+// terminate terminates a Sandbox of the failed Group, and records it if it is
+// still running.
+func (g *Group) terminate(sb *Sandbox) {
+	var orphaned OrphanedSandboxError
+	if err := sb.discard(context.Cause(g.ctx), false); errors.As(err, &orphaned) {
+		g.mu.Lock()
+		g.orphans = append(g.orphans, orphaned)
+		g.mu.Unlock()
+	}
+}
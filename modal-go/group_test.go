@@ -0,0 +1,123 @@
+package modal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+	"github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestGroup(t *testing.T) {
+	g := gomega.NewWithT(t)
+	var mu sync.Mutex
+	created := 0
+	var terminated []string
+	fake := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		mu.Lock()
+		defer mu.Unlock()
+		switch method {
+		case "/modal.client.ModalClient/SandboxCreate":
+			created++
+			proto.Merge(reply.(proto.Message), pb.SandboxCreateResponse_builder{SandboxId: fmt.Sprintf("sb-%d", created)}.Build())
+		case "/modal.client.ModalClient/SandboxTerminate":
+			terminated = append(terminated, req.(*pb.SandboxTerminateRequest).GetSandboxId())
+		}
+		return nil
+	}
+	useFakeClient(t, fake)
+	app := newApp(context.Background(), "ap-123")
+	image := &Image{ImageId: "im-123"}
+	quiet := &SandboxOptions{Stdout: Ignore, Stderr: Ignore} // no log streams outliving the fake client
+
+	// Sandboxes are kept when every function succeeds.
+	group, ctx := NewGroup(context.Background())
+	for range 2 {
+		group.Go(func(ctx context.Context) error {
+			_, err := group.CreateSandbox(app, image, quiet)
+			return err
+		})
+	}
+	g.Expect(group.Wait()).To(gomega.Succeed())
+	g.Expect(ctx.Err()).ShouldNot(gomega.HaveOccurred())
+	g.Expect(created).To(gomega.Equal(2))
+	g.Expect(terminated).To(gomega.BeEmpty())
+
+	// A failure terminates them.
+	failure := errors.New("shard failed")
+	created = 0
+	group, ctx = NewGroup(context.Background())
+	sandboxCreated := make(chan struct{})
+	group.Go(func(ctx context.Context) error {
+		_, err := group.CreateSandbox(app, image, quiet)
+		close(sandboxCreated)
+		if err != nil {
+			return err
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	group.Go(func(ctx context.Context) error {
+		<-sandboxCreated
+		return failure
+	})
+	g.Expect(group.Wait()).To(gomega.Equal(failure))
+	g.Expect(context.Cause(ctx)).To(gomega.Equal(failure))
+	g.Expect(terminated).To(gomega.Equal([]string{"sb-1"}))
+
+	// Sandboxes added after a failure are terminated right away.
+	sb := newSandboxWithStdio(context.Background(), "sb-late", Ignore, Ignore)
+	g.Expect(group.Add(sb)).To(gomega.Equal(failure))
+	g.Expect(terminated).To(gomega.Equal([]string{"sb-1", "sb-late"}))
+
+	// So does cancelling the parent context.
+	terminated = nil
+	parent, cancel := context.WithCancel(context.Background())
+	group, _ = NewGroup(parent)
+	g.Expect(group.Add(newSandboxWithStdio(context.Background(), "sb-parent", Ignore, Ignore))).To(gomega.Succeed())
+	cancel()
+	g.Expect(group.Wait()).To(gomega.MatchError(context.Canceled))
+	g.Expect(terminated).To(gomega.Equal([]string{"sb-parent"}))
+
+	// Detached Sandboxes are left running, and keep a usable context.
+	terminated = nil
+	group, _ = NewGroup(context.Background())
+	var detached *Sandbox
+	group.Go(func(ctx context.Context) error {
+		var err error
+		detached, err = group.CreateSandbox(app, image, &SandboxOptions{Stdout: Ignore, Stderr: Ignore, Detach: true})
+		if err != nil {
+			return err
+		}
+		return failure
+	})
+	g.Expect(group.Wait()).To(gomega.Equal(failure))
+	g.Expect(terminated).To(gomega.BeEmpty())
+	g.Expect(detached.ctx.Err()).ShouldNot(gomega.HaveOccurred())
+}
+
+func TestGroupWaitTwice(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	failure := errors.New("shard failed")
+
+	for _, want := range []error{nil, failure} {
+		group, _ := NewGroup(context.Background())
+		group.Go(func(ctx context.Context) error { return want })
+		results := make(chan error, 2)
+		go func() {
+			results <- group.Wait()
+			results <- group.Wait()
+		}()
+		for range 2 {
+			var err error
+			g.Eventually(results).Should(gomega.Receive(&err))
+			g.Expect(errors.Is(err, want)).To(gomega.BeTrue())
+		}
+	}
+}
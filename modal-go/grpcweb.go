@@ -0,0 +1,426 @@
+package modal
+
+// A gRPC-Web transport, for networks that block HTTP/2 gRPC traffic.
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Transport is the protocol the client uses to talk to Modal.
+type Transport string
+
+const (
+	// TransportGRPC is gRPC over HTTP/2, the default.
+	TransportGRPC Transport = "grpc"
+	// TransportGRPCWeb is gRPC-Web over HTTPS, which works with HTTP/1.1 and
+	// through proxies that block or downgrade raw gRPC traffic. It only
+	// supports unary and server-streaming RPCs, which are all the SDK uses,
+	// and uncompressed messages. Modal's API does not accept gRPC-Web
+	// itself, so it needs a proxy that translates it to gRPC, in front of
+	// both the server URL and the input plane URLs that Modal returns for
+	// some Functions.
+	TransportGRPCWeb Transport = "grpc-web"
+)
+
+// grpcWebFrameTrailer marks a frame of a gRPC-Web response that holds the
+// trailers rather than a message.
+const grpcWebFrameTrailer = 0x80
+
+// grpcWebConn is a grpc.ClientConnInterface that sends RPCs as gRPC-Web
+// requests over net/http, with the same interceptors as a gRPC connection.
+type grpcWebConn struct {
+	baseURL    string // Server URL, such as "https://api.modal.com:443".
+	httpClient *http.Client
+	unary      []grpc.UnaryClientInterceptor
+	stream     []grpc.StreamClientInterceptor
+}
+
+// newGRPCWebConn returns a connection to the gRPC-Web server at serverURL.
+func newGRPCWebConn(serverURL string, unary []grpc.UnaryClientInterceptor, stream []grpc.StreamClientInterceptor) *grpcWebConn {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: insecureSkipVerify}
+	if dialer != nil {
+		transport.Proxy = nil // the dialer decides where connections go
+		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return dialer(ctx, addr)
+		}
+	}
+	return &grpcWebConn{
+		baseURL:    strings.TrimSuffix(serverURL, "/"),
+		httpClient: &http.Client{Transport: transport},
+		unary:      unary,
+		stream:     stream,
+	}
+}
+
+// Invoke sends a unary RPC through the interceptors.
+func (c *grpcWebConn) Invoke(ctx context.Context, method string, args, reply any, opts ...grpc.CallOption) error {
+	invoker := c.invoke
+	for i := len(c.unary) - 1; i >= 0; i-- {
+		interceptor, next := c.unary[i], invoker
+		invoker = func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			return interceptor(ctx, method, req, reply, cc, next, opts...)
+		}
+	}
+	return invoker(ctx, method, args, reply, nil, opts...)
+}
+
+// NewStream starts a server-streaming RPC through the interceptors.
+func (c *grpcWebConn) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	streamer := c.newStream
+	for i := len(c.stream) - 1; i >= 0; i-- {
+		interceptor, next := c.stream[i], streamer
+		streamer = func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			return interceptor(ctx, desc, cc, method, next, opts...)
+		}
+	}
+	return streamer(ctx, desc, nil, method, opts...)
+}
+
+func (c *grpcWebConn) invoke(ctx context.Context, method string, req, reply any, _ *grpc.ClientConn, opts ...grpc.CallOption) error {
+	resp, err := c.post(ctx, method, req, opts)
+	if err != nil {
+		return err
+	}
+	defer resp.Close()
+	received := false
+	for {
+		err := resp.recv(reply)
+		if err == io.EOF {
+			if !received {
+				return status.Error(codes.Internal, "gRPC-Web response has no message")
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		received = true
+	}
+}
+
+func (c *grpcWebConn) newStream(ctx context.Context, desc *grpc.StreamDesc, _ *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	if desc.ClientStreams {
+		return nil, status.Errorf(codes.Unimplemented, "gRPC-Web does not support client streaming for %s", method)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	return &grpcWebStream{conn: c, ctx: ctx, cancel: cancel, method: method, opts: opts}, nil
+}
+
+// post sends a gRPC-Web request with the message req.
+func (c *grpcWebConn) post(ctx context.Context, method string, req any, opts []grpc.CallOption) (*grpcWebResponse, error) {
+	sendLimit, recvLimit := maxSendMsgSize, maxRecvMsgSize
+	for _, opt := range opts {
+		switch opt := opt.(type) {
+		case grpc.MaxSendMsgSizeCallOption:
+			sendLimit = opt.MaxSendMsgSize
+		case grpc.MaxRecvMsgSizeCallOption:
+			recvLimit = opt.MaxRecvMsgSize
+		}
+	}
+	msg, err := proto.Marshal(req.(proto.Message))
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "marshaling request: %v", err)
+	}
+	if len(msg) > sendLimit {
+		return nil, status.Errorf(codes.ResourceExhausted, "trying to send message larger than max (%d vs. %d)", len(msg), sendLimit)
+	}
+	body := make([]byte, 5+len(msg))
+	binary.BigEndian.PutUint32(body[1:5], uint32(len(msg)))
+	copy(body[5:], msg)
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+method, bytes.NewReader(body))
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	httpReq.Header.Set("Content-Type", "application/grpc-web+proto")
+	httpReq.Header.Set("Accept", "application/grpc-web+proto")
+	httpReq.Header.Set("X-Grpc-Web", "1")
+	if deadline, ok := ctx.Deadline(); ok {
+		httpReq.Header.Set("Grpc-Timeout", strconv.FormatInt(max(time.Until(deadline).Milliseconds(), 1), 10)+"m")
+	}
+	md, _ := metadata.FromOutgoingContext(ctx)
+	for key, values := range md {
+		for _, value := range values {
+			if strings.HasSuffix(key, "-bin") {
+				value = base64.StdEncoding.EncodeToString([]byte(value))
+			}
+			httpReq.Header.Add(key, value)
+		}
+	}
+
+	httpResp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	resp := &grpcWebResponse{
+		body:      httpResp.Body,
+		reader:    bufio.NewReader(httpResp.Body),
+		header:    headerMetadata(httpResp.Header),
+		recvLimit: recvLimit,
+	}
+	for _, opt := range opts {
+		if opt, ok := opt.(grpc.HeaderCallOption); ok {
+			*opt.HeaderAddr = resp.header
+		}
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		resp.Close()
+		return nil, status.Errorf(httpStatusCode(httpResp.StatusCode), "gRPC-Web request failed with HTTP status %s", httpResp.Status)
+	}
+	resp.opts = opts
+	if httpResp.Header.Get("Grpc-Status") != "" {
+		// A trailers-only response, with the status in the headers.
+		resp.Close()
+		resp.trailer = resp.header
+		resp.setTrailer()
+		if resp.err = trailerStatus(resp.trailer); resp.err == nil {
+			resp.err = io.EOF
+		}
+		if resp.err != io.EOF {
+			return nil, resp.err
+		}
+	}
+	return resp, nil
+}
+
+// grpcWebResponse reads the frames of a gRPC-Web response body.
+type grpcWebResponse struct {
+	body      io.ReadCloser
+	reader    *bufio.Reader
+	header    metadata.MD
+	trailer   metadata.MD
+	recvLimit int
+	opts      []grpc.CallOption
+	err       error // io.EOF or the RPC's error, once the trailers are read
+}
+
+// recv reads the next message into m. It returns io.EOF after the last
+// message of a successful RPC, and the RPC's error otherwise.
+func (r *grpcWebResponse) recv(m any) error {
+	if r.err != nil {
+		return r.err
+	}
+	var prefix [5]byte
+	if _, err := io.ReadFull(r.reader, prefix[:]); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			r.err = status.Error(codes.Unavailable, "gRPC-Web response ended without trailers")
+		} else {
+			r.err = status.Error(codes.Unavailable, err.Error())
+		}
+		return r.err
+	}
+	flags, length := prefix[0], int(binary.BigEndian.Uint32(prefix[1:]))
+	if length > r.recvLimit {
+		r.err = status.Errorf(codes.ResourceExhausted, "received message larger than max (%d vs. %d)", length, r.recvLimit)
+		return r.err
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r.reader, data); err != nil {
+		r.err = status.Error(codes.Unavailable, err.Error())
+		return r.err
+	}
+	if flags&grpcWebFrameTrailer != 0 {
+		r.trailer = parseTrailerFrame(data)
+		r.setTrailer()
+		r.err = trailerStatus(r.trailer)
+		if r.err == nil {
+			r.err = io.EOF
+		}
+		return r.err
+	}
+	if flags&1 != 0 {
+		r.err = status.Error(codes.Internal, "compressed gRPC-Web messages are not supported")
+		return r.err
+	}
+	if err := proto.Unmarshal(data, m.(proto.Message)); err != nil {
+		r.err = status.Errorf(codes.Internal, "unmarshaling response: %v", err)
+		return r.err
+	}
+	return nil
+}
+
+// setTrailer sets the trailers of grpc.Trailer call options.
+func (r *grpcWebResponse) setTrailer() {
+	for _, opt := range r.opts {
+		if opt, ok := opt.(grpc.TrailerCallOption); ok {
+			*opt.TrailerAddr = r.trailer
+		}
+	}
+}
+
+func (r *grpcWebResponse) Close() {
+	r.body.Close()
+}
+
+// grpcWebStream is the grpc.ClientStream of a server-streaming RPC. The
+// request is sent when it is closed for sending, as generated clients do
+// right after sending the request message.
+type grpcWebStream struct {
+	conn   *grpcWebConn
+	ctx    context.Context
+	cancel context.CancelFunc
+	method string
+	opts   []grpc.CallOption
+
+	req  any
+	once sync.Once
+	resp *grpcWebResponse
+	err  error
+}
+
+func (s *grpcWebStream) start() error {
+	s.once.Do(func() {
+		if s.req == nil {
+			s.err = status.Error(codes.Internal, "no request message was sent")
+			return
+		}
+		s.resp, s.err = s.conn.post(s.ctx, s.method, s.req, s.opts)
+		if s.err != nil {
+			s.cancel()
+		}
+	})
+	return s.err
+}
+
+func (s *grpcWebStream) Header() (metadata.MD, error) {
+	if err := s.start(); err != nil {
+		return nil, err
+	}
+	return s.resp.header, nil
+}
+
+func (s *grpcWebStream) Trailer() metadata.MD {
+	if s.resp == nil {
+		return nil
+	}
+	return s.resp.trailer
+}
+
+func (s *grpcWebStream) CloseSend() error {
+	return nil // the request is sent by the first RecvMsg or Header
+}
+
+func (s *grpcWebStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *grpcWebStream) SendMsg(m any) error {
+	if s.req != nil {
+		return status.Error(codes.Internal, "gRPC-Web does not support client streaming")
+	}
+	s.req = m
+	return nil
+}
+
+func (s *grpcWebStream) RecvMsg(m any) error {
+	if err := s.start(); err != nil {
+		return err
+	}
+	err := s.resp.recv(m)
+	if err != nil {
+		s.resp.Close()
+		s.cancel()
+		if s.ctx.Err() != nil && err != io.EOF {
+			return status.FromContextError(s.ctx.Err()).Err()
+		}
+	}
+	return err
+}
+
+// headerMetadata converts HTTP headers to gRPC metadata, with lowercase keys.
+func headerMetadata(header http.Header) metadata.MD {
+	md := metadata.MD{}
+	for key, values := range header {
+		key = strings.ToLower(key)
+		for _, value := range values {
+			md.Append(key, decodeMetadataValue(key, value))
+		}
+	}
+	return md
+}
+
+// parseTrailerFrame parses the trailers of a gRPC-Web response, which are
+// sent as HTTP/1 header lines in the last frame.
+func parseTrailerFrame(data []byte) metadata.MD {
+	reader := textproto.NewReader(bufio.NewReader(io.MultiReader(bytes.NewReader(data), strings.NewReader("\r\n"))))
+	header, _ := reader.ReadMIMEHeader()
+	return headerMetadata(http.Header(header))
+}
+
+func decodeMetadataValue(key, value string) string {
+	if strings.HasSuffix(key, "-bin") {
+		if decoded, err := base64.StdEncoding.DecodeString(value); err == nil {
+			return string(decoded)
+		}
+		if decoded, err := base64.RawStdEncoding.DecodeString(value); err == nil {
+			return string(decoded)
+		}
+	}
+	return value
+}
+
+// trailerStatus returns the error of the status in trailers, or nil for OK.
+func trailerStatus(trailer metadata.MD) error {
+	values := trailer.Get("grpc-status")
+	if len(values) == 0 {
+		return status.Error(codes.Internal, "gRPC-Web response has no grpc-status")
+	}
+	code, err := strconv.Atoi(values[0])
+	if err != nil {
+		return status.Errorf(codes.Internal, "invalid grpc-status %q", values[0])
+	}
+	if codes.Code(code) == codes.OK {
+		return nil
+	}
+	var message string
+	if values := trailer.Get("grpc-message"); len(values) > 0 {
+		message, err = url.PathUnescape(values[0])
+		if err != nil {
+			message = values[0]
+		}
+	}
+	return status.Error(codes.Code(code), message)
+}
+
+// httpStatusCode maps the HTTP status of a failed gRPC-Web request to a gRPC
+// code, as gRPC does for HTTP/2.
+func httpStatusCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest:
+		return codes.Internal
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.Unimplemented
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return codes.Unavailable
+	}
+	return codes.Unknown
+}
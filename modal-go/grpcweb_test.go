@@ -0,0 +1,100 @@
+package modal
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+	"github.com/onsi/gomega"
+	"google.golang.org/protobuf/proto"
+)
+
+// grpcWebFrame returns a gRPC-Web frame with the given flags and data.
+func grpcWebFrame(flags byte, data []byte) []byte {
+	frame := make([]byte, 5, 5+len(data))
+	frame[0] = flags
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(data)))
+	return append(frame, data...)
+}
+
+// stubGRPCWebHandler is a gRPC-Web server for a few RPCs.
+func stubGRPCWebHandler(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/grpc-web+proto" || r.Header.Get("X-Modal-Token-Id") != "token-id" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/grpc-web+proto")
+		switch r.URL.Path {
+		case "/modal.client.ModalClient/AppGetOrCreate":
+			var req pb.AppGetOrCreateRequest
+			if err := proto.Unmarshal(body[5:], &req); err != nil {
+				t.Error(err)
+			}
+			msg, _ := proto.Marshal(pb.AppGetOrCreateResponse_builder{AppId: "ap-" + req.GetAppName()}.Build())
+			w.Write(grpcWebFrame(0, msg))
+			w.Write(grpcWebFrame(grpcWebFrameTrailer, []byte("grpc-status: 0\r\nx-modal-auth-token: web-token\r\n")))
+		case "/modal.client.ModalClient/AppGetLogs":
+			for _, data := range []string{"hello ", "world"} {
+				msg, _ := proto.Marshal(pb.TaskLogsBatch_builder{Items: []*pb.TaskLogs{pb.TaskLogs_builder{Data: data}.Build()}}.Build())
+				w.Write(grpcWebFrame(0, msg))
+				w.(http.Flusher).Flush()
+			}
+			w.Write(grpcWebFrame(grpcWebFrameTrailer, []byte("grpc-status: 0\r\n")))
+		default:
+			// A trailers-only response.
+			w.Header().Set("Grpc-Status", "5")
+			w.Header().Set("Grpc-Message", "no%20such%20object")
+		}
+	}
+}
+
+func TestGRPCWebTransport(t *testing.T) {
+	g := gomega.NewWithT(t)
+	server := httptest.NewServer(stubGRPCWebHandler(t))
+	defer server.Close()
+	savedProfile, savedClient, savedAuthToken := clientProfile, client, authToken
+	defer func() {
+		clientProfile, client, authToken = savedProfile, savedClient, savedAuthToken
+		transport = TransportGRPC
+	}()
+
+	err := InitializeClient(ClientOptions{Transport: "http3"})
+	g.Expect(err).To(gomega.BeAssignableToTypeOf(InvalidError{}))
+
+	err = InitializeClient(ClientOptions{
+		TokenId:     "token-id",
+		TokenSecret: "token-secret",
+		ServerURL:   server.URL,
+		Transport:   TransportGRPCWeb,
+	})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	app, err := AppLookup(context.Background(), "web", nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(app.AppId).To(gomega.Equal("ap-web"))
+	g.Expect(authToken).To(gomega.Equal("web-token"))
+
+	ctx, err := clientContext(context.Background())
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	stream, err := client.AppGetLogs(ctx, pb.AppGetLogsRequest_builder{AppId: app.AppId}.Build())
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	var logs string
+	for {
+		batch, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		g.Expect(err).ShouldNot(gomega.HaveOccurred())
+		logs += batch.GetItems()[0].GetData()
+	}
+	g.Expect(logs).To(gomega.Equal("hello world"))
+
+	_, err = SecretFromName(context.Background(), "missing", nil)
+	g.Expect(err).To(gomega.Equal(NotFoundError{"no such object"}))
+}
@@ -4,28 +4,167 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
 
 	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // Image represents a Modal image, which can be used to create sandboxes.
 type Image struct {
 	ImageId string
 
-	//lint:ignore U1000 may be used in future
-	ctx context.Context
+	ctx                context.Context
+	dockerfileCommands []string // set for Images built by this client
 }
 
-func fromRegistryInternal(app *App, tag string, imageRegistryConfig *pb.ImageRegistryConfig) (*Image, error) {
+// ObjectId returns the Image's ID, see Object.
+func (image *Image) ObjectId() string {
+	return image.ImageId
+}
+
+// WithContext returns a copy of the Image whose calls use ctx, for example to
+// apply a request's deadline and cancellation.
+func (image *Image) WithContext(ctx context.Context) (*Image, error) {
+	ctx, err := handleContext(ctx, image.ctx)
+	if err != nil {
+		return nil, err
+	}
+	c := *image
+	c.ctx = ctx
+	return &c, nil
+}
+
+// Name returns an empty string, since Images have no name.
+func (image *Image) Name() string {
+	return ""
+}
+
+// Environment returns the name of the environment the Image was built or
+// looked up in, see Object.
+func (image *Image) Environment() string {
+	return environmentName(image.ctx, "")
+}
+
+// IsHydrated reports whether the Image has an ID, see Object.
+func (image *Image) IsHydrated() bool {
+	return image.ImageId != ""
+}
+
+// Hydrate makes the Image ready for use, see Object.
+func (image *Image) Hydrate(ctx context.Context) error {
+	return hydrate(ctx, "Image", image.ImageId, &image.ctx)
+}
+
+// ImageInfo describes the contents of a built Image, see Image.Inspect.
+type ImageInfo struct {
+	ImageId        string
+	BuilderVersion string // Version of Modal's Image builder used to build it.
+	Workdir        string // Working directory, empty for the default.
+	LibcVersion    string // Version of glibc installed, if any.
+	PythonVersion  string // Output of `python -VV`, if Python is installed.
+	// Installed Python packages, as listed by `pip list`, by package name.
+	PythonPackages map[string]string
+	// Dockerfile commands the Image was built from. Only set for Images built
+	// by this client, not those looked up with ImageFromId.
+	DockerfileCommands []string
+}
+
+// Inspect returns metadata recorded by Modal when the Image was built.
+//
+// Modal does not expose registry digests, sizes, or layers of built Images.
+// The ImageId identifies the exact Image that a Sandbox ran.
+func (image *Image) Inspect() (*ImageInfo, error) {
+	resp, err := client.ImageFromId(image.ctx, pb.ImageFromIdRequest_builder{ImageId: image.ImageId}.Build())
+	if err != nil {
+		return nil, err
+	}
+	metadata := resp.GetMetadata()
+	return &ImageInfo{
+		ImageId:            image.ImageId,
+		BuilderVersion:     metadata.GetImageBuilderVersion(),
+		Workdir:            metadata.GetWorkdir(),
+		LibcVersion:        metadata.GetLibcVersionInfo(),
+		PythonVersion:      metadata.GetPythonVersionInfo(),
+		PythonPackages:     metadata.GetPythonPackages(),
+		DockerfileCommands: image.dockerfileCommands,
+	}, nil
+}
+
+// ImageFromId looks up an Image that was already built, by its ID.
+//
+// Images are cached per workspace by their definition, so an Image built by
+// one App can be used by Sandboxes of any other App in the same workspace
+// without being rebuilt.
+func ImageFromId(ctx context.Context, imageId string) (*Image, error) {
+	var err error
+	ctx, err = clientContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.ImageFromId(ctx, pb.ImageFromIdRequest_builder{ImageId: imageId}.Build())
+	if err != nil {
+		if status, ok := status.FromError(err); ok && status.Code() == codes.NotFound {
+			return nil, NotFoundError{fmt.Sprintf("Image '%s' not found", imageId)}
+		}
+		return nil, err
+	}
+	return &Image{ImageId: resp.GetImageId(), ctx: context.WithoutCancel(ctx)}, nil
+}
+
+// Maximum number of bytes of build logs retained for error reporting.
+const imageBuildLogsMaxBytes = 16 * 1024
+
+// buildLogs keeps the tail of an Image build's log output.
+type buildLogs struct {
+	sb strings.Builder
+}
+
+func (b *buildLogs) append(data string) {
+	b.sb.WriteString(data)
+	if b.sb.Len() > 2*imageBuildLogsMaxBytes {
+		tail := b.String()
+		b.sb.Reset()
+		b.sb.WriteString(tail)
+	}
+}
+
+// String returns up to imageBuildLogsMaxBytes of the most recent log output.
+func (b *buildLogs) String() string {
+	s := b.sb.String()
+	if len(s) > imageBuildLogsMaxBytes {
+		s = s[len(s)-imageBuildLogsMaxBytes:]
+	}
+	return s
+}
+
+func fromRegistryInternal(app *App, tag string, imageRegistryConfig *pb.ImageRegistryConfig, timeout time.Duration, builderVersion string) (*Image, error) {
+	return buildImage(app, pb.Image_builder{
+		DockerfileCommands:  []string{`FROM ` + tag},
+		ImageRegistryConfig: imageRegistryConfig,
+	}.Build(), timeout, builderVersion)
+}
+
+// buildImage creates an Image from its definition, and waits for the build.
+// builderVersion is the image builder version to build with, if not the
+// default.
+func buildImage(app *App, image *pb.Image, timeout time.Duration, builderVersion string) (*Image, error) {
+	builderVersion, err := imageBuilderVersion(app.ctx, app.Environment(), builderVersion)
+	if err != nil {
+		return nil, err
+	}
 	resp, err := client.ImageGetOrCreate(
 		app.ctx,
 		pb.ImageGetOrCreateRequest_builder{
-			AppId: app.AppId,
-			Image: pb.Image_builder{
-				DockerfileCommands:  []string{`FROM ` + tag},
-				ImageRegistryConfig: imageRegistryConfig,
-			}.Build(),
-			BuilderVersion: imageBuilderVersion(""),
+			AppId:          app.AppId,
+			Image:          image,
+			BuilderVersion: builderVersion,
 		}.Build(),
 	)
 	if err != nil {
@@ -40,14 +179,32 @@ func fromRegistryInternal(app *App, tag string, imageRegistryConfig *pb.ImageReg
 		metadata = resp.GetMetadata()
 	} else {
 		// Not built or in the process of building - wait for build
+		ctx := app.ctx
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		var logs buildLogs
 		lastEntryId := ""
+		reconnector := newStreamReconnector()
 		for result == nil {
-			stream, err := client.ImageJoinStreaming(app.ctx, pb.ImageJoinStreamingRequest_builder{
+			pollTimeout := 55 * time.Second
+			if deadline, ok := ctx.Deadline(); ok {
+				pollTimeout = min(pollTimeout, max(time.Until(deadline), time.Second))
+			}
+			stream, err := client.ImageJoinStreaming(ctx, pb.ImageJoinStreamingRequest_builder{
 				ImageId:     resp.GetImageId(),
-				Timeout:     55,
+				Timeout:     float32(pollTimeout.Seconds()),
 				LastEntryId: lastEntryId,
 			}.Build())
 			if err != nil {
+				if ctx.Err() != nil {
+					return nil, imageBuildInterrupted(resp.GetImageId(), ctx.Err(), &logs)
+				}
+				if reconnector.retry(ctx, err) {
+					continue
+				}
 				return nil, err
 			}
 			for {
@@ -56,8 +213,15 @@ func fromRegistryInternal(app *App, tag string, imageRegistryConfig *pb.ImageReg
 					if err == io.EOF {
 						break
 					}
+					if ctx.Err() != nil {
+						return nil, imageBuildInterrupted(resp.GetImageId(), ctx.Err(), &logs)
+					}
+					if reconnector.retry(ctx, err) {
+						break
+					}
 					return nil, err
 				}
+				reconnector.progress()
 				if item.GetEntryId() != "" {
 					lastEntryId = item.GetEntryId()
 				}
@@ -66,7 +230,10 @@ func fromRegistryInternal(app *App, tag string, imageRegistryConfig *pb.ImageReg
 					metadata = item.GetMetadata()
 					break
 				}
-				// Ignore all log lines and progress updates.
+				// Keep log lines for error reporting, ignore progress updates.
+				for _, taskLog := range item.GetTaskLogs() {
+					logs.append(taskLog.GetData())
+				}
 			}
 		}
 	}
@@ -75,6 +242,9 @@ func fromRegistryInternal(app *App, tag string, imageRegistryConfig *pb.ImageReg
 
 	switch result.GetStatus() {
 	case pb.GenericResult_GENERIC_STATUS_FAILURE:
+		if err := imagePullFailure(resp.GetImageId(), image, result.GetException()); err != nil {
+			return nil, err
+		}
 		return nil, RemoteError{fmt.Sprintf("Image build for %s failed with the exception:\n%s", resp.GetImageId(), result.GetException())}
 	case pb.GenericResult_GENERIC_STATUS_TERMINATED:
 		return nil, RemoteError{fmt.Sprintf("Image build for %s terminated due to external shut-down, please try again", resp.GetImageId())}
@@ -87,8 +257,95 @@ func fromRegistryInternal(app *App, tag string, imageRegistryConfig *pb.ImageReg
 	}
 
 	img := &Image{
-		ImageId: resp.GetImageId(),
-		ctx:     app.ctx,
+		ImageId:            resp.GetImageId(),
+		ctx:                app.ctx,
+		dockerfileCommands: image.GetDockerfileCommands(),
 	}
 	return img, nil
 }
+
+// imageBuildInterrupted builds the error returned when waiting on an Image
+// build is stopped by a timeout or context cancellation.
+func imageBuildInterrupted(imageId string, cause error, logs *buildLogs) error {
+	var exception string
+	if cause == context.DeadlineExceeded {
+		exception = fmt.Sprintf("Image build for %s timed out", imageId)
+	} else {
+		exception = fmt.Sprintf("Image build for %s was cancelled", imageId)
+	}
+	return ImageBuildError{Exception: exception, ImageId: imageId, Logs: logs.String(), cause: cause}
+}
+
+var runtimePattern = regexp.MustCompile(`^(python|node|go)(\d+(?:\.\d+)*)$`)
+
+// runtimeDockerfile returns the Dockerfile commands of ImageForRuntime.
+func runtimeDockerfile(runtime string, packages []string) ([]string, error) {
+	m := runtimePattern.FindStringSubmatch(runtime)
+	if m == nil {
+		return nil, InvalidError{fmt.Sprintf("invalid runtime %q, expected a language and version such as \"python3.12\", \"node20\", or \"go1.23\"", runtime)}
+	}
+	language, version := m[1], m[2]
+	quoted := make([]string, len(packages))
+	for i, pkg := range packages {
+		if pkg == "" {
+			return nil, InvalidError{"package names must not be empty"}
+		}
+		quoted[i] = shellQuote(pkg)
+	}
+	args := strings.Join(quoted, " ")
+
+	var commands []string
+	switch language {
+	case "python":
+		commands = []string{`FROM python:` + version + `-slim`}
+		if len(packages) > 0 {
+			commands = append(commands, `RUN pip install --no-cache-dir `+args)
+		}
+	case "node":
+		commands = []string{
+			`FROM node:` + version + `-slim`,
+			`ENV NODE_PATH=/usr/local/lib/node_modules`,
+		}
+		if len(packages) > 0 {
+			commands = append(commands, `RUN npm install --global `+args)
+		}
+	case "go":
+		commands = []string{
+			`FROM golang:` + version,
+			`WORKDIR /app`,
+			`RUN go mod init sandbox`,
+		}
+		if len(packages) > 0 {
+			commands = append(commands, `RUN go get `+args)
+		}
+	}
+	return commands, nil
+}
+
+// shellQuote quotes s as a single word for sh.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// goBinaryPath is where ImageFromGoBinary installs the compiled binary.
+const goBinaryPath = "/usr/local/bin/app"
+
+// crossCompileGo builds the Go package at pkgPath for linux/amd64 as a static
+// binary, and returns its contents.
+func crossCompileGo(ctx context.Context, dir, pkgPath string, buildFlags []string) ([]byte, error) {
+	tmpDir, err := os.MkdirTemp("", "modal-go-build-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+	output := filepath.Join(tmpDir, "app")
+
+	args := append([]string{"build", "-o", output}, buildFlags...)
+	cmd := exec.CommandContext(ctx, "go", append(args, pkgPath)...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOOS=linux", "GOARCH=amd64", "CGO_ENABLED=0")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("failed to build Go package %s: %w\n%s", pkgPath, err, out)
+	}
+	return os.ReadFile(output)
+}
@@ -0,0 +1,249 @@
+package modal
+
+// Building Images from Dockerfile commands with a local build context.
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+)
+
+// Number of files of a build context uploaded at once.
+const contextUploadConcurrency = 8
+
+// ImageFromContextOptions are options for App.ImageFromContext.
+type ImageFromContextOptions struct {
+	// Exclude skips files and directories of the context whose base name, or
+	// slash-separated path relative to the context directory, matches any of
+	// these path.Match patterns, such as ".git" or "*.pyc".
+	Exclude []string
+	// Progress, if set, is called as the context is uploaded, with the number
+	// of bytes uploaded so far and the total size of the context. Files that
+	// Modal already has count as uploaded without being sent. Calls are not
+	// concurrent.
+	Progress func(uploaded, total int64)
+	Timeout  time.Duration // Maximum time to wait for the Image build, defaults to no limit.
+	// BuilderVersion is the image builder version to build with, see
+	// ImageFromRegistryOptions.BuilderVersion.
+	BuilderVersion string
+}
+
+// ImageFromContext builds an Image from Dockerfile commands, such as
+// `FROM python:3.12` and `COPY . /app`, with the files of the local directory
+// contextDir as the build context.
+//
+// Files are uploaded concurrently, and files larger than a few MiB are sent
+// to blob storage in parts, each retried on transient failures. Uploads are
+// addressed by content, so files that Modal already has are not sent again:
+// if an upload fails, calling ImageFromContext again only sends the files
+// that are still missing. Symbolic links to files are uploaded as copies of
+// their targets, and other symbolic links are skipped.
+func (app *App) ImageFromContext(contextDir string, dockerfileCommands []string, options *ImageFromContextOptions) (*Image, error) {
+	if options == nil {
+		options = &ImageFromContextOptions{}
+	}
+	if len(dockerfileCommands) == 0 {
+		return nil, InvalidError{"Dockerfile commands must not be empty"}
+	}
+	exclude, err := excludePatterns(options.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	files, err := contextFiles(contextDir, exclude)
+	if err != nil {
+		return nil, err
+	}
+	mountId, err := uploadContext(app.ctx, app.AppId, files, options.Progress)
+	if err != nil {
+		return nil, err
+	}
+	return buildImage(app, pb.Image_builder{
+		DockerfileCommands: dockerfileCommands,
+		ContextMountId:     mountId,
+	}.Build(), options.Timeout, options.BuilderVersion)
+}
+
+// contextFile is a file of a build context.
+type contextFile struct {
+	localPath  string
+	remotePath string // absolute, slash-separated path in the context Mount
+	size       int64
+	mode       fs.FileMode
+}
+
+// contextFiles returns the regular files in dir, and the targets of symbolic
+// links to regular files, other than those matching exclude.
+func contextFiles(dir string, exclude []string) ([]contextFile, error) {
+	var files []contextFile
+	err := filepath.WalkDir(dir, func(localPath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, localPath)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			return nil
+		}
+		if excludedPath(exclude, rel) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		info, err := os.Stat(localPath) // follows symbolic links
+		if err != nil || !info.Mode().IsRegular() {
+			return nil // broken links, links to directories, devices, etc.
+		}
+		files = append(files, contextFile{
+			localPath:  localPath,
+			remotePath: path.Join("/", rel),
+			size:       info.Size(),
+			mode:       info.Mode().Perm(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read build context: %w", err)
+	}
+	return files, nil
+}
+
+// uploadContext uploads files, and returns the ID of a Mount of them owned by
+// the App.
+func uploadContext(ctx context.Context, appId string, files []contextFile, progress func(uploaded, total int64)) (string, error) {
+	var total int64
+	for _, file := range files {
+		total += file.size
+	}
+	var mu sync.Mutex
+	var uploaded int64
+	report := func(n int64) {
+		if progress == nil {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		uploaded += n
+		progress(uploaded, total)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	mountFiles := make([]*pb.MountFile, len(files))
+	inFlight := make(chan struct{}, contextUploadConcurrency)
+	var wg sync.WaitGroup
+	var firstErr error
+	for i, file := range files {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			inFlight <- struct{}{}
+			defer func() { <-inFlight }()
+			if ctx.Err() != nil {
+				return // another file failed
+			}
+			mountFile, err := uploadContextFile(ctx, file, report)
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+				cancel()
+				return
+			}
+			mountFiles[i] = mountFile
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return "", firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	resp, err := client.MountGetOrCreate(ctx, pb.MountGetOrCreateRequest_builder{
+		ObjectCreationType: pb.ObjectCreationType_OBJECT_CREATION_TYPE_ANONYMOUS_OWNED_BY_APP,
+		AppId:              appId,
+		Files:              mountFiles,
+	}.Build())
+	if err != nil {
+		return "", fmt.Errorf("failed to create build context: %w", err)
+	}
+	return resp.GetMountId(), nil
+}
+
+// uploadContextFile uploads a file of a build context unless Modal already
+// has its content, and returns its entry in the context Mount.
+func uploadContextFile(ctx context.Context, file contextFile, progress func(n int64)) (*pb.MountFile, error) {
+	f, err := os.Open(file.localPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	md5Hash, sha256Hash := md5.New(), sha256.New()
+	size, err := io.Copy(io.MultiWriter(md5Hash, sha256Hash), f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file.localPath, err)
+	}
+	sha256Hex := hex.EncodeToString(sha256Hash.Sum(nil))
+
+	resp, err := client.MountPutFile(ctx, pb.MountPutFileRequest_builder{
+		Sha256Hex: sha256Hex,
+	}.Build())
+	if err != nil {
+		return nil, err
+	}
+	if resp.GetExists() {
+		progress(size)
+	} else {
+		// Blob uploads report their progress by part, others once sent.
+		req := pb.MountPutFileRequest_builder{Sha256Hex: sha256Hex}.Build()
+		blob := size > int64(maxObjectSizeBytes)
+		if blob {
+			blobId, err := blobUploadFrom(ctx, f, size, md5Hash.Sum(nil), sha256Hash.Sum(nil), progress)
+			if err != nil {
+				return nil, fmt.Errorf("failed to upload %s: %w", file.localPath, err)
+			}
+			req.SetDataBlobId(blobId)
+		} else {
+			data := make([]byte, size)
+			if _, err := f.ReadAt(data, 0); err != nil && err != io.EOF {
+				return nil, fmt.Errorf("failed to read %s: %w", file.localPath, err)
+			}
+			req.SetData(data)
+		}
+		if _, err := client.MountPutFile(ctx, req); err != nil {
+			return nil, fmt.Errorf("failed to upload %s: %w", file.localPath, err)
+		}
+		if !blob {
+			progress(size)
+		}
+	}
+
+	fileSize, mode := uint64(size), uint32(file.mode)
+	return pb.MountFile_builder{
+		Filename:  file.remotePath,
+		Sha256Hex: sha256Hex,
+		Size:      &fileSize,
+		Mode:      &mode,
+	}.Build(), nil
+}
@@ -0,0 +1,73 @@
+package modal
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+	"github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestUploadContext(t *testing.T) {
+	g := gomega.NewWithT(t)
+	dir := t.TempDir()
+	g.Expect(os.MkdirAll(filepath.Join(dir, "src"), 0o755)).To(gomega.Succeed())
+	g.Expect(os.MkdirAll(filepath.Join(dir, ".git"), 0o755)).To(gomega.Succeed())
+	g.Expect(os.WriteFile(filepath.Join(dir, "Makefile"), []byte("all:\n"), 0o644)).To(gomega.Succeed())
+	g.Expect(os.WriteFile(filepath.Join(dir, "src", "main.py"), []byte("print(1)\n"), 0o755)).To(gomega.Succeed())
+	g.Expect(os.WriteFile(filepath.Join(dir, "src", "main.pyc"), []byte("x"), 0o644)).To(gomega.Succeed())
+	g.Expect(os.WriteFile(filepath.Join(dir, ".git", "HEAD"), []byte("ref"), 0o644)).To(gomega.Succeed())
+
+	files, err := contextFiles(dir, []string{".git", "*.pyc"})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	var paths []string
+	for _, file := range files {
+		paths = append(paths, file.remotePath)
+	}
+	g.Expect(paths).To(gomega.ConsistOf("/Makefile", "/src/main.py"))
+
+	sum := sha256.Sum256([]byte("all:\n"))
+	makefileSha256 := hex.EncodeToString(sum[:])
+	var sent []string
+	var mount *pb.MountGetOrCreateRequest
+	fake := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		switch method {
+		case "/modal.client.ModalClient/MountPutFile":
+			put := req.(*pb.MountPutFileRequest)
+			if len(put.GetData()) > 0 {
+				sent = append(sent, string(put.GetData()))
+			} else if put.GetSha256Hex() == makefileSha256 {
+				// Modal already has the Makefile from an earlier upload.
+				proto.Merge(reply.(proto.Message), pb.MountPutFileResponse_builder{Exists: true}.Build())
+			}
+		case "/modal.client.ModalClient/MountGetOrCreate":
+			mount = req.(*pb.MountGetOrCreateRequest)
+			proto.Merge(reply.(proto.Message), pb.MountGetOrCreateResponse_builder{MountId: "mo-123"}.Build())
+		}
+		return nil
+	}
+	useFakeClient(t, fake)
+
+	var reported [][2]int64
+	mountId, err := uploadContext(context.Background(), "ap-123", files, func(uploaded, total int64) {
+		reported = append(reported, [2]int64{uploaded, total})
+	})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(mountId).To(gomega.Equal("mo-123"))
+	g.Expect(sent).To(gomega.Equal([]string{"print(1)\n"}))
+	g.Expect(mount.GetAppId()).To(gomega.Equal("ap-123"))
+	g.Expect(mount.GetFiles()).To(gomega.HaveLen(2))
+	for _, file := range mount.GetFiles() {
+		if file.GetFilename() == "/src/main.py" {
+			g.Expect(file.GetMode()).To(gomega.Equal(uint32(0o755)))
+		}
+	}
+	g.Expect(reported).To(gomega.HaveLen(2))
+	g.Expect(reported[1]).To(gomega.Equal([2]int64{14, 14}))
+}
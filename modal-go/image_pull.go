@@ -0,0 +1,126 @@
+package modal
+
+// Classification of registry pull failures in Image builds.
+
+import (
+	"fmt"
+	"strings"
+
+	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+)
+
+// ImagePullReason is why pulling an Image's base image failed, see
+// ImagePullError.
+type ImagePullReason string
+
+// Reasons for pull failures.
+const (
+	ImagePullUnauthorized ImagePullReason = "unauthorized" // The registry rejected the credentials, or none were given.
+	ImagePullNotFound     ImagePullReason = "not-found"    // The repository or tag does not exist.
+	ImagePullRateLimited  ImagePullReason = "rate-limited" // The registry limits pulls, as Docker Hub does for anonymous users.
+)
+
+// RegistryAuthType is how an Image build authenticates to a registry.
+type RegistryAuthType string
+
+// Registry authentication types.
+const (
+	RegistryAuthPublic RegistryAuthType = "public" // No credentials, from ImageFromRegistry without a Secret.
+	RegistryAuthStatic RegistryAuthType = "static" // Username and password, from ImageFromRegistryOptions.Secret.
+	RegistryAuthAWS    RegistryAuthType = "aws"    // AWS credentials, from ImageFromAwsEcr.
+	RegistryAuthGCP    RegistryAuthType = "gcp"    // A service account, from ImageFromGcpArtifactRegistry.
+)
+
+func registryAuthTypeFromProto(config *pb.ImageRegistryConfig) RegistryAuthType {
+	switch config.GetRegistryAuthType() {
+	case pb.RegistryAuthType_REGISTRY_AUTH_TYPE_STATIC_CREDS:
+		return RegistryAuthStatic
+	case pb.RegistryAuthType_REGISTRY_AUTH_TYPE_AWS:
+		return RegistryAuthAWS
+	case pb.RegistryAuthType_REGISTRY_AUTH_TYPE_GCP:
+		return RegistryAuthGCP
+	}
+	return RegistryAuthPublic
+}
+
+// imagePullFailure returns an ImagePullError if the failed build of image, a
+// registry Image, failed to pull its base image, and nil otherwise.
+func imagePullFailure(imageId string, image *pb.Image, exception string) error {
+	commands := image.GetDockerfileCommands()
+	if len(commands) != 1 || !strings.HasPrefix(commands[0], "FROM ") {
+		return nil // not only a pull, other failures may look alike
+	}
+	reason, ok := classifyPullFailure(exception)
+	if !ok {
+		return nil
+	}
+	tag := strings.TrimSpace(strings.TrimPrefix(commands[0], "FROM "))
+	registry := registryHost(tag)
+	authType := registryAuthTypeFromProto(image.GetImageRegistryConfig())
+	return ImagePullError{
+		Exception: fmt.Sprintf("failed to pull %s from %s (%s): %s\n%s", tag, registry, reason, strings.TrimSpace(exception), imagePullHint(reason, authType)),
+		ImageId:   imageId,
+		Tag:       tag,
+		Registry:  registry,
+		Reason:    reason,
+		AuthType:  authType,
+	}
+}
+
+// classifyPullFailure returns the reason for a pull failure reported by the
+// build, from the messages of common registries.
+func classifyPullFailure(exception string) (ImagePullReason, bool) {
+	msg := strings.ToLower(exception)
+	containsAny := func(patterns ...string) bool {
+		for _, p := range patterns {
+			if strings.Contains(msg, p) {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case containsAny("toomanyrequests", "rate limit"):
+		return ImagePullRateLimited, true
+	case containsAny("unauthorized", "authentication required", "no basic auth credentials", "401", "403 forbidden"):
+		return ImagePullUnauthorized, true
+	case containsAny("manifest unknown", "name unknown", "not found", "does not exist"):
+		return ImagePullNotFound, true
+	case containsAny("denied"):
+		return ImagePullUnauthorized, true
+	}
+	return "", false
+}
+
+// registryHost returns the registry of an image tag, following Docker's rule
+// that the first component is a host if it has a dot or port, or is localhost.
+func registryHost(tag string) string {
+	first, _, found := strings.Cut(tag, "/")
+	if found && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		return first
+	}
+	return "docker.io"
+}
+
+// imagePullHint returns how to fix a pull failure, including the keys the
+// Secret of each authentication type must have.
+func imagePullHint(reason ImagePullReason, authType RegistryAuthType) string {
+	switch reason {
+	case ImagePullRateLimited:
+		return "Pull as a registry user by passing a Secret with REGISTRY_USERNAME and REGISTRY_PASSWORD in ImageFromRegistryOptions, or mirror the image to another registry."
+	case ImagePullNotFound:
+		if authType == RegistryAuthPublic {
+			return "Check the repository name and tag. Private repositories are also reported as not found without credentials: pass a Secret with REGISTRY_USERNAME and REGISTRY_PASSWORD in ImageFromRegistryOptions."
+		}
+		return "Check the repository name and tag, and that the credentials in the Secret can see the repository."
+	}
+	switch authType {
+	case RegistryAuthStatic:
+		return "Check the REGISTRY_USERNAME and REGISTRY_PASSWORD keys of the Secret. Some registries expect an access token as the password."
+	case RegistryAuthAWS:
+		return "Check the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and AWS_REGION keys of the Secret, and that the credentials may pull from the ECR repository."
+	case RegistryAuthGCP:
+		return "Check the SERVICE_ACCOUNT_JSON key of the Secret, and that the service account may read from the Artifact Registry repository."
+	}
+	return "The image may be private: pass a Secret with REGISTRY_USERNAME and REGISTRY_PASSWORD in ImageFromRegistryOptions, or use ImageFromAwsEcr or ImageFromGcpArtifactRegistry for those registries."
+}
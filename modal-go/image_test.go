@@ -0,0 +1,192 @@
+package modal
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+	"github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestBuildLogsKeepsTail(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	var logs buildLogs
+	g.Expect(logs.String()).To(gomega.BeEmpty())
+
+	logs.append("first line\n")
+	g.Expect(logs.String()).To(gomega.Equal("first line\n"))
+
+	logs.append(strings.Repeat("a", 3*imageBuildLogsMaxBytes))
+	logs.append("last line\n")
+	tail := logs.String()
+	g.Expect(tail).To(gomega.HaveLen(imageBuildLogsMaxBytes))
+	g.Expect(tail).To(gomega.HaveSuffix("alast line\n"))
+	g.Expect(tail).NotTo(gomega.ContainSubstring("first line"))
+}
+
+func TestImageBuildInterrupted(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	var logs buildLogs
+	logs.append("Step 1/3\n")
+
+	err := imageBuildInterrupted("im-123", context.DeadlineExceeded, &logs)
+	var buildErr ImageBuildError
+	g.Expect(errors.As(err, &buildErr)).To(gomega.BeTrue())
+	g.Expect(buildErr.ImageId).To(gomega.Equal("im-123"))
+	g.Expect(buildErr.Logs).To(gomega.Equal("Step 1/3\n"))
+	g.Expect(err.Error()).To(gomega.ContainSubstring("timed out"))
+	g.Expect(err.Error()).To(gomega.ContainSubstring("Step 1/3"))
+	g.Expect(errors.Is(err, context.DeadlineExceeded)).To(gomega.BeTrue())
+
+	err = imageBuildInterrupted("im-123", context.Canceled, &buildLogs{})
+	g.Expect(err.Error()).To(gomega.Equal("ImageBuildError: Image build for im-123 was cancelled"))
+	g.Expect(errors.Is(err, context.Canceled)).To(gomega.BeTrue())
+}
+
+func TestRuntimeDockerfile(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	commands, err := runtimeDockerfile("python3.12", []string{"numpy", "pandas==2.2.3"})
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(commands).To(gomega.Equal([]string{
+		`FROM python:3.12-slim`,
+		`RUN pip install --no-cache-dir 'numpy' 'pandas==2.2.3'`,
+	}))
+
+	commands, err = runtimeDockerfile("node20", nil)
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(commands).To(gomega.Equal([]string{
+		`FROM node:20-slim`,
+		`ENV NODE_PATH=/usr/local/lib/node_modules`,
+	}))
+
+	commands, err = runtimeDockerfile("go1.23", []string{"github.com/google/uuid@v1.6.0"})
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(commands).To(gomega.HaveExactElements(
+		`FROM golang:1.23`,
+		`WORKDIR /app`,
+		`RUN go mod init sandbox`,
+		`RUN go get 'github.com/google/uuid@v1.6.0'`,
+	))
+
+	commands, err = runtimeDockerfile("python3.12", []string{"it's"})
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(commands[1]).To(gomega.Equal(`RUN pip install --no-cache-dir 'it'\''s'`))
+
+	for _, runtime := range []string{"", "python", "ruby3.3", "python3.12-slim", "Python3.12"} {
+		_, err := runtimeDockerfile(runtime, nil)
+		g.Expect(err).To(gomega.BeAssignableToTypeOf(InvalidError{}), "runtime: %q", runtime)
+	}
+	_, err = runtimeDockerfile("node20", []string{""})
+	g.Expect(err).To(gomega.BeAssignableToTypeOf(InvalidError{}))
+}
+
+func TestImagePullError(t *testing.T) {
+	g := gomega.NewWithT(t)
+	fake := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if method != "/modal.client.ModalClient/ImageGetOrCreate" {
+			return nil
+		}
+		proto.Merge(reply.(proto.Message), pb.ImageGetOrCreateResponse_builder{
+			ImageId: "im-123",
+			Result: pb.GenericResult_builder{
+				Status:    pb.GenericResult_GENERIC_STATUS_FAILURE,
+				Exception: "failed to resolve reference: unexpected status: 401 Unauthorized",
+			}.Build(),
+		}.Build())
+		return nil
+	}
+	useFakeClient(t, fake)
+	app := newApp(context.Background(), "ap-123")
+
+	_, err := app.ImageFromRegistry("ghcr.io/org/private:1.0", &ImageFromRegistryOptions{Secret: &Secret{SecretId: "st-123"}})
+	var pullErr ImagePullError
+	g.Expect(errors.As(err, &pullErr)).To(gomega.BeTrue())
+	g.Expect(pullErr.ImageId).To(gomega.Equal("im-123"))
+	g.Expect(pullErr.Tag).To(gomega.Equal("ghcr.io/org/private:1.0"))
+	g.Expect(pullErr.Registry).To(gomega.Equal("ghcr.io"))
+	g.Expect(pullErr.Reason).To(gomega.Equal(ImagePullUnauthorized))
+	g.Expect(pullErr.AuthType).To(gomega.Equal(RegistryAuthStatic))
+	g.Expect(pullErr.Exception).To(gomega.ContainSubstring("REGISTRY_PASSWORD"))
+
+	_, err = app.ImageFromAwsEcr("123.dkr.ecr.us-east-1.amazonaws.com/app:1", &Secret{SecretId: "st-123"})
+	g.Expect(errors.As(err, &pullErr)).To(gomega.BeTrue())
+	g.Expect(pullErr.AuthType).To(gomega.Equal(RegistryAuthAWS))
+	g.Expect(pullErr.Exception).To(gomega.ContainSubstring("AWS_SECRET_ACCESS_KEY"))
+}
+
+func TestClassifyPullFailure(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	for exception, want := range map[string]ImagePullReason{
+		"toomanyrequests: You have reached your pull rate limit":                     ImagePullRateLimited,
+		"no basic auth credentials":                                                  ImagePullUnauthorized,
+		"denied: requested access to the resource is denied":                         ImagePullUnauthorized,
+		"manifest unknown: manifest tagged by \"9.9\" is not found":                  ImagePullNotFound,
+		"pull access denied for foo, repository does not exist or may require login": ImagePullNotFound,
+	} {
+		reason, ok := classifyPullFailure(exception)
+		g.Expect(ok).To(gomega.BeTrue(), exception)
+		g.Expect(reason).To(gomega.Equal(want), exception)
+	}
+	_, ok := classifyPullFailure("Image build failed: exit code 1")
+	g.Expect(ok).To(gomega.BeFalse())
+
+	// Builds with more than a FROM are not classified, as their commands can
+	// fail with similar messages.
+	image := pb.Image_builder{DockerfileCommands: []string{"FROM alpine", "RUN foo"}}.Build()
+	g.Expect(imagePullFailure("im-123", image, "sh: foo: not found")).To(gomega.Succeed())
+}
+
+func TestRegistryHost(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	g.Expect(registryHost("alpine:3.21")).To(gomega.Equal("docker.io"))
+	g.Expect(registryHost("library/alpine")).To(gomega.Equal("docker.io"))
+	g.Expect(registryHost("ghcr.io/org/app:1")).To(gomega.Equal("ghcr.io"))
+	g.Expect(registryHost("localhost/app")).To(gomega.Equal("localhost"))
+	g.Expect(registryHost("registry:5000/app")).To(gomega.Equal("registry:5000"))
+}
+
+func TestImageBuilderVersion(t *testing.T) {
+	g := gomega.NewWithT(t)
+	var environmentLookups int
+	var builderVersions []string
+	fake := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		switch method {
+		case "/modal.client.ModalClient/EnvironmentGetOrCreate":
+			environmentLookups++
+			g.Expect(req.(*pb.EnvironmentGetOrCreateRequest).GetDeploymentName()).To(gomega.Equal("dev"))
+			proto.Merge(reply.(proto.Message), pb.EnvironmentGetOrCreateResponse_builder{
+				Metadata: pb.EnvironmentMetadata_builder{
+					Settings: pb.EnvironmentSettings_builder{ImageBuilderVersion: "2023.12"}.Build(),
+				}.Build(),
+			}.Build())
+		case "/modal.client.ModalClient/ImageGetOrCreate":
+			builderVersions = append(builderVersions, req.(*pb.ImageGetOrCreateRequest).GetBuilderVersion())
+			proto.Merge(reply.(proto.Message), pb.ImageGetOrCreateResponse_builder{
+				ImageId: "im-123",
+				Result:  pb.GenericResult_builder{Status: pb.GenericResult_GENERIC_STATUS_SUCCESS}.Build(),
+			}.Build())
+		}
+		return nil
+	}
+	useFakeClient(t, fake)
+	clientProfile.ImageBuilderVersion = ""
+	app := newApp(WithEnvironment(context.Background(), "dev"), "ap-123")
+
+	for range 2 {
+		_, err := app.ImageFromRegistry("alpine:3.21", nil)
+		g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	}
+	_, err := app.ImageFromRegistry("alpine:3.21", &ImageFromRegistryOptions{BuilderVersion: "2024.04"})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(builderVersions).To(gomega.Equal([]string{"2023.12", "2023.12", "2024.04"}))
+	g.Expect(environmentLookups).To(gomega.Equal(1))
+}
@@ -0,0 +1,93 @@
+// Package redact hides the credentials and environment variables in the
+// proto messages that the SDK logs or records.
+package redact
+
+import (
+	"path"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// DefaultFields are patterns for request and response fields that hold
+// credentials or environment variables, matched against snake_case proto
+// field names with path.Match.
+var DefaultFields = []string{"*token*", "*secret", "*password*", "env_dict", "proxy_key"}
+
+// Placeholder replaces the values of redacted fields.
+const Placeholder = "[REDACTED]"
+
+// Match reports whether the proto field name matches any of patterns.
+func Match(name string, patterns []string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Clone returns a copy of msg in which the fields matching patterns, at any
+// depth, are redacted: strings, and the strings in lists and maps, are
+// replaced by Placeholder, and fields of other types are cleared. The copy
+// remains a valid message of the same type.
+func Clone(msg proto.Message, patterns []string) proto.Message {
+	msg = proto.Clone(msg)
+	redactFields(msg.ProtoReflect(), patterns)
+	return msg
+}
+
+func redactFields(m protoreflect.Message, patterns []string) {
+	var fields []protoreflect.FieldDescriptor
+	m.Range(func(fd protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+		fields = append(fields, fd)
+		return true
+	})
+	for _, fd := range fields {
+		if Match(string(fd.Name()), patterns) {
+			redactField(m, fd)
+			continue
+		}
+		switch {
+		case fd.IsList() && fd.Message() != nil:
+			list := m.Mutable(fd).List()
+			for i := range list.Len() {
+				redactFields(list.Get(i).Message(), patterns)
+			}
+		case fd.IsMap() && fd.MapValue().Message() != nil:
+			m.Mutable(fd).Map().Range(func(_ protoreflect.MapKey, v protoreflect.Value) bool {
+				redactFields(v.Message(), patterns)
+				return true
+			})
+		case !fd.IsList() && !fd.IsMap() && fd.Message() != nil:
+			redactFields(m.Mutable(fd).Message(), patterns)
+		}
+	}
+}
+
+func redactField(m protoreflect.Message, fd protoreflect.FieldDescriptor) {
+	placeholder := protoreflect.ValueOfString(Placeholder)
+	switch {
+	case fd.IsList() && fd.Kind() == protoreflect.StringKind:
+		list := m.Mutable(fd).List()
+		for i := range list.Len() {
+			list.Set(i, placeholder)
+		}
+	case fd.IsMap() && fd.MapValue().Kind() == protoreflect.StringKind:
+		entries := m.Mutable(fd).Map()
+		var keys []protoreflect.MapKey
+		entries.Range(func(k protoreflect.MapKey, _ protoreflect.Value) bool {
+			keys = append(keys, k)
+			return true
+		})
+		for _, k := range keys {
+			entries.Set(k, placeholder)
+		}
+	case !fd.IsList() && !fd.IsMap() && fd.Kind() == protoreflect.StringKind:
+		m.Set(fd, placeholder)
+	default:
+		m.Clear(fd)
+	}
+}
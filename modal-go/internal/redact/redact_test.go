@@ -0,0 +1,35 @@
+package redact
+
+import (
+	"testing"
+
+	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+	"github.com/onsi/gomega"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestClone(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	req := pb.SecretGetOrCreateRequest_builder{
+		EnvironmentName: "main",
+		EnvDict:         map[string]string{"API_KEY": "hunter2"},
+		RequiredKeys:    []string{"API_KEY"},
+	}.Build()
+	redacted := Clone(req, DefaultFields).(*pb.SecretGetOrCreateRequest)
+	g.Expect(redacted.GetEnvDict()).To(gomega.Equal(map[string]string{"API_KEY": Placeholder}))
+	g.Expect(redacted.GetEnvironmentName()).To(gomega.Equal("main"))
+	g.Expect(redacted.GetRequiredKeys()).To(gomega.Equal([]string{"API_KEY"}))
+	g.Expect(req.GetEnvDict()).To(gomega.HaveKeyWithValue("API_KEY", "hunter2")) // the original is unchanged
+
+	// Nested fields are redacted, and fields that are not strings cleared.
+	input := pb.FunctionPutInputsItem_builder{
+		Idx:   1,
+		Input: pb.FunctionInput_builder{Args: []byte("pickled"), MethodName: proto.String("run")}.Build(),
+	}.Build()
+	redactedInput := Clone(input, []string{"args", "method_name"}).(*pb.FunctionPutInputsItem)
+	g.Expect(redactedInput.GetIdx()).To(gomega.Equal(int32(1)))
+	g.Expect(redactedInput.GetInput().GetArgs()).To(gomega.BeEmpty())
+	g.Expect(redactedInput.GetInput().GetMethodName()).To(gomega.Equal(Placeholder))
+}
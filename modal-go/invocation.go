@@ -254,6 +254,9 @@ func blobDownload(ctx context.Context, blobId string) ([]byte, error) {
 		return nil, fmt.Errorf("failed to download blob: %w", err)
 	}
 	defer s3resp.Body.Close()
+	if s3resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download blob: status %s", s3resp.Status)
+	}
 	buf, err := io.ReadAll(s3resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read blob data: %w", err)
@@ -0,0 +1,143 @@
+package modal
+
+// Debug logging of RPCs, with sensitive fields redacted.
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/modal-labs/libmodal/modal-go/internal/redact"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// defaultRedactedFields are patterns for request and response fields that
+// hold credentials or environment variables, shared with rpcreplay.
+var defaultRedactedFields = redact.DefaultFields
+
+const redacted = redact.Placeholder
+
+var (
+	loggingMu      sync.RWMutex
+	rpcLogger      *slog.Logger // nil disables RPC logging
+	redactedFields = defaultRedactedFields
+)
+
+// setRPCLogging configures the logger and the extra redacted field patterns
+// used by loggingInterceptor.
+func setRPCLogging(logger *slog.Logger, extraRedactedFields []string) error {
+	for _, pattern := range extraRedactedFields {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return InvalidError{fmt.Sprintf("invalid redacted field pattern %q: %v", pattern, err)}
+		}
+	}
+	loggingMu.Lock()
+	defer loggingMu.Unlock()
+	rpcLogger = logger
+	redactedFields = append(append([]string{}, defaultRedactedFields...), extraRedactedFields...)
+	return nil
+}
+
+// loggingInterceptor logs unary RPCs at debug level, including their request
+// and response messages with sensitive fields redacted.
+func loggingInterceptor() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply any,
+		cc *grpc.ClientConn,
+		inv grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		loggingMu.RLock()
+		logger, patterns := rpcLogger, redactedFields
+		loggingMu.RUnlock()
+		if logger == nil || !logger.Enabled(ctx, slog.LevelDebug) {
+			return inv(ctx, method, req, reply, cc, opts...)
+		}
+
+		start := time.Now()
+		err := inv(ctx, method, req, reply, cc, opts...)
+		attrs := []slog.Attr{
+			slog.String("method", method),
+			slog.String("code", status.Code(err).String()),
+			slog.Duration("duration", time.Since(start)),
+			slog.Any("request", redactMessage(req, patterns)),
+		}
+		if err == nil {
+			attrs = append(attrs, slog.Any("response", redactMessage(reply, patterns)))
+		} else {
+			attrs = append(attrs, slog.String("error", err.Error()))
+		}
+		logger.LogAttrs(ctx, slog.LevelDebug, "modal rpc", attrs...)
+		return err
+	}
+}
+
+// redactMessage converts a proto message into maps and slices for logging.
+// Fields matching patterns are replaced by a placeholder, and bytes fields,
+// which may hold pickled arguments or file contents, by their length.
+func redactMessage(msg any, patterns []string) any {
+	m, ok := msg.(proto.Message)
+	if !ok || m == nil {
+		return nil
+	}
+	return redactFields(m.ProtoReflect(), patterns)
+}
+
+func redactFields(m protoreflect.Message, patterns []string) map[string]any {
+	out := map[string]any{}
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		name := string(fd.Name())
+		if isRedactedField(name, patterns) {
+			out[name] = redacted
+			return true
+		}
+		switch {
+		case fd.IsList():
+			list := v.List()
+			items := make([]any, list.Len())
+			for i := range items {
+				items[i] = redactValue(fd, list.Get(i), patterns)
+			}
+			out[name] = items
+		case fd.IsMap():
+			entries := map[string]any{}
+			v.Map().Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+				entries[k.String()] = redactValue(fd.MapValue(), v, patterns)
+				return true
+			})
+			out[name] = entries
+		default:
+			out[name] = redactValue(fd, v, patterns)
+		}
+		return true
+	})
+	return out
+}
+
+func redactValue(fd protoreflect.FieldDescriptor, v protoreflect.Value, patterns []string) any {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return redactFields(v.Message(), patterns)
+	case protoreflect.BytesKind:
+		return fmt.Sprintf("<%d bytes>", len(v.Bytes()))
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name())
+		}
+		return int32(v.Enum())
+	default:
+		return v.Interface()
+	}
+}
+
+func isRedactedField(name string, patterns []string) bool {
+	return redact.Match(name, patterns)
+}
@@ -0,0 +1,49 @@
+package modal
+
+import (
+	"testing"
+
+	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+	"github.com/onsi/gomega"
+)
+
+func TestRedactMessage(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	req := pb.SecretGetOrCreateRequest_builder{
+		EnvironmentName:    "main",
+		ObjectCreationType: pb.ObjectCreationType_OBJECT_CREATION_TYPE_EPHEMERAL,
+		EnvDict:            map[string]string{"API_KEY": "hunter2"},
+		RequiredKeys:       []string{"API_KEY"},
+	}.Build()
+	g.Expect(redactMessage(req, defaultRedactedFields)).To(gomega.Equal(map[string]any{
+		"environment_name":     "main",
+		"object_creation_type": "OBJECT_CREATION_TYPE_EPHEMERAL",
+		"env_dict":             redacted,
+		"required_keys":        []any{"API_KEY"},
+	}))
+
+	token := pb.TokenFlowWaitRequest_builder{TokenFlowId: "tf-123", WaitSecret: "s3cret"}.Build()
+	g.Expect(redactMessage(token, defaultRedactedFields)).To(gomega.Equal(map[string]any{
+		"token_flow_id": redacted,
+		"wait_secret":   redacted,
+	}))
+
+	input := pb.FunctionPutInputsItem_builder{
+		Idx:   1,
+		Input: pb.FunctionInput_builder{Args: []byte("pickled")}.Build(),
+	}.Build()
+	g.Expect(redactMessage(input, []string{"idx"})).To(gomega.Equal(map[string]any{
+		"idx":   redacted,
+		"input": map[string]any{"args": "<7 bytes>"},
+	}))
+}
+
+func TestSetRPCLogging(t *testing.T) {
+	g := gomega.NewWithT(t)
+	defer setRPCLogging(nil, nil)
+
+	g.Expect(setRPCLogging(nil, []string{"["})).To(gomega.BeAssignableToTypeOf(InvalidError{}))
+	g.Expect(setRPCLogging(nil, []string{"command"})).To(gomega.Succeed())
+	g.Expect(redactedFields).To(gomega.ContainElements("*token*", "command"))
+}
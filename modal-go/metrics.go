@@ -0,0 +1,97 @@
+package modal
+
+// Hooks for exporting SDK metrics to a monitoring system.
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// Metrics receives measurements of SDK operations. Implementations must be
+// safe for concurrent use and should return quickly, since they are called
+// inline with requests.
+//
+// To serve the metrics to Prometheus scrapes, pass a textformat.Exporter from
+// the modal-go/metrics/textformat package to SetMetrics. To add them to a
+// Prometheus client library registry, implement Metrics with its types.
+type Metrics interface {
+	// ObserveRPC is called when a unary RPC to Modal completes, including all
+	// retries. code is the gRPC status code name, "OK" on success.
+	ObserveRPC(method string, code string, duration time.Duration)
+	// RPCRetry is called each time an RPC is retried after a failed attempt.
+	RPCRetry(method string, code string)
+	// ObserveSandboxCreate is called when App.CreateSandbox returns.
+	ObserveSandboxCreate(duration time.Duration, err error)
+	// ActiveHeartbeats is called with the number of ephemeral Queues and
+	// Dicts that are currently being kept alive by background heartbeats.
+	ActiveHeartbeats(n int)
+}
+
+// noopMetrics discards all measurements.
+type noopMetrics struct{}
+
+func (noopMetrics) ObserveRPC(string, string, time.Duration)  {}
+func (noopMetrics) RPCRetry(string, string)                   {}
+func (noopMetrics) ObserveSandboxCreate(time.Duration, error) {}
+func (noopMetrics) ActiveHeartbeats(int)                      {}
+
+var (
+	metricsMu sync.RWMutex
+	metrics   Metrics = noopMetrics{}
+
+	// activeHeartbeats counts running ephemeral object heartbeat loops.
+	activeHeartbeats int
+)
+
+// SetMetrics installs m to receive SDK metrics. Pass nil to stop recording.
+func SetMetrics(m Metrics) {
+	if m == nil {
+		m = noopMetrics{}
+	}
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	metrics = m
+	m.ActiveHeartbeats(activeHeartbeats)
+}
+
+func currentMetrics() Metrics {
+	metricsMu.RLock()
+	defer metricsMu.RUnlock()
+	return metrics
+}
+
+// heartbeatStarted records a new heartbeat loop, and returns a function to
+// call when it stops.
+func heartbeatStarted() func() {
+	metricsMu.Lock()
+	activeHeartbeats++
+	metrics.ActiveHeartbeats(activeHeartbeats)
+	metricsMu.Unlock()
+	return func() {
+		metricsMu.Lock()
+		activeHeartbeats--
+		metrics.ActiveHeartbeats(activeHeartbeats)
+		metricsMu.Unlock()
+	}
+}
+
+// metricsInterceptor reports the latency and status of unary RPCs.
+func metricsInterceptor() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply any,
+		cc *grpc.ClientConn,
+		inv grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		start := time.Now()
+		err := inv(ctx, method, req, reply, cc, opts...)
+		currentMetrics().ObserveRPC(method, status.Code(err).String(), time.Since(start))
+		return err
+	}
+}
@@ -0,0 +1,207 @@
+// Package textformat exports Modal SDK metrics in the text exposition format
+// that Prometheus scrapes.
+//
+// An Exporter implements modal.Metrics and http.Handler, so it can be
+// installed with modal.SetMetrics and served on a scrape endpoint of its own,
+// without a dependency on a metrics library:
+//
+//	exporter := textformat.NewExporter()
+//	modal.SetMetrics(exporter)
+//	http.Handle("/modal-metrics", exporter)
+//
+// An Exporter is not a Prometheus client library Collector, and cannot be
+// registered with an existing registry. To add the SDK's metrics to one,
+// implement modal.Metrics with the library's counters and histograms instead.
+//
+// The following metrics are exported:
+//
+//   - modal_rpc_duration_seconds: histogram of unary RPC latency, including
+//     retries, labeled by method and gRPC status code.
+//   - modal_rpc_retries_total: counter of RPC retries, labeled by method and
+//     the status code of the failed attempt.
+//   - modal_sandbox_create_duration_seconds: histogram of App.CreateSandbox
+//     latency, labeled by result ("ok" or "error").
+//   - modal_active_heartbeats: gauge of ephemeral Queues and Dicts being kept
+//     alive by background heartbeats.
+package textformat
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Histogram bucket upper bounds, in seconds.
+var (
+	rpcBuckets           = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+	sandboxCreateBuckets = []float64{.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+)
+
+type histogram struct {
+	buckets []float64
+	counts  []uint64 // cumulative counts are computed when writing
+	count   uint64
+	sum     float64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+func (h *histogram) observe(v float64) {
+	for i, upper := range h.buckets {
+		if v <= upper {
+			h.counts[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+type rpcKey struct {
+	method string
+	code   string
+}
+
+func compareRPCKeys(a, b rpcKey) int {
+	return cmp.Or(cmp.Compare(a.method, b.method), cmp.Compare(a.code, b.code))
+}
+
+// Exporter records Modal SDK metrics, and serves them to Prometheus scrapes.
+// It is safe for concurrent use.
+type Exporter struct {
+	mu            sync.Mutex
+	rpcDuration   map[rpcKey]*histogram
+	rpcRetries    map[rpcKey]uint64
+	sandboxCreate map[string]*histogram // keyed by result
+	heartbeats    int
+}
+
+// NewExporter returns an Exporter with no recorded metrics.
+func NewExporter() *Exporter {
+	return &Exporter{
+		rpcDuration:   map[rpcKey]*histogram{},
+		rpcRetries:    map[rpcKey]uint64{},
+		sandboxCreate: map[string]*histogram{},
+	}
+}
+
+// ObserveRPC implements modal.Metrics.
+func (e *Exporter) ObserveRPC(method string, code string, duration time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	key := rpcKey{method, code}
+	h, ok := e.rpcDuration[key]
+	if !ok {
+		h = newHistogram(rpcBuckets)
+		e.rpcDuration[key] = h
+	}
+	h.observe(duration.Seconds())
+}
+
+// RPCRetry implements modal.Metrics.
+func (e *Exporter) RPCRetry(method string, code string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rpcRetries[rpcKey{method, code}]++
+}
+
+// ObserveSandboxCreate implements modal.Metrics.
+func (e *Exporter) ObserveSandboxCreate(duration time.Duration, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	h, ok := e.sandboxCreate[result]
+	if !ok {
+		h = newHistogram(sandboxCreateBuckets)
+		e.sandboxCreate[result] = h
+	}
+	h.observe(duration.Seconds())
+}
+
+// ActiveHeartbeats implements modal.Metrics.
+func (e *Exporter) ActiveHeartbeats(n int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.heartbeats = n
+}
+
+// ServeHTTP writes the recorded metrics in the Prometheus text format.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = e.Write(w)
+}
+
+// Write writes the recorded metrics to w in the Prometheus text format.
+func (e *Exporter) Write(w io.Writer) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintln(bw, "# HELP modal_rpc_duration_seconds Latency of unary RPCs to Modal, including retries.")
+	fmt.Fprintln(bw, "# TYPE modal_rpc_duration_seconds histogram")
+	for _, key := range slices.SortedFunc(maps.Keys(e.rpcDuration), compareRPCKeys) {
+		writeHistogram(bw, "modal_rpc_duration_seconds", labels("method", key.method, "code", key.code), e.rpcDuration[key])
+	}
+
+	fmt.Fprintln(bw, "# HELP modal_rpc_retries_total Number of RPC attempts to Modal that were retried.")
+	fmt.Fprintln(bw, "# TYPE modal_rpc_retries_total counter")
+	for _, key := range slices.SortedFunc(maps.Keys(e.rpcRetries), compareRPCKeys) {
+		fmt.Fprintf(bw, "modal_rpc_retries_total{%s} %d\n", labels("method", key.method, "code", key.code), e.rpcRetries[key])
+	}
+
+	fmt.Fprintln(bw, "# HELP modal_sandbox_create_duration_seconds Latency of Sandbox creation.")
+	fmt.Fprintln(bw, "# TYPE modal_sandbox_create_duration_seconds histogram")
+	for _, result := range slices.Sorted(maps.Keys(e.sandboxCreate)) {
+		writeHistogram(bw, "modal_sandbox_create_duration_seconds", labels("result", result), e.sandboxCreate[result])
+	}
+
+	fmt.Fprintln(bw, "# HELP modal_active_heartbeats Number of ephemeral objects kept alive by background heartbeats.")
+	fmt.Fprintln(bw, "# TYPE modal_active_heartbeats gauge")
+	fmt.Fprintf(bw, "modal_active_heartbeats %d\n", e.heartbeats)
+
+	return bw.Flush()
+}
+
+func writeHistogram(w io.Writer, name string, labels string, h *histogram) {
+	var cumulative uint64
+	for i, upper := range h.buckets {
+		cumulative += h.counts[i]
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", name, labels, formatFloat(upper), cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+	fmt.Fprintf(w, "%s_sum{%s} %s\n", name, labels, formatFloat(h.sum))
+	fmt.Fprintf(w, "%s_count{%s} %d\n", name, labels, h.count)
+}
+
+// labels formats alternating label names and values.
+func labels(pairs ...string) string {
+	parts := make([]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		parts = append(parts, pairs[i]+`="`+escapeLabelValue(pairs[i+1])+`"`)
+	}
+	return strings.Join(parts, ",")
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(v string) string {
+	return labelValueEscaper.Replace(v)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
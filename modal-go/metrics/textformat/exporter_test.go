@@ -0,0 +1,51 @@
+package textformat
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/modal-labs/libmodal/modal-go"
+	"github.com/onsi/gomega"
+)
+
+var _ modal.Metrics = (*Exporter)(nil)
+
+func TestExporter(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	e := NewExporter()
+	e.ObserveRPC("/modal.client.ModalClient/SandboxCreate", "OK", 20*time.Millisecond)
+	e.ObserveRPC("/modal.client.ModalClient/SandboxCreate", "OK", 2*time.Second)
+	e.RPCRetry("/modal.client.ModalClient/SandboxCreate", "Unavailable")
+	e.ObserveSandboxCreate(3*time.Second, nil)
+	e.ObserveSandboxCreate(time.Second, errors.New("failed"))
+	e.ActiveHeartbeats(2)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	g.Expect(rec.Header().Get("Content-Type")).To(gomega.HavePrefix("text/plain; version=0.0.4"))
+
+	lines := strings.Split(rec.Body.String(), "\n")
+	rpcLabels := `method="/modal.client.ModalClient/SandboxCreate",code="OK"`
+	g.Expect(lines).To(gomega.ContainElements(
+		`modal_rpc_duration_seconds_bucket{`+rpcLabels+`,le="0.01"} 0`,
+		`modal_rpc_duration_seconds_bucket{`+rpcLabels+`,le="0.025"} 1`,
+		`modal_rpc_duration_seconds_bucket{`+rpcLabels+`,le="2.5"} 2`,
+		`modal_rpc_duration_seconds_bucket{`+rpcLabels+`,le="+Inf"} 2`,
+		`modal_rpc_duration_seconds_sum{`+rpcLabels+`} 2.02`,
+		`modal_rpc_duration_seconds_count{`+rpcLabels+`} 2`,
+		`modal_rpc_retries_total{method="/modal.client.ModalClient/SandboxCreate",code="Unavailable"} 1`,
+		`modal_sandbox_create_duration_seconds_count{result="error"} 1`,
+		`modal_sandbox_create_duration_seconds_bucket{result="ok",le="2.5"} 0`,
+		`modal_sandbox_create_duration_seconds_bucket{result="ok",le="5"} 1`,
+		`modal_active_heartbeats 2`,
+	))
+}
+
+func TestEscapeLabelValue(t *testing.T) {
+	g := gomega.NewWithT(t)
+	g.Expect(escapeLabelValue("a\"b\\c\nd")).To(gomega.Equal(`a\"b\\c\nd`))
+}
@@ -0,0 +1,35 @@
+package modal
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+type recordingMetrics struct {
+	noopMetrics
+	mu         sync.Mutex
+	heartbeats []int
+}
+
+func (m *recordingMetrics) ActiveHeartbeats(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.heartbeats = append(m.heartbeats, n)
+}
+
+func TestMetricsActiveHeartbeats(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	m := &recordingMetrics{}
+	SetMetrics(m)
+	defer SetMetrics(nil)
+
+	stop1 := heartbeatStarted()
+	stop2 := heartbeatStarted()
+	stop1()
+	stop2()
+
+	g.Expect(m.heartbeats).To(gomega.Equal([]int{0, 1, 2, 1, 0}))
+}
@@ -0,0 +1,183 @@
+// Package modaltest injects faults into the RPCs between the Modal SDK and
+// Modal, so tests can check how code built on the SDK copes with errors,
+// dropped streams, and latency.
+//
+// Faults are added to the client's interceptors with WithFaults, in front of
+// any interceptors already set, such as an rpcreplay Replayer or a fake:
+//
+//	createFails := &modaltest.Fault{
+//		Method: "SandboxCreate",
+//		Err:    status.Error(codes.Unavailable, "injected"),
+//		Times:  2,
+//	}
+//	logsDrop := &modaltest.Fault{Method: "SandboxGetLogs", DropAfter: 3}
+//	slow := &modaltest.Fault{Latency: 50 * time.Millisecond}
+//	err := modal.InitializeClient(modaltest.WithFaults(options, createFails, logsDrop, slow))
+//	// ... run the code under test ...
+//	fmt.Println(createFails.Count()) // 2
+//
+// Faults apply to each attempt of an RPC, after the SDK's own interceptors,
+// so the SDK retries injected errors that it considers transient, such as
+// codes.Unavailable, as it would retry them from Modal.
+package modaltest
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/modal-labs/libmodal/modal-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// methodPrefix is the prefix of the full names of Modal's RPCs.
+const methodPrefix = "/modal.client.ModalClient/"
+
+// Fault is a fault injected into matching RPCs. A Fault is safe for
+// concurrent use, and may be shared between clients.
+type Fault struct {
+	// Method is the name of the RPCs to inject into, such as "SandboxCreate",
+	// or the full name "/modal.client.ModalClient/SandboxCreate". Empty
+	// matches every RPC.
+	Method string
+	// Err, if set, fails matching RPCs with this error instead of sending
+	// them. Use a gRPC status error, such as
+	// status.Error(codes.Unavailable, "injected"), to test retries.
+	Err error
+	// Latency delays matching RPCs before they are sent.
+	Latency time.Duration
+	// DropAfter, if positive, ends matching streams with DropErr after they
+	// have received this many messages.
+	DropAfter int
+	// DropErr is the error that streams are dropped with, defaults to a
+	// codes.Unavailable status error.
+	DropErr error
+	// Times is the number of RPCs to inject into, after which the Fault is
+	// spent. Zero means no limit.
+	Times int
+	// Probability is the chance that the Fault is injected into a matching
+	// RPC, from 0 to 1. Zero means always.
+	Probability float64
+
+	mu    sync.Mutex
+	count int
+}
+
+// Count returns the number of RPCs that the Fault has been injected into.
+func (f *Fault) Count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.count
+}
+
+// take reports whether the Fault is injected into an RPC to method, and
+// counts it if so.
+func (f *Fault) take(method string) bool {
+	if f.Method != "" && method != f.Method && method != methodPrefix+f.Method {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Times > 0 && f.count >= f.Times {
+		return false
+	}
+	if f.Probability > 0 && rand.Float64() >= f.Probability {
+		return false
+	}
+	f.count++
+	return true
+}
+
+// WithFaults returns options with interceptors that inject faults into the
+// client's RPCs. Faults are checked in order for each RPC, and every matching
+// Fault is applied: latencies add up, and the first Err fails the RPC.
+func WithFaults(options modal.ClientOptions, faults ...*Fault) modal.ClientOptions {
+	options.UnaryInterceptors = append([]grpc.UnaryClientInterceptor{unaryInterceptor(faults)}, options.UnaryInterceptors...)
+	options.StreamInterceptors = append([]grpc.StreamClientInterceptor{streamInterceptor(faults)}, options.StreamInterceptors...)
+	return options
+}
+
+// inject applies the faults matching method, and returns the Fault of
+// streams to drop, if any, and the error that the RPC fails with, if any.
+func inject(ctx context.Context, faults []*Fault, method string) (*Fault, error) {
+	var drop *Fault
+	for _, f := range faults {
+		if !f.take(method) {
+			continue
+		}
+		if f.Latency > 0 {
+			timer := time.NewTimer(f.Latency)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, status.FromContextError(ctx.Err()).Err()
+			case <-timer.C:
+			}
+		}
+		if f.Err != nil {
+			return nil, f.Err
+		}
+		if f.DropAfter > 0 && drop == nil {
+			drop = f
+		}
+	}
+	return drop, nil
+}
+
+func unaryInterceptor(faults []*Fault) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if _, err := inject(ctx, faults, method); err != nil {
+			return err
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+func streamInterceptor(faults []*Fault) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		drop, err := inject(ctx, faults, method)
+		if err != nil {
+			return nil, err
+		}
+		if drop == nil {
+			return streamer(ctx, desc, cc, method, opts...)
+		}
+		ctx, cancel := context.WithCancel(ctx)
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		dropErr := drop.DropErr
+		if dropErr == nil {
+			dropErr = status.Error(codes.Unavailable, "modaltest: stream dropped")
+		}
+		return &droppingStream{ClientStream: stream, cancel: cancel, remaining: drop.DropAfter, err: dropErr}, nil
+	}
+}
+
+// droppingStream is a stream that fails with err after receiving a number of
+// messages, and cancels the underlying stream.
+type droppingStream struct {
+	grpc.ClientStream
+	cancel    context.CancelFunc
+	remaining int
+	err       error
+}
+
+func (s *droppingStream) RecvMsg(m any) error {
+	if s.remaining <= 0 {
+		s.cancel()
+		return s.err
+	}
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil {
+		s.cancel()
+		return err
+	}
+	s.remaining--
+	return nil
+}
@@ -0,0 +1,104 @@
+package modaltest
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/modal-labs/libmodal/modal-go"
+	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+	"github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestInjectedErrorsAreRetried(t *testing.T) {
+	g := gomega.NewWithT(t)
+	calls := 0
+	fake := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		calls++
+		proto.Merge(reply.(proto.Message), pb.SecretGetOrCreateResponse_builder{SecretId: "st-123"}.Build())
+		return nil
+	}
+	unavailable := &Fault{Method: "SecretGetOrCreate", Err: status.Error(codes.Unavailable, "injected"), Times: 2}
+	unrelated := &Fault{Method: "AppGetOrCreate", Err: status.Error(codes.Internal, "injected")}
+	err := modal.InitializeClient(WithFaults(modal.ClientOptions{
+		TokenId:           "token-id",
+		TokenSecret:       "token-secret",
+		UnaryInterceptors: []grpc.UnaryClientInterceptor{fake},
+	}, unavailable, unrelated))
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	// The SDK retries the injected errors, and the third attempt reaches the fake.
+	secret, err := modal.SecretFromName(context.Background(), "db", nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(secret.SecretId).To(gomega.Equal("st-123"))
+	g.Expect(unavailable.Count()).To(gomega.Equal(2))
+	g.Expect(unrelated.Count()).To(gomega.Equal(0))
+	g.Expect(calls).To(gomega.Equal(1))
+}
+
+func TestLatency(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	interceptor := unaryInterceptor([]*Fault{{Latency: 20 * time.Millisecond}, {Latency: 30 * time.Millisecond}})
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}
+	start := time.Now()
+	g.Expect(interceptor(context.Background(), "/modal.client.ModalClient/AppList", nil, nil, nil, invoker)).To(gomega.Succeed())
+	g.Expect(time.Since(start)).To(gomega.BeNumerically(">=", 50*time.Millisecond))
+
+	// Waiting for the latency stops with the context.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	err := interceptor(ctx, "/modal.client.ModalClient/AppList", nil, nil, nil, invoker)
+	g.Expect(status.Code(err)).To(gomega.Equal(codes.DeadlineExceeded))
+}
+
+// countingStream is a stream that receives messages until it is cancelled.
+type countingStream struct {
+	grpc.ClientStream
+	ctx      context.Context
+	received int
+}
+
+func (s *countingStream) RecvMsg(m any) error {
+	if s.ctx.Err() != nil {
+		return io.EOF
+	}
+	s.received++
+	return nil
+}
+
+func TestStreamDrop(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	var underlying *countingStream
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		underlying = &countingStream{ctx: ctx}
+		return underlying, nil
+	}
+	drop := &Fault{Method: "SandboxGetLogs", DropAfter: 2, Times: 1}
+	interceptor := streamInterceptor([]*Fault{drop})
+
+	stream, err := interceptor(context.Background(), &grpc.StreamDesc{}, nil, "/modal.client.ModalClient/SandboxGetLogs", streamer)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(stream.RecvMsg(nil)).To(gomega.Succeed())
+	g.Expect(stream.RecvMsg(nil)).To(gomega.Succeed())
+	err = stream.RecvMsg(nil)
+	g.Expect(status.Code(err)).To(gomega.Equal(codes.Unavailable))
+	g.Expect(underlying.ctx.Err()).To(gomega.HaveOccurred())
+	g.Expect(underlying.received).To(gomega.Equal(2))
+
+	// The Fault is spent, so the reconnected stream is left alone.
+	stream, err = interceptor(context.Background(), &grpc.StreamDesc{}, nil, "/modal.client.ModalClient/SandboxGetLogs", streamer)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	for range 5 {
+		g.Expect(stream.RecvMsg(nil)).To(gomega.Succeed())
+	}
+	g.Expect(drop.Count()).To(gomega.Equal(1))
+}
@@ -0,0 +1,70 @@
+package modal
+
+// A common interface to handles of Modal objects.
+
+import (
+	"context"
+	"fmt"
+)
+
+// Object is a handle to a Modal object, implemented by *App, *Volume,
+// *Secret, *Image, *Queue, and *Dict, for code that handles objects of any
+// type, such as caches keyed by ID, logging, and dependency resolution.
+type Object interface {
+	// ObjectId returns the ID of the object, such as "vo-123" for a Volume,
+	// or an empty string if the handle is not hydrated.
+	ObjectId() string
+	// Name returns the name the object was looked up by, or an empty string
+	// for objects without one, such as Images and ephemeral objects.
+	Name() string
+	// Environment returns the name of the environment the object is in, or
+	// an empty string for the workspace's default environment.
+	Environment() string
+	// IsHydrated reports whether the handle refers to an object on Modal,
+	// which it does once it has an ID.
+	IsHydrated() bool
+	// Hydrate makes the handle ready for use. Handles returned by the SDK
+	// are always hydrated, and Hydrate does nothing for them. A handle
+	// created as a struct literal with only an ID, such as
+	// &modal.Volume{VolumeId: id}, is bound to ctx for its later calls;
+	// Hydrate must not be called concurrently with them. It returns an
+	// InvalidError for a handle without an ID.
+	Hydrate(ctx context.Context) error
+}
+
+var (
+	_ Object = (*App)(nil)
+	_ Object = (*Volume)(nil)
+	_ Object = (*Secret)(nil)
+	_ Object = (*Image)(nil)
+	_ Object = (*Queue)(nil)
+	_ Object = (*Dict)(nil)
+)
+
+// handleContext returns the context of a handle's copy made by WithContext:
+// ctx with the client's credentials, in the environment of the handle's
+// context handleCtx.
+func handleContext(ctx, handleCtx context.Context) (context.Context, error) {
+	ctx, err := clientContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return WithEnvironment(ctx, environmentName(handleCtx, "")), nil
+}
+
+// hydrate implements Object.Hydrate for a handle of the given kind, with ID
+// id and context handleCtx.
+func hydrate(ctx context.Context, kind, id string, handleCtx *context.Context) error {
+	if id == "" {
+		return InvalidError{fmt.Sprintf("%s handle has no ID, it must be looked up or created first", kind)}
+	}
+	if *handleCtx != nil {
+		return nil
+	}
+	ctx, err := clientContext(ctx)
+	if err != nil {
+		return err
+	}
+	*handleCtx = context.WithoutCancel(ctx)
+	return nil
+}
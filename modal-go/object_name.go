@@ -0,0 +1,71 @@
+package modal
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// From: modal/_utils/name_utils.py
+const maxObjectNameLength = 64
+
+var (
+	objectNamePattern = regexp.MustCompile(`^[a-zA-Z0-9\-_.]+$`)
+	appIdPattern      = regexp.MustCompile(`^ap-[a-zA-Z0-9]{22}$`)
+)
+
+// validateObjectName checks a deployed object name against the rules enforced
+// by the Modal server, so invalid names fail before making an RPC.
+func validateObjectName(name string, objectType string) error {
+	var reason string
+	switch {
+	case name == "":
+		reason = "name must not be empty"
+	case len(name) > maxObjectNameLength:
+		reason = fmt.Sprintf("name must be at most %d characters long", maxObjectNameLength)
+	case !objectNamePattern.MatchString(name):
+		reason = "name may contain only alphanumeric characters, dashes, periods, and underscores"
+	case appIdPattern.MatchString(name):
+		reason = "name must not conflict with App ID strings"
+	default:
+		return nil
+	}
+	return InvalidNameError{
+		Exception:  fmt.Sprintf("invalid %s name '%s': %s", objectType, name, reason),
+		Name:       name,
+		ObjectType: objectType,
+	}
+}
+
+// resolveObjectRef resolves a possibly qualified object reference of the form
+// "name" or "environment/name", and validates the name. It returns the bare
+// name and the environment to look it up in.
+//
+// Objects are always looked up in the workspace of the client's credentials:
+// Modal's API has no way to look up objects in another workspace.
+func resolveObjectRef(ref string, objectType string, environment string) (string, string, error) {
+	name := ref
+	parts := strings.Split(ref, "/")
+	switch len(parts) {
+	case 1:
+	case 2:
+		environment, name = mergeRefPart(environment, parts[0]), parts[1]
+		if environment != parts[0] {
+			return "", "", InvalidError{fmt.Sprintf("%s reference '%s' conflicts with environment '%s'", objectType, ref, environment)}
+		}
+	default:
+		return "", "", validateObjectName(ref, objectType)
+	}
+	if err := validateObjectName(name, objectType); err != nil {
+		return "", "", err
+	}
+	return name, environment, nil
+}
+
+// mergeRefPart returns the value from an option if set, else from a qualified reference.
+func mergeRefPart(option string, fromRef string) string {
+	if option != "" {
+		return option
+	}
+	return fromRef
+}
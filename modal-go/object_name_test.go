@@ -0,0 +1,47 @@
+package modal
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+func TestValidateObjectName(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	for _, name := range []string{"a", "libmodal-test", "my_volume.v2", strings.Repeat("a", 64), "ap-short"} {
+		g.Expect(validateObjectName(name, "Volume")).To(gomega.Succeed(), "name: %s", name)
+	}
+
+	for _, name := range []string{"", "has space", "has/slash", strings.Repeat("a", 65), "ap-0123456789abcdefghijkl"} {
+		err := validateObjectName(name, "Volume")
+		var nameErr InvalidNameError
+		g.Expect(errors.As(err, &nameErr)).To(gomega.BeTrue(), "name: %s", name)
+		g.Expect(nameErr.Name).To(gomega.Equal(name))
+		g.Expect(nameErr.ObjectType).To(gomega.Equal("Volume"))
+	}
+}
+
+func TestResolveObjectRef(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	name, env, err := resolveObjectRef("weights", "Volume", "")
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect([]string{name, env}).To(gomega.Equal([]string{"weights", ""}))
+
+	name, env, err = resolveObjectRef("shared/weights", "Volume", "")
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect([]string{name, env}).To(gomega.Equal([]string{"weights", "shared"}))
+
+	_, _, err = resolveObjectRef("shared/weights", "Volume", "main")
+	g.Expect(err).Should(gomega.MatchError(gomega.ContainSubstring("conflicts with environment 'main'")))
+
+	for _, ref := range []string{"workspace/shared/weights", "a/b/c/d"} {
+		_, _, err = resolveObjectRef(ref, "Volume", "")
+		g.Expect(errors.As(err, &InvalidNameError{})).To(gomega.BeTrue(), "ref: %s", ref)
+	}
+}
@@ -0,0 +1,90 @@
+package modal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+	"github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestObject(t *testing.T) {
+	g := gomega.NewWithT(t)
+	fake := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if method == "/modal.client.ModalClient/VolumeGetOrCreate" {
+			proto.Merge(reply.(proto.Message), pb.VolumeGetOrCreateResponse_builder{VolumeId: "vo-123"}.Build())
+		}
+		return nil
+	}
+	useFakeClient(t, fake)
+	clientProfile.Environment = "main"
+
+	volume, err := VolumeFromName(context.Background(), "my-volume", &VolumeFromNameOptions{Environment: "dev"})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	var object Object = volume
+	g.Expect(object.ObjectId()).To(gomega.Equal("vo-123"))
+	g.Expect(object.Name()).To(gomega.Equal("my-volume"))
+	g.Expect(object.Environment()).To(gomega.Equal("dev"))
+	g.Expect(object.IsHydrated()).To(gomega.BeTrue())
+	g.Expect(object.Hydrate(context.Background())).To(gomega.Succeed())
+
+	// Handles created as struct literals are bound to a context by Hydrate.
+	for _, object := range []Object{&App{AppId: "ap-1"}, &Volume{VolumeId: "vo-1"}, &Secret{SecretId: "st-1"}, &Image{ImageId: "im-1"}, &Queue{QueueId: "qu-1"}, &Dict{DictId: "di-1"}} {
+		g.Expect(object.IsHydrated()).To(gomega.BeTrue())
+		g.Expect(object.Name()).To(gomega.BeEmpty())
+		g.Expect(object.Environment()).To(gomega.Equal("main"))
+		g.Expect(object.Hydrate(WithEnvironment(context.Background(), "staging"))).To(gomega.Succeed())
+		g.Expect(object.Environment()).To(gomega.Equal("staging"))
+	}
+
+	var empty Object = &Secret{}
+	g.Expect(empty.IsHydrated()).To(gomega.BeFalse())
+	g.Expect(empty.Hydrate(context.Background())).To(gomega.BeAssignableToTypeOf(InvalidError{}))
+}
+
+func TestHandleWithContext(t *testing.T) {
+	g := gomega.NewWithT(t)
+	fake := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		switch method {
+		case "/modal.client.ModalClient/QueueGetOrCreate":
+			proto.Merge(reply.(proto.Message), pb.QueueGetOrCreateResponse_builder{QueueId: "qu-123"}.Build())
+		case "/modal.client.ModalClient/QueueGet", "/modal.client.ModalClient/SandboxWait":
+			<-ctx.Done() // blocks until the call is cancelled
+			return status.FromContextError(ctx.Err()).Err()
+		}
+		return nil
+	}
+	useFakeClient(t, fake)
+
+	// The lookup's cancellation does not reach the handle, while that of the
+	// context given to WithContext does.
+	lookupCtx, cancelLookup := context.WithCancel(WithEnvironment(context.Background(), "dev"))
+	queue, err := QueueLookup(lookupCtx, "my-queue", nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	cancelLookup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	bounded, err := queue.WithContext(ctx)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(bounded.Environment()).To(gomega.Equal("dev"))
+	_, err = bounded.Get(nil)
+	g.Expect(status.Code(err)).To(gomega.Equal(codes.DeadlineExceeded))
+
+	sb := newSandboxWithStdio(context.Background(), "sb-123", Ignore, Ignore)
+	ctx, cancel = context.WithCancel(context.Background())
+	boundedSb, err := sb.WithContext(ctx)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	done := make(chan error, 1)
+	go func() {
+		_, err := boundedSb.Wait()
+		done <- err
+	}()
+	cancel()
+	g.Eventually(done).Should(gomega.Receive(gomega.WithTransform(status.Code, gomega.Equal(codes.Canceled))))
+}
@@ -4,8 +4,10 @@ package modal
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"iter"
+	"sync"
 	"time"
 
 	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
@@ -41,9 +43,13 @@ type QueueGetOptions struct {
 }
 
 type QueuePutOptions struct {
-	Timeout      *time.Duration // max wait for space (nil = indefinitely)
-	Partition    string
-	PartitionTtl time.Duration // ttl for the *partition* (default 24h)
+	Timeout   *time.Duration // max wait for space (nil = indefinitely)
+	Partition string
+	// PartitionTtl is how long the partition is kept after the last Put to it
+	// (default 24h). Once it passes, the partition and all of its items are
+	// dropped, so stale items do not outlive an idle producer. Modal has no
+	// TTL for individual items, so items are kept as long as their partition.
+	PartitionTtl time.Duration
 }
 
 type QueueLenOptions struct {
@@ -62,6 +68,48 @@ type Queue struct {
 	cancel    context.CancelFunc // only for ephemeral queues
 	ephemeral bool
 	ctx       context.Context
+
+	name        string // deployment name, empty for ephemeral queues
+	environment string
+}
+
+// ObjectId returns the Queue's ID, see Object.
+func (q *Queue) ObjectId() string {
+	return q.QueueId
+}
+
+// WithContext returns a copy of the Queue whose calls use ctx, for example to
+// apply a request's deadline and cancellation.
+func (q *Queue) WithContext(ctx context.Context) (*Queue, error) {
+	ctx, err := handleContext(ctx, q.ctx)
+	if err != nil {
+		return nil, err
+	}
+	c := *q
+	c.ctx = ctx
+	return &c, nil
+}
+
+// Name returns the name the Queue was looked up by, or an empty string for
+// ephemeral queues.
+func (q *Queue) Name() string {
+	return q.name
+}
+
+// Environment returns the name of the environment the Queue is in, see
+// Object.
+func (q *Queue) Environment() string {
+	return firstNonEmpty(q.environment, environmentName(q.ctx, ""))
+}
+
+// IsHydrated reports whether the Queue has an ID, see Object.
+func (q *Queue) IsHydrated() bool {
+	return q.QueueId != ""
+}
+
+// Hydrate makes the Queue ready for use, see Object.
+func (q *Queue) Hydrate(ctx context.Context) error {
+	return hydrate(ctx, "Queue", q.QueueId, &q.ctx)
 }
 
 // QueueEphemeral creates a nameless, temporary queue. Caller must CloseEphemeral.
@@ -77,17 +125,18 @@ func QueueEphemeral(ctx context.Context, options *EphemeralOptions) (*Queue, err
 
 	resp, err := client.QueueGetOrCreate(ctx, pb.QueueGetOrCreateRequest_builder{
 		ObjectCreationType: pb.ObjectCreationType_OBJECT_CREATION_TYPE_EPHEMERAL,
-		EnvironmentName:    environmentName(options.Environment),
+		EnvironmentName:    environmentName(ctx, options.Environment),
 	}.Build())
 	if err != nil {
 		return nil, err
 	}
 
 	heartbeatCtx, cancel := context.WithCancel(ctx)
-	q := &Queue{QueueId: resp.GetQueueId(), cancel: cancel, ephemeral: true, ctx: ctx}
+	q := &Queue{QueueId: resp.GetQueueId(), cancel: cancel, ephemeral: true, ctx: ctx, environment: environmentName(ctx, options.Environment)}
 
 	// backgroundheart‑beat goroutine
 	go func() {
+		defer heartbeatStarted()()
 		t := time.NewTicker(ephemeralObjectHeartbeatSleep)
 		defer t.Stop()
 		for {
@@ -126,6 +175,11 @@ func QueueLookup(ctx context.Context, name string, options *LookupOptions) (*Que
 	if err != nil {
 		return nil, err
 	}
+	var environment string
+	name, environment, err = resolveObjectRef(name, "Queue", options.Environment)
+	if err != nil {
+		return nil, err
+	}
 
 	creationType := pb.ObjectCreationType_OBJECT_CREATION_TYPE_UNSPECIFIED
 	if options.CreateIfMissing {
@@ -134,13 +188,13 @@ func QueueLookup(ctx context.Context, name string, options *LookupOptions) (*Que
 
 	resp, err := client.QueueGetOrCreate(ctx, pb.QueueGetOrCreateRequest_builder{
 		DeploymentName:     name,
-		EnvironmentName:    environmentName(options.Environment),
+		EnvironmentName:    environmentName(ctx, environment),
 		ObjectCreationType: creationType,
 	}.Build())
 	if err != nil {
 		return nil, err
 	}
-	return &Queue{ctx: ctx, QueueId: resp.GetQueueId()}, nil
+	return &Queue{ctx: context.WithoutCancel(ctx), QueueId: resp.GetQueueId(), name: name, environment: environmentName(ctx, environment)}, nil
 }
 
 // QueueDelete removes a queue by name.
@@ -175,6 +229,23 @@ func (q *Queue) Clear(options *QueueClearOptions) error {
 
 // internal helper for both Get and GetMany.
 func (q *Queue) get(n int, options *QueueGetOptions) ([]any, error) {
+	raws, err := q.getRaw(n, options)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]any, len(raws))
+	for i, raw := range raws {
+		v, err := pickleDeserialize(raw)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+// getRaw removes up to n serialized items, without deserializing them.
+func (q *Queue) getRaw(n int, options *QueueGetOptions) ([][]byte, error) {
 	if options == nil {
 		options = &QueueGetOptions{}
 	}
@@ -200,15 +271,7 @@ func (q *Queue) get(n int, options *QueueGetOptions) ([]any, error) {
 			return nil, err
 		}
 		if len(resp.GetValues()) > 0 {
-			out := make([]any, len(resp.GetValues()))
-			for i, raw := range resp.GetValues() {
-				v, err := pickleDeserialize(raw)
-				if err != nil {
-					return nil, err
-				}
-				out[i] = v
-			}
-			return out, nil
+			return resp.GetValues(), nil
 		}
 		if options.Timeout != nil {
 			remaining := *options.Timeout - time.Since(startTime)
@@ -245,22 +308,45 @@ func (q *Queue) GetMany(n int, options *QueueGetOptions) ([]any, error) {
 
 // internal put helper (single/many).
 func (q *Queue) put(values []any, options *QueuePutOptions) error {
-	if options == nil {
-		options = &QueuePutOptions{}
-	}
-	key, err := validatePartitionKey(options.Partition)
-	if err != nil {
-		return err
+	valuesEncoded := make([][]byte, len(values))
+	for i, v := range values {
+		b, err := pickleSerialize(v)
+		if err != nil {
+			return err
+		}
+		valuesEncoded[i] = b.Bytes()
 	}
+	return q.putRaw(valuesEncoded, options)
+}
 
+// serializeQueueValues pickles the values for one partition of
+// PutManyPartitions, reporting the first that fails as a QueueItemError.
+func serializeQueueValues(partition string, values []any) ([][]byte, error) {
 	valuesEncoded := make([][]byte, len(values))
 	for i, v := range values {
 		b, err := pickleSerialize(v)
 		if err != nil {
-			return err
+			return nil, QueueItemError{
+				Exception: fmt.Sprintf("failed to serialize item %d: %v", i, err),
+				Partition: partition,
+				Index:     i,
+				cause:     err,
+			}
 		}
 		valuesEncoded[i] = b.Bytes()
 	}
+	return valuesEncoded, nil
+}
+
+// putRaw adds already serialized items to the queue.
+func (q *Queue) putRaw(valuesEncoded [][]byte, options *QueuePutOptions) error {
+	if options == nil {
+		options = &QueuePutOptions{}
+	}
+	key, err := validatePartitionKey(options.Partition)
+	if err != nil {
+		return err
+	}
 
 	deadline := time.Time{}
 	if options.Timeout != nil {
@@ -272,6 +358,9 @@ func (q *Queue) put(values []any, options *QueuePutOptions) error {
 	if ttl == 0 {
 		ttl = queueDefaultPartitionTtl
 	}
+	if ttl < time.Second {
+		return InvalidError{fmt.Sprintf("PartitionTtl must be at least 1s, got %v", ttl)}
+	}
 
 	for {
 		_, err := client.QueuePut(q.ctx, pb.QueuePutRequest_builder{
@@ -346,8 +435,214 @@ func (q *Queue) Len(options *QueueLenOptions) (int, error) {
 	return int(resp.GetLen()), nil
 }
 
+// Maximum number of partitions accessed concurrently by bulk operations.
+const queueMaxPartitionConcurrency = 16
+
+// forEachPartition runs fn concurrently for each partition, and collects the
+// item errors it reports into a QueueBatchError.
+func forEachPartition(op string, partitions []string, fn func(partition string) []QueueItemError) error {
+	var (
+		mu     sync.Mutex
+		failed []QueueItemError
+		wg     sync.WaitGroup
+	)
+	sem := make(chan struct{}, queueMaxPartitionConcurrency)
+	for _, partition := range partitions {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if errs := fn(partition); len(errs) > 0 {
+				mu.Lock()
+				failed = append(failed, errs...)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(failed) == 0 {
+		return nil
+	}
+	return QueueBatchError{
+		Exception: fmt.Sprintf("%s failed for %d item(s)", op, len(failed)),
+		Items:     failed,
+	}
+}
+
+// PutManyPartitions adds values to several partitions of the queue at once,
+// keyed by partition name, with partitions written concurrently. This is
+// useful for distributing sharded work to consumers of each partition.
+//
+// options.Partition must be empty. If some items could not be added, a
+// QueueBatchError lists them; all other items were added.
+func (q *Queue) PutManyPartitions(values map[string][]any, options *QueuePutOptions) error {
+	if options == nil {
+		options = &QueuePutOptions{}
+	}
+	if options.Partition != "" {
+		return InvalidError{"options.Partition must be \"\" when putting to multiple partitions"}
+	}
+	partitions := make([]string, 0, len(values))
+	for partition := range values {
+		if _, err := validatePartitionKey(partition); err != nil {
+			return err
+		}
+		partitions = append(partitions, partition)
+	}
+
+	return forEachPartition("PutManyPartitions", partitions, func(partition string) []QueueItemError {
+		valuesEncoded, err := serializeQueueValues(partition, values[partition])
+		if err != nil {
+			return []QueueItemError{err.(QueueItemError)}
+		}
+		partitionOptions := *options
+		partitionOptions.Partition = partition
+		if err := q.putRaw(valuesEncoded, &partitionOptions); err != nil {
+			return []QueueItemError{{
+				Exception: fmt.Sprintf("failed to put to partition %q: %v", partition, err),
+				Partition: partition,
+				Index:     -1,
+				cause:     err,
+			}}
+		}
+		return nil
+	})
+}
+
+// GetManyPartitions removes up to n items from each of the given partitions,
+// with partitions read concurrently. Partitions that stay empty until
+// options.Timeout are omitted from the result. Unlike GetMany, a nil
+// options.Timeout does not wait for items: the call returns once every
+// partition has been read, so one idle partition cannot block the others.
+//
+// Items that cannot be deserialized are reported in a QueueBatchError, with
+// their serialized bytes in QueueItemError.Data.
+//
+// options.Partition must be empty. If some items could not be read, a
+// QueueBatchError lists them alongside the items that were.
+func (q *Queue) GetManyPartitions(n int, partitions []string, options *QueueGetOptions) (map[string][]any, error) {
+	if options == nil {
+		options = &QueueGetOptions{}
+	}
+	if options.Partition != "" {
+		return nil, InvalidError{"options.Partition must be \"\" when getting from multiple partitions"}
+	}
+	for _, partition := range partitions {
+		if _, err := validatePartitionKey(partition); err != nil {
+			return nil, err
+		}
+	}
+
+	var mu sync.Mutex
+	results := map[string][]any{}
+	err := forEachPartition("GetManyPartitions", partitions, func(partition string) []QueueItemError {
+		partitionOptions := *options
+		partitionOptions.Partition = partition
+		if partitionOptions.Timeout == nil {
+			var noWait time.Duration
+			partitionOptions.Timeout = &noWait
+		}
+		raws, err := q.getRaw(n, &partitionOptions)
+		if errors.As(err, &QueueEmptyError{}) {
+			return nil
+		}
+		if err != nil {
+			return []QueueItemError{{
+				Exception: fmt.Sprintf("failed to get from partition %q: %v", partition, err),
+				Partition: partition,
+				Index:     -1,
+				cause:     err,
+			}}
+		}
+
+		var values []any
+		var failed []QueueItemError
+		for i, raw := range raws {
+			v, err := pickleDeserialize(raw)
+			if err != nil {
+				failed = append(failed, QueueItemError{
+					Exception: fmt.Sprintf("failed to deserialize item %d: %v", i, err),
+					Partition: partition,
+					Index:     i,
+					Data:      raw,
+					cause:     err,
+				})
+				continue
+			}
+			values = append(values, v)
+		}
+		if len(values) > 0 {
+			mu.Lock()
+			results[partition] = values
+			mu.Unlock()
+		}
+		return failed
+	})
+	return results, err
+}
+
+// QueueStats describes the contents of a Queue.
+type QueueStats struct {
+	Len        int       // Number of objects across all partitions.
+	Partitions int       // Number of partitions holding objects, zero for ephemeral queues.
+	CreatedAt  time.Time // Creation time of the Queue, zero for ephemeral queues.
+}
+
+// Stats returns the size and metadata of the queue.
+//
+// Modal has no request for the metadata of a single Queue, so for named
+// queues this lists all Queues in the environment and picks this one out.
+// The cost grows with the number of Queues in the environment, so avoid
+// calling it in a tight loop.
+func (q *Queue) Stats() (*QueueStats, error) {
+	n, err := q.Len(&QueueLenOptions{Total: true})
+	if err != nil {
+		return nil, err
+	}
+	stats := &QueueStats{Len: n}
+	if q.name == "" {
+		return stats, nil
+	}
+
+	resp, err := client.QueueList(q.ctx, pb.QueueListRequest_builder{
+		EnvironmentName: q.environment,
+		TotalSizeLimit:  1, // sizes are not needed, so let the server skip counting them
+	}.Build())
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range resp.GetQueues() {
+		if info.GetName() == q.name {
+			stats.Partitions = int(info.GetNumPartitions())
+			stats.CreatedAt = time.Unix(0, int64(info.GetCreatedAt()*1e9))
+			break
+		}
+	}
+	return stats, nil
+}
+
 // Iterate yields items from the queue until it is empty.
 func (q *Queue) Iterate(options *QueueIterateOptions) iter.Seq2[any, error] {
+	return func(yield func(any, error) bool) {
+		for raw, err := range q.iterateRaw(options) {
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			v, err := pickleDeserialize(raw)
+			if err != nil {
+				return
+			}
+			if !yield(v, nil) {
+				return
+			}
+		}
+	}
+}
+
+// iterateRaw yields serialized items from the queue until it is empty.
+func (q *Queue) iterateRaw(options *QueueIterateOptions) iter.Seq2[[]byte, error] {
 	if options == nil {
 		options = &QueueIterateOptions{}
 	}
@@ -356,7 +651,7 @@ func (q *Queue) Iterate(options *QueueIterateOptions) iter.Seq2[any, error] {
 	lastEntryID := ""
 	maxPoll := 30 * time.Second
 
-	return func(yield func(any, error) bool) {
+	return func(yield func([]byte, error) bool) {
 		key, err := validatePartitionKey(options.Partition)
 		if err != nil {
 			yield(nil, err)
@@ -378,11 +673,7 @@ func (q *Queue) Iterate(options *QueueIterateOptions) iter.Seq2[any, error] {
 			}
 			if len(resp.GetItems()) > 0 {
 				for _, item := range resp.GetItems() {
-					v, err := pickleDeserialize(item.GetValue())
-					if err != nil {
-						return
-					}
-					if !yield(v, nil) {
+					if !yield(item.GetValue(), nil) {
 						return
 					}
 					lastEntryID = item.GetEntryId()
@@ -0,0 +1,89 @@
+package modal
+
+// Typed resource quantities, checked before they are converted to the
+// integer units of Modal's API.
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// CPUCores is a CPU request in physical cores, such as 0.5 or 2. Modal
+// allocates CPU in thousandths of a core, so requests are rounded to the
+// nearest thousandth.
+type CPUCores float64
+
+// MemoryMiB is a memory request in MiB.
+type MemoryMiB int
+
+// DiskMiB is a disk request in MiB.
+type DiskMiB int
+
+// GPUCount is a number of GPUs, as in a GPU request such as "H100:2".
+type GPUCount int
+
+// maxGPUCount is the most GPUs Modal attaches to one container.
+const maxGPUCount = 8
+
+// Validate returns an InvalidError if c is negative, not a number, or too
+// small or too large to request.
+func (c CPUCores) Validate() error {
+	_, err := c.milli()
+	return err
+}
+
+// milli returns c in thousandths of a core, the unit of Modal's API.
+func (c CPUCores) milli() (uint32, error) {
+	milli := math.Round(float64(c) * 1000)
+	switch {
+	case math.IsNaN(float64(c)) || c < 0:
+		return 0, InvalidError{fmt.Sprintf("CPU must be non-negative, got %v", float64(c))}
+	case c > 0 && milli == 0:
+		return 0, InvalidError{fmt.Sprintf("CPU must be at least 0.001 cores, got %v", float64(c))}
+	case milli > math.MaxUint32:
+		return 0, InvalidError{fmt.Sprintf("CPU of %v cores is too large", float64(c))}
+	}
+	return uint32(milli), nil
+}
+
+// Validate returns an InvalidError if m is negative or too large to request.
+func (m MemoryMiB) Validate() error {
+	_, err := mebibytes("Memory", int(m))
+	return err
+}
+
+// Validate returns an InvalidError if d is negative or too large to request.
+func (d DiskMiB) Validate() error {
+	_, err := mebibytes("EphemeralDiskMB", int(d))
+	return err
+}
+
+// mebibytes converts the size of field to the unit of Modal's API.
+func mebibytes(field string, mib int) (uint32, error) {
+	if mib < 0 {
+		return 0, InvalidError{fmt.Sprintf("%s must be non-negative, got %d", field, mib)}
+	}
+	if uint64(mib) > math.MaxUint32 {
+		return 0, InvalidError{fmt.Sprintf("%s of %d MiB is too large", field, mib)}
+	}
+	return uint32(mib), nil
+}
+
+// Validate returns an InvalidError if n is not between 1 and the most GPUs
+// Modal attaches to a container.
+func (n GPUCount) Validate() error {
+	if n < 1 || n > maxGPUCount {
+		return InvalidError{fmt.Sprintf("GPU count must be between 1 and %d, got %d", maxGPUCount, n)}
+	}
+	return nil
+}
+
+// timeoutSecs converts a timeout to whole seconds, rounding up so that a
+// short timeout is not sent as 0, which means no timeout.
+func timeoutSecs(timeout time.Duration) uint32 {
+	if timeout <= 0 {
+		return 0
+	}
+	return uint32((timeout + time.Second - 1) / time.Second)
+}
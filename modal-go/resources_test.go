@@ -0,0 +1,46 @@
+package modal
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+)
+
+func TestCPUCoresMilli(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	for cores, want := range map[CPUCores]uint32{0: 0, 0.125: 125, 0.5: 500, 2: 2000, 0.0016: 2} {
+		milli, err := cores.milli()
+		g.Expect(err).ShouldNot(gomega.HaveOccurred())
+		g.Expect(milli).To(gomega.Equal(want), "%v cores", cores)
+	}
+	for _, cores := range []CPUCores{-1, 0.0001, CPUCores(math.NaN()), CPUCores(math.Inf(1)), 1e7} {
+		g.Expect(cores.Validate()).To(gomega.BeAssignableToTypeOf(InvalidError{}), "%v cores", cores)
+	}
+}
+
+func TestResourceQuantities(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	g.Expect(MemoryMiB(4096).Validate()).To(gomega.Succeed())
+	g.Expect(MemoryMiB(-1).Validate()).To(gomega.MatchError(gomega.ContainSubstring("Memory must be non-negative")))
+	g.Expect(DiskMiB(math.MaxUint32 + 1).Validate()).To(gomega.MatchError(gomega.ContainSubstring("EphemeralDiskMB of")))
+	g.Expect(GPUCount(8).Validate()).To(gomega.Succeed())
+	g.Expect(GPUCount(0).Validate()).To(gomega.BeAssignableToTypeOf(InvalidError{}))
+	g.Expect(GPUCount(9).Validate()).To(gomega.BeAssignableToTypeOf(InvalidError{}))
+}
+
+func TestTimeoutSecs(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	g.Expect(timeoutSecs(0)).To(gomega.Equal(uint32(0)))
+	g.Expect(timeoutSecs(-time.Second)).To(gomega.Equal(uint32(0)))
+	g.Expect(timeoutSecs(500 * time.Millisecond)).To(gomega.Equal(uint32(1)))
+	g.Expect(timeoutSecs(2 * time.Second)).To(gomega.Equal(uint32(2)))
+	g.Expect(timeoutSecs(2*time.Second + time.Nanosecond)).To(gomega.Equal(uint32(3)))
+}
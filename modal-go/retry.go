@@ -0,0 +1,77 @@
+package modal
+
+// Retries with exponential backoff, shared by RPCs and user code.
+
+import (
+	"context"
+	"time"
+)
+
+// RetryPolicy configures Retry. Zero fields take the defaults that the SDK
+// uses for RPCs.
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt, defaults
+	// to 3. Negative disables retries.
+	MaxRetries int
+	BaseDelay  time.Duration // Delay before the first retry, defaults to 100ms.
+	MaxDelay   time.Duration // Maximum delay between attempts, defaults to 1s.
+	Multiplier float64       // Growth of the delay after each retry, defaults to 2.
+	// Retryable reports whether an attempt that failed with err may succeed
+	// if retried. Defaults to IsRetryable.
+	Retryable func(err error) bool
+
+	onRetry func(err error) // called before each retry, after the delay
+}
+
+// IsRetryable reports whether err is a transient gRPC error from Modal, such
+// as Unavailable or DeadlineExceeded, that the SDK retries RPCs after.
+func IsRetryable(err error) bool {
+	return isRetryableGrpc(err)
+}
+
+// Retry calls fn until it succeeds, returns an error that policy does not
+// consider retryable, or the retries are used up, waiting with exponential
+// backoff in between. It returns the last error from fn. If ctx is done while
+// waiting, Retry stops and returns the last error from fn. A nil policy uses
+// the defaults.
+func Retry(ctx context.Context, policy *RetryPolicy, fn func(ctx context.Context) error) error {
+	p := RetryPolicy{}
+	if policy != nil {
+		p = *policy
+	}
+	if p.MaxRetries == 0 {
+		p.MaxRetries = defaultRetryAttempts
+	}
+	p.MaxRetries = max(p.MaxRetries, 0)
+	if p.BaseDelay == 0 {
+		p.BaseDelay = defaultRetryBaseDelay
+	}
+	if p.MaxDelay == 0 {
+		p.MaxDelay = defaultRetryMaxDelay
+	}
+	if p.Multiplier == 0 {
+		p.Multiplier = defaultRetryBackoffMul
+	}
+	if p.Retryable == nil {
+		p.Retryable = IsRetryable
+	}
+	return retry(ctx, p, fn)
+}
+
+// retry is Retry without defaults, for policies that are fully set.
+func retry(ctx context.Context, p RetryPolicy, fn func(ctx context.Context) error) error {
+	delay := p.BaseDelay
+	for attempt := 0; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || attempt >= p.MaxRetries || !p.Retryable(err) {
+			return err
+		}
+		if sleepCtx(ctx, delay) != nil {
+			return err
+		}
+		if p.onRetry != nil {
+			p.onRetry(err)
+		}
+		delay = min(time.Duration(float64(delay)*p.Multiplier), p.MaxDelay)
+	}
+}
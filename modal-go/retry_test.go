@@ -0,0 +1,78 @@
+package modal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRetry(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	ctx := context.Background()
+	policy := &RetryPolicy{BaseDelay: time.Millisecond}
+	unavailable := status.Error(codes.Unavailable, "connection reset")
+
+	// Transient errors are retried until fn succeeds.
+	calls := 0
+	err := Retry(ctx, policy, func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return unavailable
+		}
+		return nil
+	})
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(calls).To(gomega.Equal(3))
+
+	// The last error is returned once retries are used up.
+	calls = 0
+	err = Retry(ctx, policy, func(ctx context.Context) error {
+		calls++
+		return unavailable
+	})
+	g.Expect(err).To(gomega.Equal(unavailable))
+	g.Expect(calls).To(gomega.Equal(1 + defaultRetryAttempts))
+
+	// Other errors are not retried, unless the policy says so.
+	calls = 0
+	notFound := status.Error(codes.NotFound, "no such sandbox")
+	err = Retry(ctx, policy, func(ctx context.Context) error {
+		calls++
+		return notFound
+	})
+	g.Expect(err).To(gomega.Equal(notFound))
+	g.Expect(calls).To(gomega.Equal(1))
+
+	calls = 0
+	custom := &RetryPolicy{MaxRetries: 1, BaseDelay: time.Millisecond, Retryable: func(error) bool { return true }}
+	err = Retry(ctx, custom, func(ctx context.Context) error {
+		calls++
+		return errors.New("flaky")
+	})
+	g.Expect(err).To(gomega.MatchError("flaky"))
+	g.Expect(calls).To(gomega.Equal(2))
+
+	// Negative MaxRetries disables retries, and a done context stops waiting.
+	calls = 0
+	Retry(ctx, &RetryPolicy{MaxRetries: -1}, func(ctx context.Context) error {
+		calls++
+		return unavailable
+	})
+	g.Expect(calls).To(gomega.Equal(1))
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	calls = 0
+	err = Retry(cancelled, nil, func(ctx context.Context) error {
+		calls++
+		return unavailable
+	})
+	g.Expect(err).To(gomega.Equal(unavailable))
+	g.Expect(calls).To(gomega.Equal(1))
+}
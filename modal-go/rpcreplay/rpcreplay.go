@@ -0,0 +1,347 @@
+// Package rpcreplay records the gRPC exchanges between the Modal SDK and
+// Modal to a golden file, and replays them in tests, so orchestration code can
+// be tested deterministically without a network connection or credentials.
+//
+// Record once against Modal, with real credentials:
+//
+//	rec := rpcreplay.NewRecorder("testdata/sandbox.json")
+//	err := modal.InitializeClient(modal.ClientOptions{
+//		TokenId:            tokenId,
+//		TokenSecret:        tokenSecret,
+//		UnaryInterceptors:  []grpc.UnaryClientInterceptor{rec.UnaryInterceptor()},
+//		StreamInterceptors: []grpc.StreamClientInterceptor{rec.StreamInterceptor()},
+//	})
+//	// ... run the code under test ...
+//	err = rec.Close() // writes the golden file
+//
+// Then replay it in CI, with any token:
+//
+//	rep, err := rpcreplay.NewReplayer("testdata/sandbox.json")
+//	err = modal.InitializeClient(modal.ClientOptions{
+//		TokenId:            "replay",
+//		TokenSecret:        "replay",
+//		UnaryInterceptors:  []grpc.UnaryClientInterceptor{rep.UnaryInterceptor()},
+//		StreamInterceptors: []grpc.StreamClientInterceptor{rep.StreamInterceptor()},
+//	})
+//
+// A replayed RPC gets the result of the first unused recorded exchange with
+// the same method and request. Failing that, it gets the first unused
+// exchange with the same method, so requests with random fields, such as
+// idempotency keys, still match if the code under test makes them in the same
+// order. RPCs with no recorded exchange fail with codes.FailedPrecondition.
+//
+// Metadata is not recorded, and fields of messages that hold credentials or
+// environment variables, such as the EnvDict of a Secret created by
+// modal.SecretFromMap or SandboxOptions.EnvVars, are recorded with their
+// values replaced by "[REDACTED]", as in the SDK's RPC logs. Requests are
+// matched with the same fields redacted. Other fields, such as pickled
+// Function arguments, are recorded verbatim, so review golden files before
+// committing them. Blobs
+// are transferred over HTTP rather than gRPC and are not recorded, so keep
+// payloads below ClientOptions.BlobThreshold. Streams are recorded when they
+// end, and streams that are abandoned before their end are not recorded.
+package rpcreplay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/modal-labs/libmodal/modal-go/internal/redact"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// exchange is a recorded RPC: its request, and the response of a unary RPC,
+// or the messages received on a stream, followed by the final status.
+type exchange struct {
+	Method    string            `json:"method"`
+	Request   json.RawMessage   `json:"request,omitempty"`
+	Responses []json.RawMessage `json:"responses,omitempty"`
+	Code      string            `json:"code,omitempty"` // status code name, empty for OK
+	Message   string            `json:"message,omitempty"`
+
+	used bool
+}
+
+func (ex *exchange) setStatus(err error) {
+	if err != nil {
+		s := status.Convert(err)
+		ex.Code, ex.Message = s.Code().String(), s.Message()
+	}
+}
+
+func (ex *exchange) err() error {
+	if ex.Code == "" {
+		return nil
+	}
+	for c := codes.OK; c <= codes.Unauthenticated; c++ {
+		if c.String() == ex.Code {
+			return status.Error(c, ex.Message)
+		}
+	}
+	return status.Error(codes.Unknown, ex.Message)
+}
+
+// marshal encodes a message for a golden file, with sensitive fields
+// redacted.
+func marshal(m any) (json.RawMessage, error) {
+	msg, ok := m.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("rpcreplay: message of type %T is not a proto message", m)
+	}
+	return protojson.Marshal(redact.Clone(msg, redact.DefaultFields))
+}
+
+func unmarshal(data json.RawMessage, m any) error {
+	msg, ok := m.(proto.Message)
+	if !ok {
+		return fmt.Errorf("rpcreplay: message of type %T is not a proto message", m)
+	}
+	return protojson.Unmarshal(data, msg)
+}
+
+// Recorder records RPCs made through its interceptors. It is safe for
+// concurrent use.
+type Recorder struct {
+	path      string
+	mu        sync.Mutex
+	exchanges []*exchange
+}
+
+// NewRecorder returns a Recorder that writes to the golden file at path when
+// closed.
+func NewRecorder(path string) *Recorder {
+	return &Recorder{path: path}
+}
+
+func (r *Recorder) add(ex *exchange) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.exchanges = append(r.exchanges, ex)
+}
+
+// UnaryInterceptor returns an interceptor that records unary RPCs.
+func (r *Recorder) UnaryInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		request, err := marshal(req)
+		if err != nil {
+			return err
+		}
+		callErr := invoker(ctx, method, req, reply, cc, opts...)
+		ex := &exchange{Method: method, Request: request}
+		if callErr == nil {
+			response, err := marshal(reply)
+			if err != nil {
+				return err
+			}
+			ex.Responses = []json.RawMessage{response}
+		}
+		ex.setStatus(callErr)
+		r.add(ex)
+		return callErr
+	}
+}
+
+// StreamInterceptor returns an interceptor that records streaming RPCs.
+func (r *Recorder) StreamInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			ex := &exchange{Method: method}
+			ex.setStatus(err)
+			r.add(ex)
+			return nil, err
+		}
+		return &recordingStream{ClientStream: stream, r: r, ex: &exchange{Method: method}}, nil
+	}
+}
+
+// recordingStream records the last message sent, and the messages received
+// until the stream ends.
+type recordingStream struct {
+	grpc.ClientStream
+	r    *Recorder
+	ex   *exchange
+	done bool
+}
+
+func (s *recordingStream) SendMsg(m any) error {
+	request, err := marshal(m)
+	if err != nil {
+		return err
+	}
+	s.ex.Request = request
+	return s.ClientStream.SendMsg(m)
+}
+
+func (s *recordingStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	if s.done {
+		return err
+	}
+	if err == nil {
+		response, err := marshal(m)
+		if err != nil {
+			return err
+		}
+		s.ex.Responses = append(s.ex.Responses, response)
+		return nil
+	}
+	if err != io.EOF {
+		s.ex.setStatus(err)
+	}
+	s.done = true
+	s.r.add(s.ex)
+	return err
+}
+
+// Close writes the recorded exchanges to the golden file.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data, err := json.MarshalIndent(r.exchanges, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, append(data, '\n'), 0o644)
+}
+
+// Replayer answers RPCs made through its interceptors from a golden file,
+// without calling Modal. It is safe for concurrent use.
+type Replayer struct {
+	mu        sync.Mutex
+	exchanges []*exchange
+}
+
+// NewReplayer returns a Replayer for the golden file at path, written by a
+// Recorder.
+func NewReplayer(path string) (*Replayer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var exchanges []*exchange
+	if err := json.Unmarshal(data, &exchanges); err != nil {
+		return nil, fmt.Errorf("rpcreplay: invalid golden file %s: %w", path, err)
+	}
+	return &Replayer{exchanges: exchanges}, nil
+}
+
+// take marks and returns the exchange that answers an RPC, or nil. req may be
+// nil, to match on the method alone.
+func (r *Replayer) take(method string, req any) *exchange {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var fallback *exchange
+	for _, ex := range r.exchanges {
+		if ex.used || ex.Method != method {
+			continue
+		}
+		if fallback == nil {
+			fallback = ex
+		}
+		if req != nil && sameRequest(ex.Request, req) {
+			ex.used = true
+			return ex
+		}
+	}
+	if fallback != nil {
+		fallback.used = true
+	}
+	return fallback
+}
+
+func sameRequest(recorded json.RawMessage, req any) bool {
+	msg, ok := req.(proto.Message)
+	if !ok || recorded == nil {
+		return false
+	}
+	other := msg.ProtoReflect().New().Interface()
+	return protojson.Unmarshal(recorded, other) == nil && proto.Equal(redact.Clone(msg, redact.DefaultFields), other)
+}
+
+func notRecorded(method string) error {
+	return status.Errorf(codes.FailedPrecondition, "rpcreplay: no recorded exchange for %s", method)
+}
+
+// Unused returns the number of recorded exchanges that no RPC has used yet.
+func (r *Replayer) Unused() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, ex := range r.exchanges {
+		if !ex.used {
+			n++
+		}
+	}
+	return n
+}
+
+// UnaryInterceptor returns an interceptor that answers unary RPCs.
+func (r *Replayer) UnaryInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ex := r.take(method, req)
+		if ex == nil {
+			return notRecorded(method)
+		}
+		if len(ex.Responses) > 0 {
+			if err := unmarshal(ex.Responses[0], reply); err != nil {
+				return err
+			}
+		}
+		return ex.err()
+	}
+}
+
+// StreamInterceptor returns an interceptor that answers streaming RPCs.
+func (r *Replayer) StreamInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return &replayStream{ctx: ctx, r: r, method: method}, nil
+	}
+}
+
+// replayStream picks its exchange when the first message is received, after
+// the request has been sent.
+type replayStream struct {
+	ctx     context.Context
+	r       *Replayer
+	method  string
+	request any
+	ex      *exchange
+	next    int
+}
+
+func (s *replayStream) Header() (metadata.MD, error) { return metadata.MD{}, nil }
+func (s *replayStream) Trailer() metadata.MD         { return metadata.MD{} }
+func (s *replayStream) CloseSend() error             { return nil }
+func (s *replayStream) Context() context.Context     { return s.ctx }
+
+func (s *replayStream) SendMsg(m any) error {
+	s.request = m
+	return nil
+}
+
+func (s *replayStream) RecvMsg(m any) error {
+	if s.ex == nil {
+		s.ex = s.r.take(s.method, s.request)
+		if s.ex == nil {
+			return notRecorded(s.method)
+		}
+	}
+	if s.next < len(s.ex.Responses) {
+		s.next++
+		return unmarshal(s.ex.Responses[s.next-1], m)
+	}
+	if err := s.ex.err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
@@ -0,0 +1,158 @@
+package rpcreplay
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/modal-labs/libmodal/modal-go"
+	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+	"github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	appMethod  = "/modal.client.ModalClient/AppGetOrCreate"
+	logsMethod = "/modal.client.ModalClient/AppGetLogs"
+)
+
+// fakeStream is a server stream that returns batches, then err.
+type fakeStream struct {
+	grpc.ClientStream
+	batches []*pb.TaskLogsBatch
+	err     error
+}
+
+func (s *fakeStream) SendMsg(m any) error { return nil }
+
+func (s *fakeStream) RecvMsg(m any) error {
+	if len(s.batches) == 0 {
+		return s.err
+	}
+	proto.Merge(m.(*pb.TaskLogsBatch), s.batches[0])
+	s.batches = s.batches[1:]
+	return nil
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "golden.json")
+
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		name := req.(*pb.AppGetOrCreateRequest).GetAppName()
+		if name == "missing" {
+			return status.Error(codes.NotFound, "app not found")
+		}
+		proto.Merge(reply.(*pb.AppGetOrCreateResponse), pb.AppGetOrCreateResponse_builder{AppId: "ap-" + name}.Build())
+		return nil
+	}
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return &fakeStream{
+			batches: []*pb.TaskLogsBatch{pb.TaskLogsBatch_builder{EntryId: "1"}.Build(), pb.TaskLogsBatch_builder{EntryId: "2"}.Build()},
+			err:     io.EOF,
+		}, nil
+	}
+
+	rec := NewRecorder(path)
+	unary, stream := rec.UnaryInterceptor(), rec.StreamInterceptor()
+	for _, name := range []string{"first", "second", "missing"} {
+		req := pb.AppGetOrCreateRequest_builder{AppName: name}.Build()
+		_ = unary(ctx, appMethod, req, &pb.AppGetOrCreateResponse{}, nil, invoker)
+	}
+	s, err := stream(ctx, &grpc.StreamDesc{ServerStreams: true}, nil, logsMethod, streamer)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(s.SendMsg(pb.AppGetLogsRequest_builder{AppId: "ap-first"}.Build())).To(gomega.Succeed())
+	for s.RecvMsg(&pb.TaskLogsBatch{}) == nil {
+	}
+	g.Expect(rec.Close()).To(gomega.Succeed())
+
+	rep, err := NewReplayer(path)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	unary, stream = rep.UnaryInterceptor(), rep.StreamInterceptor()
+	noNetwork := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		t.Fatal("replayed RPC called Modal")
+		return nil
+	}
+
+	// Requests are matched regardless of order.
+	reply := &pb.AppGetOrCreateResponse{}
+	err = unary(ctx, appMethod, pb.AppGetOrCreateRequest_builder{AppName: "second"}.Build(), reply, nil, noNetwork)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(reply.GetAppId()).To(gomega.Equal("ap-second"))
+
+	err = unary(ctx, appMethod, pb.AppGetOrCreateRequest_builder{AppName: "missing"}.Build(), reply, nil, noNetwork)
+	g.Expect(status.Code(err)).To(gomega.Equal(codes.NotFound))
+	g.Expect(status.Convert(err).Message()).To(gomega.Equal("app not found"))
+
+	// An unmatched request falls back to the next exchange of the method.
+	err = unary(ctx, appMethod, pb.AppGetOrCreateRequest_builder{AppName: "random"}.Build(), reply, nil, noNetwork)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(reply.GetAppId()).To(gomega.Equal("ap-first"))
+
+	err = unary(ctx, appMethod, pb.AppGetOrCreateRequest_builder{AppName: "first"}.Build(), reply, nil, noNetwork)
+	g.Expect(status.Code(err)).To(gomega.Equal(codes.FailedPrecondition))
+
+	s, err = stream(ctx, &grpc.StreamDesc{ServerStreams: true}, nil, logsMethod, nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(s.SendMsg(pb.AppGetLogsRequest_builder{AppId: "ap-first"}.Build())).To(gomega.Succeed())
+	var entryIds []string
+	for {
+		batch := &pb.TaskLogsBatch{}
+		err := s.RecvMsg(batch)
+		if err == io.EOF {
+			break
+		}
+		g.Expect(err).ShouldNot(gomega.HaveOccurred())
+		entryIds = append(entryIds, batch.GetEntryId())
+	}
+	g.Expect(entryIds).To(gomega.Equal([]string{"1", "2"}))
+	g.Expect(rep.Unused()).To(gomega.Equal(0))
+}
+
+func TestRecordRedactsSecrets(t *testing.T) {
+	g := gomega.NewWithT(t)
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "golden.json")
+
+	server := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		proto.Merge(reply.(proto.Message), pb.SecretGetOrCreateResponse_builder{SecretId: "st-123"}.Build())
+		return nil
+	}
+	rec := NewRecorder(path)
+	err := modal.InitializeClient(modal.ClientOptions{
+		TokenId:           "token-id",
+		TokenSecret:       "token-secret",
+		UnaryInterceptors: []grpc.UnaryClientInterceptor{rec.UnaryInterceptor(), server},
+	})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	_, err = modal.SecretFromMap(ctx, map[string]string{"API_KEY": "hunter2"}, nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(rec.Close()).To(gomega.Succeed())
+
+	data, err := os.ReadFile(path)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(string(data)).To(gomega.ContainSubstring(`"API_KEY": "[REDACTED]"`))
+	g.Expect(string(data)).NotTo(gomega.ContainSubstring("hunter2"))
+	g.Expect(string(data)).NotTo(gomega.ContainSubstring("token-secret"))
+
+	// The request still matches its redacted recording when replayed.
+	rep, err := NewReplayer(path)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	err = modal.InitializeClient(modal.ClientOptions{
+		TokenId:           "replay",
+		TokenSecret:       "replay",
+		UnaryInterceptors: []grpc.UnaryClientInterceptor{rep.UnaryInterceptor()},
+	})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	secret, err := modal.SecretFromMap(ctx, map[string]string{"API_KEY": "hunter2"}, nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(secret.SecretId).To(gomega.Equal("st-123"))
+	g.Expect(rep.Unused()).To(gomega.Equal(0))
+}
@@ -5,12 +5,20 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"iter"
+	"maps"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/djherbis/buffer"
 	"github.com/djherbis/nio/v3"
 	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // StdioBehavior defines how the standard input/output/error streams should behave.
@@ -20,6 +28,8 @@ const (
 	// Pipe allows the sandbox to pipe the streams.
 	Pipe StdioBehavior = "pipe"
 	// Ignore ignores the streams, meaning they will not be available.
+	// For a Sandbox's entrypoint, ignored output is not streamed to the
+	// client but remains available in the Sandbox logs on Modal.
 	Ignore StdioBehavior = "ignore"
 )
 
@@ -33,6 +43,24 @@ type ExecOptions struct {
 	Workdir string
 	// Timeout is the timeout for command execution. Defaults to 0 (no timeout).
 	Timeout time.Duration
+	// Secrets are injected as environment variables into this command only.
+	Secrets []*Secret
+	// EnvVars are environment variables set for this command only.
+	EnvVars map[string]string
+	// MaxOutputBytes caps the bytes returned from each of stdout and stderr.
+	// Further output is read and discarded, which is reported by
+	// ContainerProcess.StdoutStats and StderrStats. Defaults to 0 (no limit).
+	MaxOutputBytes int
+	// CleanEnv runs the command in an empty environment, without the
+	// environment variables of the Sandbox, its Secrets, or its Image. Only
+	// EnvVars, and a default PATH unless EnvVars sets one, are set. This
+	// does not isolate secrets: the command runs as the same user in the
+	// same container, and can still read the Sandbox's environment, such as
+	// from /proc/1/environ. Use a separate Sandbox for untrusted code. The
+	// command is started with `sh -c 'exec env -i ...'`, so the Image must
+	// have sh and env. Secrets cannot be used with it, since the client does
+	// not know their keys.
+	CleanEnv bool
 }
 
 // Tunnel represents a port forwarded from within a running Modal sandbox.
@@ -75,19 +103,87 @@ type Sandbox struct {
 	ctx     context.Context
 	taskId  string
 	tunnels map[int]*Tunnel
+	health  *sandboxHealthMonitor // nil without SandboxOptions.HealthCheck
+	hooks   *sandboxHooks         // callbacks registered with OnStart, OnExit, and OnOOM
+
+	definitionHash string             // empty if not created by CreateSandbox
+	definition     *SandboxDefinition // nil if not created by CreateSandbox
+	detached       bool               // set by SandboxOptions.Detach
+	release        func()             // frees the Sandbox's slot in AppLimits, nil if it has none
+	idempotencyKey string             // set by SandboxOptions.IdempotencyKey
+
+	backgroundProcesses map[string]*ContainerProcess
+}
+
+// WithContext returns a copy of the Sandbox whose calls use ctx, for example
+// to apply a request's deadline to Wait or Exec. The copy shares the
+// Sandbox's Stdin, Stdout, and Stderr, which keep the original's context.
+func (sb *Sandbox) WithContext(ctx context.Context) (*Sandbox, error) {
+	ctx, err := handleContext(ctx, sb.ctx)
+	if err != nil {
+		return nil, err
+	}
+	c := *sb
+	c.ctx = ctx
+	return &c, nil
 }
 
 // newSandbox creates a new Sandbox object from ID.
 func newSandbox(ctx context.Context, sandboxId string) *Sandbox {
-	sb := &Sandbox{SandboxId: sandboxId, ctx: ctx}
+	return newSandboxWithStdio(ctx, sandboxId, Pipe, Pipe)
+}
+
+// AdoptSandbox returns a running or finished Sandbox by ID, such as one
+// started with SandboxOptions.Detach by another program. Its methods use a
+// context that is not cancelled with ctx. The Sandbox's output is read from
+// the start, and it is not terminated when the adopting program exits. Writes
+// to its Stdin are dropped by Modal if another program already wrote to it,
+// since each program numbers its writes from the start.
+func AdoptSandbox(ctx context.Context, sandboxId string) (*Sandbox, error) {
+	var err error
+	ctx, err = clientContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	_, err = client.SandboxWait(ctx, pb.SandboxWaitRequest_builder{
+		SandboxId: sandboxId,
+		Timeout:   0,
+	}.Build())
+	if status, ok := status.FromError(err); ok && status.Code() == codes.NotFound {
+		return nil, NotFoundError{fmt.Sprintf("Sandbox '%s' not found", sandboxId)}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newSandbox(context.WithoutCancel(ctx), sandboxId), nil
+}
+
+// newSandboxWithStdio creates a new Sandbox object from ID. Output streams
+// with the Ignore behavior are never fetched from Modal.
+func newSandboxWithStdio(ctx context.Context, sandboxId string, stdout, stderr StdioBehavior) *Sandbox {
+	sb := &Sandbox{SandboxId: sandboxId, ctx: ctx, hooks: &sandboxHooks{}}
 	sb.Stdin = inputStreamSb(ctx, sandboxId)
-	sb.Stdout = outputStreamSb(ctx, sandboxId, pb.FileDescriptor_FILE_DESCRIPTOR_STDOUT)
-	sb.Stderr = outputStreamSb(ctx, sandboxId, pb.FileDescriptor_FILE_DESCRIPTOR_STDERR)
+	if stdout == Ignore {
+		sb.Stdout = io.NopCloser(bytes.NewReader(nil))
+	} else {
+		sb.Stdout = outputStreamSb(ctx, sandboxId, pb.FileDescriptor_FILE_DESCRIPTOR_STDOUT)
+	}
+	if stderr == Ignore {
+		sb.Stderr = io.NopCloser(bytes.NewReader(nil))
+	} else {
+		sb.Stderr = outputStreamSb(ctx, sandboxId, pb.FileDescriptor_FILE_DESCRIPTOR_STDERR)
+	}
 	return sb
 }
 
 // Exec runs a command in the sandbox and returns text streams.
 func (sb *Sandbox) Exec(command []string, opts ExecOptions) (*ContainerProcess, error) {
+	if opts.CleanEnv {
+		var err error
+		if command, err = cleanEnvCommand(command, opts); err != nil {
+			return nil, err
+		}
+	}
 	if err := sb.ensureTaskId(); err != nil {
 		return nil, err
 	}
@@ -95,11 +191,16 @@ func (sb *Sandbox) Exec(command []string, opts ExecOptions) (*ContainerProcess,
 	if opts.Workdir != "" {
 		workdir = &opts.Workdir
 	}
+	secretIds, err := secretIdsWithEnv(sb.ctx, opts.Secrets, opts.EnvVars)
+	if err != nil {
+		return nil, err
+	}
 	resp, err := client.ContainerExec(sb.ctx, pb.ContainerExecRequest_builder{
 		TaskId:      sb.taskId,
 		Command:     command,
 		Workdir:     workdir,
-		TimeoutSecs: uint32(opts.Timeout.Seconds()),
+		TimeoutSecs: timeoutSecs(opts.Timeout),
+		SecretIds:   secretIds,
 	}.Build())
 	if err != nil {
 		return nil, err
@@ -107,6 +208,151 @@ func (sb *Sandbox) Exec(command []string, opts ExecOptions) (*ContainerProcess,
 	return newContainerProcess(sb.ctx, resp.GetExecId(), opts), nil
 }
 
+// cleanEnvPath is the PATH of commands run with ExecOptions.CleanEnv.
+const cleanEnvPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// envNamePattern matches environment variable names that sh can expand.
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// cleanEnvCommand wraps command to run with only the EnvVars of opts. Their
+// values are read from the environment that Modal sets for the exec, so they
+// do not appear in the command line.
+func cleanEnvCommand(command []string, opts ExecOptions) ([]string, error) {
+	if len(command) == 0 {
+		return nil, InvalidError{"command must not be empty"}
+	}
+	if len(opts.Secrets) > 0 {
+		return nil, InvalidError{"Secrets cannot be used with CleanEnv, pass values in EnvVars instead"}
+	}
+	var assignments []string
+	if _, ok := opts.EnvVars["PATH"]; !ok {
+		assignments = append(assignments, "PATH="+cleanEnvPath)
+	}
+	for _, name := range slices.Sorted(maps.Keys(opts.EnvVars)) {
+		if !envNamePattern.MatchString(name) {
+			return nil, InvalidError{fmt.Sprintf("invalid environment variable name for CleanEnv: %q", name)}
+		}
+		assignments = append(assignments, fmt.Sprintf(`%s="$%s"`, name, name))
+	}
+	script := `exec env -i ` + strings.Join(assignments, " ") + ` "$@"`
+	return append([]string{"sh", "-c", script, "sh"}, command...), nil
+}
+
+// AttachExec reattaches to a command started with Exec, by its
+// ContainerProcess.ExecId, such as after the process that started it crashed.
+//
+// Stdout and Stderr replay the command's output from the start, as far as
+// Modal retains it, and follow it until the command exits. Stdin is not
+// available, since the position of the previous writer's input cannot be
+// recovered, and writes to it fail.
+func (sb *Sandbox) AttachExec(execId string, opts ExecOptions) (*ContainerProcess, error) {
+	_, err := client.ContainerExecWait(sb.ctx, pb.ContainerExecWaitRequest_builder{
+		ExecId:  execId,
+		Timeout: 0,
+	}.Build())
+	if status.Code(err) == codes.NotFound {
+		return nil, NotFoundError{fmt.Sprintf("exec '%s' not found", execId)}
+	}
+	if err != nil {
+		return nil, err
+	}
+	cp := newContainerProcess(sb.ctx, execId, opts)
+	cp.Stdin = closedStdin{}
+	return cp, nil
+}
+
+// closedStdin is the Stdin of a reattached ContainerProcess.
+type closedStdin struct{}
+
+func (closedStdin) Write(p []byte) (int, error) {
+	return 0, InvalidError{"stdin of a reattached exec is not writable"}
+}
+
+func (closedStdin) Close() error { return nil }
+
+// RunResult is the outcome of a command run with Sandbox.Run.
+type RunResult struct {
+	Stdout      []byte
+	Stderr      []byte
+	ExitCode    int
+	StdoutStats ExecOutputStats // Total bytes written to stdout, and whether Stdout was truncated.
+	StderrStats ExecOutputStats // Total bytes written to stderr, and whether Stderr was truncated.
+}
+
+const defaultRunMaxOutputBytes = 10 * 1024 * 1024
+
+// Run executes a command in the sandbox, waits for it to exit, and returns its
+// output and exit code. It is a shorthand for Exec, reading both output
+// streams, and Wait. Unless opts.MaxOutputBytes is set, at most 10 MiB of
+// each stream is returned, and RunResult.StdoutStats and StderrStats report
+// whether any output was discarded.
+func (sb *Sandbox) Run(command []string, opts ExecOptions) (*RunResult, error) {
+	if opts.MaxOutputBytes <= 0 {
+		opts.MaxOutputBytes = defaultRunMaxOutputBytes
+	}
+
+	cp, err := sb.Exec(command, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RunResult{}
+	var wg sync.WaitGroup
+	var stdoutErr, stderrErr error
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		result.Stdout, stdoutErr = io.ReadAll(cp.Stdout)
+	}()
+	go func() {
+		defer wg.Done()
+		result.Stderr, stderrErr = io.ReadAll(cp.Stderr)
+	}()
+	wg.Wait()
+	if stdoutErr != nil {
+		return nil, stdoutErr
+	}
+	if stderrErr != nil {
+		return nil, stderrErr
+	}
+
+	result.ExitCode, err = cp.Wait()
+	if err != nil {
+		return nil, err
+	}
+	result.StdoutStats = cp.StdoutStats()
+	result.StderrStats = cp.StderrStats()
+	return result, nil
+}
+
+// secretIdsWithEnv returns the IDs of the Secrets to inject into a command,
+// creating an ephemeral Secret for envVars if needed.
+func secretIdsWithEnv(ctx context.Context, secrets []*Secret, envVars map[string]string) ([]string, error) {
+	var secretIds []string
+	for _, secret := range secrets {
+		if secret == nil {
+			continue
+		}
+		secretIds = append(secretIds, secret.SecretId)
+	}
+	if len(envVars) > 0 {
+		secret, err := SecretFromMap(ctx, envVars, nil)
+		if err != nil {
+			return nil, err
+		}
+		secretIds = append(secretIds, secret.SecretId)
+	}
+	return secretIds, nil
+}
+
+// DefinitionHash returns a hash of the definition the Sandbox was created
+// with: its Image, command, resources, Volumes, Secrets, environment
+// variables, and other options. Sandboxes created with equal options have
+// equal hashes, which can be used as a cache key for their setup.
+func (sb *Sandbox) DefinitionHash() string {
+	return sb.definitionHash
+}
+
 // Open opens a file in the sandbox filesystem.
 // The mode parameter follows the same conventions as os.OpenFile:
 // "r" for read-only, "w" for write-only (truncates), "a" for append, etc.
@@ -153,34 +399,160 @@ func (sb *Sandbox) ensureTaskId() error {
 	return nil
 }
 
+// DashboardURL returns a link to the Sandbox in the Modal web UI.
+func (sb *Sandbox) DashboardURL() string {
+	return dashboardURL(sb.SandboxId)
+}
+
 // Terminate stops the sandbox.
 func (sb *Sandbox) Terminate() error {
-	_, err := client.SandboxTerminate(sb.ctx, pb.SandboxTerminateRequest_builder{
+	return sb.terminate(sb.ctx)
+}
+
+func (sb *Sandbox) terminate(ctx context.Context) error {
+	if sb.health != nil {
+		sb.health.cancel()
+	}
+	_, err := client.SandboxTerminate(ctx, pb.SandboxTerminateRequest_builder{
 		SandboxId: sb.SandboxId,
 	}.Build())
 	if err != nil {
 		return err
 	}
 	sb.taskId = ""
+	sb.forgetIdempotencyKey()
+	if sb.release != nil {
+		sb.release()
+	}
+	return nil
+}
+
+// forgetIdempotencyKey stops returning the Sandbox for its idempotency key.
+func (sb *Sandbox) forgetIdempotencyKey() {
+	if sb.idempotencyKey != "" {
+		createdSandboxes.CompareAndDelete(sb.idempotencyKey, sb)
+	}
+}
+
+// Maximum time to terminate a Sandbox that CreateSandbox does not return.
+const discardTimeout = 10 * time.Second
+
+// discard terminates a Sandbox that CreateSandbox failed to return because of
+// cause, unless keep is set. It terminates even if the Sandbox's context is
+// done, and returns cause, or an OrphanedSandboxError if the Sandbox is still
+// running.
+func (sb *Sandbox) discard(cause error, keep bool) error {
+	if keep {
+		return OrphanedSandboxError{
+			Exception: fmt.Sprintf("Sandbox %s was kept running after creation was interrupted: %v", sb.SandboxId, cause),
+			SandboxId: sb.SandboxId,
+			cause:     cause,
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(sb.ctx), discardTimeout)
+	defer cancel()
+	if err := sb.terminate(ctx); err != nil {
+		return OrphanedSandboxError{
+			Exception: fmt.Sprintf("failed to terminate Sandbox %s after creation was interrupted by %v: %v", sb.SandboxId, cause, err),
+			SandboxId: sb.SandboxId,
+			cause:     cause,
+		}
+	}
+	return cause
+}
+
+// SetTags replaces the Sandbox's tags. Tags are key/value labels, such as a
+// team or cost center, that are reported in Sandbox listings and cost
+// estimates.
+func (sb *Sandbox) SetTags(tags map[string]string) error {
+	if err := validateTags(tags); err != nil {
+		return err
+	}
+	_, err := client.SandboxTagsSet(sb.ctx, pb.SandboxTagsSetRequest_builder{
+		EnvironmentName: environmentName(sb.ctx, ""),
+		SandboxId:       sb.SandboxId,
+		Tags:            tagsToProto(tags),
+	}.Build())
+	return err
+}
+
+func validateTags(tags map[string]string) error {
+	for name := range tags {
+		if name == "" {
+			return InvalidError{"tag names must not be empty"}
+		}
+	}
+	return nil
+}
+
+// tagsToProto converts tags to their proto form, sorted by name so that
+// requests are deterministic.
+func tagsToProto(tags map[string]string) []*pb.SandboxTag {
+	var result []*pb.SandboxTag
+	for _, name := range slices.Sorted(maps.Keys(tags)) {
+		result = append(result, pb.SandboxTag_builder{TagName: name, TagValue: tags[name]}.Build())
+	}
+	return result
+}
+
+func tagsFromProto(tags []*pb.SandboxTag) map[string]string {
+	result := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		result[tag.GetTagName()] = tag.GetTagValue()
+	}
+	return result
+}
+
+// startBackgroundProcesses execs the background processes, waiting for the
+// sandbox to start.
+func (sb *Sandbox) startBackgroundProcesses(processes []BackgroundProcess) error {
+	sb.backgroundProcesses = make(map[string]*ContainerProcess, len(processes))
+	for _, process := range processes {
+		cp, err := sb.Exec(process.Command, ExecOptions{
+			Workdir: process.Workdir,
+			EnvVars: process.EnvVars,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to start background process %q: %w", process.Name, err)
+		}
+		sb.backgroundProcesses[process.Name] = cp
+	}
 	return nil
 }
 
+// BackgroundProcess returns the process with the given name, from
+// SandboxOptions.BackgroundProcesses, or nil if there is none. Its output can
+// be read from the process's Stdout and Stderr, and Wait returns its exit
+// code; it is not restarted after exiting.
+func (sb *Sandbox) BackgroundProcess(name string) *ContainerProcess {
+	return sb.backgroundProcesses[name]
+}
+
 // Wait blocks until the sandbox exits.
 func (sb *Sandbox) Wait() (int, error) {
+	result, err := sb.waitResult(sb.ctx)
+	if err != nil {
+		return 0, err
+	}
+	returnCode := getReturnCode(result)
+	if returnCode != nil {
+		return *returnCode, nil
+	}
+	return 0, nil
+}
+
+// waitResult blocks until the sandbox exits, and returns its result.
+func (sb *Sandbox) waitResult(ctx context.Context) (*pb.GenericResult, error) {
 	for {
-		resp, err := client.SandboxWait(sb.ctx, pb.SandboxWaitRequest_builder{
+		resp, err := client.SandboxWait(ctx, pb.SandboxWaitRequest_builder{
 			SandboxId: sb.SandboxId,
 			Timeout:   55,
 		}.Build())
 		if err != nil {
-			return 0, err
+			return nil, err
 		}
 		if resp.GetResult() != nil {
-			returnCode := getReturnCode(resp.GetResult())
-			if returnCode != nil {
-				return *returnCode, nil
-			}
-			return 0, nil
+			return resp.GetResult(), nil
 		}
 	}
 }
@@ -251,17 +623,124 @@ func getReturnCode(result *pb.GenericResult) *int {
 	return &exitCode
 }
 
+// SandboxInfo describes a Sandbox in a listing, see App.ListSandboxes.
+type SandboxInfo struct {
+	SandboxId  string
+	CreatedAt  time.Time
+	FinishedAt time.Time         // Zero if the Sandbox is still running.
+	ExitCode   *int              // As returned by Sandbox.Poll, nil if still running.
+	Tags       map[string]string // Tags set on the Sandbox.
+}
+
+// SandboxListOptions are options for App.ListSandboxes.
+type SandboxListOptions struct {
+	IncludeFinished bool              // Also list Sandboxes that have finished.
+	Tags            map[string]string // Only list Sandboxes that have all of these tags.
+}
+
+// ListSandboxes returns an iterator over the App's Sandboxes, newest first.
+// Pages are fetched from Modal as iteration proceeds.
+func (app *App) ListSandboxes(options *SandboxListOptions) iter.Seq2[SandboxInfo, error] {
+	if options == nil {
+		options = &SandboxListOptions{}
+	}
+	return func(yield func(SandboxInfo, error) bool) {
+		for info, err := range app.sandboxInfos(options.IncludeFinished, options.Tags, 0) {
+			if err != nil {
+				yield(SandboxInfo{}, err)
+				return
+			}
+			sandbox := SandboxInfo{
+				SandboxId: info.GetId(),
+				CreatedAt: time.Unix(0, int64(info.GetCreatedAt()*1e9)),
+				ExitCode:  getReturnCode(info.GetTaskInfo().GetResult()),
+			}
+			if finishedAt := info.GetTaskInfo().GetFinishedAt(); finishedAt != 0 {
+				sandbox.FinishedAt = time.Unix(0, int64(finishedAt*1e9))
+			}
+			sandbox.Tags = tagsFromProto(info.GetTags())
+			if !yield(sandbox, nil) {
+				return
+			}
+		}
+	}
+}
+
+// sandboxInfos returns an iterator over the App's Sandboxes that have all of
+// tags, newest first, fetching a page at a time. If before is nonzero, only
+// Sandboxes created before it, in seconds since the epoch, are listed.
+func (app *App) sandboxInfos(includeFinished bool, tags map[string]string, before float64) iter.Seq2[*pb.SandboxInfo, error] {
+	return func(yield func(*pb.SandboxInfo, error) bool) {
+		// Sandboxes created at the same time can be split across pages, so
+		// each page after the first starts just after the oldest creation
+		// time of the previous one, skipping the Sandboxes already listed.
+		var boundary float64
+		listed := map[string]bool{}
+		for {
+			resp, err := client.SandboxList(app.ctx, pb.SandboxListRequest_builder{
+				AppId:           app.AppId,
+				EnvironmentName: app.Environment(),
+				BeforeTimestamp: before,
+				IncludeFinished: includeFinished,
+				Tags:            tagsToProto(tags),
+			}.Build())
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			page := resp.GetSandboxes()
+			if len(page) == 0 {
+				return
+			}
+			var fresh bool
+			for _, info := range page {
+				if info.GetCreatedAt() == boundary && listed[info.GetId()] {
+					continue
+				}
+				fresh = true
+				if !yield(info, nil) {
+					return
+				}
+			}
+			last := page[len(page)-1].GetCreatedAt()
+			if !fresh {
+				// A whole page created at the same time, step past it.
+				before = last
+				continue
+			}
+			if last != boundary {
+				boundary = last
+				clear(listed)
+			}
+			for _, info := range page {
+				if info.GetCreatedAt() == boundary {
+					listed[info.GetId()] = true
+				}
+			}
+			before = math.Nextafter(last, math.Inf(1))
+		}
+	}
+}
+
 // ContainerProcess represents a process running in a Modal container, allowing
 // interaction with its standard input/output/error streams.
 //
 // It is created by executing a command in a sandbox.
 type ContainerProcess struct {
+	ExecId string // ID of the command, to reattach with Sandbox.AttachExec.
 	Stdin  io.WriteCloser
 	Stdout io.ReadCloser
 	Stderr io.ReadCloser
 
-	ctx    context.Context
-	execId string
+	ctx         context.Context
+	stdoutLimit *truncatingReader
+	stderrLimit *truncatingReader
+}
+
+// ExecOutputStats reports how much a command wrote to an output stream.
+type ExecOutputStats struct {
+	TotalBytes int64 // Bytes written by the command, including discarded ones.
+	Truncated  bool  // Whether output beyond ExecOptions.MaxOutputBytes was discarded.
 }
 
 func newContainerProcess(ctx context.Context, execId string, opts ExecOptions) *ContainerProcess {
@@ -274,7 +753,7 @@ func newContainerProcess(ctx context.Context, execId string, opts ExecOptions) *
 		stderrBehavior = opts.Stderr
 	}
 
-	cp := &ContainerProcess{execId: execId, ctx: ctx}
+	cp := &ContainerProcess{ExecId: execId, ctx: ctx}
 	cp.Stdin = inputStreamCp(ctx, execId)
 
 	cp.Stdout = outputStreamCp(ctx, execId, pb.FileDescriptor_FILE_DESCRIPTOR_STDOUT)
@@ -288,14 +767,71 @@ func newContainerProcess(ctx context.Context, execId string, opts ExecOptions) *
 		cp.Stderr = io.NopCloser(bytes.NewReader(nil))
 	}
 
+	limit := int64(math.MaxInt64)
+	if opts.MaxOutputBytes > 0 {
+		limit = int64(opts.MaxOutputBytes)
+	}
+	cp.stdoutLimit = &truncatingReader{r: cp.Stdout, max: limit}
+	cp.stderrLimit = &truncatingReader{r: cp.Stderr, max: limit}
+	cp.Stdout = cp.stdoutLimit
+	cp.Stderr = cp.stderrLimit
+
 	return cp
 }
 
+// StdoutStats returns the number of bytes written to stdout so far, and
+// whether any were discarded. The counts are final once Stdout returns EOF.
+func (cp *ContainerProcess) StdoutStats() ExecOutputStats {
+	return cp.stdoutLimit.Stats()
+}
+
+// StderrStats is like StdoutStats, for stderr.
+func (cp *ContainerProcess) StderrStats() ExecOutputStats {
+	return cp.stderrLimit.Stats()
+}
+
+// truncatingReader returns the first max bytes of r, then reads and counts
+// the rest without returning it.
+type truncatingReader struct {
+	r   io.ReadCloser
+	max int64
+
+	mu    sync.Mutex
+	stats ExecOutputStats
+}
+
+func (t *truncatingReader) Read(p []byte) (int, error) {
+	for {
+		n, err := t.r.Read(p)
+		t.mu.Lock()
+		kept := max(min(int64(n), t.max-t.stats.TotalBytes), 0)
+		t.stats.TotalBytes += int64(n)
+		if kept < int64(n) {
+			t.stats.Truncated = true
+		}
+		t.mu.Unlock()
+		if kept > 0 || err != nil {
+			return int(kept), err
+		}
+	}
+}
+
+func (t *truncatingReader) Close() error {
+	return t.r.Close()
+}
+
+// Stats returns the counts so far.
+func (t *truncatingReader) Stats() ExecOutputStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.stats
+}
+
 // Wait blocks until the container process exits and returns its exit code.
 func (cp *ContainerProcess) Wait() (int, error) {
 	for {
 		resp, err := client.ContainerExecWait(cp.ctx, pb.ContainerExecWaitRequest_builder{
-			ExecId:  cp.execId,
+			ExecId:  cp.ExecId,
 			Timeout: 55,
 		}.Build())
 		if err != nil {
@@ -307,79 +843,113 @@ func (cp *ContainerProcess) Wait() (int, error) {
 	}
 }
 
+// Maximum number of bytes of stdin sent in one request.
+const stdinChunkSize = 1 << 20
+
 func inputStreamSb(ctx context.Context, sandboxId string) io.WriteCloser {
-	return &sbStdin{sandboxId: sandboxId, ctx: ctx, index: 1}
+	return &stdinWriter{send: func(data []byte, index uint64, eof bool) error {
+		_, err := client.SandboxStdinWrite(ctx, pb.SandboxStdinWriteRequest_builder{
+			SandboxId: sandboxId,
+			Input:     data,
+			Index:     uint32(index),
+			Eof:       eof,
+		}.Build())
+		return err
+	}}
 }
 
-type sbStdin struct {
-	sandboxId string
-	ctx       context.Context // context for the sandbox operations
-
-	mu    sync.Mutex // protects index
-	index uint32
+func inputStreamCp(ctx context.Context, execId string) io.WriteCloser {
+	return &stdinWriter{send: func(data []byte, index uint64, eof bool) error {
+		_, err := client.ContainerExecPutInput(ctx, pb.ContainerExecPutInputRequest_builder{
+			ExecId: execId,
+			Input: pb.RuntimeInputMessage_builder{
+				Message:      data,
+				MessageIndex: index,
+				Eof:          eof,
+			}.Build(),
+		}.Build())
+		return err
+	}}
 }
 
-func (s *sbStdin) Write(p []byte) (n int, err error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	index := s.index
-	s.index++
-	_, err = client.SandboxStdinWrite(s.ctx, pb.SandboxStdinWriteRequest_builder{
-		SandboxId: s.sandboxId,
-		Input:     p,
-		Index:     index,
-	}.Build())
-	if err != nil {
-		return 0, err
-	}
-	return len(p), nil
-}
+// stdinWriter writes to the stdin of a Sandbox or command, sending at most
+// stdinChunkSize bytes per request. Requests are sent one at a time, and Write
+// returns once Modal has accepted all of its data, so a writer that is faster
+// than the network is held back instead of buffering data in memory.
+//
+// It implements io.ReaderFrom, so io.Copy streams large inputs, such as a file,
+// in full-size chunks through a single buffer.
+type stdinWriter struct {
+	send func(data []byte, index uint64, eof bool) error
 
-func (s *sbStdin) Close() error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	_, err := client.SandboxStdinWrite(s.ctx, pb.SandboxStdinWriteRequest_builder{
-		SandboxId: s.sandboxId,
-		Index:     s.index,
-		Eof:       true,
-	}.Build())
-	return err
+	mu     sync.Mutex // serializes requests, and protects index and closed
+	index  uint64     // index of the last message sent, starting at 1
+	closed bool
 }
 
-func inputStreamCp(ctx context.Context, execId string) io.WriteCloser {
-	return &cpStdin{execId: execId, messageIndex: 1, ctx: ctx}
+func (w *stdinWriter) Write(p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, io.ErrClosedPipe
+	}
+	for n < len(p) {
+		chunk := p[n:min(len(p), n+stdinChunkSize)]
+		if err := w.sendLocked(chunk, false); err != nil {
+			return n, err
+		}
+		n += len(chunk)
+	}
+	return n, nil
 }
 
-type cpStdin struct {
-	execId       string
-	messageIndex uint64
-	ctx          context.Context // context for the exec operations
+func (w *stdinWriter) ReadFrom(r io.Reader) (n int64, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, io.ErrClosedPipe
+	}
+	buf := make([]byte, stdinChunkSize)
+	for {
+		m, readErr := r.Read(buf)
+		if m > 0 {
+			if err := w.sendLocked(buf[:m], false); err != nil {
+				return n, err
+			}
+			n += int64(m)
+		}
+		if readErr == io.EOF {
+			return n, nil
+		}
+		if readErr != nil {
+			return n, readErr
+		}
+	}
 }
 
-func (c *cpStdin) Write(p []byte) (n int, err error) {
-	_, err = client.ContainerExecPutInput(c.ctx, pb.ContainerExecPutInputRequest_builder{
-		ExecId: c.execId,
-		Input: pb.RuntimeInputMessage_builder{
-			Message:      p,
-			MessageIndex: c.messageIndex,
-		}.Build(),
-	}.Build())
-	if err != nil {
-		return 0, err
+// Close sends EOF after all data written before it. If sending fails, the
+// writer stays open, so that Close can be retried.
+func (w *stdinWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	if err := w.sendLocked(nil, true); err != nil {
+		return err
 	}
-	c.messageIndex++
-	return len(p), nil
+	w.closed = true
+	return nil
 }
 
-func (c *cpStdin) Close() error {
-	_, err := client.ContainerExecPutInput(c.ctx, pb.ContainerExecPutInputRequest_builder{
-		ExecId: c.execId,
-		Input: pb.RuntimeInputMessage_builder{
-			MessageIndex: c.messageIndex,
-			Eof:          true,
-		}.Build(),
-	}.Build())
-	return err
+// sendLocked sends a message with the next index. A failed message may be
+// retried by the next call, with the same index.
+func (w *stdinWriter) sendLocked(data []byte, eof bool) error {
+	if err := w.send(data, w.index+1, eof); err != nil {
+		return err
+	}
+	w.index++
+	return nil
 }
 
 func outputStreamSb(ctx context.Context, sandboxId string, fd pb.FileDescriptor) io.ReadCloser {
@@ -388,7 +958,7 @@ func outputStreamSb(ctx context.Context, sandboxId string, fd pb.FileDescriptor)
 		defer pw.Close()
 		lastIndex := "0-0"
 		completed := false
-		retries := 10
+		reconnector := newStreamReconnector()
 		for !completed {
 			stream, err := client.SandboxGetLogs(ctx, pb.SandboxGetLogsRequest_builder{
 				SandboxId:      sandboxId,
@@ -397,8 +967,7 @@ func outputStreamSb(ctx context.Context, sandboxId string, fd pb.FileDescriptor)
 				LastEntryId:    lastIndex,
 			}.Build())
 			if err != nil {
-				if isRetryableGrpc(err) && retries > 0 {
-					retries--
+				if reconnector.retry(ctx, err) {
 					continue
 				}
 				pw.CloseWithError(fmt.Errorf("error getting output stream: %w", err))
@@ -408,15 +977,14 @@ func outputStreamSb(ctx context.Context, sandboxId string, fd pb.FileDescriptor)
 				batch, err := stream.Recv()
 				if err != nil {
 					if err != io.EOF {
-						if isRetryableGrpc(err) && retries > 0 {
-							retries--
-						} else {
+						if !reconnector.retry(ctx, err) {
 							pw.CloseWithError(fmt.Errorf("error getting output stream: %w", err))
 							return
 						}
 					}
 					break // we need to retry, either from an EOF or gRPC error
 				}
+				reconnector.progress()
 				lastIndex = batch.GetEntryId()
 				for _, item := range batch.GetItems() {
 					// On error, writer has been closed. Still consume the rest of the channel.
@@ -438,7 +1006,7 @@ func outputStreamCp(ctx context.Context, execId string, fd pb.FileDescriptor) io
 		defer pw.Close()
 		var lastIndex uint64
 		completed := false
-		retries := 10
+		reconnector := newStreamReconnector()
 		for !completed {
 			stream, err := client.ContainerExecGetOutput(ctx, pb.ContainerExecGetOutputRequest_builder{
 				ExecId:         execId,
@@ -448,8 +1016,7 @@ func outputStreamCp(ctx context.Context, execId string, fd pb.FileDescriptor) io
 				LastBatchIndex: lastIndex,
 			}.Build())
 			if err != nil {
-				if isRetryableGrpc(err) && retries > 0 {
-					retries--
+				if reconnector.retry(ctx, err) {
 					continue
 				}
 				pw.CloseWithError(fmt.Errorf("error getting output stream: %w", err))
@@ -459,15 +1026,14 @@ func outputStreamCp(ctx context.Context, execId string, fd pb.FileDescriptor) io
 				batch, err := stream.Recv()
 				if err != nil {
 					if err != io.EOF {
-						if isRetryableGrpc(err) && retries > 0 {
-							retries--
-						} else {
+						if !reconnector.retry(ctx, err) {
 							pw.CloseWithError(fmt.Errorf("error getting output stream: %w", err))
 							return
 						}
 					}
 					break // we need to retry, either from an EOF or gRPC error
 				}
+				reconnector.progress()
 				lastIndex = batch.GetBatchIndex()
 				for _, item := range batch.GetItems() {
 					// On error, writer has been closed. Still consume the rest of the channel.
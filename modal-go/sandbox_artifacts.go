@@ -0,0 +1,143 @@
+package modal
+
+// Diagnostics of finished Sandboxes, from their result and logs.
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+)
+
+// failureLogTailBytes is how much of the end of each log FailureArtifacts
+// keeps.
+const failureLogTailBytes = 64 * 1024
+
+// FailureArtifactName is the kind of a FailureArtifact.
+type FailureArtifactName string
+
+// Kinds of artifacts returned by Sandbox.FailureArtifacts.
+const (
+	ArtifactException FailureArtifactName = "exception"  // Error reported by Modal for the Sandbox.
+	ArtifactTraceback FailureArtifactName = "traceback"  // Traceback of the error, if any.
+	ArtifactSystemLog FailureArtifactName = "system.log" // Messages from the Modal worker, such as image pull and kill notices.
+	ArtifactStderrLog FailureArtifactName = "stderr.log" // End of the Sandbox's stderr.
+	ArtifactOOMReport FailureArtifactName = "oom-report" // Summary of an out-of-memory kill, see Sandbox.OnOOM.
+)
+
+// FailureArtifact is a piece of diagnostic data about how a Sandbox finished.
+type FailureArtifact struct {
+	Name FailureArtifactName
+	Data []byte
+}
+
+// FailureArtifacts returns diagnostic artifacts of a finished Sandbox, such as
+// Modal's error and system log, the end of its stderr, and an OOM report if it
+// was likely killed for running out of memory. Artifacts with no data are
+// left out. It returns an InvalidError if the Sandbox is still running.
+//
+// The filesystem of a Sandbox is gone once it finishes, so core dumps and
+// kernel logs such as dmesg are not available. To keep core dumps, have the
+// Sandbox write them to a Volume.
+func (sb *Sandbox) FailureArtifacts() ([]FailureArtifact, error) {
+	resp, err := client.SandboxWait(sb.ctx, pb.SandboxWaitRequest_builder{
+		SandboxId: sb.SandboxId,
+		Timeout:   0,
+	}.Build())
+	if err != nil {
+		return nil, err
+	}
+	result := resp.GetResult()
+	if getReturnCode(result) == nil {
+		return nil, InvalidError{fmt.Sprintf("Sandbox %s is still running", sb.SandboxId)}
+	}
+
+	systemLog, err := sandboxLogTail(sb.ctx, sb.SandboxId, pb.FileDescriptor_FILE_DESCRIPTOR_INFO)
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := sandboxLogTail(sb.ctx, sb.SandboxId, pb.FileDescriptor_FILE_DESCRIPTOR_STDERR)
+	if err != nil {
+		return nil, err
+	}
+	return failureArtifacts(result, systemLog, stderr), nil
+}
+
+// failureArtifacts assembles the artifacts of a Sandbox from its result and
+// the tails of its logs.
+func failureArtifacts(result *pb.GenericResult, systemLog, stderr []byte) []FailureArtifact {
+	var artifacts []FailureArtifact
+	add := func(name FailureArtifactName, data []byte) {
+		if len(data) > 0 {
+			artifacts = append(artifacts, FailureArtifact{Name: name, Data: data})
+		}
+	}
+	add(ArtifactException, []byte(result.GetException()))
+	add(ArtifactTraceback, []byte(result.GetTraceback()))
+	add(ArtifactSystemLog, systemLog)
+	add(ArtifactStderrLog, stderr)
+	if isOOM(result) {
+		var report strings.Builder
+		fmt.Fprintf(&report, "Sandbox was likely killed for running out of memory (exit code %d).\n", result.GetExitcode())
+		if result.GetException() != "" {
+			fmt.Fprintf(&report, "Error: %s\n", result.GetException())
+		}
+		for _, line := range strings.Split(string(systemLog), "\n") {
+			if lower := strings.ToLower(line); strings.Contains(lower, "memory") || strings.Contains(lower, "oom-kill") || strings.Contains(lower, "oomkill") {
+				fmt.Fprintf(&report, "Log: %s\n", strings.TrimSpace(line))
+			}
+		}
+		add(ArtifactOOMReport, []byte(report.String()))
+	}
+	return artifacts
+}
+
+// sandboxLogTail returns the end of one of the logs of a finished Sandbox, up
+// to failureLogTailBytes.
+func sandboxLogTail(ctx context.Context, sandboxId string, fd pb.FileDescriptor) ([]byte, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	req := pb.AppGetLogsRequest_builder{SandboxId: sandboxId, FileDescriptor: fd, Timeout: 55}
+	reconnector := newStreamReconnector()
+	var tail []byte
+	for {
+		stream, err := client.AppGetLogs(ctx, req.Build())
+		if err != nil {
+			if reconnector.retry(ctx, err) {
+				continue
+			}
+			return nil, err
+		}
+		for {
+			batch, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				if reconnector.retry(ctx, err) {
+					break
+				}
+				return nil, err
+			}
+			reconnector.progress()
+			if batch.GetEntryId() != "" {
+				req.LastEntryId = batch.GetEntryId()
+			}
+			for _, item := range batch.GetItems() {
+				if item.GetFileDescriptor() == fd {
+					tail = append(tail, item.GetData()...)
+				}
+			}
+			if len(tail) > failureLogTailBytes {
+				tail = bytes.Clone(tail[len(tail)-failureLogTailBytes:])
+			}
+			if batch.GetAppDone() || batch.GetEof() {
+				return tail, nil
+			}
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package modal
+
+import (
+	"testing"
+
+	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+	"github.com/onsi/gomega"
+)
+
+func TestFailureArtifacts(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	failed := pb.GenericResult_builder{Status: pb.GenericResult_GENERIC_STATUS_FAILURE, Exitcode: 1}.Build()
+	g.Expect(failureArtifacts(failed, nil, []byte("boom\n"))).To(gomega.Equal([]FailureArtifact{
+		{Name: ArtifactStderrLog, Data: []byte("boom\n")},
+	}))
+
+	oom := pb.GenericResult_builder{Status: pb.GenericResult_GENERIC_STATUS_FAILURE, Exitcode: 137}.Build()
+	systemLog := []byte("Pulling image\nContainer exceeded its memory limit of 1024 MiB\n")
+	artifacts := failureArtifacts(oom, systemLog, nil)
+	g.Expect(artifacts).To(gomega.HaveLen(2))
+	g.Expect(artifacts[0]).To(gomega.Equal(FailureArtifact{Name: ArtifactSystemLog, Data: systemLog}))
+	g.Expect(artifacts[1].Name).To(gomega.Equal(ArtifactOOMReport))
+	g.Expect(string(artifacts[1].Data)).To(gomega.Equal(
+		"Sandbox was likely killed for running out of memory (exit code 137).\n" +
+			"Log: Container exceeded its memory limit of 1024 MiB\n"))
+}
@@ -0,0 +1,117 @@
+package modal
+
+// Creating bursts of Sandboxes with bounded concurrency.
+
+import (
+	"fmt"
+	"sync"
+)
+
+const defaultSandboxBatchMaxInFlight = 32
+
+// CreateSandboxBatcherOptions are options for App.NewCreateSandboxBatcher.
+type CreateSandboxBatcherOptions struct {
+	// MaxInFlight is the maximum number of creation requests in flight at
+	// once, defaults to 32.
+	MaxInFlight int
+}
+
+// CreateSandboxBatcher creates many Sandboxes in an App with bounded
+// concurrency, for bursts of hundreds of Sandboxes. Creation requests are sent
+// as soon as they are added, concurrently over the client's connection, and
+// Flush waits for them and reports their errors together.
+//
+//	batcher := app.NewCreateSandboxBatcher(nil)
+//	for range 500 {
+//		batcher.Add(image, options)
+//	}
+//	sandboxes, err := batcher.Flush()
+//
+// It is safe for concurrent use.
+type CreateSandboxBatcher struct {
+	app      *App
+	inFlight chan struct{} // semaphore of requests in flight
+
+	mu    sync.Mutex
+	batch *sandboxBatch // Sandboxes added since the last Flush
+}
+
+// sandboxBatch is the state of the Sandboxes added between two flushes.
+type sandboxBatch struct {
+	wg        sync.WaitGroup
+	sandboxes []*Sandbox
+	errs      []error
+	failed    int
+}
+
+// NewCreateSandboxBatcher returns a CreateSandboxBatcher that creates
+// Sandboxes in the App, with its context.
+func (app *App) NewCreateSandboxBatcher(options *CreateSandboxBatcherOptions) *CreateSandboxBatcher {
+	if options == nil {
+		options = &CreateSandboxBatcherOptions{}
+	}
+	maxInFlight := options.MaxInFlight
+	if maxInFlight <= 0 {
+		maxInFlight = defaultSandboxBatchMaxInFlight
+	}
+	return &CreateSandboxBatcher{
+		app:      app,
+		inFlight: make(chan struct{}, maxInFlight),
+		batch:    &sandboxBatch{},
+	}
+}
+
+// Add starts creating a Sandbox with image and options, like
+// App.CreateSandbox, once fewer than MaxInFlight requests are in flight. It
+// returns the index of the Sandbox in the results of the next Flush.
+func (b *CreateSandboxBatcher) Add(image *Image, options *SandboxOptions) int {
+	b.mu.Lock()
+	batch := b.batch
+	i := len(batch.sandboxes)
+	batch.sandboxes = append(batch.sandboxes, nil)
+	batch.errs = append(batch.errs, nil)
+	batch.wg.Add(1)
+	b.mu.Unlock()
+
+	go func() {
+		defer batch.wg.Done()
+		b.inFlight <- struct{}{}
+		sb, err := b.app.CreateSandbox(image, options)
+		<-b.inFlight
+
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		batch.sandboxes[i], batch.errs[i] = sb, err
+		if err != nil {
+			batch.failed++
+		}
+	}()
+	return i
+}
+
+// Flush waits for the Sandboxes added since the last Flush to be created, and
+// returns them in the order they were added. If any could not be created,
+// their entries are nil, and a SandboxBatchError with the error of each is
+// returned along with the others.
+func (b *CreateSandboxBatcher) Flush() ([]*Sandbox, error) {
+	b.mu.Lock()
+	batch := b.batch
+	b.batch = &sandboxBatch{}
+	b.mu.Unlock()
+
+	batch.wg.Wait()
+	if batch.failed == 0 {
+		return batch.sandboxes, nil
+	}
+	var first error
+	for _, err := range batch.errs {
+		if err != nil {
+			first = err
+			break
+		}
+	}
+	return batch.sandboxes, SandboxBatchError{
+		Exception: fmt.Sprintf("failed to create %d of %d Sandboxes, first error: %v", batch.failed, len(batch.sandboxes), first),
+		Errors:    batch.errs,
+	}
+}
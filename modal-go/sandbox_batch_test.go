@@ -0,0 +1,68 @@
+package modal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+	"github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestCreateSandboxBatcher(t *testing.T) {
+	g := gomega.NewWithT(t)
+	var inFlight, maxInFlight atomic.Int32
+	fake := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if method != "/modal.client.ModalClient/SandboxCreate" {
+			return nil
+		}
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for m := maxInFlight.Load(); n > m && !maxInFlight.CompareAndSwap(m, n); m = maxInFlight.Load() {
+		}
+		time.Sleep(10 * time.Millisecond)
+		args := req.(*pb.SandboxCreateRequest).GetDefinition().GetEntrypointArgs()
+		if args[0] == "fail" {
+			return status.Error(codes.PermissionDenied, "not allowed")
+		}
+		proto.Merge(reply.(proto.Message), pb.SandboxCreateResponse_builder{SandboxId: "sb-" + args[0]}.Build())
+		return nil
+	}
+	useFakeClient(t, fake)
+	app := newApp(context.Background(), "ap-123")
+	image := &Image{ImageId: "im-123"}
+
+	batcher := app.NewCreateSandboxBatcher(&CreateSandboxBatcherOptions{MaxInFlight: 4})
+	for i := range 20 {
+		command := fmt.Sprint(i)
+		if i == 7 {
+			command = "fail"
+		}
+		g.Expect(batcher.Add(image, &SandboxOptions{Command: []string{command}, Stdout: Ignore, Stderr: Ignore})).To(gomega.Equal(i))
+	}
+	sandboxes, err := batcher.Flush()
+	g.Expect(maxInFlight.Load()).To(gomega.BeNumerically("==", 4))
+	g.Expect(sandboxes).To(gomega.HaveLen(20))
+	g.Expect(sandboxes[0].SandboxId).To(gomega.Equal("sb-0"))
+	g.Expect(sandboxes[19].SandboxId).To(gomega.Equal("sb-19"))
+	g.Expect(sandboxes[7]).To(gomega.BeNil())
+
+	var batchErr SandboxBatchError
+	g.Expect(errors.As(err, &batchErr)).To(gomega.BeTrue())
+	g.Expect(batchErr.Exception).To(gomega.HavePrefix("failed to create 1 of 20 Sandboxes"))
+	g.Expect(batchErr.Errors[6]).ShouldNot(gomega.HaveOccurred())
+	g.Expect(status.Code(batchErr.Errors[7])).To(gomega.Equal(codes.PermissionDenied))
+	g.Expect(batchErr.Unwrap()).To(gomega.HaveLen(1))
+
+	// The next batch starts empty.
+	sandboxes, err = batcher.Flush()
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(sandboxes).To(gomega.BeEmpty())
+}
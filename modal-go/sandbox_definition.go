@@ -0,0 +1,203 @@
+package modal
+
+// Serializable definitions of Sandboxes, for comparing desired and actual
+// specs.
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"time"
+)
+
+// SandboxDefinition is the spec of a Sandbox: its Image, command, resources,
+// and other options that Modal runs it with, with objects referenced by ID.
+// It can be serialized, for example to JSON, and compared, such as by tools
+// that keep Sandboxes in line with specs checked into a repository:
+//
+//	actual := sb.Definition()
+//	if !reflect.DeepEqual(actual, desired) {
+//		sb.Terminate()
+//		sb, err = app.CreateSandboxFromDefinition(desired, nil)
+//	}
+//
+// Definitions returned by Sandbox.Definition are normalized, with empty
+// slices and maps left nil and the shorthand port fields of SandboxOptions
+// merged into Ports, so that equal options give equal definitions. Durations
+// are serialized in nanoseconds. EnvVars hold values in plain text, so keep
+// definitions that set them as private as the values.
+type SandboxDefinition struct {
+	ImageId             string              `json:"image_id"`
+	Command             []string            `json:"command,omitempty"`
+	Entrypoint          []string            `json:"entrypoint,omitempty"`
+	CPU                 CPUCores            `json:"cpu,omitempty"`
+	Memory              MemoryMiB           `json:"memory,omitempty"`
+	GPU                 string              `json:"gpu,omitempty"`
+	EphemeralDiskMB     DiskMiB             `json:"ephemeral_disk_mb,omitempty"`
+	Timeout             time.Duration       `json:"timeout,omitempty"`
+	Volumes             map[string]string   `json:"volumes,omitempty"` // mount point to Volume ID
+	Ports               []PortSpec          `json:"ports,omitempty"`
+	SecretIds           []string            `json:"secret_ids,omitempty"`
+	EnvVars             map[string]string   `json:"env_vars,omitempty"`
+	Regions             []string            `json:"regions,omitempty"`
+	Runtime             SandboxRuntime      `json:"runtime,omitempty"`
+	Arch                string              `json:"arch,omitempty"`
+	HealthCheck         *HealthCheck        `json:"health_check,omitempty"`
+	BackgroundProcesses []BackgroundProcess `json:"background_processes,omitempty"`
+	Tags                map[string]string   `json:"tags,omitempty"`
+}
+
+// newSandboxDefinition returns the definition of a Sandbox created with image
+// and options.
+func newSandboxDefinition(image *Image, options *SandboxOptions) *SandboxDefinition {
+	var volumes map[string]string
+	if len(options.Volumes) > 0 {
+		volumes = make(map[string]string, len(options.Volumes))
+		for mountPath, volume := range options.Volumes {
+			volumes[mountPath] = volume.VolumeId
+		}
+	}
+	var secretIds []string
+	for _, secret := range options.Secrets {
+		if secret != nil {
+			secretIds = append(secretIds, secret.SecretId)
+		}
+	}
+	definition := &SandboxDefinition{
+		ImageId:             image.ImageId,
+		Command:             options.Command,
+		Entrypoint:          options.Entrypoint,
+		CPU:                 options.CPU,
+		Memory:              options.Memory,
+		GPU:                 options.GPU,
+		EphemeralDiskMB:     options.EphemeralDiskMB,
+		Timeout:             options.Timeout,
+		Volumes:             volumes,
+		Ports:               options.portSpecs(),
+		SecretIds:           secretIds,
+		EnvVars:             options.EnvVars,
+		Regions:             options.Regions,
+		Runtime:             options.Runtime,
+		Arch:                options.Arch,
+		HealthCheck:         options.HealthCheck,
+		BackgroundProcesses: options.BackgroundProcesses,
+		Tags:                options.Tags,
+	}
+	return definition.clone()
+}
+
+// clone returns a deep copy of the definition, with empty slices and maps
+// left nil.
+func (definition *SandboxDefinition) clone() *SandboxDefinition {
+	c := *definition
+	c.Command = cloneSlice(c.Command)
+	c.Entrypoint = cloneSlice(c.Entrypoint)
+	c.Volumes = cloneMap(c.Volumes)
+	c.Ports = cloneSlice(c.Ports)
+	c.SecretIds = cloneSlice(c.SecretIds)
+	c.EnvVars = cloneMap(c.EnvVars)
+	c.Regions = cloneSlice(c.Regions)
+	c.Tags = cloneMap(c.Tags)
+	if c.HealthCheck != nil {
+		check := *c.HealthCheck
+		check.Command = cloneSlice(check.Command)
+		c.HealthCheck = &check
+	}
+	c.BackgroundProcesses = cloneSlice(c.BackgroundProcesses)
+	for i, process := range c.BackgroundProcesses {
+		process.Command = cloneSlice(process.Command)
+		process.EnvVars = cloneMap(process.EnvVars)
+		c.BackgroundProcesses[i] = process
+	}
+	return &c
+}
+
+func cloneSlice[S ~[]E, E any](s S) S {
+	if len(s) == 0 {
+		return nil
+	}
+	return slices.Clone(s)
+}
+
+func cloneMap[M ~map[K]V, K comparable, V any](m M) M {
+	if len(m) == 0 {
+		return nil
+	}
+	return maps.Clone(m)
+}
+
+// Definition returns the definition that the Sandbox was created with, or
+// nil if it was not created by this client, such as one found with
+// SandboxFromId, since Modal does not return the definitions of existing
+// Sandboxes. The definition includes the App's defaults applied by
+// CreateSandbox. The result is a copy that the caller may modify.
+func (sb *Sandbox) Definition() *SandboxDefinition {
+	if sb.definition == nil {
+		return nil
+	}
+	return sb.definition.clone()
+}
+
+// CreateSandboxFromDefinition creates a new Sandbox in the App from a
+// definition, such as one returned by Sandbox.Definition or read from a file.
+// The Sandbox runs as specified by the definition alone: the App's defaults
+// are not applied, while its limits are, see CreateSandbox.
+//
+// options may be nil, and may only set the fields that are not part of a
+// definition: Stdout, Stderr, IdempotencyKey, KeepOnCancel, and Detach.
+func (app *App) CreateSandboxFromDefinition(definition *SandboxDefinition, options *SandboxOptions) (*Sandbox, error) {
+	if definition == nil {
+		return nil, InvalidError{"definition must not be nil"}
+	}
+	if options == nil {
+		options = &SandboxOptions{}
+	}
+	merged := SandboxOptions{
+		Stdout:         options.Stdout,
+		Stderr:         options.Stderr,
+		IdempotencyKey: options.IdempotencyKey,
+		KeepOnCancel:   options.KeepOnCancel,
+		Detach:         options.Detach,
+	}
+	if !reflect.DeepEqual(*options, merged) {
+		return nil, InvalidError{"options of CreateSandboxFromDefinition may only set Stdout, Stderr, IdempotencyKey, KeepOnCancel, and Detach"}
+	}
+
+	d := definition.clone()
+	if d.ImageId == "" {
+		return nil, InvalidError{"definition must have an ImageId"}
+	}
+	image := &Image{ImageId: d.ImageId, ctx: app.ctx}
+	if len(d.Volumes) > 0 {
+		merged.Volumes = make(map[string]*Volume, len(d.Volumes))
+		for mountPath, volumeId := range d.Volumes {
+			if volumeId == "" {
+				return nil, InvalidError{fmt.Sprintf("Volume ID for mount point %s must not be empty", mountPath)}
+			}
+			merged.Volumes[mountPath] = &Volume{VolumeId: volumeId, ctx: app.ctx}
+		}
+	}
+	for _, secretId := range d.SecretIds {
+		if secretId == "" {
+			return nil, InvalidError{"Secret IDs must not be empty"}
+		}
+		merged.Secrets = append(merged.Secrets, &Secret{SecretId: secretId, ctx: app.ctx})
+	}
+	merged.CPU = d.CPU
+	merged.Memory = d.Memory
+	merged.GPU = d.GPU
+	merged.EphemeralDiskMB = d.EphemeralDiskMB
+	merged.Timeout = d.Timeout
+	merged.Command = d.Command
+	merged.Entrypoint = d.Entrypoint
+	merged.Ports = d.Ports
+	merged.EnvVars = d.EnvVars
+	merged.Regions = d.Regions
+	merged.Runtime = d.Runtime
+	merged.Arch = d.Arch
+	merged.HealthCheck = d.HealthCheck
+	merged.BackgroundProcesses = d.BackgroundProcesses
+	merged.Tags = d.Tags
+	return app.startSandbox(image, &merged)
+}
@@ -0,0 +1,92 @@
+package modal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+	"github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestSandboxDefinition(t *testing.T) {
+	g := gomega.NewWithT(t)
+	var requests []*pb.SandboxCreateRequest
+	secrets := 0
+	fake := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		switch method {
+		case "/modal.client.ModalClient/SecretGetOrCreate":
+			secrets++
+			proto.Merge(reply.(proto.Message), pb.SecretGetOrCreateResponse_builder{SecretId: fmt.Sprintf("st-env-%d", secrets)}.Build())
+		case "/modal.client.ModalClient/SandboxCreate":
+			requests = append(requests, req.(*pb.SandboxCreateRequest))
+			proto.Merge(reply.(proto.Message), pb.SandboxCreateResponse_builder{SandboxId: fmt.Sprintf("sb-%d", len(requests))}.Build())
+		}
+		return nil
+	}
+	useFakeClient(t, fake)
+	app := newApp(context.Background(), "ap-123")
+	app.SetDefaults(SandboxDefaults{Memory: 1024})
+
+	sb, err := app.CreateSandbox(&Image{ImageId: "im-123"}, &SandboxOptions{
+		CPU:            2,
+		Timeout:        time.Hour,
+		Command:        []string{"sleep", "infinity"},
+		Volumes:        map[string]*Volume{"/data": {VolumeId: "vo-123"}},
+		Ports:          []PortSpec{{Port: 9000, H2: true}},
+		EncryptedPorts: []int{8000},
+		Secrets:        []*Secret{{SecretId: "st-123"}},
+		EnvVars:        map[string]string{"A": "1"},
+		Regions:        []string{},
+		Stdout:         Ignore,
+		Stderr:         Ignore,
+	})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	definition := sb.Definition()
+	g.Expect(definition).To(gomega.Equal(&SandboxDefinition{
+		ImageId:   "im-123",
+		Command:   []string{"sleep", "infinity"},
+		CPU:       2,
+		Memory:    1024, // from the App's defaults
+		Timeout:   time.Hour,
+		Volumes:   map[string]string{"/data": "vo-123"},
+		Ports:     []PortSpec{{Port: 9000, H2: true}, {Port: 8000}},
+		SecretIds: []string{"st-123"}, // not the ephemeral Secret for EnvVars
+		EnvVars:   map[string]string{"A": "1"},
+	}))
+
+	// Definitions are copies, and survive a round trip through JSON.
+	definition.Command[0] = "echo"
+	g.Expect(sb.Definition().Command).To(gomega.Equal([]string{"sleep", "infinity"}))
+	data, err := json.Marshal(sb.Definition())
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(string(data)).To(gomega.ContainSubstring(`"ports":[{"port":9000,"h2":true},{"port":8000}]`))
+	var decoded *SandboxDefinition
+	g.Expect(json.Unmarshal(data, &decoded)).To(gomega.Succeed())
+	g.Expect(decoded).To(gomega.Equal(sb.Definition()))
+
+	// Without the App's defaults, a Sandbox created from the definition has
+	// the same spec.
+	app.SetDefaults(SandboxDefaults{Memory: 2048, Secrets: []*Secret{{SecretId: "st-default"}}})
+	recreated, err := app.CreateSandboxFromDefinition(decoded, &SandboxOptions{Stdout: Ignore, Stderr: Ignore})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(recreated.SandboxId).To(gomega.Equal("sb-2"))
+	g.Expect(recreated.Definition()).To(gomega.Equal(sb.Definition()))
+	g.Expect(recreated.DefinitionHash()).To(gomega.Equal(sb.DefinitionHash()))
+	g.Expect(requests[1].GetDefinition().GetSecretIds()).To(gomega.Equal([]string{"st-123", "st-env-2"}))
+
+	_, err = app.CreateSandboxFromDefinition(decoded, &SandboxOptions{CPU: 4})
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("may only set")))
+	_, err = app.CreateSandboxFromDefinition(&SandboxDefinition{}, nil)
+	g.Expect(err).To(gomega.BeAssignableToTypeOf(InvalidError{}))
+	_, err = app.CreateSandboxFromDefinition(nil, nil)
+	g.Expect(err).To(gomega.BeAssignableToTypeOf(InvalidError{}))
+	g.Expect(requests).To(gomega.HaveLen(2))
+
+	g.Expect(newSandboxWithStdio(context.Background(), "sb-123", Ignore, Ignore).Definition()).To(gomega.BeNil())
+}
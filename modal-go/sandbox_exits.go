@@ -0,0 +1,230 @@
+package modal
+
+// Watching many Sandboxes of an App for completion with a single poller.
+
+import (
+	"iter"
+	"maps"
+	"math"
+	"slices"
+	"time"
+
+	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+)
+
+// SandboxExit is a finished Sandbox, reported by App.SandboxExits.
+type SandboxExit struct {
+	SandboxId  string
+	ExitCode   int // As returned by Sandbox.Wait.
+	FinishedAt time.Time
+}
+
+// SandboxExitsOptions are options for App.SandboxExits.
+type SandboxExitsOptions struct {
+	PollInterval time.Duration // Time between listings of the App's Sandboxes, defaults to 5 seconds.
+}
+
+// Sandboxes created up to this long before the newest one seen by a poll are
+// listed again by the next, to tolerate Sandboxes that become visible late.
+const sandboxExitsLookback = time.Minute
+
+// SandboxExits returns an iterator over Sandboxes of the App as they finish.
+//
+// Modal has no completion webhooks, so this polls the list of the App's
+// Sandboxes instead of waiting on each one, and one loop can watch thousands
+// of Sandboxes. Callers can send their own HTTP callback from the loop.
+// Sandboxes that already finished when iteration starts are not reported.
+// Iteration stops at the first error, or when the loop exits.
+//
+// Each poll lists the Sandboxes created since shortly before the newest one
+// seen, and the running Sandboxes, so its cost grows with the number of
+// Sandboxes created or running rather than with the App's history.
+func (app *App) SandboxExits(options *SandboxExitsOptions) iter.Seq2[SandboxExit, error] {
+	if options == nil {
+		options = &SandboxExitsOptions{}
+	}
+	interval := options.PollInterval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	return func(yield func(SandboxExit, error) bool) {
+		tracker := newSandboxExitTracker(time.Now())
+		running, err := app.listSandboxes(false, 0)
+		if err != nil {
+			yield(SandboxExit{}, err)
+			return
+		}
+		tracker.addRunning(running)
+
+		for {
+			if err := sleepCtx(app.ctx, interval); err != nil {
+				yield(SandboxExit{}, err)
+				return
+			}
+			infos, err := app.pollSandboxes(tracker)
+			if err != nil {
+				yield(SandboxExit{}, err)
+				return
+			}
+			for _, exit := range tracker.observe(infos) {
+				if !yield(exit, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// pollSandboxes lists the Sandboxes created since the tracker's horizon, and
+// the Sandboxes it watches from before the horizon that have since finished.
+func (app *App) pollSandboxes(tracker *sandboxExitTracker) ([]*pb.SandboxInfo, error) {
+	infos, err := app.listSandboxes(true, tracker.horizon())
+	if err != nil {
+		return nil, err
+	}
+	older := tracker.older()
+	if len(older) == 0 {
+		return infos, nil
+	}
+	// Only running Sandboxes are listed down to the oldest one watched, and
+	// those missing from the listing are looked up one by one.
+	oldest := slices.Min(slices.Collect(maps.Values(older)))
+	running, err := app.listSandboxes(false, oldest)
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range running {
+		delete(older, info.GetId())
+	}
+	for id, createdAt := range older {
+		info, err := app.findSandbox(id, createdAt)
+		if err != nil {
+			return nil, err
+		}
+		if info == nil {
+			tracker.forget(id) // no longer listed by Modal
+			continue
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// listSandboxes lists the App's Sandboxes, newest first, down to those
+// created at the horizon, in seconds since the epoch.
+func (app *App) listSandboxes(includeFinished bool, horizon float64) ([]*pb.SandboxInfo, error) {
+	var infos []*pb.SandboxInfo
+	for info, err := range app.sandboxInfos(includeFinished, nil, 0) {
+		if err != nil {
+			return nil, err
+		}
+		if info.GetCreatedAt() < horizon {
+			break
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// findSandbox returns the listing of a Sandbox created at createdAt, or nil
+// if it isn't listed.
+func (app *App) findSandbox(id string, createdAt float64) (*pb.SandboxInfo, error) {
+	for info, err := range app.sandboxInfos(true, nil, math.Nextafter(createdAt, math.Inf(1))) {
+		if err != nil {
+			return nil, err
+		}
+		if info.GetCreatedAt() < createdAt {
+			break
+		}
+		if info.GetId() == id {
+			return info, nil
+		}
+	}
+	return nil, nil
+}
+
+// sandboxExitTracker finds newly finished Sandboxes in successive listings.
+type sandboxExitTracker struct {
+	pending  map[string]float64 // running Sandbox ID -> creation time
+	reported map[string]float64 // finished Sandbox ID -> creation time
+	newest   float64            // newest creation time seen
+}
+
+func newSandboxExitTracker(start time.Time) *sandboxExitTracker {
+	return &sandboxExitTracker{
+		pending:  map[string]float64{},
+		reported: map[string]float64{},
+		newest:   float64(start.UnixNano()) / 1e9,
+	}
+}
+
+// horizon returns the creation time down to which new Sandboxes must be
+// listed: a lookback before the newest seen.
+func (t *sandboxExitTracker) horizon() float64 {
+	return t.newest - sandboxExitsLookback.Seconds()
+}
+
+// older returns the running Sandboxes created before the horizon, which
+// aren't in the listing of new Sandboxes.
+func (t *sandboxExitTracker) older() map[string]float64 {
+	horizon := t.horizon()
+	older := map[string]float64{}
+	for id, createdAt := range t.pending {
+		if createdAt < horizon {
+			older[id] = createdAt
+		}
+	}
+	return older
+}
+
+// addRunning records the Sandboxes running when watching starts.
+func (t *sandboxExitTracker) addRunning(infos []*pb.SandboxInfo) {
+	for _, info := range infos {
+		t.pending[info.GetId()] = info.GetCreatedAt()
+	}
+}
+
+// forget stops watching a Sandbox.
+func (t *sandboxExitTracker) forget(id string) {
+	delete(t.pending, id)
+}
+
+// observe records a listing of the Sandboxes created since the horizon and
+// of watched older ones, and returns the Sandboxes that finished since the
+// previous one.
+func (t *sandboxExitTracker) observe(infos []*pb.SandboxInfo) []SandboxExit {
+	horizon := t.horizon()
+	var exits []SandboxExit
+	for _, info := range infos {
+		id, createdAt := info.GetId(), info.GetCreatedAt()
+		if _, ok := t.pending[id]; !ok && createdAt < horizon {
+			continue // outside the listing, may have been reported already
+		}
+		t.newest = max(t.newest, createdAt)
+		exitCode := getReturnCode(info.GetTaskInfo().GetResult())
+		if exitCode == nil {
+			t.pending[id] = createdAt
+			continue
+		}
+		if _, ok := t.reported[id]; ok {
+			continue
+		}
+		delete(t.pending, id)
+		t.reported[id] = createdAt
+		finishedAt := info.GetTaskInfo().GetFinishedAt()
+		exits = append(exits, SandboxExit{
+			SandboxId:  id,
+			ExitCode:   *exitCode,
+			FinishedAt: time.Unix(0, int64(finishedAt*1e9)),
+		})
+	}
+	// Finished Sandboxes older than the horizon are no longer listed, so they
+	// can't be reported twice.
+	horizon = t.horizon()
+	for id, createdAt := range t.reported {
+		if createdAt < horizon {
+			delete(t.reported, id)
+		}
+	}
+	return exits
+}
@@ -0,0 +1,139 @@
+package modal
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+	"github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+func sandboxInfo(id string, createdAt float64, result *pb.GenericResult) *pb.SandboxInfo {
+	return pb.SandboxInfo_builder{
+		Id:        id,
+		CreatedAt: createdAt,
+		TaskInfo:  pb.TaskInfo_builder{Result: result, FinishedAt: createdAt + 10}.Build(),
+	}.Build()
+}
+
+func TestSandboxExitTracker(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	success := pb.GenericResult_builder{Status: pb.GenericResult_GENERIC_STATUS_SUCCESS, Exitcode: 3}.Build()
+	start := time.Unix(1000, 0)
+	tracker := newSandboxExitTracker(start)
+
+	// A Sandbox running since long before watching started.
+	tracker.addRunning([]*pb.SandboxInfo{sandboxInfo("sb-old", 100, nil)})
+	g.Expect(tracker.horizon()).To(gomega.Equal(1000 - sandboxExitsLookback.Seconds()))
+	g.Expect(tracker.older()).To(gomega.Equal(map[string]float64{"sb-old": 100}))
+
+	exits := tracker.observe([]*pb.SandboxInfo{
+		sandboxInfo("sb-new", 1005, success),
+		sandboxInfo("sb-running", 1002, nil),
+		sandboxInfo("sb-old", 100, nil),
+	})
+	g.Expect(exits).To(gomega.Equal([]SandboxExit{{SandboxId: "sb-new", ExitCode: 3, FinishedAt: time.Unix(1015, 0)}}))
+
+	// Finished Sandboxes are reported once, and older Sandboxes are reported
+	// when their listing is looked up.
+	exits = tracker.observe([]*pb.SandboxInfo{
+		sandboxInfo("sb-new", 1005, success),
+		sandboxInfo("sb-running", 1002, nil),
+		sandboxInfo("sb-old", 100, success),
+	})
+	g.Expect(exits).To(gomega.HaveLen(1))
+	g.Expect(exits[0].SandboxId).To(gomega.Equal("sb-old"))
+	g.Expect(tracker.horizon()).To(gomega.Equal(1005 - sandboxExitsLookback.Seconds()))
+	g.Expect(tracker.older()).To(gomega.BeEmpty())
+}
+
+// fakeSandboxList serves SandboxList from the Sandboxes returned by infos,
+// newest first, pageSize at a time, and returns the number of requests.
+func fakeSandboxList(t *testing.T, infos func() []*pb.SandboxInfo, pageSize int) *int {
+	requests := new(int)
+	fake := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if method != "/modal.client.ModalClient/SandboxList" {
+			return nil
+		}
+		*requests++
+		r := req.(*pb.SandboxListRequest)
+		var page []*pb.SandboxInfo
+		for _, info := range infos() {
+			if r.GetBeforeTimestamp() != 0 && info.GetCreatedAt() >= r.GetBeforeTimestamp() {
+				continue
+			}
+			if !r.GetIncludeFinished() && info.GetTaskInfo().GetResult() != nil {
+				continue
+			}
+			if len(page) == pageSize {
+				break
+			}
+			page = append(page, info)
+		}
+		proto.Merge(reply.(proto.Message), pb.SandboxListResponse_builder{Sandboxes: page}.Build())
+		return nil
+	}
+	useFakeClient(t, fake)
+	return requests
+}
+
+func TestSandboxInfosPageBoundary(t *testing.T) {
+	g := gomega.NewWithT(t)
+	infos := []*pb.SandboxInfo{
+		sandboxInfo("sb-4", 40, nil),
+		sandboxInfo("sb-3", 30, nil),
+		sandboxInfo("sb-2b", 20, nil),
+		sandboxInfo("sb-2a", 20, nil),
+		sandboxInfo("sb-1", 10, nil),
+	}
+	fakeSandboxList(t, func() []*pb.SandboxInfo { return infos }, 3)
+	app := newApp(context.Background(), "ap-123")
+
+	var ids []string
+	for info, err := range app.sandboxInfos(false, nil, 0) {
+		g.Expect(err).ShouldNot(gomega.HaveOccurred())
+		ids = append(ids, info.GetId())
+	}
+	g.Expect(ids).To(gomega.Equal([]string{"sb-4", "sb-3", "sb-2b", "sb-2a", "sb-1"}))
+}
+
+func TestSandboxExitsSkipsHistory(t *testing.T) {
+	g := gomega.NewWithT(t)
+	success := pb.GenericResult_builder{Status: pb.GenericResult_GENERIC_STATUS_SUCCESS, Exitcode: 1}.Build()
+	now := float64(time.Now().Unix())
+
+	// An old Sandbox that finishes once watching starts, and a long history
+	// of finished Sandboxes created after it.
+	var history []*pb.SandboxInfo
+	for i := range 100 {
+		history = append(history, sandboxInfo(fmt.Sprintf("sb-done-%d", i), now-1000-float64(i), success))
+	}
+	started := false
+	requests := fakeSandboxList(t, func() []*pb.SandboxInfo {
+		old := sandboxInfo("sb-old", now-7200, success)
+		if !started {
+			old = sandboxInfo("sb-old", now-7200, nil)
+			started = true
+		}
+		return append(slices.Clone(history), old)
+	}, 10)
+	app := newApp(context.Background(), "ap-123")
+
+	for exit, err := range app.SandboxExits(&SandboxExitsOptions{PollInterval: time.Millisecond}) {
+		g.Expect(err).ShouldNot(gomega.HaveOccurred())
+		g.Expect(exit.SandboxId).To(gomega.Equal("sb-old"))
+		g.Expect(exit.ExitCode).To(gomega.Equal(1))
+		break
+	}
+	// Two pages of running Sandboxes to start, then a page of new Sandboxes,
+	// the running ones, and the old Sandbox's own listing, instead of the
+	// whole history.
+	g.Expect(*requests).To(gomega.Equal(5))
+}
@@ -3,6 +3,7 @@ package modal
 import (
 	"context"
 	"io"
+	"iter"
 
 	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
 )
@@ -84,45 +85,63 @@ func runFilesystemExec(ctx context.Context, req *pb.ContainerFilesystemExecReque
 	if err != nil {
 		return 0, nil, err
 	}
-	retries := 10
 	totalRead := 0
-
-	for {
-		outputIterator, err := client.ContainerFilesystemExecGetOutput(ctx, pb.ContainerFilesystemExecGetOutputRequest_builder{
-			ExecId:  resp.GetExecId(),
-			Timeout: 55,
-		}.Build())
+	for chunk, err := range filesystemExecOutput(ctx, resp.GetExecId()) {
 		if err != nil {
-			if isRetryableGrpc(err) && retries > 0 {
-				retries--
-				continue
-			}
 			return 0, nil, err
 		}
+		copyLen := copy(p[totalRead:], chunk)
+		totalRead += copyLen
+	}
+	return totalRead, resp, nil
+}
+
+// filesystemExecOutput yields the output chunks of a filesystem command as
+// they arrive, until the command reaches EOF.
+func filesystemExecOutput(ctx context.Context, execId string) iter.Seq2[[]byte, error] {
+	return func(yield func([]byte, error) bool) {
+		reconnector := newStreamReconnector()
 
 		for {
-			batch, err := outputIterator.Recv()
-			if err == io.EOF {
-				break
-			}
+			outputIterator, err := client.ContainerFilesystemExecGetOutput(ctx, pb.ContainerFilesystemExecGetOutputRequest_builder{
+				ExecId:  execId,
+				Timeout: 55,
+			}.Build())
 			if err != nil {
-				if isRetryableGrpc(err) && retries > 0 {
-					retries--
-					break
+				if reconnector.retry(ctx, err) {
+					continue
 				}
-				return 0, nil, err
-			}
-			if batch.GetError() != nil {
-				return 0, nil, SandboxFilesystemError{batch.GetError().GetErrorMessage()}
+				yield(nil, err)
+				return
 			}
 
-			for _, chunk := range batch.GetOutput() {
-				copyLen := copy(p[totalRead:], chunk)
-				totalRead += copyLen
-			}
+			for {
+				batch, err := outputIterator.Recv()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					if reconnector.retry(ctx, err) {
+						break
+					}
+					yield(nil, err)
+					return
+				}
+				reconnector.progress()
+				if batch.GetError() != nil {
+					yield(nil, SandboxFilesystemError{batch.GetError().GetErrorMessage()})
+					return
+				}
+
+				for _, chunk := range batch.GetOutput() {
+					if !yield(chunk, nil) {
+						return
+					}
+				}
 
-			if batch.GetEof() {
-				return totalRead, resp, nil
+				if batch.GetEof() {
+					return
+				}
 			}
 		}
 	}
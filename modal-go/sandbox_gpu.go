@@ -0,0 +1,82 @@
+package modal
+
+// Introspection of the GPUs attached to a Sandbox.
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// GPUInfo describes a GPU attached to a Sandbox, as reported by the NVIDIA
+// driver on the worker.
+type GPUInfo struct {
+	Index         int    // Index of the GPU in the Sandbox.
+	Model         string // Model name, such as "NVIDIA A100-SXM4-40GB".
+	MemoryMiB     int    // Total memory in MiB.
+	DriverVersion string // Version of the NVIDIA driver, such as "535.129.03".
+}
+
+var gpuQueryCommand = []string{
+	"nvidia-smi",
+	"--query-gpu=index,name,memory.total,driver_version",
+	"--format=csv,noheader,nounits",
+}
+
+// GPUInfo returns the GPUs attached to the Sandbox, so that workloads can
+// verify their allocation before running. It runs nvidia-smi in the Sandbox,
+// and returns an InvalidError if the Sandbox has no GPU.
+func (sb *Sandbox) GPUInfo() ([]GPUInfo, error) {
+	result, err := sb.Run(gpuQueryCommand, ExecOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if result.ExitCode != 0 {
+		msg := strings.TrimSpace(string(result.Stderr))
+		if msg == "" {
+			msg = fmt.Sprintf("nvidia-smi exited with code %d", result.ExitCode)
+		}
+		return nil, InvalidError{fmt.Sprintf("no GPU found in Sandbox %s: %s", sb.SandboxId, msg)}
+	}
+	gpus, err := parseGPUInfo(string(result.Stdout))
+	if err != nil {
+		return nil, err
+	}
+	if len(gpus) == 0 {
+		return nil, InvalidError{fmt.Sprintf("no GPU found in Sandbox %s", sb.SandboxId)}
+	}
+	return gpus, nil
+}
+
+// parseGPUInfo parses the CSV output of gpuQueryCommand.
+func parseGPUInfo(output string) ([]GPUInfo, error) {
+	var gpus []GPUInfo
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, ",")
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("unexpected nvidia-smi output: %q", line)
+		}
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		index, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("unexpected GPU index in nvidia-smi output: %q", line)
+		}
+		memory, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("unexpected GPU memory in nvidia-smi output: %q", line)
+		}
+		gpus = append(gpus, GPUInfo{
+			Index:         index,
+			Model:         fields[1],
+			MemoryMiB:     memory,
+			DriverVersion: fields[3],
+		})
+	}
+	return gpus, nil
+}
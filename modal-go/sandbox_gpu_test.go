@@ -0,0 +1,28 @@
+package modal
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+func TestParseGPUInfo(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	gpus, err := parseGPUInfo("0, NVIDIA A100-SXM4-40GB, 40960, 535.129.03\n1, NVIDIA A100-SXM4-40GB, 40960, 535.129.03\n")
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(gpus).To(gomega.Equal([]GPUInfo{
+		{Index: 0, Model: "NVIDIA A100-SXM4-40GB", MemoryMiB: 40960, DriverVersion: "535.129.03"},
+		{Index: 1, Model: "NVIDIA A100-SXM4-40GB", MemoryMiB: 40960, DriverVersion: "535.129.03"},
+	}))
+
+	gpus, err = parseGPUInfo("\n")
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(gpus).To(gomega.BeEmpty())
+
+	_, err = parseGPUInfo("0, Tesla T4, [N/A], 535.129.03")
+	g.Expect(err).To(gomega.HaveOccurred())
+	_, err = parseGPUInfo("No devices were found")
+	g.Expect(err).To(gomega.HaveOccurred())
+}
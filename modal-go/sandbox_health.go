@@ -0,0 +1,138 @@
+package modal
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHealthCheckInterval = 30 * time.Second
+	defaultHealthCheckTimeout  = 10 * time.Second
+	defaultHealthCheckRetries  = 3
+)
+
+// HealthCheck defines a liveness probe that is run periodically inside a
+// Sandbox. The probe succeeds if Command exits with code 0 within Timeout.
+type HealthCheck struct {
+	Command  []string      `json:"command"`            // Command to exec in the Sandbox, e.g. a curl against a local server.
+	Interval time.Duration `json:"interval,omitempty"` // Time between probes, defaults to 30 seconds.
+	Timeout  time.Duration `json:"timeout,omitempty"`  // Maximum duration of a probe, rounded up to whole seconds, defaults to 10 seconds.
+	Retries  int           `json:"retries,omitempty"`  // Consecutive failures before the Sandbox is unhealthy, defaults to 3.
+}
+
+// HealthStatus is the result of a Sandbox's health checks.
+type HealthStatus string
+
+const (
+	// HealthUnknown means no probe has completed yet, or no HealthCheck is configured.
+	HealthUnknown HealthStatus = "unknown"
+	// Healthy means the last probe succeeded, or fewer than Retries probes have failed since.
+	Healthy HealthStatus = "healthy"
+	// Unhealthy means the last Retries probes all failed.
+	Unhealthy HealthStatus = "unhealthy"
+)
+
+// SandboxHealth is a snapshot of a Sandbox's health check state.
+type SandboxHealth struct {
+	Status              HealthStatus
+	ConsecutiveFailures int
+	LastChecked         time.Time // zero if no probe has completed
+	LastError           error     // error of the last failed probe, nil after a success
+}
+
+// sandboxHealthMonitor runs a HealthCheck in the background and records the results.
+type sandboxHealthMonitor struct {
+	check  HealthCheck
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	health SandboxHealth
+}
+
+func startHealthMonitor(sb *Sandbox, check HealthCheck) *sandboxHealthMonitor {
+	if check.Interval <= 0 {
+		check.Interval = defaultHealthCheckInterval
+	}
+	if check.Timeout <= 0 {
+		check.Timeout = defaultHealthCheckTimeout
+	}
+	if check.Retries <= 0 {
+		check.Retries = defaultHealthCheckRetries
+	}
+
+	ctx, cancel := context.WithCancel(sb.ctx)
+	m := &sandboxHealthMonitor{
+		check:  check,
+		cancel: cancel,
+		health: SandboxHealth{Status: HealthUnknown},
+	}
+	go func() {
+		t := time.NewTicker(check.Interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+			if exitCode, err := sb.Poll(); err == nil && exitCode != nil {
+				return // Sandbox has exited, nothing left to probe.
+			}
+			m.record(m.probe(sb))
+		}
+	}()
+	return m
+}
+
+// probe runs the health check command once.
+func (m *sandboxHealthMonitor) probe(sb *Sandbox) error {
+	cp, err := sb.Exec(m.check.Command, ExecOptions{
+		Stdout:  Ignore,
+		Stderr:  Ignore,
+		Timeout: m.check.Timeout,
+	})
+	if err != nil {
+		return err
+	}
+	exitCode, err := cp.Wait()
+	if err != nil {
+		return err
+	}
+	if exitCode != 0 {
+		return fmt.Errorf("health check command exited with code %d", exitCode)
+	}
+	return nil
+}
+
+func (m *sandboxHealthMonitor) record(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.health.LastChecked = time.Now()
+	m.health.LastError = err
+	if err == nil {
+		m.health.ConsecutiveFailures = 0
+		m.health.Status = Healthy
+		return
+	}
+	m.health.ConsecutiveFailures++
+	if m.health.ConsecutiveFailures >= m.check.Retries {
+		m.health.Status = Unhealthy
+	}
+}
+
+func (m *sandboxHealthMonitor) snapshot() SandboxHealth {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.health
+}
+
+// Health returns the current result of the HealthCheck configured in
+// SandboxOptions. Without a HealthCheck, the status is always HealthUnknown.
+func (sb *Sandbox) Health() SandboxHealth {
+	if sb.health == nil {
+		return SandboxHealth{Status: HealthUnknown}
+	}
+	return sb.health.snapshot()
+}
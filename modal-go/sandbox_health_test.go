@@ -0,0 +1,70 @@
+package modal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+	"github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestSandboxHealthTransitions(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	m := &sandboxHealthMonitor{
+		check:  HealthCheck{Retries: 2},
+		health: SandboxHealth{Status: HealthUnknown},
+	}
+	probeErr := errors.New("connection refused")
+
+	m.record(probeErr)
+	g.Expect(m.snapshot().Status).To(gomega.Equal(HealthUnknown))
+
+	m.record(nil)
+	g.Expect(m.snapshot().Status).To(gomega.Equal(Healthy))
+	g.Expect(m.snapshot().LastError).To(gomega.BeNil())
+
+	m.record(probeErr)
+	g.Expect(m.snapshot().Status).To(gomega.Equal(Healthy))
+	g.Expect(m.snapshot().ConsecutiveFailures).To(gomega.Equal(1))
+
+	m.record(probeErr)
+	g.Expect(m.snapshot().Status).To(gomega.Equal(Unhealthy))
+	g.Expect(m.snapshot().LastError).To(gomega.MatchError(probeErr))
+
+	m.record(nil)
+	g.Expect(m.snapshot().Status).To(gomega.Equal(Healthy))
+	g.Expect(m.snapshot().ConsecutiveFailures).To(gomega.Equal(0))
+}
+
+func TestSandboxHealthProbeTimeout(t *testing.T) {
+	g := gomega.NewWithT(t)
+	var timeouts []uint32
+	fake := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		switch method {
+		case "/modal.client.ModalClient/SandboxGetTaskId":
+			proto.Merge(reply.(proto.Message), pb.SandboxGetTaskIdResponse_builder{TaskId: proto.String("ta-123")}.Build())
+		case "/modal.client.ModalClient/ContainerExec":
+			timeouts = append(timeouts, req.(*pb.ContainerExecRequest).GetTimeoutSecs())
+			return status.Error(codes.FailedPrecondition, "stop here")
+		}
+		return nil
+	}
+	useFakeClient(t, fake)
+	sb := newSandboxWithStdio(context.Background(), "sb-123", Ignore, Ignore)
+
+	// Sub-second timeouts are rounded up rather than sent as 0, which means
+	// no timeout.
+	for _, timeout := range []time.Duration{500 * time.Millisecond, 1500 * time.Millisecond} {
+		m := &sandboxHealthMonitor{check: HealthCheck{Command: []string{"true"}, Timeout: timeout}}
+		g.Expect(m.probe(sb)).To(gomega.HaveOccurred())
+	}
+	g.Expect(timeouts).To(gomega.Equal([]uint32{1, 2}))
+}
@@ -0,0 +1,199 @@
+package modal
+
+// Callbacks on Sandbox lifecycle transitions, driven by a background watcher.
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+)
+
+// SandboxStartEvent is passed to OnStart hooks when the Sandbox's container
+// becomes active.
+type SandboxStartEvent struct {
+	SandboxId string
+	TaskId    string    // Container of the Sandbox.
+	Time      time.Time // Time the container became active.
+}
+
+// SandboxExitEvent is passed to OnExit hooks when the Sandbox finishes.
+type SandboxExitEvent struct {
+	SandboxId string
+	ExitCode  int    // As returned by Sandbox.Wait.
+	Exception string // Error reported by Modal, if any, such as a failure to start.
+	OOM       bool   // Whether the Sandbox was likely killed for running out of memory, see OnOOM.
+	Time      time.Time
+}
+
+// SandboxOOMEvent is passed to OnOOM hooks when the Sandbox is likely to have
+// been killed for running out of memory.
+type SandboxOOMEvent struct {
+	SandboxId string
+	ExitCode  int    // As returned by Sandbox.Wait.
+	Exception string // Error reported by Modal, if any.
+	Time      time.Time
+}
+
+// sandboxHooks holds the callbacks registered on a Sandbox, and the events
+// already observed by its watcher, which are replayed to later callbacks.
+type sandboxHooks struct {
+	once sync.Once // starts the watcher
+
+	mu      sync.Mutex
+	onStart []func(SandboxStartEvent)
+	onExit  []func(SandboxExitEvent)
+	onOOM   []func(SandboxOOMEvent)
+	started *SandboxStartEvent
+	exited  *SandboxExitEvent
+}
+
+// OnStart registers fn to be called when the Sandbox's container becomes
+// active, for example to record its scheduling latency. If it was already
+// seen to become active, fn is called right away.
+//
+// Hooks are called one at a time from a watcher goroutine, started by the
+// first hook registered on the Sandbox, which follows Sandbox.Events and then
+// waits for the Sandbox to finish. Hooks should return quickly. The watcher
+// stops when the Sandbox's context is done. Hooks are per handle: those
+// registered on another Sandbox value with the same ID are not called.
+func (sb *Sandbox) OnStart(fn func(SandboxStartEvent)) {
+	h := sb.hooks
+	h.mu.Lock()
+	h.onStart = append(h.onStart, fn)
+	started := h.started
+	h.mu.Unlock()
+	if started != nil {
+		fn(*started)
+	}
+	sb.watchHooks()
+}
+
+// OnExit registers fn to be called when the Sandbox finishes, with its exit
+// code, so metrics and alerting need not wrap every call to Wait. If it was
+// already seen to finish, fn is called right away. See OnStart for how hooks are
+// called.
+func (sb *Sandbox) OnExit(fn func(SandboxExitEvent)) {
+	h := sb.hooks
+	h.mu.Lock()
+	h.onExit = append(h.onExit, fn)
+	exited := h.exited
+	h.mu.Unlock()
+	if exited != nil {
+		fn(*exited)
+	}
+	sb.watchHooks()
+}
+
+// OnOOM registers fn to be called, before the OnExit hooks, when the Sandbox
+// finishes after likely being killed for running out of memory. If it was
+// already seen to, fn is called right away. See OnStart for how hooks are called.
+//
+// Modal does not report the cause of a kill, so this is inferred from the
+// result: a failure with exit code 137, as from the kernel's OOM killer, or
+// with an error mentioning memory. A process in the Sandbox sending SIGKILL
+// to its main process is reported as well.
+func (sb *Sandbox) OnOOM(fn func(SandboxOOMEvent)) {
+	h := sb.hooks
+	h.mu.Lock()
+	h.onOOM = append(h.onOOM, fn)
+	exited := h.exited
+	h.mu.Unlock()
+	if exited != nil && exited.OOM {
+		fn(oomEvent(*exited))
+	}
+	sb.watchHooks()
+}
+
+// watchHooks starts the watcher that calls the Sandbox's hooks, once.
+func (sb *Sandbox) watchHooks() {
+	sb.hooks.once.Do(func() {
+		go sb.runHooks(sb.ctx)
+	})
+}
+
+// runHooks calls the hooks on the transitions of the Sandbox, until it
+// finishes or ctx is done. If the event stream fails, OnStart hooks may not be
+// called, but OnExit hooks still are, unless the result cannot be fetched
+// either.
+func (sb *Sandbox) runHooks(ctx context.Context) {
+	for event, err := range sb.Events(nil) {
+		if err != nil || event.Type == LifecycleDone {
+			break
+		}
+		if event.State == TaskStateActive {
+			sb.hooks.start(SandboxStartEvent{SandboxId: sb.SandboxId, TaskId: event.TaskId, Time: event.Time})
+		}
+	}
+	if result, err := sb.waitResult(ctx); err == nil {
+		sb.hooks.exit(sb.SandboxId, result, time.Now())
+	}
+}
+
+// start records that the Sandbox started, and calls the OnStart hooks the
+// first time.
+func (h *sandboxHooks) start(event SandboxStartEvent) {
+	h.mu.Lock()
+	if h.started != nil {
+		h.mu.Unlock()
+		return
+	}
+	h.started = &event
+	hooks := h.onStart
+	h.mu.Unlock()
+	for _, fn := range hooks {
+		fn(event)
+	}
+}
+
+// exit records that the Sandbox finished with result, and calls the OnOOM
+// and OnExit hooks.
+func (h *sandboxHooks) exit(sandboxId string, result *pb.GenericResult, at time.Time) {
+	event := SandboxExitEvent{
+		SandboxId: sandboxId,
+		Exception: result.GetException(),
+		OOM:       isOOM(result),
+		Time:      at,
+	}
+	if exitCode := getReturnCode(result); exitCode != nil {
+		event.ExitCode = *exitCode
+	}
+
+	h.mu.Lock()
+	if h.exited != nil {
+		h.mu.Unlock()
+		return
+	}
+	h.exited = &event
+	onOOM, onExit := h.onOOM, h.onExit
+	h.mu.Unlock()
+	if event.OOM {
+		for _, fn := range onOOM {
+			fn(oomEvent(event))
+		}
+	}
+	for _, fn := range onExit {
+		fn(event)
+	}
+}
+
+func oomEvent(exit SandboxExitEvent) SandboxOOMEvent {
+	return SandboxOOMEvent{SandboxId: exit.SandboxId, ExitCode: exit.ExitCode, Exception: exit.Exception, Time: exit.Time}
+}
+
+// isOOM reports whether a Sandbox result looks like the Sandbox was killed
+// for running out of memory. Terminated and timed out Sandboxes are also
+// killed, and are not.
+func isOOM(result *pb.GenericResult) bool {
+	switch result.GetStatus() {
+	case pb.GenericResult_GENERIC_STATUS_TERMINATED, pb.GenericResult_GENERIC_STATUS_TIMEOUT, pb.GenericResult_GENERIC_STATUS_SUCCESS:
+		return false
+	}
+	exception := strings.ToLower(result.GetException())
+	return result.GetExitcode() == 137 ||
+		strings.Contains(exception, "out of memory") ||
+		strings.Contains(exception, "oom-kill") ||
+		strings.Contains(exception, "oomkill")
+}
@@ -0,0 +1,50 @@
+package modal
+
+import (
+	"testing"
+	"time"
+
+	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+	"github.com/onsi/gomega"
+)
+
+func TestSandboxHooks(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	sb := &Sandbox{SandboxId: "sb-123", hooks: &sandboxHooks{}}
+	sb.hooks.once.Do(func() {}) // no watcher, transitions are fed below
+	var calls []string
+	sb.OnStart(func(e SandboxStartEvent) { calls = append(calls, "start "+e.TaskId) })
+	sb.OnOOM(func(e SandboxOOMEvent) { calls = append(calls, "oom") })
+	sb.OnExit(func(e SandboxExitEvent) { calls = append(calls, "exit") })
+	g.Expect(calls).To(gomega.BeEmpty())
+
+	sb.hooks.start(SandboxStartEvent{SandboxId: "sb-123", TaskId: "ta-1"})
+	sb.hooks.start(SandboxStartEvent{SandboxId: "sb-123", TaskId: "ta-2"})
+	at := time.Unix(100, 0)
+	sb.hooks.exit("sb-123", pb.GenericResult_builder{Status: pb.GenericResult_GENERIC_STATUS_FAILURE, Exitcode: 137}.Build(), at)
+	g.Expect(calls).To(gomega.Equal([]string{"start ta-1", "oom", "exit"}))
+
+	// Hooks registered later are called with the events already seen.
+	var exit SandboxExitEvent
+	sb.OnExit(func(e SandboxExitEvent) { exit = e })
+	g.Expect(exit).To(gomega.Equal(SandboxExitEvent{SandboxId: "sb-123", ExitCode: 137, OOM: true, Time: at}))
+	var started SandboxStartEvent
+	sb.OnStart(func(e SandboxStartEvent) { started = e })
+	g.Expect(started.TaskId).To(gomega.Equal("ta-1"))
+}
+
+func TestIsOOM(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	result := func(status pb.GenericResult_GenericStatus, exitcode int32, exception string) *pb.GenericResult {
+		return pb.GenericResult_builder{Status: status, Exitcode: exitcode, Exception: exception}.Build()
+	}
+	g.Expect(isOOM(result(pb.GenericResult_GENERIC_STATUS_FAILURE, 137, ""))).To(gomega.BeTrue())
+	g.Expect(isOOM(result(pb.GenericResult_GENERIC_STATUS_INTERNAL_FAILURE, 0, "Container ran out of memory"))).To(gomega.BeTrue())
+	g.Expect(isOOM(result(pb.GenericResult_GENERIC_STATUS_FAILURE, 1, ""))).To(gomega.BeFalse())
+	g.Expect(isOOM(result(pb.GenericResult_GENERIC_STATUS_TERMINATED, 137, ""))).To(gomega.BeFalse())
+	g.Expect(isOOM(result(pb.GenericResult_GENERIC_STATUS_SUCCESS, 0, ""))).To(gomega.BeFalse())
+}
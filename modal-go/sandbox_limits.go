@@ -0,0 +1,126 @@
+package modal
+
+// Client-side limits on the Sandboxes created through an App.
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// AppLimits are limits on the Sandboxes created through an App by this
+// client, see App.SetLimits. Zero fields are not limited.
+type AppLimits struct {
+	// MaxConcurrentSandboxes is the maximum number of Sandboxes created
+	// through the App that may be running at once.
+	MaxConcurrentSandboxes int
+	// MaxSandboxesPerMinute is the maximum number of Sandboxes that may be
+	// created through the App in any one minute.
+	MaxSandboxesPerMinute int
+}
+
+// sandboxLimiter enforces the AppLimits of an App. It is shared by the
+// copies of the App made by WithContext.
+type sandboxLimiter struct {
+	mu      sync.Mutex
+	limits  AppLimits
+	running int           // Sandboxes holding a slot
+	created []time.Time   // start of creations in the last minute, oldest first
+	changed chan struct{} // closed when a slot is released or limits change
+}
+
+func newSandboxLimiter() *sandboxLimiter {
+	return &sandboxLimiter{changed: make(chan struct{})}
+}
+
+// SetLimits sets limits on the Sandboxes subsequently created through the
+// App, and its copies made with WithContext, to stop a runaway loop from
+// creating thousands of Sandboxes before Modal's quotas apply.
+//
+// CreateSandbox waits while a limit is reached, until a running Sandbox
+// finishes or the minute's oldest creation expires, or until the App's
+// context is done, returning its error. Sandboxes are counted as running
+// until they are seen to finish or are terminated through this client; a
+// background goroutine waits for each of them while MaxConcurrentSandboxes
+// is set. The limits are enforced by this client only, and do not count
+// Sandboxes created by other clients or processes.
+func (app *App) SetLimits(limits AppLimits) error {
+	if limits.MaxConcurrentSandboxes < 0 || limits.MaxSandboxesPerMinute < 0 {
+		return InvalidError{fmt.Sprintf("App limits must not be negative, got %+v", limits)}
+	}
+	l := app.limiter
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limits = limits
+	l.notify()
+	return nil
+}
+
+// notify wakes up the creations waiting for a limit. l.mu must be held.
+func (l *sandboxLimiter) notify() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// acquire waits until a Sandbox may be created within the limits. While
+// MaxConcurrentSandboxes is set, it returns a function that releases the
+// Sandbox's slot, which may be called more than once, and nil otherwise.
+func (l *sandboxLimiter) acquire(ctx context.Context) (func(), error) {
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		for len(l.created) > 0 && now.Sub(l.created[0]) >= time.Minute {
+			l.created = l.created[1:]
+		}
+		concurrent := l.limits.MaxConcurrentSandboxes == 0 || l.running < l.limits.MaxConcurrentSandboxes
+		rate := l.limits.MaxSandboxesPerMinute == 0 || len(l.created) < l.limits.MaxSandboxesPerMinute
+		if concurrent && rate {
+			if l.limits.MaxSandboxesPerMinute > 0 {
+				l.created = append(l.created, now)
+			}
+			var release func()
+			if l.limits.MaxConcurrentSandboxes > 0 {
+				l.running++
+				release = sync.OnceFunc(l.release)
+			}
+			l.mu.Unlock()
+			return release, nil
+		}
+		changed := l.changed
+		wait := time.Duration(math.MaxInt64)
+		if !rate {
+			wait = l.created[0].Add(time.Minute).Sub(now)
+		}
+		l.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-changed:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// release frees the slot of a Sandbox that has finished.
+func (l *sandboxLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.running--
+	l.notify()
+}
+
+// watchRelease releases the slot of sb once it finishes. Waiting uses a
+// context that is not cancelled with the Sandbox's, and if it fails the slot
+// is released, so that a lost Sandbox does not hold a slot for good.
+func (sb *Sandbox) watchRelease() {
+	go func() {
+		defer sb.release()
+		_, _ = sb.waitResult(context.WithoutCancel(sb.ctx))
+	}()
+}
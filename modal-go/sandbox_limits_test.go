@@ -0,0 +1,110 @@
+package modal
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+	"github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestAppLimitsConcurrency(t *testing.T) {
+	g := gomega.NewWithT(t)
+	var mu sync.Mutex
+	created, waited := 0, 0
+	finish := make(chan struct{}) // Sandboxes run until it is closed
+	fake := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		switch method {
+		case "/modal.client.ModalClient/SandboxCreate":
+			mu.Lock()
+			created++
+			id := fmt.Sprintf("sb-%d", created)
+			mu.Unlock()
+			proto.Merge(reply.(proto.Message), pb.SandboxCreateResponse_builder{SandboxId: id}.Build())
+		case "/modal.client.ModalClient/SandboxWait":
+			<-finish
+			mu.Lock()
+			waited++
+			mu.Unlock()
+			proto.Merge(reply.(proto.Message), pb.SandboxWaitResponse_builder{
+				Result: pb.GenericResult_builder{Status: pb.GenericResult_GENERIC_STATUS_SUCCESS}.Build(),
+			}.Build())
+		}
+		return nil
+	}
+	useFakeClient(t, fake)
+	app := newApp(context.Background(), "ap-123")
+	g.Expect(app.SetLimits(AppLimits{MaxConcurrentSandboxes: 1})).To(gomega.Succeed())
+	image := &Image{ImageId: "im-123"}
+	quiet := &SandboxOptions{Stdout: Ignore, Stderr: Ignore} // no log streams outliving the fake client
+
+	first, err := app.CreateSandbox(image, quiet)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	// The second Sandbox waits for the first to be terminated.
+	second := make(chan *Sandbox)
+	go func() {
+		sb, _ := app.CreateSandbox(image, quiet)
+		second <- sb
+	}()
+	g.Consistently(second, 50*time.Millisecond).ShouldNot(gomega.Receive())
+	g.Expect(first.Terminate()).To(gomega.Succeed())
+	var sb *Sandbox
+	g.Eventually(second).Should(gomega.Receive(&sb))
+	g.Expect(sb.SandboxId).To(gomega.Equal("sb-2"))
+
+	// Copies made with WithContext share the limits, and stop waiting with
+	// their context.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	scoped, err := app.WithContext(ctx)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	_, err = scoped.CreateSandbox(image, quiet)
+	g.Expect(err).To(gomega.MatchError(context.DeadlineExceeded))
+
+	// A Sandbox that finishes on its own frees its slot too.
+	close(finish)
+	sb, err = app.CreateSandbox(image, quiet)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(sb.SandboxId).To(gomega.Equal("sb-3"))
+	finished := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return waited
+	}
+	g.Eventually(finished).Should(gomega.Equal(3)) // no waits outliving the fake client
+
+	g.Expect(app.SetLimits(AppLimits{MaxSandboxesPerMinute: -1})).To(gomega.BeAssignableToTypeOf(InvalidError{}))
+}
+
+func TestAppLimitsRate(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	l := newSandboxLimiter()
+	l.limits = AppLimits{MaxSandboxesPerMinute: 2}
+
+	for range 2 {
+		release, err := l.acquire(context.Background())
+		g.Expect(err).ShouldNot(gomega.HaveOccurred())
+		g.Expect(release).To(gomega.BeNil()) // no slot is held without MaxConcurrentSandboxes
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := l.acquire(ctx)
+	g.Expect(err).To(gomega.MatchError(context.DeadlineExceeded))
+
+	// Once the oldest creation is a minute old, another Sandbox may be created.
+	l.mu.Lock()
+	l.created[0] = time.Now().Add(-time.Minute + 20*time.Millisecond)
+	l.mu.Unlock()
+	start := time.Now()
+	_, err = l.acquire(context.Background())
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(time.Since(start)).To(gomega.BeNumerically(">=", 10*time.Millisecond))
+	g.Expect(l.created).To(gomega.HaveLen(2))
+}
@@ -0,0 +1,274 @@
+package modal
+
+// Functional options for building SandboxOptions.
+
+import (
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// SandboxOption sets fields of SandboxOptions, and checks the values it is
+// given. Options are layered over the struct, so code can mix them with
+// struct literals, see NewSandboxOptions and SandboxOptions.Apply.
+type SandboxOption func(*SandboxOptions) error
+
+// NewSandboxOptions returns SandboxOptions with opts applied in order, or the
+// error of the first option given an invalid value.
+//
+//	options, err := modal.NewSandboxOptions(
+//		modal.WithCPU(2),
+//		modal.WithMemory(4096),
+//		modal.WithVolume("/data", volume),
+//		modal.WithCommand("python", "main.py"),
+//	)
+func NewSandboxOptions(opts ...SandboxOption) (*SandboxOptions, error) {
+	options := &SandboxOptions{}
+	if err := options.Apply(opts...); err != nil {
+		return nil, err
+	}
+	return options, nil
+}
+
+// Apply applies opts to the options in order. It stops at the first option
+// given an invalid value, leaving the fields set by earlier options.
+func (options *SandboxOptions) Apply(opts ...SandboxOption) error {
+	for _, opt := range opts {
+		if err := opt(options); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WithCPU sets the CPU request in physical cores.
+func WithCPU(cores CPUCores) SandboxOption {
+	return func(options *SandboxOptions) error {
+		if cores <= 0 {
+			return InvalidError{fmt.Sprintf("CPU must be positive, got %v", float64(cores))}
+		}
+		if err := cores.Validate(); err != nil {
+			return err
+		}
+		options.CPU = cores
+		return nil
+	}
+}
+
+// WithMemory sets the memory request in MiB.
+func WithMemory(mib MemoryMiB) SandboxOption {
+	return func(options *SandboxOptions) error {
+		if mib <= 0 {
+			return InvalidError{fmt.Sprintf("Memory must be positive, got %d", mib)}
+		}
+		if err := mib.Validate(); err != nil {
+			return err
+		}
+		options.Memory = mib
+		return nil
+	}
+}
+
+// WithGPU sets the GPU request, such as "A100" or "H100:2".
+func WithGPU(gpu string) SandboxOption {
+	return func(options *SandboxOptions) error {
+		if gpu == "" {
+			return InvalidError{"GPU must not be empty"}
+		}
+		if _, err := parseGPUConfig(gpu); err != nil {
+			return err
+		}
+		options.GPU = gpu
+		return nil
+	}
+}
+
+// WithEphemeralDisk sets the ephemeral disk request in MiB.
+func WithEphemeralDisk(mib DiskMiB) SandboxOption {
+	return func(options *SandboxOptions) error {
+		if mib <= 0 {
+			return InvalidError{fmt.Sprintf("EphemeralDiskMB must be positive, got %d", mib)}
+		}
+		if err := mib.Validate(); err != nil {
+			return err
+		}
+		options.EphemeralDiskMB = mib
+		return nil
+	}
+}
+
+// WithTimeout sets the maximum duration of the Sandbox.
+func WithTimeout(timeout time.Duration) SandboxOption {
+	return func(options *SandboxOptions) error {
+		if timeout <= 0 || timeout > maxSandboxTimeout {
+			return InvalidError{fmt.Sprintf("Timeout must be between 0 and %v, got %v", maxSandboxTimeout, timeout)}
+		}
+		options.Timeout = timeout
+		return nil
+	}
+}
+
+// WithCommand sets the command to run in the Sandbox on startup.
+func WithCommand(args ...string) SandboxOption {
+	return func(options *SandboxOptions) error {
+		if len(args) == 0 {
+			return InvalidError{"Command must not be empty"}
+		}
+		options.Command = slices.Clone(args)
+		return nil
+	}
+}
+
+// WithEntrypoint sets the entrypoint that Command is appended to.
+func WithEntrypoint(args ...string) SandboxOption {
+	return func(options *SandboxOptions) error {
+		if len(args) == 0 {
+			return InvalidError{"Entrypoint must not be empty"}
+		}
+		options.Entrypoint = slices.Clone(args)
+		return nil
+	}
+}
+
+// WithVolume mounts volume at mountPath, an absolute path other than /.
+func WithVolume(mountPath string, volume *Volume) SandboxOption {
+	return func(options *SandboxOptions) error {
+		if volume == nil {
+			return InvalidError{fmt.Sprintf("Volume for mount point %s must not be nil", mountPath)}
+		}
+		if !path.IsAbs(mountPath) || path.Clean(mountPath) == "/" {
+			return InvalidError{fmt.Sprintf("Volume mount point must be an absolute path other than /, got %q", mountPath)}
+		}
+		if _, ok := options.Volumes[mountPath]; ok {
+			return InvalidError{fmt.Sprintf("Volume mount point %s is specified more than once", mountPath)}
+		}
+		options.Volumes = maps.Clone(options.Volumes)
+		if options.Volumes == nil {
+			options.Volumes = map[string]*Volume{}
+		}
+		options.Volumes[mountPath] = volume
+		return nil
+	}
+}
+
+// WithPort tunnels a port into the Sandbox, see Sandbox.Tunnels.
+func WithPort(spec PortSpec) SandboxOption {
+	return func(options *SandboxOptions) error {
+		if spec.Port < 1 || spec.Port > 65535 {
+			return InvalidError{fmt.Sprintf("port %d is out of range 1-65535", spec.Port)}
+		}
+		if spec.H2 && spec.Unencrypted {
+			return InvalidError{fmt.Sprintf("port %d cannot be both H2 and Unencrypted", spec.Port)}
+		}
+		for _, existing := range options.portSpecs() {
+			if existing.Port == spec.Port {
+				return InvalidError{fmt.Sprintf("port %d is specified more than once", spec.Port)}
+			}
+		}
+		options.Ports = append(slices.Clip(options.Ports), spec)
+		return nil
+	}
+}
+
+// WithSecrets injects secrets as environment variables.
+func WithSecrets(secrets ...*Secret) SandboxOption {
+	return func(options *SandboxOptions) error {
+		if slices.Contains(secrets, nil) {
+			return InvalidError{"Secrets must not be nil"}
+		}
+		options.Secrets = append(slices.Clip(options.Secrets), secrets...)
+		return nil
+	}
+}
+
+// WithEnvVar sets an environment variable in the Sandbox.
+func WithEnvVar(key, value string) SandboxOption {
+	return func(options *SandboxOptions) error {
+		if key == "" || strings.ContainsAny(key, "=\x00") {
+			return InvalidError{fmt.Sprintf("invalid environment variable name: %q", key)}
+		}
+		options.EnvVars = maps.Clone(options.EnvVars)
+		if options.EnvVars == nil {
+			options.EnvVars = map[string]string{}
+		}
+		options.EnvVars[key] = value
+		return nil
+	}
+}
+
+// WithRegions restricts the regions the Sandbox may run in.
+func WithRegions(regions ...string) SandboxOption {
+	return func(options *SandboxOptions) error {
+		if len(regions) == 0 || slices.Contains(regions, "") {
+			return InvalidError{"Regions must be non-empty"}
+		}
+		options.Regions = slices.Clone(regions)
+		return nil
+	}
+}
+
+// WithHealthCheck sets the liveness probe of the Sandbox, see Sandbox.Health.
+func WithHealthCheck(check HealthCheck) SandboxOption {
+	return func(options *SandboxOptions) error {
+		if len(check.Command) == 0 {
+			return InvalidError{"HealthCheck.Command must not be empty"}
+		}
+		options.HealthCheck = &check
+		return nil
+	}
+}
+
+// WithBackgroundProcess starts a process alongside the main command, see
+// Sandbox.BackgroundProcess.
+func WithBackgroundProcess(process BackgroundProcess) SandboxOption {
+	return func(options *SandboxOptions) error {
+		if process.Name == "" || slices.ContainsFunc(options.BackgroundProcesses, func(p BackgroundProcess) bool { return p.Name == process.Name }) {
+			return InvalidError{fmt.Sprintf("background process names must be unique and non-empty, got %q", process.Name)}
+		}
+		if len(process.Command) == 0 {
+			return InvalidError{fmt.Sprintf("command of background process %q must not be empty", process.Name)}
+		}
+		options.BackgroundProcesses = append(slices.Clip(options.BackgroundProcesses), process)
+		return nil
+	}
+}
+
+// WithDetach runs the Sandbox independently of the caller, see
+// SandboxOptions.Detach.
+func WithDetach() SandboxOption {
+	return func(options *SandboxOptions) error {
+		options.Detach = true
+		return nil
+	}
+}
+
+// WithTag sets a tag on the Sandbox.
+func WithTag(key, value string) SandboxOption {
+	return func(options *SandboxOptions) error {
+		tags := maps.Clone(options.Tags)
+		if tags == nil {
+			tags = map[string]string{}
+		}
+		tags[key] = value
+		if err := validateTags(tags); err != nil {
+			return err
+		}
+		options.Tags = tags
+		return nil
+	}
+}
+
+// WithIdempotencyKey sets the key that identifies the creation request to
+// Modal, see SandboxOptions.IdempotencyKey.
+func WithIdempotencyKey(key string) SandboxOption {
+	return func(options *SandboxOptions) error {
+		if key == "" {
+			return InvalidError{"IdempotencyKey must not be empty"}
+		}
+		options.IdempotencyKey = key
+		return nil
+	}
+}
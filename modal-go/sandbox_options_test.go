@@ -0,0 +1,76 @@
+package modal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+)
+
+func TestNewSandboxOptions(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	volume := &Volume{VolumeId: "vo-123"}
+	options, err := NewSandboxOptions(
+		WithCPU(2),
+		WithMemory(4096),
+		WithGPU("A100"),
+		WithTimeout(time.Hour),
+		WithCommand("python", "main.py"),
+		WithVolume("/data", volume),
+		WithPort(PortSpec{Port: 8080, H2: true}),
+		WithEnvVar("MODE", "test"),
+		WithTag("team", "infra"),
+	)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(*options).To(gomega.Equal(SandboxOptions{
+		CPU:     2,
+		Memory:  4096,
+		GPU:     "A100",
+		Timeout: time.Hour,
+		Command: []string{"python", "main.py"},
+		Volumes: map[string]*Volume{"/data": volume},
+		Ports:   []PortSpec{{Port: 8080, H2: true}},
+		EnvVars: map[string]string{"MODE": "test"},
+		Tags:    map[string]string{"team": "infra"},
+	}))
+	g.Expect(ValidateSandboxOptions(&Image{ImageId: "im-123"}, options)).To(gomega.Succeed())
+}
+
+func TestSandboxOptionsApply(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	// Options are layered over struct literals, without modifying their maps
+	// and slices.
+	envVars := map[string]string{"A": "1"}
+	base := SandboxOptions{Memory: 1024, EnvVars: envVars, EncryptedPorts: []int{443}}
+	options := base
+	g.Expect(options.Apply(WithCPU(0.5), WithEnvVar("B", "2"))).To(gomega.Succeed())
+	g.Expect(options.CPU).To(gomega.Equal(CPUCores(0.5)))
+	g.Expect(options.Memory).To(gomega.Equal(MemoryMiB(1024)))
+	g.Expect(options.EnvVars).To(gomega.Equal(map[string]string{"A": "1", "B": "2"}))
+	g.Expect(envVars).To(gomega.Equal(map[string]string{"A": "1"}))
+
+	for _, opt := range []SandboxOption{
+		WithCPU(-1),
+		WithMemory(0),
+		WithGPU(""),
+		WithGPU("A100:0"),
+		WithTimeout(48 * time.Hour),
+		WithCommand(),
+		WithVolume("data", &Volume{VolumeId: "vo-123"}),
+		WithVolume("/data", nil),
+		WithPort(PortSpec{Port: 70000}),
+		WithPort(PortSpec{Port: 443}), // already in EncryptedPorts
+		WithEnvVar("A=B", "1"),
+		WithTag("", "x"),
+		WithBackgroundProcess(BackgroundProcess{Name: "server"}),
+	} {
+		g.Expect(options.Apply(opt)).To(gomega.BeAssignableToTypeOf(InvalidError{}))
+	}
+
+	_, err := NewSandboxOptions(WithVolume("/data", &Volume{}), WithVolume("/data", &Volume{}))
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("more than once")))
+}
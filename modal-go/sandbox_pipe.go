@@ -0,0 +1,136 @@
+package modal
+
+// Streaming output of one command into the input of another.
+
+import (
+	"errors"
+	"io"
+)
+
+const (
+	defaultPipeBufferSize = 4 << 20 // 4 MiB
+	pipeReadSize          = 64 << 10
+)
+
+// PipeStreamOptions are options for PipeStream.
+type PipeStreamOptions struct {
+	// BufferSize is the maximum number of bytes read from src and not yet
+	// written to dst, defaults to 4 MiB. Once it is reached, reading from
+	// src waits for writes to catch up, so a fast producer is slowed down
+	// rather than buffered in memory.
+	BufferSize int
+	// KeepOpen leaves dst open after src is exhausted. By default dst is
+	// closed, so the reading command sees the end of its input, as with a
+	// shell pipe.
+	KeepOpen bool
+}
+
+// PipeStream copies src to dst until src is exhausted, and returns the number of
+// bytes copied. It is meant to chain commands across Sandboxes without
+// temporary files:
+//
+//	producer, _ := sandboxA.Exec([]string{"tar", "c", "/data"}, modal.ExecOptions{})
+//	consumer, _ := sandboxB.Exec([]string{"tar", "x", "-C", "/restore"}, modal.ExecOptions{})
+//	_, err := modal.PipeStream(consumer.Stdin, producer.Stdout, nil)
+//
+// Modal has no server-side pipes, so the data passes through the client.
+// Reads from src and writes to dst run concurrently, with at most
+// options.BufferSize bytes in between. Small reads are combined into writes
+// of up to 1 MiB, to send fewer requests. If writing to dst fails, PipeStream
+// returns the error at once, and closes src if it is an io.Closer, so that a
+// pending read is interrupted. The rest of src is left unread.
+func PipeStream(dst io.WriteCloser, src io.Reader, options *PipeStreamOptions) (int64, error) {
+	if options == nil {
+		options = &PipeStreamOptions{}
+	}
+	bufferSize := options.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = defaultPipeBufferSize
+	}
+
+	// The reader sends chunks to the writer, and the writer returns their
+	// buffers once written, which bounds the bytes in flight.
+	buffers := make(chan []byte, max(bufferSize/pipeReadSize, 1))
+	for range cap(buffers) {
+		buffers <- make([]byte, min(pipeReadSize, bufferSize))
+	}
+	chunks := make(chan []byte, cap(buffers))
+	stop := make(chan struct{})
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(chunks)
+		for {
+			var buf []byte
+			select {
+			case <-stop:
+				readErr <- nil
+				return
+			default:
+			}
+			select {
+			case buf = <-buffers:
+			case <-stop:
+				readErr <- nil
+				return
+			}
+			n, err := src.Read(buf)
+			if n > 0 {
+				chunks <- buf[:n]
+			} else {
+				buffers <- buf
+			}
+			if err == io.EOF {
+				readErr <- nil
+				return
+			}
+			if err != nil {
+				readErr <- err
+				return
+			}
+		}
+	}()
+
+	var written int64
+	batch := make([]byte, 0, stdinChunkSize)
+	var held [][]byte // buffers of the batch, returned once it is written
+	for chunk := range chunks {
+		batch = append(batch[:0], chunk...)
+		held = append(held[:0], chunk)
+	coalesce:
+		for len(batch)+pipeReadSize <= stdinChunkSize {
+			select {
+			case chunk, ok := <-chunks:
+				if !ok {
+					break coalesce
+				}
+				batch = append(batch, chunk...)
+				held = append(held, chunk)
+			default:
+				break coalesce
+			}
+		}
+		n, err := dst.Write(batch)
+		written += int64(n)
+		for _, buf := range held {
+			buffers <- buf[:cap(buf)]
+		}
+		if err != nil {
+			// The reader never blocks on the channels, so it exits once it
+			// sees stop, or once its read returns.
+			close(stop)
+			if closer, ok := src.(io.Closer); ok {
+				closer.Close()
+			}
+			return written, err
+		}
+	}
+	if err := <-readErr; err != nil {
+		return written, err
+	}
+	if !options.KeepOpen {
+		if err := dst.Close(); err != nil && !errors.Is(err, io.ErrClosedPipe) {
+			return written, err
+		}
+	}
+	return written, nil
+}
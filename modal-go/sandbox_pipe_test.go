@@ -0,0 +1,126 @@
+package modal
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+)
+
+// pipeSink records writes, optionally waiting on a channel before each one.
+type pipeSink struct {
+	bytes.Buffer
+	writes int
+	gate   chan struct{}
+	err    error
+	closed bool
+}
+
+func (s *pipeSink) Write(p []byte) (int, error) {
+	if s.gate != nil {
+		<-s.gate
+	}
+	if s.err != nil {
+		return 0, s.err
+	}
+	s.writes++
+	return s.Buffer.Write(p)
+}
+
+func (s *pipeSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+// countingReader counts the bytes read from it.
+type countingReader struct {
+	r io.Reader
+	n atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+func TestPipeStream(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	data := bytes.Repeat([]byte("0123456789"), 500_000)
+	sink := &pipeSink{}
+	n, err := PipeStream(sink, bytes.NewReader(data), nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(n).To(gomega.Equal(int64(len(data))))
+	g.Expect(sink.Bytes()).To(gomega.Equal(data))
+	g.Expect(sink.closed).To(gomega.BeTrue())
+
+	sink = &pipeSink{}
+	_, err = PipeStream(sink, bytes.NewReader(data), &PipeStreamOptions{KeepOpen: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(sink.closed).To(gomega.BeFalse())
+}
+
+func TestPipeStreamBoundedBuffer(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	src := &countingReader{r: bytes.NewReader(make([]byte, 10<<20))}
+	sink := &pipeSink{gate: make(chan struct{})}
+	done := make(chan error)
+	go func() {
+		_, err := PipeStream(sink, src, &PipeStreamOptions{BufferSize: 256 << 10})
+		done <- err
+	}()
+
+	// With writes blocked, reads stop once the buffer is full.
+	time.Sleep(50 * time.Millisecond)
+	g.Expect(src.n.Load()).To(gomega.BeNumerically("<=", 256<<10))
+
+	close(sink.gate)
+	g.Eventually(done).Should(gomega.Receive(gomega.BeNil()))
+	g.Expect(sink.Len()).To(gomega.Equal(10 << 20))
+}
+
+func TestPipeStreamWriteError(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	writeErr := errors.New("stdin closed")
+	sink := &pipeSink{err: writeErr}
+	n, err := PipeStream(sink, bytes.NewReader(make([]byte, 10<<20)), nil)
+	g.Expect(err).To(gomega.MatchError(writeErr))
+	g.Expect(n).To(gomega.BeZero())
+	g.Expect(sink.closed).To(gomega.BeFalse())
+}
+
+func TestPipeStreamWriteErrorBlockedRead(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	// src produces one chunk, then blocks until it is closed.
+	pr, pw := io.Pipe()
+	go pw.Write([]byte("chunk"))
+	writeErr := errors.New("stdin closed")
+	_, err := PipeStream(&pipeSink{err: writeErr}, pr, nil)
+	g.Expect(err).To(gomega.MatchError(writeErr))
+	_, err = pw.Write([]byte("more"))
+	g.Expect(err).To(gomega.MatchError(io.ErrClosedPipe))
+
+	// Readers that can't be closed are left blocked, without blocking the
+	// caller.
+	pr, pw = io.Pipe()
+	defer pw.Close()
+	go pw.Write([]byte("chunk"))
+	done := make(chan error, 1)
+	go func() {
+		_, err := PipeStream(&pipeSink{err: writeErr}, struct{ io.Reader }{pr}, nil)
+		done <- err
+	}()
+	g.Eventually(done).Should(gomega.Receive(gomega.MatchError(writeErr)))
+}
@@ -0,0 +1,124 @@
+package modal
+
+// Structured errors for rejected Sandbox resource requests.
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const resourceNumber = `(\d+(?:\.\d+)?)`
+
+var (
+	resourceRangePattern = regexp.MustCompile(`between ` + resourceNumber + `\s*\w*\s+and ` + resourceNumber)
+	resourceMaxPattern   = regexp.MustCompile(`(?:at most|up to|maximum(?: of| is)?|max(?:imum)?:|exceeds?(?: the)?(?: maximum| limit)?(?: of)?)\s+` + resourceNumber)
+	resourceMinPattern   = regexp.MustCompile(`(?:at least|minimum(?: of| is)?|min(?:imum)?:)\s+` + resourceNumber)
+)
+
+// resourceFields maps the fields of Modal's Resources message, as named by
+// the field violations of a BadRequest error detail, to those of
+// SandboxOptions.
+var resourceFields = map[string]string{
+	"milli_cpu":         "CPU",
+	"milli_cpu_max":     "CPU",
+	"memory_mb":         "Memory",
+	"memory_mb_max":     "Memory",
+	"ephemeral_disk_mb": "EphemeralDiskMB",
+	"gpu_config":        "GPU",
+}
+
+// resourceKeywords are the words naming each field in rejection messages.
+var resourceKeywords = map[string][]string{
+	"CPU":             {"cpu", "cores"},
+	"Memory":          {"memory"},
+	"EphemeralDiskMB": {"disk"},
+	"GPU":             {"gpu"},
+}
+
+// parseResourcesError converts a rejection of a Sandbox's resource requests
+// into an InvalidResourcesError. Other errors are returned unchanged.
+//
+// The field is taken from a BadRequest detail of the error if there is one,
+// and otherwise from the message, only if it names a single resource. The
+// bounds are recovered from the text of the violation or message.
+func parseResourcesError(err error, options *SandboxOptions) error {
+	st, ok := status.FromError(err)
+	if !ok || (st.Code() != codes.InvalidArgument && st.Code() != codes.ResourceExhausted) {
+		return err
+	}
+	field, description := resourceViolation(st)
+	if field == "" {
+		field, description = messageResource(st.Message()), st.Message()
+	}
+
+	resErr := InvalidResourcesError{Exception: st.Message(), Field: field, cause: err}
+	switch field {
+	case "CPU":
+		resErr.Requested = float64(options.CPU)
+	case "Memory":
+		resErr.Requested = float64(options.Memory)
+	case "EphemeralDiskMB":
+		resErr.Requested = float64(options.EphemeralDiskMB)
+	case "GPU":
+		if gpuConfig, err := parseGPUConfig(options.GPU); err == nil && gpuConfig != nil {
+			resErr.Requested = float64(gpuConfig.GetCount())
+		}
+	default:
+		return err
+	}
+
+	description = strings.ToLower(description)
+	if m := resourceRangePattern.FindStringSubmatch(description); m != nil {
+		resErr.Min, _ = strconv.ParseFloat(m[1], 64)
+		resErr.Max, _ = strconv.ParseFloat(m[2], 64)
+		return resErr
+	}
+	if m := resourceMaxPattern.FindStringSubmatch(description); m != nil {
+		resErr.Max, _ = strconv.ParseFloat(m[1], 64)
+	}
+	if m := resourceMinPattern.FindStringSubmatch(description); m != nil {
+		resErr.Min, _ = strconv.ParseFloat(m[1], 64)
+	}
+	return resErr
+}
+
+// resourceViolation returns the field of the first violation of a resource
+// in the BadRequest details of st, and its description.
+func resourceViolation(st *status.Status) (field, description string) {
+	for _, detail := range st.Details() {
+		badRequest, ok := detail.(*errdetails.BadRequest)
+		if !ok {
+			continue
+		}
+		for _, violation := range badRequest.GetFieldViolations() {
+			for _, name := range strings.Split(violation.GetField(), ".") {
+				if field, ok := resourceFields[name]; ok {
+					return field, violation.GetDescription()
+				}
+			}
+		}
+	}
+	return "", ""
+}
+
+// messageResource returns the field named by a rejection message, or "" if
+// it names none or several.
+func messageResource(message string) string {
+	message = strings.ToLower(message)
+	var named string
+	for field, keywords := range resourceKeywords {
+		if slices.ContainsFunc(keywords, func(keyword string) bool { return strings.Contains(message, keyword) }) {
+			if named != "" {
+				return ""
+			}
+			named = field
+		}
+	}
+	return named
+}
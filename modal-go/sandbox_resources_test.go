@@ -0,0 +1,72 @@
+package modal
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/onsi/gomega"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// resourcesStatus returns an error with a BadRequest detail for a violation
+// of field.
+func resourcesStatus(code codes.Code, message, field, description string) error {
+	st, err := status.New(code, message).WithDetails(&errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: field, Description: description}},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return st.Err()
+}
+
+func TestParseResourcesError(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	options := &SandboxOptions{CPU: 128, Memory: 1 << 20, EphemeralDiskMB: 1 << 30, GPU: "H100:4"}
+
+	// The field comes from the error details, even when the message names
+	// several resources.
+	err := parseResourcesError(resourcesStatus(codes.InvalidArgument, "invalid resources: memory exceeds what the requested CPU allows",
+		"resources.memory_mb", "must be at most 344064"), options)
+	var resErr InvalidResourcesError
+	g.Expect(errors.As(err, &resErr)).To(gomega.BeTrue())
+	g.Expect(resErr.Field).To(gomega.Equal("Memory"))
+	g.Expect(resErr.Requested).To(gomega.Equal(float64(1 << 20)))
+	g.Expect(resErr.Min).To(gomega.BeZero())
+	g.Expect(resErr.Max).To(gomega.Equal(float64(344064)))
+	g.Expect(status.Code(errors.Unwrap(err))).To(gomega.Equal(codes.InvalidArgument))
+
+	err = parseResourcesError(resourcesStatus(codes.InvalidArgument, "invalid resources",
+		"resources.milli_cpu", "must be between 0.125 and 64 cores"), options)
+	g.Expect(errors.As(err, &resErr)).To(gomega.BeTrue())
+	g.Expect(resErr.Field).To(gomega.Equal("CPU"))
+	g.Expect(resErr.Requested).To(gomega.Equal(float64(128)))
+	g.Expect(resErr.Min).To(gomega.Equal(0.125))
+	g.Expect(resErr.Max).To(gomega.Equal(float64(64)))
+
+	err = parseResourcesError(resourcesStatus(codes.ResourceExhausted, "invalid resources",
+		"resources.gpu_config.count", "must be at least 1 and at most 2"), options)
+	g.Expect(errors.As(err, &resErr)).To(gomega.BeTrue())
+	g.Expect(resErr.Field).To(gomega.Equal("GPU"))
+	g.Expect(resErr.Requested).To(gomega.Equal(float64(4)))
+	g.Expect(resErr.Min).To(gomega.Equal(float64(1)))
+	g.Expect(resErr.Max).To(gomega.Equal(float64(2)))
+
+	// Without details, a message naming a single resource is classified.
+	err = parseResourcesError(status.Error(codes.InvalidArgument, "Ephemeral disk must be between 512 and 3145728 MiB"), options)
+	g.Expect(errors.As(err, &resErr)).To(gomega.BeTrue())
+	g.Expect(resErr.Field).To(gomega.Equal("EphemeralDiskMB"))
+	g.Expect(resErr.Requested).To(gomega.Equal(float64(1 << 30)))
+	g.Expect(resErr.Max).To(gomega.Equal(float64(3145728)))
+
+	// Ambiguous and unrelated errors are returned unchanged.
+	original := status.Error(codes.InvalidArgument, "memory exceeds what the requested CPU allows")
+	g.Expect(parseResourcesError(original, options)).To(gomega.BeIdenticalTo(original))
+	original = status.Error(codes.InvalidArgument, "invalid image")
+	g.Expect(parseResourcesError(original, options)).To(gomega.BeIdenticalTo(original))
+	original = status.Error(codes.Unavailable, "memory pressure")
+	g.Expect(parseResourcesError(original, options)).To(gomega.BeIdenticalTo(original))
+}
@@ -0,0 +1,295 @@
+package modal
+
+// Request/response protocols over the stdin and stdout of a command in a
+// Sandbox.
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RPCFraming is how a SandboxRPC delimits messages on the command's stdin and
+// stdout. Messages are JSON objects in both framings.
+type RPCFraming string
+
+const (
+	// FramingJSONLines sends one JSON object per line.
+	FramingJSONLines RPCFraming = "jsonl"
+	// FramingLengthPrefixed sends each JSON object after its length in bytes,
+	// as a 4-byte big-endian integer.
+	FramingLengthPrefixed RPCFraming = "length-prefixed"
+)
+
+// SandboxRPCOptions are options for Sandbox.StartRPC.
+type SandboxRPCOptions struct {
+	ExecOptions            // Options for the command. Stdout is always piped.
+	Framing     RPCFraming // Message framing, defaults to FramingJSONLines.
+	// IdField is the field of request and response objects that correlates a
+	// response with its request. Defaults to "id".
+	IdField string
+	// Timeout is the maximum time Call waits for a response. Defaults to no
+	// timeout.
+	Timeout time.Duration
+	// MaxMessageBytes is the maximum size of a message read from the
+	// command's stdout. A larger message ends the SandboxRPC with an error,
+	// since the output is untrusted. Defaults to 16 MiB.
+	MaxMessageBytes int
+}
+
+// defaultRPCMaxMessageBytes is the default SandboxRPCOptions.MaxMessageBytes.
+const defaultRPCMaxMessageBytes = 16 << 20
+
+// SandboxRPC sends requests to a command running in a Sandbox, such as a tool
+// wrapped in a JSON-RPC-style server, and matches responses to requests by an
+// ID field, so several requests can be in flight at once.
+//
+// It is safe for concurrent use. Output that is not a JSON object, or has no
+// ID of a pending request, is skipped.
+type SandboxRPC struct {
+	cp      *ContainerProcess
+	framing RPCFraming
+	idField string
+	timeout time.Duration
+	maxSize int
+
+	writeMu sync.Mutex // serializes writes to stdin
+
+	mu      sync.Mutex
+	nextId  uint64
+	pending map[string]chan json.RawMessage
+	err     error // set once the output has ended
+	done    chan struct{}
+}
+
+// StartRPC runs command in the Sandbox and returns a SandboxRPC to send it
+// requests. Stderr is ignored unless options.Stderr is set.
+func (sb *Sandbox) StartRPC(command []string, options *SandboxRPCOptions) (*SandboxRPC, error) {
+	if options == nil {
+		options = &SandboxRPCOptions{}
+	}
+	framing := options.Framing
+	if framing == "" {
+		framing = FramingJSONLines
+	}
+	if framing != FramingJSONLines && framing != FramingLengthPrefixed {
+		return nil, InvalidError{fmt.Sprintf("invalid RPC framing: %q", framing)}
+	}
+	opts := options.ExecOptions
+	opts.Stdout = Pipe
+	opts.MaxOutputBytes = 0
+	if opts.Stderr == "" {
+		opts.Stderr = Ignore
+	}
+	cp, err := sb.Exec(command, opts)
+	if err != nil {
+		return nil, err
+	}
+	return newSandboxRPC(cp, framing, options), nil
+}
+
+func newSandboxRPC(cp *ContainerProcess, framing RPCFraming, options *SandboxRPCOptions) *SandboxRPC {
+	r := &SandboxRPC{
+		cp:      cp,
+		framing: framing,
+		idField: options.IdField,
+		timeout: options.Timeout,
+		maxSize: options.MaxMessageBytes,
+		pending: map[string]chan json.RawMessage{},
+		done:    make(chan struct{}),
+	}
+	if r.idField == "" {
+		r.idField = "id"
+	}
+	if r.maxSize <= 0 {
+		r.maxSize = defaultRPCMaxMessageBytes
+	}
+	go r.readResponses()
+	return r
+}
+
+// Process returns the command's ContainerProcess, to read its Stderr or wait
+// for it to exit.
+func (r *SandboxRPC) Process() *ContainerProcess {
+	return r.cp
+}
+
+// Call sends request, which must marshal to a JSON object, and unmarshals the
+// matching response into response. The request is sent with a new ID in the
+// ID field, replacing any that it has.
+func (r *SandboxRPC) Call(request, response any) error {
+	return r.CallContext(context.Background(), request, response)
+}
+
+// CallContext is like Call, but stops waiting for the response when ctx is
+// done.
+func (r *SandboxRPC) CallContext(ctx context.Context, request, response any) error {
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+
+	var fields map[string]json.RawMessage
+	data, err := json.Marshal(request)
+	if err == nil {
+		err = json.Unmarshal(data, &fields)
+	}
+	if err != nil || fields == nil {
+		return InvalidError{fmt.Sprintf("RPC request must be a JSON object, got %T", request)}
+	}
+
+	r.mu.Lock()
+	if r.err != nil {
+		r.mu.Unlock()
+		return r.err
+	}
+	r.nextId++
+	id := strconv.FormatUint(r.nextId, 10)
+	ch := make(chan json.RawMessage, 1)
+	r.pending[id] = ch
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		delete(r.pending, id)
+		r.mu.Unlock()
+	}()
+
+	fields[r.idField] = json.RawMessage(id)
+	if data, err = json.Marshal(fields); err != nil {
+		return err
+	}
+	if err := r.write(data); err != nil {
+		return err
+	}
+
+	select {
+	case resp := <-ch:
+		if response == nil {
+			return nil
+		}
+		return json.Unmarshal(resp, response)
+	case <-r.done:
+		return r.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// write sends a message in the framing.
+func (r *SandboxRPC) write(data []byte) error {
+	if r.framing == FramingLengthPrefixed {
+		data = append(binary.BigEndian.AppendUint32(nil, uint32(len(data))), data...)
+	} else {
+		data = append(data, '\n')
+	}
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	_, err := r.cp.Stdin.Write(data)
+	return err
+}
+
+// readResponses delivers responses to pending calls until the output ends.
+func (r *SandboxRPC) readResponses() {
+	stdout := bufio.NewReader(r.cp.Stdout)
+	var err error
+	for {
+		var msg []byte
+		if msg, err = r.readMessage(stdout); err != nil {
+			break
+		}
+		var fields map[string]json.RawMessage
+		if json.Unmarshal(msg, &fields) != nil {
+			continue
+		}
+		id := string(fields[r.idField])
+		if unquoted, err := strconv.Unquote(id); err == nil {
+			id = unquoted // tolerate IDs echoed as strings
+		}
+		r.mu.Lock()
+		if ch, ok := r.pending[id]; ok {
+			ch <- msg
+			delete(r.pending, id)
+		}
+		r.mu.Unlock()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var tooLarge rpcMessageTooLargeError
+	if err == io.EOF {
+		r.err = InvalidError{"sandbox RPC command exited"}
+	} else if errors.As(err, &tooLarge) {
+		r.err = InvalidError{tooLarge.Error()}
+	} else {
+		r.err = fmt.Errorf("error reading sandbox RPC output: %w", err)
+	}
+	close(r.done)
+}
+
+// rpcMessageTooLargeError is returned by readMessage for a message larger
+// than the SandboxRPC's maximum size.
+type rpcMessageTooLargeError struct {
+	limit int
+}
+
+func (e rpcMessageTooLargeError) Error() string {
+	return fmt.Sprintf("sandbox RPC message exceeds MaxMessageBytes of %d bytes", e.limit)
+}
+
+// readMessage reads a message in the framing, of at most r.maxSize bytes.
+func (r *SandboxRPC) readMessage(stdout *bufio.Reader) ([]byte, error) {
+	if r.framing == FramingLengthPrefixed {
+		var size [4]byte
+		if _, err := io.ReadFull(stdout, size[:]); err != nil {
+			return nil, err
+		}
+		n := binary.BigEndian.Uint32(size[:])
+		if uint64(n) > uint64(r.maxSize) {
+			return nil, rpcMessageTooLargeError{r.maxSize}
+		}
+		msg := make([]byte, n)
+		if _, err := io.ReadFull(stdout, msg); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		return msg, nil
+	}
+	var line []byte
+	for {
+		chunk, err := stdout.ReadSlice('\n')
+		line = append(line, chunk...)
+		if len(bytes.TrimSuffix(line, []byte("\n"))) > r.maxSize {
+			return nil, rpcMessageTooLargeError{r.maxSize}
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF && len(line) > 0 {
+			err = nil // last line without a newline
+		}
+		return line, err
+	}
+}
+
+// Close closes the command's stdin, and waits for it to exit.
+func (r *SandboxRPC) Close() error {
+	r.writeMu.Lock()
+	err := r.cp.Stdin.Close()
+	r.writeMu.Unlock()
+	if err != nil {
+		return err
+	}
+	_, err = r.cp.Wait()
+	return err
+}
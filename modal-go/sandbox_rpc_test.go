@@ -0,0 +1,128 @@
+package modal
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+)
+
+// rpcServer answers requests of the form {"id": ..., "n": N} with
+// {"id": ..., "double": 2N}, holding each response until the next request
+// arrives, so responses are sent out of order.
+func rpcServer(stdin io.Reader, stdout io.WriteCloser, framing RPCFraming) {
+	defer stdout.Close()
+	in := bufio.NewReader(stdin)
+	var held []byte
+	for {
+		var msg []byte
+		var err error
+		if framing == FramingLengthPrefixed {
+			var size [4]byte
+			if _, err = io.ReadFull(in, size[:]); err == nil {
+				msg = make([]byte, binary.BigEndian.Uint32(size[:]))
+				_, err = io.ReadFull(in, msg)
+			}
+		} else {
+			msg, err = in.ReadBytes('\n')
+		}
+		if err != nil {
+			return
+		}
+		var req struct {
+			Id json.RawMessage `json:"id"`
+			N  int             `json:"n"`
+		}
+		json.Unmarshal(msg, &req)
+		resp, _ := json.Marshal(map[string]any{"id": req.Id, "double": 2 * req.N})
+		resp = frame(resp, framing)
+		if held == nil {
+			held = resp
+			stdout.Write(frame([]byte("not a response"), framing))
+			continue
+		}
+		stdout.Write(resp)
+		stdout.Write(held)
+		held = nil
+	}
+}
+
+func frame(msg []byte, framing RPCFraming) []byte {
+	if framing == FramingLengthPrefixed {
+		return append(binary.BigEndian.AppendUint32(nil, uint32(len(msg))), msg...)
+	}
+	return append(msg, '\n')
+}
+
+func TestSandboxRPC(t *testing.T) {
+	t.Parallel()
+	for _, framing := range []RPCFraming{FramingJSONLines, FramingLengthPrefixed} {
+		t.Run(string(framing), func(t *testing.T) {
+			g := gomega.NewWithT(t)
+			stdinR, stdinW := io.Pipe()
+			stdoutR, stdoutW := io.Pipe()
+			go rpcServer(stdinR, stdoutW, framing)
+
+			cp := &ContainerProcess{Stdin: stdinW, Stdout: stdoutR}
+			r := newSandboxRPC(cp, framing, &SandboxRPCOptions{})
+
+			var wg sync.WaitGroup
+			results := make([]int, 4)
+			errs := make([]error, 4)
+			for i := range results {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					var resp struct {
+						Double int `json:"double"`
+					}
+					errs[i] = r.Call(map[string]int{"n": i}, &resp)
+					results[i] = resp.Double
+				}()
+			}
+			wg.Wait()
+			g.Expect(errs).To(gomega.Equal(make([]error, 4)))
+			g.Expect(results).To(gomega.Equal([]int{0, 2, 4, 6}))
+
+			// An unanswered request times out.
+			r.timeout = 10 * time.Millisecond
+			g.Expect(r.Call(map[string]int{"n": 5}, nil)).To(gomega.MatchError(context.DeadlineExceeded))
+
+			g.Expect(r.Call([]int{1}, nil)).To(gomega.BeAssignableToTypeOf(InvalidError{}))
+
+			stdinW.Close()
+			<-r.done
+			g.Expect(r.Call(map[string]int{"n": 1}, nil)).To(gomega.BeAssignableToTypeOf(InvalidError{}))
+		})
+	}
+}
+
+func TestSandboxRPCMaxMessageBytes(t *testing.T) {
+	t.Parallel()
+	for _, framing := range []RPCFraming{FramingJSONLines, FramingLengthPrefixed} {
+		t.Run(string(framing), func(t *testing.T) {
+			g := gomega.NewWithT(t)
+			stdoutR, stdoutW := io.Pipe()
+			r := newSandboxRPC(&ContainerProcess{Stdout: stdoutR}, framing, &SandboxRPCOptions{MaxMessageBytes: 64})
+			go stdoutW.Write(frame([]byte(`{"id": 1, "data": "`+strings.Repeat("x", 5000)+`"}`), framing))
+
+			<-r.done
+			g.Expect(r.Call(map[string]int{"n": 1}, nil)).To(gomega.MatchError(gomega.ContainSubstring("exceeds MaxMessageBytes of 64 bytes")))
+		})
+	}
+
+	// A length prefix over the default limit fails before allocating.
+	g := gomega.NewWithT(t)
+	stdoutR, stdoutW := io.Pipe()
+	r := newSandboxRPC(&ContainerProcess{Stdout: stdoutR}, FramingLengthPrefixed, &SandboxRPCOptions{})
+	go stdoutW.Write([]byte{0xff, 0xff, 0xff, 0xff})
+	<-r.done
+	g.Expect(r.err).To(gomega.BeAssignableToTypeOf(InvalidError{}))
+}
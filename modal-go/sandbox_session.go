@@ -0,0 +1,105 @@
+package modal
+
+// Persistent shell sessions for low-latency command round trips in a Sandbox.
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+)
+
+// ExecSession is a long-lived shell in a Sandbox. Each command sent with Run
+// reuses the same process and output stream, so it costs one round trip
+// rather than starting a new exec. Shell state such as the working directory
+// and exported variables persists between commands.
+//
+// An ExecSession is safe for concurrent use, but commands run one at a time.
+type ExecSession struct {
+	cp     *ContainerProcess
+	stdout *bufio.Reader
+	marker string
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// StartExecSession starts a shell session in the sandbox. The image must
+// provide a POSIX sh. Output options in opts are ignored.
+func (sb *Sandbox) StartExecSession(opts ExecOptions) (*ExecSession, error) {
+	opts.Stdout = Pipe
+	opts.Stderr = Ignore
+	opts.MaxOutputBytes = 0
+	cp, err := sb.Exec([]string{"sh"}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	suffix := make([]byte, 16)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+	return &ExecSession{
+		cp:     cp,
+		stdout: bufio.NewReader(cp.Stdout),
+		marker: "__modal_session_" + hex.EncodeToString(suffix),
+	}, nil
+}
+
+// Run executes a shell command in the session, and returns its combined
+// stdout and stderr and its exit code. The command's stdin is /dev/null.
+func (s *ExecSession) Run(command string) (output []byte, exitCode int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, 0, InvalidError{"exec session is closed"}
+	}
+
+	// The marker line, preceded by a newline in case the output does not end
+	// with one, reports the exit code and ends the command's output.
+	script := fmt.Sprintf("{ %s\n} </dev/null 2>&1; printf '\\n%s %%d\\n' $?\n", command, s.marker)
+	if _, err := s.cp.Stdin.Write([]byte(script)); err != nil {
+		return nil, 0, err
+	}
+
+	var buf bytes.Buffer
+	prefix := []byte(s.marker + " ")
+	for {
+		line, err := s.stdout.ReadBytes('\n')
+		if rest, ok := bytes.CutPrefix(line, prefix); ok && err == nil {
+			exitCode, convErr := strconv.Atoi(string(bytes.TrimSpace(rest)))
+			if convErr != nil {
+				return nil, 0, fmt.Errorf("invalid exit code in exec session output: %q", rest)
+			}
+			out := buf.Bytes()
+			return out[:max(len(out)-1, 0)], exitCode, nil // drop the newline before the marker
+		}
+		buf.Write(line)
+		if err == io.EOF {
+			s.closed = true
+			return buf.Bytes(), 0, InvalidError{"exec session shell exited"}
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+}
+
+// Close ends the session's shell and waits for it to exit.
+func (s *ExecSession) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	if err := s.cp.Stdin.Close(); err != nil {
+		return err
+	}
+	_, err := s.cp.Wait()
+	return err
+}
@@ -0,0 +1,131 @@
+package modal
+
+// SSH access to Sandboxes, through a tunnel to an sshd started with exec.
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultSSHPort          = 22
+	defaultSSHTunnelTimeout = 50 * time.Second
+)
+
+// SSHServerOptions are options for Sandbox.SSHServer.
+type SSHServerOptions struct {
+	// Port sshd listens on in the Sandbox, defaults to 22. The Sandbox must
+	// have been created with the port in SandboxOptions.UnencryptedPorts, or
+	// in Ports with Unencrypted set, so that it is reachable over raw TCP.
+	Port int
+	// AuthorizedKeys are the public keys allowed to log in as root, as lines
+	// of an authorized_keys file, such as "ssh-ed25519 AAAA... user@host".
+	// Password logins are disabled.
+	AuthorizedKeys []string
+	Timeout        time.Duration // Maximum time to wait for the tunnel, defaults to 50 seconds.
+}
+
+// SSHServer is an SSH server running in a Sandbox, reachable through a
+// tunnel.
+type SSHServer struct {
+	Host string // Public hostname of the tunnel.
+	Port int    // Public port of the tunnel.
+	User string // User to log in as, always "root".
+
+	cp *ContainerProcess
+}
+
+// Command returns the ssh command line to connect to the server.
+func (s *SSHServer) Command() []string {
+	return []string{"ssh", "-p", strconv.Itoa(s.Port), s.User + "@" + s.Host}
+}
+
+// Process returns the sshd process, to wait for it to exit. It runs until the
+// Sandbox is terminated.
+func (s *SSHServer) Process() *ContainerProcess {
+	return s.cp
+}
+
+// SSHServer starts an SSH server in the Sandbox, and returns the address to
+// connect to with ssh. The Image must provide OpenSSH's sshd and ssh-keygen,
+// for example from the openssh-server package, and a POSIX sh. Host keys are
+// generated if the Image has none, so clients see a new host key for every
+// Sandbox.
+func (sb *Sandbox) SSHServer(options *SSHServerOptions) (*SSHServer, error) {
+	if options == nil {
+		options = &SSHServerOptions{}
+	}
+	port := options.Port
+	if port == 0 {
+		port = defaultSSHPort
+	}
+	timeout := options.Timeout
+	if timeout == 0 {
+		timeout = defaultSSHTunnelTimeout
+	}
+	if len(options.AuthorizedKeys) == 0 {
+		return nil, InvalidError{"at least one authorized key is required to log in"}
+	}
+	for _, key := range options.AuthorizedKeys {
+		if strings.TrimSpace(key) == "" || strings.ContainsAny(key, "\r\n") {
+			return nil, InvalidError{fmt.Sprintf("invalid authorized key: %q", key)}
+		}
+	}
+
+	tunnels, err := sb.Tunnels(timeout)
+	if err != nil {
+		return nil, err
+	}
+	tunnel, ok := tunnels[port]
+	if !ok {
+		return nil, InvalidError{fmt.Sprintf("port %d is not tunneled, create the Sandbox with it in SandboxOptions.UnencryptedPorts", port)}
+	}
+	host, publicPort, err := tunnel.TCPSocket()
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := sb.Run([]string{"sh", "-c", sshSetupScript(port, options.AuthorizedKeys)}, ExecOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if result.ExitCode != 0 {
+		return nil, InvalidError{fmt.Sprintf("failed to set up sshd in Sandbox %s: %s", sb.SandboxId, strings.TrimSpace(string(result.Stderr)))}
+	}
+
+	cp, err := sb.Exec([]string{"sh", "-c", `exec "$(command -v sshd)" -D -e ` + sshdFlags(port)}, ExecOptions{
+		Stdout: Ignore,
+		Stderr: Ignore,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &SSHServer{Host: host, Port: publicPort, User: "root", cp: cp}, nil
+}
+
+// sshdFlags returns the sshd flags that listen on port and only allow public
+// key logins.
+func sshdFlags(port int) string {
+	return fmt.Sprintf("-p %d -o PasswordAuthentication=no -o KbdInteractiveAuthentication=no -o PermitRootLogin=prohibit-password", port)
+}
+
+// sshSetupScript returns a shell script that installs authorizedKeys for root,
+// generates missing host keys, and checks the sshd configuration.
+func sshSetupScript(port int, authorizedKeys []string) string {
+	quoted := make([]string, len(authorizedKeys))
+	for i, key := range authorizedKeys {
+		quoted[i] = shellQuote(key)
+	}
+	return strings.Join([]string{
+		`set -e`,
+		`command -v sshd >/dev/null || { echo "sshd not found, install openssh-server in the Image" >&2; exit 127; }`,
+		`mkdir -p /root/.ssh /run/sshd /var/run/sshd`,
+		`chmod 700 /root/.ssh`,
+		`printf '%s\n' ` + strings.Join(quoted, " ") + ` > /root/.ssh/authorized_keys`,
+		`chmod 600 /root/.ssh/authorized_keys`,
+		`ssh-keygen -A >/dev/null`,
+		`"$(command -v sshd)" -t ` + sshdFlags(port),
+	}, "\n")
+}
@@ -0,0 +1,35 @@
+package modal
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+func TestSSHSetupScript(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	script := sshSetupScript(2222, []string{"ssh-ed25519 AAAA alice@laptop", "ssh-rsa BBBB bob's key"})
+	g.Expect(script).To(gomega.ContainSubstring(`printf '%s\n' 'ssh-ed25519 AAAA alice@laptop' 'ssh-rsa BBBB bob'\''s key' > /root/.ssh/authorized_keys`))
+	g.Expect(script).To(gomega.ContainSubstring(`-t -p 2222 -o PasswordAuthentication=no`))
+	g.Expect(script).To(gomega.HavePrefix("set -e\n"))
+}
+
+func TestSSHServerValidation(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	sb := &Sandbox{SandboxId: "sb-123"}
+
+	for _, options := range []*SSHServerOptions{
+		nil,
+		{AuthorizedKeys: []string{" "}},
+		{AuthorizedKeys: []string{"ssh-ed25519 AAAA\nssh-rsa BBBB"}},
+	} {
+		_, err := sb.SSHServer(options)
+		g.Expect(err).To(gomega.BeAssignableToTypeOf(InvalidError{}), "options: %+v", options)
+	}
+
+	server := &SSHServer{Host: "r1.modal.host", Port: 41234, User: "root"}
+	g.Expect(server.Command()).To(gomega.Equal([]string{"ssh", "-p", "41234", "root@r1.modal.host"}))
+}
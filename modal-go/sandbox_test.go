@@ -0,0 +1,123 @@
+package modal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+	"github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type stdinMessage struct {
+	size  int
+	index uint64
+	eof   bool
+}
+
+func TestStdinWriter(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	var messages []stdinMessage
+	var received bytes.Buffer
+	var failNext bool
+	w := &stdinWriter{send: func(data []byte, index uint64, eof bool) error {
+		if failNext {
+			failNext = false
+			return errors.New("connection reset")
+		}
+		messages = append(messages, stdinMessage{len(data), index, eof})
+		received.Write(data)
+		return nil
+	}}
+
+	// Large writes are split into chunks.
+	n, err := w.Write(make([]byte, 2*stdinChunkSize+10))
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(n).To(gomega.Equal(2*stdinChunkSize + 10))
+	g.Expect(messages).To(gomega.Equal([]stdinMessage{
+		{stdinChunkSize, 1, false},
+		{stdinChunkSize, 2, false},
+		{10, 3, false},
+	}))
+
+	// io.Copy reads in full chunks.
+	messages = nil
+	input := bytes.Repeat([]byte("x"), stdinChunkSize+5)
+	copied, err := io.Copy(w, io.MultiReader(bytes.NewReader(input))) // hide bytes.Reader's WriteTo
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(copied).To(gomega.Equal(int64(len(input))))
+	g.Expect(messages).To(gomega.Equal([]stdinMessage{
+		{stdinChunkSize, 4, false},
+		{5, 5, false},
+	}))
+
+	// A failed message is retried with the same index.
+	messages = nil
+	failNext = true
+	_, err = w.Write([]byte("abc"))
+	g.Expect(err).Should(gomega.HaveOccurred())
+	_, err = w.Write([]byte("abc"))
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(messages).To(gomega.Equal([]stdinMessage{{3, 6, false}}))
+
+	// A failed Close leaves the writer open, and is retried with the same
+	// index. Close then sends EOF once, and later writes fail.
+	messages = nil
+	failNext = true
+	g.Expect(w.Close()).ShouldNot(gomega.Succeed())
+	g.Expect(w.Close()).To(gomega.Succeed())
+	g.Expect(w.Close()).To(gomega.Succeed())
+	g.Expect(messages).To(gomega.Equal([]stdinMessage{{0, 7, true}}))
+	_, err = w.Write([]byte("late"))
+	g.Expect(err).To(gomega.MatchError(io.ErrClosedPipe))
+	g.Expect(received.Len()).To(gomega.Equal(2*stdinChunkSize + 10 + len(input) + 3))
+}
+
+func TestAdoptSandboxNotFound(t *testing.T) {
+	g := gomega.NewWithT(t)
+	var waited []string
+	fake := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if method == "/modal.client.ModalClient/SandboxWait" {
+			waited = append(waited, req.(*pb.SandboxWaitRequest).GetSandboxId())
+			return status.Error(codes.NotFound, "not found")
+		}
+		return nil
+	}
+	useFakeClient(t, fake)
+
+	sb, err := AdoptSandbox(context.Background(), "sb-gone")
+	g.Expect(sb).To(gomega.BeNil())
+	var notFound NotFoundError
+	g.Expect(errors.As(err, &notFound)).To(gomega.BeTrue())
+	g.Expect(waited).To(gomega.Equal([]string{"sb-gone"}))
+}
+
+func TestCleanEnvCommand(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	command, err := cleanEnvCommand([]string{"python", "plugin.py"}, ExecOptions{EnvVars: map[string]string{"B": "2", "A": "1"}})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(command).To(gomega.Equal([]string{
+		"sh", "-c", `exec env -i PATH=` + cleanEnvPath + ` A="$A" B="$B" "$@"`, "sh", "python", "plugin.py",
+	}))
+
+	// PATH may be replaced.
+	command, err = cleanEnvCommand([]string{"ls"}, ExecOptions{EnvVars: map[string]string{"PATH": "/opt/bin"}})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(command[2]).To(gomega.Equal(`exec env -i PATH="$PATH" "$@"`))
+
+	_, err = cleanEnvCommand([]string{"ls"}, ExecOptions{EnvVars: map[string]string{"NOT-SH": "x"}})
+	g.Expect(err).To(gomega.BeAssignableToTypeOf(InvalidError{}))
+	_, err = cleanEnvCommand([]string{"ls"}, ExecOptions{Secrets: []*Secret{{SecretId: "st-123"}}})
+	g.Expect(err).To(gomega.BeAssignableToTypeOf(InvalidError{}))
+	_, err = cleanEnvCommand(nil, ExecOptions{})
+	g.Expect(err).To(gomega.BeAssignableToTypeOf(InvalidError{}))
+}
@@ -0,0 +1,144 @@
+package modal
+
+// Resource usage of Sandboxes, and cost estimates for chargeback reports.
+
+import (
+	"context"
+	"time"
+
+	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+)
+
+// SandboxUsage is the billed resource usage of a Sandbox.
+type SandboxUsage struct {
+	CPUCoreSeconds   float64
+	MemoryGiBSeconds float64
+	GPUSeconds       float64
+	GPUType          string // Such as "A100", empty if the Sandbox has no GPU.
+}
+
+// UsageSummary returns the resources the Sandbox has been billed for so far,
+// or in total once it has finished.
+func (sb *Sandbox) UsageSummary() (SandboxUsage, error) {
+	return sandboxUsage(sb.ctx, sb.SandboxId)
+}
+
+func sandboxUsage(ctx context.Context, sandboxId string) (SandboxUsage, error) {
+	resp, err := client.SandboxGetResourceUsage(ctx, pb.SandboxGetResourceUsageRequest_builder{
+		SandboxId: sandboxId,
+	}.Build())
+	if err != nil {
+		return SandboxUsage{}, err
+	}
+	return SandboxUsage{
+		CPUCoreSeconds:   float64(resp.GetCpuCoreNanosecs()) / 1e9,
+		MemoryGiBSeconds: float64(resp.GetMemGibNanosecs()) / 1e9,
+		GPUSeconds:       float64(resp.GetGpuNanosecs()) / 1e9,
+		GPUType:          resp.GetGpuType(),
+	}, nil
+}
+
+// UsageRates are prices per unit of resource, in any currency, to estimate
+// costs with. Modal does not expose its prices through the API, so they must
+// be taken from the pricing page or the workspace's contract.
+type UsageRates struct {
+	CPUCoreSecond   float64            // Price of one physical core for a second.
+	MemoryGiBSecond float64            // Price of one GiB of memory for a second.
+	GPUSecond       map[string]float64 // Price of one GPU for a second, by GPU type.
+}
+
+// Cost returns the cost of the usage at the given rates. GPU types missing
+// from rates.GPUSecond are not counted.
+func (u SandboxUsage) Cost(rates UsageRates) float64 {
+	return u.CPUCoreSeconds*rates.CPUCoreSecond +
+		u.MemoryGiBSeconds*rates.MemoryGiBSecond +
+		u.GPUSeconds*rates.GPUSecond[u.GPUType]
+}
+
+// scaled returns the usage multiplied by f.
+func (u SandboxUsage) scaled(f float64) SandboxUsage {
+	u.CPUCoreSeconds *= f
+	u.MemoryGiBSeconds *= f
+	u.GPUSeconds *= f
+	return u
+}
+
+// CostEstimate is the aggregate usage and estimated cost of an App's
+// Sandboxes, see App.CostEstimate.
+type CostEstimate struct {
+	Since            time.Time          // Start of the period.
+	Sandboxes        int                // Number of Sandboxes that ran during the period.
+	CPUCoreSeconds   float64            // Total CPU usage.
+	MemoryGiBSeconds float64            // Total memory usage.
+	GPUSeconds       map[string]float64 // Total GPU usage, by GPU type.
+	Cost             float64            // Estimated cost at the given rates.
+	// CostByTag is the estimated cost by tag name and value, to split costs
+	// between the teams or projects that Sandboxes are tagged with.
+	CostByTag map[string]map[string]float64
+}
+
+// add adds the usage of a Sandbox to the estimate.
+func (e *CostEstimate) add(u SandboxUsage, rates UsageRates, tags map[string]string) {
+	e.Sandboxes++
+	e.CPUCoreSeconds += u.CPUCoreSeconds
+	e.MemoryGiBSeconds += u.MemoryGiBSeconds
+	if u.GPUType != "" {
+		e.GPUSeconds[u.GPUType] += u.GPUSeconds
+	}
+	cost := u.Cost(rates)
+	e.Cost += cost
+	for name, value := range tags {
+		if e.CostByTag[name] == nil {
+			e.CostByTag[name] = map[string]float64{}
+		}
+		e.CostByTag[name][value] += cost
+	}
+}
+
+// CostEstimate aggregates the usage of the App's Sandboxes that ran during
+// the last period, running or finished, and estimates their cost at the
+// given rates.
+//
+// Modal reports the usage of a Sandbox over its whole lifetime, so for
+// Sandboxes created before the period, only the share of their lifetime
+// within the period is counted, assuming that usage was spread evenly.
+// Usage of Functions is not included, since Modal only reports it for
+// Sandboxes. The usage of each Sandbox is fetched with a separate request, so
+// this is intended for periodic reports rather than hot paths.
+func (app *App) CostEstimate(period time.Duration, rates UsageRates) (*CostEstimate, error) {
+	now := time.Now()
+	estimate := &CostEstimate{
+		Since:      now.Add(-period),
+		GPUSeconds: map[string]float64{},
+		CostByTag:  map[string]map[string]float64{},
+	}
+	since := float64(estimate.Since.UnixNano()) / 1e9
+	// Sandboxes run for at most maxSandboxTimeout, so the ones that ran
+	// during the period were created at most that long before it.
+	horizon := since - maxSandboxTimeout.Seconds()
+	for info, err := range app.sandboxInfos(true, nil, 0) {
+		if err != nil {
+			return nil, err
+		}
+		createdAt := info.GetCreatedAt()
+		if createdAt < horizon {
+			break // listed newest first
+		}
+		end := info.GetTaskInfo().GetFinishedAt()
+		if end == 0 {
+			end = float64(now.UnixNano()) / 1e9 // still running
+		}
+		if end < since {
+			continue // finished before the period
+		}
+		usage, err := sandboxUsage(app.ctx, info.GetId())
+		if err != nil {
+			return nil, err
+		}
+		if createdAt < since && end > createdAt {
+			usage = usage.scaled((end - since) / (end - createdAt))
+		}
+		estimate.add(usage, rates, tagsFromProto(info.GetTags()))
+	}
+	return estimate, nil
+}
@@ -0,0 +1,84 @@
+package modal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+	"github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestCostEstimate(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	rates := UsageRates{
+		CPUCoreSecond:   0.01,
+		MemoryGiBSecond: 0.001,
+		GPUSecond:       map[string]float64{"A100": 1},
+	}
+	cpu := SandboxUsage{CPUCoreSeconds: 100, MemoryGiBSeconds: 1000}
+	gpu := SandboxUsage{CPUCoreSeconds: 10, MemoryGiBSeconds: 100, GPUSeconds: 5, GPUType: "A100"}
+	unpriced := SandboxUsage{GPUSeconds: 7, GPUType: "H100"}
+	g.Expect(cpu.Cost(rates)).To(gomega.BeNumerically("~", 2))
+	g.Expect(gpu.Cost(rates)).To(gomega.BeNumerically("~", 5.2))
+	g.Expect(unpriced.Cost(rates)).To(gomega.BeZero())
+
+	estimate := &CostEstimate{GPUSeconds: map[string]float64{}, CostByTag: map[string]map[string]float64{}}
+	estimate.add(cpu, rates, map[string]string{"team": "search"})
+	estimate.add(gpu, rates, map[string]string{"team": "ml", "project": "embeddings"})
+	estimate.add(unpriced, rates, nil)
+	g.Expect(estimate.Sandboxes).To(gomega.Equal(3))
+	g.Expect(estimate.CPUCoreSeconds).To(gomega.BeNumerically("~", 110))
+	g.Expect(estimate.MemoryGiBSeconds).To(gomega.BeNumerically("~", 1100))
+	g.Expect(estimate.GPUSeconds).To(gomega.Equal(map[string]float64{"A100": 5, "H100": 7}))
+	g.Expect(estimate.Cost).To(gomega.BeNumerically("~", 7.2))
+	g.Expect(estimate.CostByTag["team"]["search"]).To(gomega.BeNumerically("~", 2))
+	g.Expect(estimate.CostByTag["team"]["ml"]).To(gomega.BeNumerically("~", 5.2))
+	g.Expect(estimate.CostByTag["project"]).To(gomega.HaveLen(1))
+}
+
+func TestAppCostEstimatePeriod(t *testing.T) {
+	g := gomega.NewWithT(t)
+	now := float64(time.Now().Unix())
+	info := func(id string, createdAt, finishedAt float64) *pb.SandboxInfo {
+		return pb.SandboxInfo_builder{
+			Id:        id,
+			CreatedAt: now - createdAt,
+			TaskInfo:  pb.TaskInfo_builder{FinishedAt: finishedAt}.Build(),
+		}.Build()
+	}
+	infos := []*pb.SandboxInfo{
+		info("sb-new", 600, 0),                   // running, created in the period
+		info("sb-running", 5400, 0),              // running since before the period
+		info("sb-overlap", 7200, now-1800),       // finished in the period
+		info("sb-before", 10000, now-5000),       // finished before the period
+		info("sb-ancient", 3*86400, now-86400*2), // beyond the longest Sandbox lifetime
+	}
+	var usages []string
+	fake := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		switch method {
+		case "/modal.client.ModalClient/SandboxList":
+			if req.(*pb.SandboxListRequest).GetBeforeTimestamp() == 0 {
+				proto.Merge(reply.(proto.Message), pb.SandboxListResponse_builder{Sandboxes: infos}.Build())
+			}
+		case "/modal.client.ModalClient/SandboxGetResourceUsage":
+			usages = append(usages, req.(*pb.SandboxGetResourceUsageRequest).GetSandboxId())
+			proto.Merge(reply.(proto.Message), pb.SandboxGetResourceUsageResponse_builder{CpuCoreNanosecs: 90e9}.Build())
+		}
+		return nil
+	}
+	useFakeClient(t, fake)
+	app := newApp(context.Background(), "ap-123")
+
+	// Sandboxes created before the period count for the share of their
+	// lifetime within it: 2/3 and 1/3.
+	estimate, err := app.CostEstimate(time.Hour, UsageRates{CPUCoreSecond: 1})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(usages).To(gomega.Equal([]string{"sb-new", "sb-running", "sb-overlap"}))
+	g.Expect(estimate.Sandboxes).To(gomega.Equal(3))
+	g.Expect(estimate.CPUCoreSeconds).To(gomega.BeNumerically("~", 90+60+30, 0.5))
+}
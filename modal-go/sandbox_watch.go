@@ -0,0 +1,193 @@
+package modal
+
+// Following files and watching for filesystem changes in a Sandbox.
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+
+	"github.com/djherbis/buffer"
+	"github.com/djherbis/nio/v3"
+	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+)
+
+// FileWatchEventType is the kind of change reported by Sandbox.Watch.
+type FileWatchEventType string
+
+// Kinds of filesystem events.
+const (
+	FileWatchAccess  FileWatchEventType = "Access"
+	FileWatchCreate  FileWatchEventType = "Create"
+	FileWatchModify  FileWatchEventType = "Modify"
+	FileWatchRemove  FileWatchEventType = "Remove"
+	FileWatchUnknown FileWatchEventType = "Unknown"
+)
+
+// FileWatchEvent is a change to one or more paths in a Sandbox filesystem.
+type FileWatchEvent struct {
+	Type  FileWatchEventType `json:"event_type"`
+	Paths []string           `json:"paths"`
+}
+
+// Watch returns an iterator over filesystem events for path in the sandbox,
+// which may be a file or a directory. If recursive is true, events for all
+// descendants of a directory are included.
+//
+// The watch is started when iteration begins, and stopped when the loop
+// exits. An error is yielded if the watch could not be started or fails.
+func (sb *Sandbox) Watch(path string, recursive bool) iter.Seq2[FileWatchEvent, error] {
+	return func(yield func(FileWatchEvent, error) bool) {
+		ctx, cancel := context.WithCancel(sb.ctx)
+		defer cancel()
+
+		events, err := sb.startWatch(ctx, path, recursive)
+		if err != nil {
+			yield(FileWatchEvent{}, err)
+			return
+		}
+		for event, err := range events {
+			if !yield(event, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// startWatch starts watching path for filesystem events, and returns an
+// iterator over them. The watch stops when ctx is done.
+func (sb *Sandbox) startWatch(ctx context.Context, path string, recursive bool) (iter.Seq2[FileWatchEvent, error], error) {
+	if err := sb.ensureTaskId(); err != nil {
+		return nil, err
+	}
+	resp, err := client.ContainerFilesystemExec(ctx, pb.ContainerFilesystemExecRequest_builder{
+		FileWatchRequest: pb.ContainerFileWatchRequest_builder{
+			Path:      path,
+			Recursive: recursive,
+		}.Build(),
+		TaskId: sb.taskId,
+	}.Build())
+	if err != nil {
+		return nil, err
+	}
+
+	return func(yield func(FileWatchEvent, error) bool) {
+		// Events are newline-delimited JSON, and may be split across chunks.
+		var pending []byte
+		for chunk, err := range filesystemExecOutput(ctx, resp.GetExecId()) {
+			if err != nil {
+				yield(FileWatchEvent{}, err)
+				return
+			}
+			pending = append(pending, chunk...)
+			for {
+				i := bytes.IndexByte(pending, '\n')
+				if i < 0 {
+					break
+				}
+				line := bytes.TrimSpace(pending[:i])
+				pending = pending[i+1:]
+				if len(line) == 0 {
+					continue
+				}
+				var event FileWatchEvent
+				if err := json.Unmarshal(line, &event); err != nil {
+					yield(FileWatchEvent{}, fmt.Errorf("invalid file watch event: %w", err))
+					return
+				}
+				if !yield(event, nil) {
+					return
+				}
+			}
+		}
+	}, nil
+}
+
+// TailFile returns a reader over the contents of a file in the sandbox.
+//
+// If follow is true, the reader then keeps returning bytes as they are
+// appended to the file, like `tail -f`, until the file is removed or the
+// reader is closed. Otherwise it returns io.EOF at the current end of file.
+func (sb *Sandbox) TailFile(path string, follow bool) (io.ReadCloser, error) {
+	ctx, cancel := context.WithCancel(sb.ctx)
+
+	// Start watching before reading, so that no appended bytes are missed.
+	var events iter.Seq2[FileWatchEvent, error]
+	if follow {
+		var err error
+		events, err = sb.startWatch(ctx, path, false)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+	f, err := sb.Open(path, "r")
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	pr, pw := nio.Pipe(buffer.New(64 * 1024))
+	go func() {
+		defer f.Close()
+		defer pw.Close()
+
+		buf := make([]byte, 64*1024)
+		drain := func() error {
+			for {
+				n, err := f.Read(buf)
+				if n > 0 {
+					if _, werr := pw.Write(buf[:n]); werr != nil {
+						return werr
+					}
+				}
+				if err == io.EOF {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := drain(); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		if !follow {
+			return
+		}
+		for event, err := range events {
+			if err != nil {
+				if ctx.Err() == nil {
+					pw.CloseWithError(err)
+				}
+				return
+			}
+			switch event.Type {
+			case FileWatchModify:
+				if err := drain(); err != nil {
+					pw.CloseWithError(err)
+					return
+				}
+			case FileWatchRemove:
+				return
+			}
+		}
+	}()
+	return &tailReader{PipeReader: pr, cancel: cancel}, nil
+}
+
+// tailReader stops following the file when closed.
+type tailReader struct {
+	*nio.PipeReader
+	cancel context.CancelFunc
+}
+
+func (r *tailReader) Close() error {
+	r.cancel()
+	return r.PipeReader.Close()
+}
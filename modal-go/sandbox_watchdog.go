@@ -0,0 +1,254 @@
+package modal
+
+// Client-side enforcement of resource limits on Sandboxes.
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultWatchdogInterval = 10 * time.Second
+
+// cgroupUsageFiles are the files holding the memory usage and CPU time of
+// the Sandbox's cgroup, for cgroup v2 and v1. They are read with catPath,
+// with no shell involved.
+var cgroupUsageFiles = [][]string{
+	{"/sys/fs/cgroup/memory.current", "/sys/fs/cgroup/cpu.stat"},
+	{"/sys/fs/cgroup/memory/memory.usage_in_bytes", "/sys/fs/cgroup/cpuacct/cpuacct.usage"},
+}
+
+const catPath = "/bin/cat"
+
+// WatchdogPolicy defines limits on a Sandbox that are enforced by the client,
+// see Sandbox.StartWatchdog. Zero limits are not enforced.
+type WatchdogPolicy struct {
+	// MaxRSS is the maximum memory in use by the Sandbox, in bytes, as
+	// counted by its cgroup. This includes the page cache, as the kernel's
+	// OOM killer counts it.
+	MaxRSS int64
+	// MaxCPUSeconds is the maximum CPU time used by the Sandbox's processes.
+	MaxCPUSeconds float64
+	// MaxLifetime is the maximum time the Sandbox runs after the watchdog is
+	// started.
+	MaxLifetime time.Duration
+	// Interval is the time between checks of MaxRSS and MaxCPUSeconds,
+	// defaults to 10 seconds.
+	Interval time.Duration
+	// OnViolation, if set, is called after a Sandbox violating the policy is
+	// terminated.
+	OnViolation func(WatchdogViolation)
+}
+
+// WatchdogReason is the limit of a WatchdogPolicy that a Sandbox violated.
+type WatchdogReason string
+
+// Limits of a WatchdogPolicy.
+const (
+	WatchdogMaxRSS        WatchdogReason = "max-rss"
+	WatchdogMaxCPUSeconds WatchdogReason = "max-cpu-seconds"
+	WatchdogMaxLifetime   WatchdogReason = "max-lifetime"
+)
+
+// WatchdogViolation describes a Sandbox terminated by a watchdog.
+type WatchdogViolation struct {
+	SandboxId string
+	Reason    WatchdogReason
+	Limit     float64 // The limit, in bytes or seconds.
+	Value     float64 // The usage that exceeded it, in the same unit.
+	Time      time.Time
+	Err       error // Error terminating the Sandbox, if any.
+}
+
+// Watchdog monitors a Sandbox and terminates it if it violates a
+// WatchdogPolicy.
+type Watchdog struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu        sync.Mutex
+	violation *WatchdogViolation
+	err       error // of the latest usage reading
+}
+
+// StartWatchdog starts monitoring the Sandbox in the background, and
+// terminates it when it exceeds a limit of policy. This is useful when
+// running untrusted code, to stop Sandboxes that use more than their share of
+// resources before Modal's own limits kill them.
+//
+// Memory and CPU usage are read from the Sandbox's cgroup by running /bin/cat
+// in it every policy.Interval, so a Sandbox may exceed a limit for up to an
+// interval before it is terminated. If the first reading fails, such as when
+// the Image has no /bin/cat or /sys/fs/cgroup is not available, StartWatchdog
+// returns its error; later failures are reported by Err. The readings come
+// from inside the Sandbox, so code running in it can fake them, for example
+// by replacing /bin/cat: MaxRSS and MaxCPUSeconds stop runaway code, but not
+// code that evades them on purpose. MaxLifetime is enforced by the client
+// alone. The watchdog stops when the Sandbox finishes, when Stop is called,
+// or when the Sandbox's context is done.
+func (sb *Sandbox) StartWatchdog(policy WatchdogPolicy) (*Watchdog, error) {
+	if policy.MaxRSS < 0 || policy.MaxCPUSeconds < 0 || policy.MaxLifetime < 0 {
+		return nil, InvalidError{"watchdog limits must not be negative"}
+	}
+	if policy.MaxRSS == 0 && policy.MaxCPUSeconds == 0 && policy.MaxLifetime == 0 {
+		return nil, InvalidError{"watchdog policy has no limits"}
+	}
+	if policy.Interval <= 0 {
+		policy.Interval = defaultWatchdogInterval
+	}
+
+	var files []string
+	if policy.MaxRSS > 0 || policy.MaxCPUSeconds > 0 {
+		var err error
+		for _, files = range cgroupUsageFiles {
+			if _, _, err = cgroupUsage(sb, files); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the Sandbox's resource usage: %w", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(sb.ctx)
+	w := &Watchdog{cancel: cancel, done: make(chan struct{})}
+	go w.run(ctx, sb, policy, files)
+	return w, nil
+}
+
+// Stop stops the watchdog without terminating the Sandbox. A check in
+// progress is finished first, see Done.
+func (w *Watchdog) Stop() {
+	w.cancel()
+}
+
+// Done returns a channel that is closed when the watchdog stops.
+func (w *Watchdog) Done() <-chan struct{} {
+	return w.done
+}
+
+// Violation returns the violation that the watchdog terminated the Sandbox
+// for, or nil if it has not terminated it.
+func (w *Watchdog) Violation() *WatchdogViolation {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.violation
+}
+
+// Err returns the error of the latest reading of the Sandbox's memory and CPU
+// usage, or nil if it succeeded. While readings fail, MaxRSS and
+// MaxCPUSeconds are not enforced.
+func (w *Watchdog) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// run checks the policy until the Sandbox violates it or the watchdog stops,
+// reading usage from the cgroup files.
+func (w *Watchdog) run(ctx context.Context, sb *Sandbox, policy WatchdogPolicy, files []string) {
+	defer close(w.done)
+	defer w.cancel()
+
+	var lifetime <-chan time.Time
+	if policy.MaxLifetime > 0 {
+		timer := time.NewTimer(policy.MaxLifetime)
+		defer timer.Stop()
+		lifetime = timer.C
+	}
+	ticker := time.NewTicker(policy.Interval)
+	defer ticker.Stop()
+
+	for {
+		var violation *WatchdogViolation
+		select {
+		case <-ctx.Done():
+			return
+		case <-lifetime:
+			violation = &WatchdogViolation{Reason: WatchdogMaxLifetime, Limit: policy.MaxLifetime.Seconds(), Value: policy.MaxLifetime.Seconds()}
+		case <-ticker.C:
+		}
+		if exitCode, err := sb.Poll(); err == nil && exitCode != nil {
+			return // Sandbox has exited, nothing left to enforce.
+		}
+		if violation == nil && files != nil {
+			rss, cpuSeconds, err := cgroupUsage(sb, files)
+			w.mu.Lock()
+			w.err = err
+			w.mu.Unlock()
+			if err != nil {
+				continue // the exec may fail transiently, try again next interval
+			}
+			violation = policy.check(rss, cpuSeconds)
+		}
+		if violation == nil {
+			continue
+		}
+
+		violation.SandboxId = sb.SandboxId
+		violation.Time = time.Now()
+		violation.Err = sb.Terminate()
+		w.mu.Lock()
+		w.violation = violation
+		w.mu.Unlock()
+		if policy.OnViolation != nil {
+			policy.OnViolation(*violation)
+		}
+		return
+	}
+}
+
+// check returns the violation of the policy's usage limits, if any.
+func (p WatchdogPolicy) check(rss int64, cpuSeconds float64) *WatchdogViolation {
+	if p.MaxRSS > 0 && rss > p.MaxRSS {
+		return &WatchdogViolation{Reason: WatchdogMaxRSS, Limit: float64(p.MaxRSS), Value: float64(rss)}
+	}
+	if p.MaxCPUSeconds > 0 && cpuSeconds > p.MaxCPUSeconds {
+		return &WatchdogViolation{Reason: WatchdogMaxCPUSeconds, Limit: p.MaxCPUSeconds, Value: cpuSeconds}
+	}
+	return nil
+}
+
+// cgroupUsage returns the memory usage in bytes and the CPU time in seconds
+// of the Sandbox, read from files, one of cgroupUsageFiles.
+func cgroupUsage(sb *Sandbox, files []string) (int64, float64, error) {
+	result, err := sb.Run(append([]string{catPath}, files...), ExecOptions{Timeout: defaultWatchdogInterval})
+	if err != nil {
+		return 0, 0, err
+	}
+	if result.ExitCode != 0 {
+		return 0, 0, fmt.Errorf("reading cgroup usage failed: %s", strings.TrimSpace(string(result.Stderr)))
+	}
+	return parseCgroupUsage(string(result.Stdout))
+}
+
+// parseCgroupUsage parses the contents of cgroupUsageFiles: the memory usage
+// in bytes, followed by either the usage_usec line of a cgroup v2 cpu.stat,
+// or the CPU time in nanoseconds of cgroup v1.
+func parseCgroupUsage(out string) (int64, float64, error) {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) < 2 {
+		return 0, 0, fmt.Errorf("unexpected cgroup usage: %q", out)
+	}
+	rss, err := strconv.ParseInt(strings.TrimSpace(lines[0]), 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("parsing memory usage: %w", err)
+	}
+	for _, line := range lines[1:] {
+		if usec, ok := strings.CutPrefix(line, "usage_usec "); ok {
+			n, err := strconv.ParseInt(strings.TrimSpace(usec), 10, 64)
+			if err != nil {
+				return 0, 0, fmt.Errorf("parsing CPU usage: %w", err)
+			}
+			return rss, float64(n) / 1e6, nil
+		}
+	}
+	nsec, err := strconv.ParseInt(strings.TrimSpace(lines[1]), 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("parsing CPU usage: %w", err)
+	}
+	return rss, float64(nsec) / 1e9, nil
+}
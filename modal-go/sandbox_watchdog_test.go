@@ -0,0 +1,108 @@
+package modal
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+	"github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestParseCgroupUsage(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	rss, cpuSeconds, err := parseCgroupUsage("1048576\nusage_usec 2500000\nuser_usec 2000000\nsystem_usec 500000\n")
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(rss).To(gomega.Equal(int64(1048576)))
+	g.Expect(cpuSeconds).To(gomega.Equal(2.5))
+
+	rss, cpuSeconds, err = parseCgroupUsage("4096\n1500000000\n")
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(rss).To(gomega.Equal(int64(4096)))
+	g.Expect(cpuSeconds).To(gomega.Equal(1.5))
+
+	_, _, err = parseCgroupUsage("max\n")
+	g.Expect(err).Should(gomega.HaveOccurred())
+}
+
+func TestWatchdogPolicyCheck(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	policy := WatchdogPolicy{MaxRSS: 1 << 20, MaxCPUSeconds: 10}
+	g.Expect(policy.check(1<<20, 10)).To(gomega.BeNil())
+	g.Expect(policy.check(1<<21, 20)).To(gomega.Equal(&WatchdogViolation{Reason: WatchdogMaxRSS, Limit: 1 << 20, Value: 1 << 21}))
+	g.Expect(policy.check(1<<10, 20)).To(gomega.Equal(&WatchdogViolation{Reason: WatchdogMaxCPUSeconds, Limit: 10, Value: 20}))
+	g.Expect(WatchdogPolicy{MaxLifetime: time.Minute}.check(1<<30, 1000)).To(gomega.BeNil())
+}
+
+func TestWatchdogMaxLifetime(t *testing.T) {
+	g := gomega.NewWithT(t)
+	var terminated atomic.Bool
+	fake := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if method == "/modal.client.ModalClient/SandboxTerminate" {
+			terminated.Store(true)
+		}
+		return nil // SandboxWait without a result: still running
+	}
+	useFakeClient(t, fake)
+	sb := newSandboxWithStdio(context.Background(), "sb-123", Ignore, Ignore)
+
+	_, err := sb.StartWatchdog(WatchdogPolicy{})
+	g.Expect(err).To(gomega.BeAssignableToTypeOf(InvalidError{}))
+
+	violations := make(chan WatchdogViolation, 1)
+	w, err := sb.StartWatchdog(WatchdogPolicy{
+		MaxLifetime: 50 * time.Millisecond,
+		Interval:    10 * time.Millisecond,
+		OnViolation: func(v WatchdogViolation) { violations <- v },
+	})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Eventually(w.Done()).Should(gomega.BeClosed())
+	g.Expect(terminated.Load()).To(gomega.BeTrue())
+	g.Expect(w.Violation().Reason).To(gomega.Equal(WatchdogMaxLifetime))
+	g.Expect(w.Violation().SandboxId).To(gomega.Equal("sb-123"))
+	g.Expect(w.Violation().Err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(<-violations).To(gomega.Equal(*w.Violation()))
+
+	// A stopped watchdog does not terminate the Sandbox.
+	terminated.Store(false)
+	w, err = sb.StartWatchdog(WatchdogPolicy{MaxLifetime: 50 * time.Millisecond})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	w.Stop()
+	g.Eventually(w.Done()).Should(gomega.BeClosed())
+	g.Expect(w.Violation()).To(gomega.BeNil())
+	g.Expect(terminated.Load()).To(gomega.BeFalse())
+}
+
+func TestWatchdogUnreadableUsage(t *testing.T) {
+	g := gomega.NewWithT(t)
+	var commands [][]string
+	fake := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		switch method {
+		case "/modal.client.ModalClient/SandboxGetTaskId":
+			proto.Merge(reply.(proto.Message), pb.SandboxGetTaskIdResponse_builder{TaskId: proto.String("ta-123")}.Build())
+		case "/modal.client.ModalClient/ContainerExec":
+			commands = append(commands, req.(*pb.ContainerExecRequest).GetCommand())
+			return status.Error(codes.FailedPrecondition, "no such file")
+		}
+		return nil
+	}
+	useFakeClient(t, fake)
+	sb := newSandboxWithStdio(context.Background(), "sb-123", Ignore, Ignore)
+
+	// Usage limits that cannot be read fail the start of the watchdog.
+	_, err := sb.StartWatchdog(WatchdogPolicy{MaxRSS: 1 << 30})
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("no such file")))
+	g.Expect(commands).To(gomega.Equal([][]string{
+		{"/bin/cat", "/sys/fs/cgroup/memory.current", "/sys/fs/cgroup/cpu.stat"},
+		{"/bin/cat", "/sys/fs/cgroup/memory/memory.usage_in_bytes", "/sys/fs/cgroup/cpuacct/cpuacct.usage"},
+	}))
+}
@@ -0,0 +1,336 @@
+// Package sandboxpool keeps a pool of warm Modal Sandboxes that can be handed
+// out to callers on demand.
+//
+// Creating a Sandbox takes a few seconds, which is often too slow for
+// interactive workloads like code execution. A Pool starts Sandboxes ahead of
+// time, lends them out with Acquire, takes them back with Release, replaces
+// the ones that exit or fail health checks, and grows and shrinks between
+// Options.Min and Options.Max.
+package sandboxpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/modal-labs/libmodal/modal-go"
+)
+
+// ErrClosed is returned by Acquire after the Pool has been closed.
+var ErrClosed = errors.New("sandbox pool is closed")
+
+const (
+	defaultIdleTimeout   = 5 * time.Minute
+	defaultCheckInterval = 30 * time.Second
+)
+
+// Options are options for creating a Pool.
+type Options struct {
+	Min int // Number of Sandboxes kept warm, even when idle.
+	Max int // Maximum number of Sandboxes, idle or acquired. Must be at least 1.
+
+	// SandboxOptions are used for every Sandbox created by the Pool.
+	SandboxOptions *modal.SandboxOptions
+
+	// IdleTimeout is how long a Sandbox beyond Min may stay idle before it is
+	// terminated. Defaults to 5 minutes.
+	IdleTimeout time.Duration
+
+	// CheckInterval is how often idle Sandboxes are health-checked and the pool
+	// is trimmed or refilled. Defaults to 30 seconds.
+	CheckInterval time.Duration
+
+	// HealthCheck, if set, is called on idle Sandboxes before they are handed
+	// out and during periodic checks. Sandboxes for which it returns an error
+	// are terminated and replaced. Sandboxes that have exited are always
+	// considered unhealthy.
+	HealthCheck func(sb *modal.Sandbox) error
+}
+
+type idleSandbox struct {
+	sb    *modal.Sandbox
+	since time.Time
+}
+
+// Pool is a set of warm Sandboxes. It is safe for concurrent use.
+type Pool struct {
+	app     *modal.App
+	image   *modal.Image
+	options Options
+
+	mu      sync.Mutex
+	idle    []idleSandbox
+	lent    map[*modal.Sandbox]bool // Sandboxes returned by Acquire and not yet released.
+	size    int                     // Sandboxes owned by the pool: idle, acquired, or starting.
+	changed chan struct{}           // closed and replaced whenever idle or size changes
+	closed  bool
+
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+// New creates a Pool of Sandboxes running image in app, and starts options.Min
+// Sandboxes before returning.
+func New(app *modal.App, image *modal.Image, options *Options) (*Pool, error) {
+	if options == nil {
+		options = &Options{}
+	}
+	if options.Max < 1 {
+		return nil, modal.InvalidError{Exception: "sandbox pool Max must be at least 1"}
+	}
+	if options.Min < 0 || options.Min > options.Max {
+		return nil, modal.InvalidError{Exception: "sandbox pool Min must be between 0 and Max"}
+	}
+
+	p := &Pool{
+		app:     app,
+		image:   image,
+		options: *options,
+		lent:    map[*modal.Sandbox]bool{},
+		changed: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if p.options.IdleTimeout == 0 {
+		p.options.IdleTimeout = defaultIdleTimeout
+	}
+	if p.options.CheckInterval == 0 {
+		p.options.CheckInterval = defaultCheckInterval
+	}
+
+	if err := p.fill(); err != nil {
+		p.Close()
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.stop = cancel
+	go p.maintain(ctx)
+	return p, nil
+}
+
+// Acquire returns an idle Sandbox from the pool, starting a new one if none is
+// idle and the pool is below Max. Otherwise it blocks until a Sandbox is
+// released or ctx is done.
+func (p *Pool) Acquire(ctx context.Context) (*modal.Sandbox, error) {
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return nil, ErrClosed
+		}
+		if n := len(p.idle); n > 0 {
+			// Most recently released first, so that extra Sandboxes go idle and get trimmed.
+			sb := p.idle[n-1].sb
+			p.idle = p.idle[:n-1]
+			p.mu.Unlock()
+			if p.healthy(sb) {
+				return p.lend(sb), nil
+			}
+			p.discard(sb)
+			continue
+		}
+		if p.size < p.options.Max {
+			p.size++
+			p.mu.Unlock()
+			sb, err := p.create()
+			if err != nil {
+				return nil, err
+			}
+			return p.lend(sb), nil
+		}
+		changed := p.changed
+		p.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// Release returns a Sandbox obtained from Acquire to the pool. Sandboxes that
+// have exited or fail the health check are terminated instead. It returns an
+// InvalidError, and leaves the Sandbox alone, if the Sandbox was not acquired
+// from the pool or was already released.
+func (p *Pool) Release(sb *modal.Sandbox) error {
+	p.mu.Lock()
+	if !p.lent[sb] {
+		p.mu.Unlock()
+		return modal.InvalidError{Exception: fmt.Sprintf("Sandbox %s was not acquired from the pool", sb.SandboxId)}
+	}
+	delete(p.lent, sb)
+	p.mu.Unlock()
+	p.put(sb)
+	return nil
+}
+
+// lend records that sb was handed out by Acquire.
+func (p *Pool) lend(sb *modal.Sandbox) *modal.Sandbox {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lent[sb] = true
+	return sb
+}
+
+// put makes a Sandbox owned by the pool idle, or terminates it if it is
+// unhealthy or the pool is closed.
+func (p *Pool) put(sb *modal.Sandbox) {
+	p.mu.Lock()
+	closed := p.closed
+	p.mu.Unlock()
+	if closed || !p.healthy(sb) {
+		p.discard(sb)
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.idle = append(p.idle, idleSandbox{sb: sb, since: time.Now()})
+	p.notifyLocked()
+}
+
+// Close terminates all idle Sandboxes and stops maintaining the pool.
+// Sandboxes still acquired are terminated when they are released.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	p.notifyLocked()
+	p.mu.Unlock()
+
+	if p.stop != nil {
+		p.stop()
+		<-p.done
+	}
+
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.mu.Unlock()
+
+	var errs []error
+	for _, entry := range idle {
+		errs = append(errs, entry.sb.Terminate())
+	}
+	return errors.Join(errs...)
+}
+
+// create starts a new Sandbox for a slot already counted in size.
+func (p *Pool) create() (*modal.Sandbox, error) {
+	sb, err := p.app.CreateSandbox(p.image, p.options.SandboxOptions)
+	if err != nil {
+		p.mu.Lock()
+		p.size--
+		p.notifyLocked()
+		p.mu.Unlock()
+		return nil, err
+	}
+	return sb, nil
+}
+
+// fill starts Sandboxes concurrently until the pool holds options.Min.
+func (p *Pool) fill() error {
+	p.mu.Lock()
+	missing := p.options.Min - p.size
+	if p.closed || missing <= 0 {
+		p.mu.Unlock()
+		return nil
+	}
+	p.size += missing
+	p.mu.Unlock()
+
+	var wg sync.WaitGroup
+	errs := make([]error, missing)
+	for i := range missing {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sb, err := p.create()
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			p.put(sb)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// maintain periodically replaces unhealthy idle Sandboxes, terminates the ones
+// idle for longer than IdleTimeout beyond Min, and refills the pool up to Min.
+func (p *Pool) maintain(ctx context.Context) {
+	defer close(p.done)
+	t := time.NewTicker(p.options.CheckInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		p.mu.Lock()
+		idle := p.idle
+		p.idle = nil
+		p.mu.Unlock()
+
+		var keep []idleSandbox
+		for _, entry := range idle {
+			if p.healthy(entry.sb) {
+				keep = append(keep, entry)
+			} else {
+				p.discard(entry.sb)
+			}
+		}
+
+		p.mu.Lock()
+		// Sandboxes released during the check are newer, keep them at the end.
+		p.idle = append(keep, p.idle...)
+		var expired []*modal.Sandbox
+		for len(p.idle) > 0 && p.size > p.options.Min && time.Since(p.idle[0].since) > p.options.IdleTimeout {
+			expired = append(expired, p.idle[0].sb)
+			p.idle = p.idle[1:]
+			p.size--
+		}
+		p.notifyLocked()
+		p.mu.Unlock()
+
+		for _, sb := range expired {
+			sb.Terminate()
+		}
+		p.fill() // errors are retried on the next check
+	}
+}
+
+func (p *Pool) healthy(sb *modal.Sandbox) bool {
+	exitCode, err := sb.Poll()
+	if err != nil || exitCode != nil {
+		return false
+	}
+	if p.options.HealthCheck != nil {
+		return p.options.HealthCheck(sb) == nil
+	}
+	return true
+}
+
+// discard terminates a Sandbox owned by the pool and frees its slot.
+func (p *Pool) discard(sb *modal.Sandbox) {
+	sb.Terminate()
+	p.mu.Lock()
+	p.size--
+	p.notifyLocked()
+	p.mu.Unlock()
+}
+
+// notifyLocked wakes up callers blocked in Acquire. p.mu must be held.
+func (p *Pool) notifyLocked() {
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
@@ -0,0 +1,166 @@
+package sandboxpool
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/modal-labs/libmodal/modal-go"
+	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+	"github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// fakeModal serves the RPCs that a Pool makes, for Sandboxes that run until
+// they are terminated or exit.
+type fakeModal struct {
+	mu         sync.Mutex
+	created    int
+	exited     map[string]bool
+	terminated []string
+}
+
+func (f *fakeModal) intercept(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch method {
+	case "/modal.client.ModalClient/AppGetOrCreate":
+		proto.Merge(reply.(proto.Message), pb.AppGetOrCreateResponse_builder{AppId: "ap-123"}.Build())
+	case "/modal.client.ModalClient/SandboxCreate":
+		f.created++
+		proto.Merge(reply.(proto.Message), pb.SandboxCreateResponse_builder{SandboxId: fmt.Sprintf("sb-%d", f.created)}.Build())
+	case "/modal.client.ModalClient/SandboxWait":
+		if f.exited[req.(*pb.SandboxWaitRequest).GetSandboxId()] {
+			proto.Merge(reply.(proto.Message), pb.SandboxWaitResponse_builder{
+				Result: pb.GenericResult_builder{Status: pb.GenericResult_GENERIC_STATUS_SUCCESS}.Build(),
+			}.Build())
+		}
+	case "/modal.client.ModalClient/SandboxTerminate":
+		id := req.(*pb.SandboxTerminateRequest).GetSandboxId()
+		f.exited[id] = true
+		f.terminated = append(f.terminated, id)
+	}
+	return nil
+}
+
+// exit makes a Sandbox exit on its own.
+func (f *fakeModal) exit(id string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.exited[id] = true
+}
+
+func (f *fakeModal) stats() (created int, terminated []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.created, slices.Clone(f.terminated)
+}
+
+// newFakePool returns a Pool of Sandboxes served by a fake of Modal.
+func newFakePool(t *testing.T, options Options) (*Pool, *fakeModal) {
+	fake := &fakeModal{exited: map[string]bool{}}
+	err := modal.InitializeClient(modal.ClientOptions{
+		TokenId:           "token-id",
+		TokenSecret:       "token-secret",
+		UnaryInterceptors: []grpc.UnaryClientInterceptor{fake.intercept},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	app, err := modal.AppLookup(context.Background(), "pool", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// No log streams, which the fake does not serve.
+	options.SandboxOptions = &modal.SandboxOptions{Stdout: modal.Ignore, Stderr: modal.Ignore}
+	pool, err := New(app, &modal.Image{ImageId: "im-123"}, &options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pool.Close() })
+	return pool, fake
+}
+
+func TestAcquireRelease(t *testing.T) {
+	g := gomega.NewWithT(t)
+	pool, fake := newFakePool(t, Options{Min: 1, Max: 2, CheckInterval: time.Hour})
+	created, _ := fake.stats()
+	g.Expect(created).To(gomega.Equal(1))
+
+	// The warm Sandbox is handed out first, then a new one is started.
+	sb1, err := pool.Acquire(context.Background())
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(sb1.SandboxId).To(gomega.Equal("sb-1"))
+	sb2, err := pool.Acquire(context.Background())
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(sb2.SandboxId).To(gomega.Equal("sb-2"))
+
+	// At Max, Acquire waits for a release.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = pool.Acquire(ctx)
+	g.Expect(err).To(gomega.MatchError(context.DeadlineExceeded))
+	released := make(chan *modal.Sandbox)
+	go func() {
+		sb, _ := pool.Acquire(context.Background())
+		released <- sb
+	}()
+	g.Expect(pool.Release(sb1)).To(gomega.Succeed())
+	g.Eventually(released).Should(gomega.Receive(gomega.BeIdenticalTo(sb1)))
+
+	// A Sandbox that exited is terminated when released, freeing its slot.
+	fake.exit("sb-2")
+	g.Expect(pool.Release(sb2)).To(gomega.Succeed())
+	sb3, err := pool.Acquire(context.Background())
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(sb3.SandboxId).To(gomega.Equal("sb-3"))
+	_, terminated := fake.stats()
+	g.Expect(terminated).To(gomega.Equal([]string{"sb-2"}))
+}
+
+func TestReleaseRejectsForeignSandboxes(t *testing.T) {
+	g := gomega.NewWithT(t)
+	pool, fake := newFakePool(t, Options{Max: 1, CheckInterval: time.Hour})
+
+	foreign, err := pool.app.CreateSandbox(pool.image, &modal.SandboxOptions{Stdout: modal.Ignore, Stderr: modal.Ignore})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(pool.Release(foreign)).To(gomega.BeAssignableToTypeOf(modal.InvalidError{}))
+
+	sb, err := pool.Acquire(context.Background())
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(pool.Release(sb)).To(gomega.Succeed())
+	g.Expect(pool.Release(sb)).To(gomega.BeAssignableToTypeOf(modal.InvalidError{}))
+
+	// Neither was terminated, and the pool holds one idle Sandbox.
+	_, terminated := fake.stats()
+	g.Expect(terminated).To(gomega.BeEmpty())
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	g.Expect(pool.idle).To(gomega.HaveLen(1))
+	g.Expect(pool.size).To(gomega.Equal(1))
+}
+
+func TestReplenish(t *testing.T) {
+	g := gomega.NewWithT(t)
+	pool, fake := newFakePool(t, Options{Min: 2, Max: 3, CheckInterval: 5 * time.Millisecond})
+
+	// An idle Sandbox that exits is terminated and replaced.
+	fake.exit("sb-1")
+	g.Eventually(func() []string {
+		pool.mu.Lock()
+		defer pool.mu.Unlock()
+		var ids []string
+		for _, entry := range pool.idle {
+			ids = append(ids, entry.sb.SandboxId)
+		}
+		slices.Sort(ids)
+		return ids
+	}).Should(gomega.Equal([]string{"sb-2", "sb-3"}))
+	created, terminated := fake.stats()
+	g.Expect(created).To(gomega.Equal(3))
+	g.Expect(terminated).To(gomega.Equal([]string{"sb-1"}))
+}
@@ -2,25 +2,77 @@ package modal
 
 import (
 	"context"
+	"fmt"
+	"iter"
+	"strings"
+	"time"
 
 	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// Prefix of the server error message listing keys missing from a Secret.
+const secretMissingKeysPrefix = "Secret is missing key(s): "
+
 // Secret represents a Modal secret.
 type Secret struct {
 	SecretId string
 
-	//lint:ignore U1000 may be used in future
-	ctx context.Context
+	ctx         context.Context
+	name        string
+	environment string
+}
+
+// ObjectId returns the Secret's ID, see Object.
+func (s *Secret) ObjectId() string {
+	return s.SecretId
+}
+
+// WithContext returns a copy of the Secret whose calls use ctx, for example to
+// apply a request's deadline and cancellation.
+func (s *Secret) WithContext(ctx context.Context) (*Secret, error) {
+	ctx, err := handleContext(ctx, s.ctx)
+	if err != nil {
+		return nil, err
+	}
+	c := *s
+	c.ctx = ctx
+	return &c, nil
+}
+
+// Name returns the name the Secret was looked up by, or an empty string for
+// Secrets created from a map.
+func (s *Secret) Name() string {
+	return s.name
+}
+
+// Environment returns the name of the environment the Secret is in, see
+// Object.
+func (s *Secret) Environment() string {
+	return firstNonEmpty(s.environment, environmentName(s.ctx, ""))
+}
+
+// IsHydrated reports whether the Secret has an ID, see Object.
+func (s *Secret) IsHydrated() bool {
+	return s.SecretId != ""
+}
+
+// Hydrate makes the Secret ready for use, see Object.
+func (s *Secret) Hydrate(ctx context.Context) error {
+	return hydrate(ctx, "Secret", s.SecretId, &s.ctx)
 }
 
 // SecretFromNameOptions are options for finding Modal secrets.
 type SecretFromNameOptions struct {
 	Environment  string
-	RequiredKeys []string
+	RequiredKeys []string // Keys that must be present in the Secret.
 }
 
 // SecretFromName references a modal.Secret by its name.
+//
+// If RequiredKeys is set, the Secret is checked for those keys at lookup time,
+// and a SecretMissingKeysError listing the absent keys is returned otherwise.
 func SecretFromName(ctx context.Context, name string, options *SecretFromNameOptions) (*Secret, error) {
 	var err error
 	ctx, err = clientContext(ctx)
@@ -31,16 +83,144 @@ func SecretFromName(ctx context.Context, name string, options *SecretFromNameOpt
 	if options == nil {
 		options = &SecretFromNameOptions{}
 	}
+	var environment string
+	name, environment, err = resolveObjectRef(name, "Secret", options.Environment)
+	if err != nil {
+		return nil, err
+	}
 
 	resp, err := client.SecretGetOrCreate(ctx, pb.SecretGetOrCreateRequest_builder{
 		DeploymentName:  name,
-		EnvironmentName: environmentName(options.Environment),
+		EnvironmentName: environmentName(ctx, environment),
 		RequiredKeys:    options.RequiredKeys,
 	}.Build())
 
+	if st, ok := status.FromError(err); ok && st != nil {
+		if i := strings.Index(st.Message(), secretMissingKeysPrefix); i >= 0 {
+			return nil, SecretMissingKeysError{
+				Exception:   st.Message(),
+				MissingKeys: parseSecretMissingKeys(st.Message()[i+len(secretMissingKeysPrefix):]),
+			}
+		}
+		if st.Code() == codes.NotFound {
+			return nil, NotFoundError{st.Message()}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Secret{
+		SecretId:    resp.GetSecretId(),
+		ctx:         context.WithoutCancel(ctx),
+		name:        name,
+		environment: environmentName(ctx, environment),
+	}, nil
+}
+
+// SecretFromMapOptions are options for creating a Secret from a map.
+type SecretFromMapOptions struct {
+	Environment string
+}
+
+// SecretFromMap creates an ephemeral, nameless Secret from a map of
+// environment variables.
+func SecretFromMap(ctx context.Context, envVars map[string]string, options *SecretFromMapOptions) (*Secret, error) {
+	var err error
+	ctx, err = clientContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if options == nil {
+		options = &SecretFromMapOptions{}
+	}
+
+	resp, err := client.SecretGetOrCreate(ctx, pb.SecretGetOrCreateRequest_builder{
+		ObjectCreationType: pb.ObjectCreationType_OBJECT_CREATION_TYPE_EPHEMERAL,
+		EnvDict:            envVars,
+		EnvironmentName:    environmentName(ctx, options.Environment),
+	}.Build())
 	if err != nil {
 		return nil, err
 	}
 
-	return &Secret{SecretId: resp.GetSecretId()}, nil
+	return &Secret{
+		SecretId:    resp.GetSecretId(),
+		ctx:         ctx,
+		environment: environmentName(ctx, options.Environment),
+	}, nil
+}
+
+// SecretInfo describes a named Secret, without its keys or values, see
+// SecretList.
+type SecretInfo struct {
+	SecretId   string
+	Name       string
+	CreatedAt  time.Time
+	LastUsedAt time.Time // Zero if the Secret has not been used.
+}
+
+// SecretList returns an iterator over the named Secrets in an environment.
+// The listing is fetched when iteration begins.
+//
+// Modal does not return the keys of Secrets. To check that a Secret has the
+// keys a deployment expects, without reading their values, look it up with
+// SecretFromNameOptions.RequiredKeys.
+func SecretList(ctx context.Context, options *ListOptions) iter.Seq2[SecretInfo, error] {
+	if options == nil {
+		options = &ListOptions{}
+	}
+	return func(yield func(SecretInfo, error) bool) {
+		ctx, err := clientContext(ctx)
+		if err != nil {
+			yield(SecretInfo{}, err)
+			return
+		}
+		resp, err := client.SecretList(ctx, pb.SecretListRequest_builder{
+			EnvironmentName: environmentName(ctx, options.Environment),
+		}.Build())
+		if err != nil {
+			yield(SecretInfo{}, err)
+			return
+		}
+		for _, item := range resp.GetItems() {
+			info := SecretInfo{
+				SecretId:  item.GetSecretId(),
+				Name:      item.GetLabel(),
+				CreatedAt: time.Unix(0, int64(item.GetCreatedAt()*1e9)),
+			}
+			if lastUsedAt := item.GetLastUsedAt(); lastUsedAt != 0 {
+				info.LastUsedAt = time.Unix(0, int64(lastUsedAt*1e9))
+			}
+			if !yield(info, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Info returns the listing of a named Secret, from SecretList. Secrets
+// created with SecretFromMap are not listed, and return a NotFoundError.
+func (s *Secret) Info() (*SecretInfo, error) {
+	for info, err := range SecretList(s.ctx, &ListOptions{Environment: s.Environment()}) {
+		if err != nil {
+			return nil, err
+		}
+		if info.SecretId == s.SecretId {
+			return &info, nil
+		}
+	}
+	return nil, NotFoundError{fmt.Sprintf("Secret '%s' not found in listing", s.SecretId)}
+}
+
+// parseSecretMissingKeys splits the comma-separated key list from a server error.
+func parseSecretMissingKeys(list string) []string {
+	var keys []string
+	for _, key := range strings.Split(list, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
 }
@@ -0,0 +1,47 @@
+package modal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+	"github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestSecretList(t *testing.T) {
+	g := gomega.NewWithT(t)
+	var environment string
+	fake := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if method == "/modal.client.ModalClient/SecretList" {
+			environment = req.(*pb.SecretListRequest).GetEnvironmentName()
+			proto.Merge(reply.(proto.Message), pb.SecretListResponse_builder{Items: []*pb.SecretListItem{
+				pb.SecretListItem_builder{SecretId: "st-1", Label: "db", CreatedAt: 1700000000, LastUsedAt: 1700000060.5}.Build(),
+				pb.SecretListItem_builder{SecretId: "st-2", Label: "unused", CreatedAt: 1700000000}.Build(),
+			}}.Build())
+		}
+		return nil
+	}
+	useFakeClient(t, fake)
+
+	var infos []SecretInfo
+	for info, err := range SecretList(context.Background(), &ListOptions{Environment: "staging"}) {
+		g.Expect(err).ShouldNot(gomega.HaveOccurred())
+		infos = append(infos, info)
+	}
+	g.Expect(environment).To(gomega.Equal("staging"))
+	g.Expect(infos).To(gomega.Equal([]SecretInfo{
+		{SecretId: "st-1", Name: "db", CreatedAt: time.Unix(1700000000, 0), LastUsedAt: time.Unix(1700000060, 5e8)},
+		{SecretId: "st-2", Name: "unused", CreatedAt: time.Unix(1700000000, 0)},
+	}))
+
+	secret := &Secret{SecretId: "st-2", ctx: WithEnvironment(context.Background(), "staging")}
+	info, err := secret.Info()
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(info.Name).To(gomega.Equal("unused"))
+
+	_, err = (&Secret{SecretId: "st-3", ctx: context.Background()}).Info()
+	g.Expect(err).To(gomega.BeAssignableToTypeOf(NotFoundError{}))
+}
@@ -0,0 +1,23 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/modal-labs/libmodal/modal-go"
+	"github.com/onsi/gomega"
+)
+
+func TestAppDeploymentHistory(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	app, err := modal.AppLookup(context.Background(), "libmodal-test-support", nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	history, err := app.DeploymentHistory()
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(history).ShouldNot(gomega.BeEmpty())
+	g.Expect(history[0].Version).Should(gomega.BeNumerically(">=", 1))
+	g.Expect(history[0].DeployedAt.IsZero()).To(gomega.BeFalse())
+}
@@ -0,0 +1,104 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/modal-labs/libmodal/modal-go"
+	"github.com/onsi/gomega"
+)
+
+func TestDictEphemeral(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	dict, err := modal.DictEphemeral(context.Background(), nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer dict.CloseEphemeral()
+
+	created, err := dict.Put("key", 123, nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(created).To(gomega.BeTrue())
+
+	created, err = dict.Put("key", 456, &modal.DictPutOptions{SkipIfExists: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(created).To(gomega.BeFalse())
+
+	value, found, err := dict.Get("key")
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(found).To(gomega.BeTrue())
+	g.Expect(value).To(gomega.Equal(int64(123)))
+
+	_, found, err = dict.Get("missing")
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(found).To(gomega.BeFalse())
+
+	g.Expect(dict.Update(map[any]any{"a": "x", "b": "y"})).Should(gomega.Succeed())
+	n, err := dict.Len()
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(n).To(gomega.Equal(3))
+
+	value, found, err = dict.Pop("a")
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(found).To(gomega.BeTrue())
+	g.Expect(value).To(gomega.Equal("x"))
+
+	ok, err := dict.Contains("a")
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(ok).To(gomega.BeFalse())
+
+	g.Expect(dict.Clear()).Should(gomega.Succeed())
+	n, err = dict.Len()
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(n).To(gomega.Equal(0))
+}
+
+func TestDictStatsNamed(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	ctx := context.Background()
+
+	name := fmt.Sprintf("libmodal-test-stats-%d", time.Now().UnixNano())
+	dict, err := modal.DictLookup(ctx, name, &modal.LookupOptions{CreateIfMissing: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer modal.DictDelete(ctx, name, nil)
+
+	g.Expect(dict.Update(map[any]any{"a": 1, "b": 2})).Should(gomega.Succeed())
+
+	stats, err := dict.Stats()
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(stats.Len).Should(gomega.Equal(2))
+	g.Expect(stats.CreatedAt).Should(gomega.BeTemporally("~", time.Now(), 5*time.Minute))
+}
+
+func TestTypedDict(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	dict, err := modal.DictEphemeral(context.Background(), nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer dict.CloseEphemeral()
+
+	type user struct {
+		Name string `json:"name"`
+	}
+	users := modal.NewTypedDict(dict, modal.JSONCodec[user]())
+	g.Expect(users.Update(map[any]user{1: {Name: "a"}, 2: {Name: "b"}})).Should(gomega.Succeed())
+
+	u, found, err := users.Get(2)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(found).To(gomega.BeTrue())
+	g.Expect(u).To(gomega.Equal(user{Name: "b"}))
+
+	counts := modal.NewTypedDict[int](dict, nil)
+	g.Expect(counts.Clear()).Should(gomega.Succeed())
+	_, err = counts.Put("hits", 7, nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	hits, found, err := counts.Pop("hits")
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(found).To(gomega.BeTrue())
+	g.Expect(hits).To(gomega.Equal(7))
+}
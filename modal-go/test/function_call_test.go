@@ -1,7 +1,9 @@
 package test
 
 import (
+	"bytes"
 	"context"
+	"io"
 	"testing"
 	"time"
 
@@ -94,3 +96,48 @@ func TestFunctionCallGet0(t *testing.T) {
 	g.Expect(err).ShouldNot(gomega.HaveOccurred())
 	g.Expect(result).Should(gomega.Equal(pickle.None{}))
 }
+
+func TestFunctionCallGetContextCancel(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	sleep, err := modal.FunctionLookup(
+		context.Background(),
+		"libmodal-test-support", "sleep", nil,
+	)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	functionCall, err := sleep.Spawn(nil, map[string]any{"t": 10})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	_, err = functionCall.GetContext(ctx, &modal.FunctionCallGetOptions{CancelOnCtxDone: true})
+	g.Expect(err).Should(gomega.MatchError(context.DeadlineExceeded))
+
+	// The remote call was cancelled, so it never produces a result.
+	_, err = functionCall.Get(nil)
+	g.Expect(err).Should(gomega.HaveOccurred())
+}
+
+func TestFunctionCallStreamGenerator(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	function, err := modal.FunctionLookup(
+		context.Background(),
+		"libmodal-test-support", "byte_chunks", nil,
+	)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	functionCall, err := function.Spawn(nil, map[string]any{"n": 3, "size": 1 << 20})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	stream := functionCall.StreamGenerator()
+	defer stream.Close()
+
+	data, err := io.ReadAll(stream)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(data).To(gomega.HaveLen(3 << 20))
+	g.Expect(data[2<<20:]).To(gomega.Equal(bytes.Repeat([]byte{2}, 1<<20)))
+}
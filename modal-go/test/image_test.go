@@ -2,6 +2,8 @@ package test
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/modal-labs/libmodal/modal-go"
@@ -77,3 +79,85 @@ func TestImageFromGcpArtifactRegistry(t *testing.T) {
 	g.Expect(err).ShouldNot(gomega.HaveOccurred())
 	g.Expect(image.ImageId).Should(gomega.HavePrefix("im-"))
 }
+
+func TestImageFromId(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	app, err := modal.AppLookup(context.Background(), "libmodal-test", &modal.LookupOptions{CreateIfMissing: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	image, err := app.ImageFromRegistry("alpine:3.21", nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	image2, err := modal.ImageFromId(context.Background(), image.ImageId)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(image2.ImageId).Should(gomega.Equal(image.ImageId))
+
+	_, err = modal.ImageFromId(context.Background(), "im-nonexistent")
+	g.Expect(err).Should(gomega.HaveOccurred())
+}
+
+func TestImageInspect(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	app, err := modal.AppLookup(context.Background(), "libmodal-test", &modal.LookupOptions{CreateIfMissing: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	image, err := app.ImageFromRegistry("alpine:3.21", nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	info, err := image.Inspect()
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(info.ImageId).Should(gomega.Equal(image.ImageId))
+	g.Expect(info.BuilderVersion).ShouldNot(gomega.BeEmpty())
+	g.Expect(info.DockerfileCommands).Should(gomega.Equal([]string{"FROM alpine:3.21"}))
+}
+
+func TestImageForRuntime(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	app, err := modal.AppLookup(context.Background(), "libmodal-test", &modal.LookupOptions{CreateIfMissing: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	image, err := app.ImageForRuntime("python3.12", []string{"six"}, nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	sb, err := app.CreateSandbox(image, nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer sb.Terminate()
+
+	result, err := sb.Run([]string{"python", "-c", "import six; print(six.__name__)"}, modal.ExecOptions{})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(result.ExitCode).To(gomega.Equal(0))
+	g.Expect(string(result.Stdout)).To(gomega.Equal("six\n"))
+}
+
+func TestImageFromContext(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	app, err := modal.AppLookup(context.Background(), "libmodal-test", &modal.LookupOptions{CreateIfMissing: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	dir := t.TempDir()
+	g.Expect(os.WriteFile(filepath.Join(dir, "hello.txt"), []byte("hello from the context\n"), 0o644)).To(gomega.Succeed())
+	g.Expect(os.WriteFile(filepath.Join(dir, "skipped.log"), []byte("not uploaded\n"), 0o644)).To(gomega.Succeed())
+
+	var uploaded, total int64
+	image, err := app.ImageFromContext(dir, []string{"FROM alpine:3.21", "COPY . /ctx"}, &modal.ImageFromContextOptions{
+		Exclude:  []string{"*.log"},
+		Progress: func(n, size int64) { uploaded, total = n, size },
+	})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(uploaded).To(gomega.Equal(total))
+
+	sb, err := app.CreateSandbox(image, nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer sb.Terminate()
+	result, err := sb.Run([]string{"ls", "/ctx"}, modal.ExecOptions{})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(string(result.Stdout)).To(gomega.Equal("hello.txt\n"))
+}
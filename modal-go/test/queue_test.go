@@ -3,6 +3,7 @@ package test
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sync"
 	"testing"
 	"time"
@@ -169,3 +170,118 @@ func TestQueueNonBlocking(t *testing.T) {
 	g.Expect(err).ToNot(gomega.HaveOccurred())
 	g.Expect(item).To(gomega.Equal(int64(123)))
 }
+
+func TestTypedQueue(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	ctx := context.Background()
+
+	queue, err := modal.QueueEphemeral(ctx, nil)
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	defer queue.CloseEphemeral()
+
+	type task struct {
+		Name string `json:"name"`
+	}
+	tasks := modal.NewTypedQueue(queue, modal.JSONCodec[task]())
+	g.Expect(tasks.PutMany([]task{{Name: "a"}, {Name: "b"}}, nil)).ToNot(gomega.HaveOccurred())
+
+	item, err := tasks.Get(nil)
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(item).To(gomega.Equal(task{Name: "a"}))
+
+	ints := modal.NewTypedQueue[int](queue, nil)
+	g.Expect(tasks.Clear(nil)).ToNot(gomega.HaveOccurred())
+	g.Expect(ints.PutMany([]int{1, 2, 3}, nil)).ToNot(gomega.HaveOccurred())
+
+	items, err := ints.GetMany(3, nil)
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(items).To(gomega.Equal([]int{1, 2, 3}))
+}
+
+func TestQueueStats(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	queue, err := modal.QueueEphemeral(context.Background(), nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer queue.CloseEphemeral()
+
+	g.Expect(queue.PutMany([]any{1, 2, 3}, nil)).Should(gomega.Succeed())
+	g.Expect(queue.Put(4, &modal.QueuePutOptions{Partition: "other"})).Should(gomega.Succeed())
+
+	stats, err := queue.Stats()
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(stats.Len).Should(gomega.Equal(4))
+}
+
+func TestQueueStatsNamed(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	ctx := context.Background()
+
+	name := fmt.Sprintf("libmodal-test-stats-%d", time.Now().UnixNano())
+	queue, err := modal.QueueLookup(ctx, name, &modal.LookupOptions{CreateIfMissing: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer modal.QueueDelete(ctx, name, &modal.DeleteOptions{})
+
+	g.Expect(queue.PutMany([]any{1, 2}, nil)).Should(gomega.Succeed())
+	g.Expect(queue.Put(3, &modal.QueuePutOptions{Partition: "other"})).Should(gomega.Succeed())
+
+	stats, err := queue.Stats()
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(stats.Len).Should(gomega.Equal(3))
+	g.Expect(stats.Partitions).Should(gomega.Equal(2))
+	g.Expect(stats.CreatedAt).Should(gomega.BeTemporally("~", time.Now(), 5*time.Minute))
+}
+
+func TestQueueManyPartitions(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	queue, err := modal.QueueEphemeral(context.Background(), nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer queue.CloseEphemeral()
+
+	err = queue.PutManyPartitions(map[string][]any{
+		"shard-0": {1, 2},
+		"shard-1": {3},
+	}, nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	timeout := 1 * time.Second
+	results, err := queue.GetManyPartitions(10, []string{"shard-0", "shard-1", "shard-2"}, &modal.QueueGetOptions{Timeout: &timeout})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(results).Should(gomega.HaveLen(2))
+	g.Expect(results["shard-0"]).Should(gomega.HaveLen(2))
+	g.Expect(results["shard-1"]).Should(gomega.HaveLen(1))
+
+	err = queue.PutManyPartitions(map[string][]any{"shard-0": {1, make(chan int)}}, nil)
+	var batchErr modal.QueueBatchError
+	g.Expect(errors.As(err, &batchErr)).To(gomega.BeTrue())
+	g.Expect(batchErr.Items).Should(gomega.HaveLen(1))
+	g.Expect(batchErr.Items[0].Index).Should(gomega.Equal(1))
+
+	// Without a timeout, empty partitions are not waited on.
+	results, err = queue.GetManyPartitions(10, []string{"shard-2"}, nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(results).Should(gomega.BeEmpty())
+}
+
+func TestQueuePartitionTtl(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	queue, err := modal.QueueEphemeral(context.Background(), nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer queue.CloseEphemeral()
+
+	err = queue.Put(1, &modal.QueuePutOptions{PartitionTtl: 500 * time.Millisecond})
+	g.Expect(err).Should(gomega.BeAssignableToTypeOf(modal.InvalidError{}))
+
+	err = queue.Put(1, &modal.QueuePutOptions{Partition: "short", PartitionTtl: time.Minute})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	n, err := queue.Len(&modal.QueueLenOptions{Partition: "short"})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(n).Should(gomega.Equal(1))
+}
@@ -3,8 +3,10 @@ package test
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"io"
 	"testing"
+	"time"
 
 	"github.com/modal-labs/libmodal/modal-go"
 	"github.com/onsi/gomega"
@@ -235,3 +237,68 @@ func TestSandboxLargeFileOperations(t *testing.T) {
 	err = reader1.Close()
 	g.Expect(err).ShouldNot(gomega.HaveOccurred())
 }
+
+func TestSandboxTailFile(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	sb := createSandbox(g)
+	defer terminateSandbox(g, sb)
+
+	writer, err := sb.Open("/tmp/tail.log", "w")
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	_, err = writer.Write([]byte("first\n"))
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(writer.Close()).Should(gomega.Succeed())
+
+	reader, err := sb.TailFile("/tmp/tail.log", true)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer reader.Close()
+
+	p, err := sb.Exec([]string{"sh", "-c", "echo second >> /tmp/tail.log"}, modal.ExecOptions{})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	_, err = p.Wait()
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	output := make([]byte, len("first\nsecond\n"))
+	_, err = io.ReadFull(reader, output)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(string(output)).Should(gomega.Equal("first\nsecond\n"))
+}
+
+func TestSandboxWatch(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	sb := createSandbox(g)
+	defer terminateSandbox(g, sb)
+
+	p, err := sb.Exec([]string{"mkdir", "-p", "/tmp/watched"}, modal.ExecOptions{})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	_, err = p.Wait()
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	touched := make(chan error, 1)
+	go func() {
+		time.Sleep(2 * time.Second)
+		result, err := sb.Run([]string{"touch", "/tmp/watched/artifact.txt"}, modal.ExecOptions{})
+		if err == nil && result.ExitCode != 0 {
+			err = fmt.Errorf("touch exited with code %d", result.ExitCode)
+		}
+		touched <- err
+	}()
+
+	// Terminating the Sandbox on timeout ends the watch, if no event arrives.
+	timer := time.AfterFunc(time.Minute, func() { sb.Terminate() })
+	defer timer.Stop()
+
+	var created *modal.FileWatchEvent
+	for event, err := range sb.Watch("/tmp/watched", true) {
+		g.Expect(err).ShouldNot(gomega.HaveOccurred())
+		if event.Type == modal.FileWatchCreate {
+			created = &event
+			break
+		}
+	}
+	g.Expect(created).ShouldNot(gomega.BeNil(), "no create event within the timeout")
+	g.Expect(created.Paths).Should(gomega.ContainElement(gomega.HaveSuffix("artifact.txt")))
+	g.Expect(<-touched).ShouldNot(gomega.HaveOccurred())
+}
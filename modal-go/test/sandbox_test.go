@@ -2,7 +2,9 @@ package test
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"strings"
 	"testing"
 	"time"
 
@@ -85,6 +87,31 @@ func TestIgnoreLargeStdout(t *testing.T) {
 	g.Expect(exitCode).To(gomega.Equal(0))
 }
 
+func TestSandboxIgnoreStdout(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	app, err := modal.AppLookup(context.Background(), "libmodal-test", &modal.LookupOptions{CreateIfMissing: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	image, err := app.ImageFromRegistry("alpine:3.21", nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	sb, err := app.CreateSandbox(image, &modal.SandboxOptions{
+		Command: []string{"sh", "-c", "echo out; echo err >&2"},
+		Stdout:  modal.Ignore,
+	})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer sb.Terminate()
+
+	stdout, err := io.ReadAll(sb.Stdout)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(stdout).To(gomega.BeEmpty())
+
+	stderr, err := io.ReadAll(sb.Stderr)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(string(stderr)).To(gomega.Equal("err\n"))
+}
+
 func TestSandboxExecOptions(t *testing.T) {
 	t.Parallel()
 	g := gomega.NewWithT(t)
@@ -114,6 +141,96 @@ func TestSandboxExecOptions(t *testing.T) {
 	g.Expect(exitCode).To(gomega.Equal(0))
 }
 
+func TestSandboxRun(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	app, err := modal.AppLookup(context.Background(), "libmodal-test", &modal.LookupOptions{CreateIfMissing: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	image, err := app.ImageFromRegistry("alpine:3.21", nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	sb, err := app.CreateSandbox(image, nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer sb.Terminate()
+
+	result, err := sb.Run([]string{"sh", "-c", "pwd; echo oops >&2; exit 3"}, modal.ExecOptions{Workdir: "/tmp"})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(string(result.Stdout)).To(gomega.Equal("/tmp\n"))
+	g.Expect(string(result.Stderr)).To(gomega.Equal("oops\n"))
+	g.Expect(result.ExitCode).To(gomega.Equal(3))
+	g.Expect(result.StdoutStats.Truncated).To(gomega.BeFalse())
+
+	// Output beyond MaxOutputBytes is dropped, and reported in the stats.
+	result, err = sb.Run([]string{"sh", "-c", "yes | head -c 100000"}, modal.ExecOptions{MaxOutputBytes: 10})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(string(result.Stdout)).To(gomega.Equal("y\ny\ny\ny\ny\n"))
+	g.Expect(result.ExitCode).To(gomega.Equal(0))
+	g.Expect(result.StdoutStats).To(gomega.Equal(modal.ExecOutputStats{TotalBytes: 100000, Truncated: true}))
+}
+
+func TestSandboxExecMaxOutputBytes(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	app, err := modal.AppLookup(context.Background(), "libmodal-test", &modal.LookupOptions{CreateIfMissing: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	image, err := app.ImageFromRegistry("alpine:3.21", nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	sb, err := app.CreateSandbox(image, nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer sb.Terminate()
+
+	p, err := sb.Exec([]string{"sh", "-c", "yes | head -c 100000; echo hi >&2"}, modal.ExecOptions{MaxOutputBytes: 4})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	stdout, err := io.ReadAll(p.Stdout)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(string(stdout)).To(gomega.Equal("y\ny\n"))
+	g.Expect(p.StdoutStats()).To(gomega.Equal(modal.ExecOutputStats{TotalBytes: 100000, Truncated: true}))
+
+	stderr, err := io.ReadAll(p.Stderr)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(string(stderr)).To(gomega.Equal("hi\n"))
+	g.Expect(p.StderrStats()).To(gomega.Equal(modal.ExecOutputStats{TotalBytes: 3}))
+}
+
+func TestSandboxExecSecrets(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	app, err := modal.AppLookup(context.Background(), "libmodal-test", &modal.LookupOptions{CreateIfMissing: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	image, err := app.ImageFromRegistry("alpine:3.21", nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	sb, err := app.CreateSandbox(image, nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer sb.Terminate()
+
+	secret, err := modal.SecretFromName(context.Background(), "libmodal-test-secret", nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	p, err := sb.Exec([]string{"sh", "-c", `echo "$c $EXTRA"`}, modal.ExecOptions{
+		Secrets: []*modal.Secret{secret},
+		EnvVars: map[string]string{"EXTRA": "from exec"},
+	})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	output, err := io.ReadAll(p.Stdout)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(string(output)).To(gomega.Equal("hello world from exec\n"))
+
+	// The variables are not visible to other commands.
+	p, err = sb.Exec([]string{"sh", "-c", `echo "$c$EXTRA"`}, modal.ExecOptions{})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	output, err = io.ReadAll(p.Stdout)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(string(output)).To(gomega.Equal("\n"))
+}
+
 func TestSandboxWithVolume(t *testing.T) {
 	t.Parallel()
 	g := gomega.NewWithT(t)
@@ -253,3 +370,461 @@ func TestSandboxPollAfterFailure(t *testing.T) {
 	g.Expect(pollResult).ShouldNot(gomega.BeNil())
 	g.Expect(*pollResult).To(gomega.Equal(42))
 }
+
+func TestSandboxExecSession(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	app, err := modal.AppLookup(context.Background(), "libmodal-test", &modal.LookupOptions{CreateIfMissing: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	image, err := app.ImageFromRegistry("alpine:3.21", nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	sb, err := app.CreateSandbox(image, nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer sb.Terminate()
+
+	session, err := sb.StartExecSession(modal.ExecOptions{})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer session.Close()
+
+	output, exitCode, err := session.Run("cd /tmp && export GREETING=hi")
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(exitCode).To(gomega.Equal(0))
+	g.Expect(output).To(gomega.BeEmpty())
+
+	// Shell state carries over between commands.
+	output, exitCode, err = session.Run("echo $GREETING; pwd; echo oops >&2; false")
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(exitCode).To(gomega.Equal(1))
+	g.Expect(string(output)).To(gomega.Equal("hi\n/tmp\noops\n"))
+
+	output, _, err = session.Run("printf no-newline")
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(string(output)).To(gomega.Equal("no-newline"))
+}
+
+func TestSandboxBackgroundProcesses(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	app, err := modal.AppLookup(context.Background(), "libmodal-test", &modal.LookupOptions{CreateIfMissing: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	image, err := app.ImageFromRegistry("alpine:3.21", nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	sb, err := app.CreateSandbox(image, &modal.SandboxOptions{
+		BackgroundProcesses: []modal.BackgroundProcess{{
+			Name:    "writer",
+			Command: []string{"sh", "-c", "echo $MESSAGE > /tmp/shared.txt && echo done"},
+			EnvVars: map[string]string{"MESSAGE": "from background"},
+		}},
+	})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer sb.Terminate()
+
+	process := sb.BackgroundProcess("writer")
+	g.Expect(process).ShouldNot(gomega.BeNil())
+	output, err := io.ReadAll(process.Stdout)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(string(output)).To(gomega.Equal("done\n"))
+	exitCode, err := process.Wait()
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(exitCode).To(gomega.Equal(0))
+
+	// The process shares the Sandbox's filesystem.
+	result, err := sb.Run([]string{"cat", "/tmp/shared.txt"}, modal.ExecOptions{})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(string(result.Stdout)).To(gomega.Equal("from background\n"))
+
+	g.Expect(sb.BackgroundProcess("missing")).To(gomega.BeNil())
+}
+
+func TestSandboxEvents(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	app, err := modal.AppLookup(context.Background(), "libmodal-test", &modal.LookupOptions{CreateIfMissing: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	image, err := app.ImageFromRegistry("alpine:3.21", nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	sandbox, err := app.CreateSandbox(image, &modal.SandboxOptions{Command: []string{"true"}})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	var states []modal.TaskState
+	var last modal.LifecycleEvent
+	for event, err := range sandbox.Events(nil) {
+		g.Expect(err).ShouldNot(gomega.HaveOccurred())
+		if event.Type == modal.LifecycleTaskState {
+			states = append(states, event.State)
+		}
+		last = event
+	}
+	g.Expect(states).Should(gomega.ContainElement(modal.TaskStateActive))
+	g.Expect(last.Type).Should(gomega.Equal(modal.LifecycleDone))
+}
+
+func TestSandboxRuntimeGVisor(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	app, err := modal.AppLookup(context.Background(), "libmodal-test", &modal.LookupOptions{CreateIfMissing: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	image, err := app.ImageFromRegistry("alpine:3.21", nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	sb, err := app.CreateSandbox(image, &modal.SandboxOptions{Runtime: modal.RuntimeGVisor})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer sb.Terminate()
+
+	// gVisor reports its own kernel messages in dmesg.
+	result, err := sb.Run([]string{"dmesg"}, modal.ExecOptions{})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(string(result.Stdout)).Should(gomega.ContainSubstring("gVisor"))
+}
+
+func TestAppSandboxExits(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	app, err := modal.AppLookup(context.Background(), "libmodal-test", &modal.LookupOptions{CreateIfMissing: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	image, err := app.ImageFromRegistry("alpine:3.21", nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	sb, err := app.CreateSandbox(image, &modal.SandboxOptions{
+		Command: []string{"sh", "-c", "sleep 10; exit 7"},
+		Timeout: time.Minute,
+	})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	// Other tests share the App, so skip exits of their Sandboxes.
+	for exit, err := range app.SandboxExits(&modal.SandboxExitsOptions{PollInterval: time.Second}) {
+		g.Expect(err).ShouldNot(gomega.HaveOccurred())
+		if exit.SandboxId == sb.SandboxId {
+			g.Expect(exit.ExitCode).Should(gomega.Equal(7))
+			break
+		}
+	}
+}
+
+func TestSandboxEntrypoint(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	app, err := modal.AppLookup(context.Background(), "libmodal-test", &modal.LookupOptions{CreateIfMissing: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	image, err := app.ImageFromRegistry("alpine:3.21", nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	sb, err := app.CreateSandbox(image, &modal.SandboxOptions{
+		Entrypoint: []string{"sh", "-c", `echo "$0 $1"`},
+		Command:    []string{"hello", "entrypoint"},
+	})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	output, err := io.ReadAll(sb.Stdout)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(string(output)).Should(gomega.Equal("hello entrypoint\n"))
+}
+
+func TestAppListSandboxes(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	ctx := context.Background()
+	app, err := modal.AppLookup(ctx, "libmodal-test", &modal.LookupOptions{CreateIfMissing: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	image, err := app.ImageFromRegistry("alpine:3.21", nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	sb, err := app.CreateSandbox(image, nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer sb.Terminate()
+
+	found := false
+	for info, err := range app.ListSandboxes(nil) {
+		g.Expect(err).ShouldNot(gomega.HaveOccurred())
+		if info.SandboxId == sb.SandboxId {
+			g.Expect(info.ExitCode).Should(gomega.BeNil())
+			found = true
+			break
+		}
+	}
+	g.Expect(found).Should(gomega.BeTrue())
+
+	found = false
+	for info, err := range modal.AppList(ctx, nil) {
+		g.Expect(err).ShouldNot(gomega.HaveOccurred())
+		if info.AppId == app.AppId {
+			found = true
+			break
+		}
+	}
+	g.Expect(found).Should(gomega.BeTrue())
+}
+
+func TestSandboxTags(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	app, err := modal.AppLookup(context.Background(), "libmodal-test", &modal.LookupOptions{CreateIfMissing: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	image, err := app.ImageFromRegistry("alpine:3.21", nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	run := fmt.Sprint(time.Now().UnixNano())
+	sb, err := app.CreateSandbox(image, &modal.SandboxOptions{Tags: map[string]string{"test-run": run}})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer sb.Terminate()
+
+	var ids []string
+	for info, err := range app.ListSandboxes(&modal.SandboxListOptions{Tags: map[string]string{"test-run": run}}) {
+		g.Expect(err).ShouldNot(gomega.HaveOccurred())
+		g.Expect(info.Tags).To(gomega.HaveKeyWithValue("test-run", run))
+		ids = append(ids, info.SandboxId)
+	}
+	g.Expect(ids).To(gomega.Equal([]string{sb.SandboxId}))
+
+	err = sb.SetTags(map[string]string{"test-run": run + "-updated"})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	for range app.ListSandboxes(&modal.SandboxListOptions{Tags: map[string]string{"test-run": run}}) {
+		t.Fatal("expected no Sandbox with the previous tag")
+	}
+}
+
+func TestSandboxSSHServerWithoutSshd(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	app, err := modal.AppLookup(context.Background(), "libmodal-test", &modal.LookupOptions{CreateIfMissing: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	image, err := app.ImageFromRegistry("alpine:3.21", nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	sb, err := app.CreateSandbox(image, &modal.SandboxOptions{UnencryptedPorts: []int{22}})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer sb.Terminate()
+
+	_, err = sb.SSHServer(&modal.SSHServerOptions{AuthorizedKeys: []string{"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI test"}})
+	g.Expect(err).To(gomega.BeAssignableToTypeOf(modal.InvalidError{}))
+	g.Expect(err.Error()).To(gomega.ContainSubstring("sshd not found"))
+}
+
+func TestSandboxUsageSummary(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	app, err := modal.AppLookup(context.Background(), "libmodal-test", &modal.LookupOptions{CreateIfMissing: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	image, err := app.ImageFromRegistry("alpine:3.21", nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	sb, err := app.CreateSandbox(image, &modal.SandboxOptions{Command: []string{"sleep", "2"}})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	_, err = sb.Wait()
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	usage, err := sb.UsageSummary()
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(usage.CPUCoreSeconds).Should(gomega.BeNumerically(">", 0))
+	g.Expect(usage.GPUType).Should(gomega.BeEmpty())
+
+	estimate, err := app.CostEstimate(time.Hour, modal.UsageRates{CPUCoreSecond: 1})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(estimate.Sandboxes).Should(gomega.BeNumerically(">=", 1))
+	g.Expect(estimate.Cost).Should(gomega.BeNumerically(">=", usage.CPUCoreSeconds))
+}
+
+func TestSandboxAttachExec(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	app, err := modal.AppLookup(context.Background(), "libmodal-test", &modal.LookupOptions{CreateIfMissing: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	image, err := app.ImageFromRegistry("alpine:3.21", nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	sb, err := app.CreateSandbox(image, nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer sb.Terminate()
+
+	p, err := sb.Exec([]string{"sh", "-c", "echo first; sleep 2; echo second; exit 3"}, modal.ExecOptions{})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(p.ExecId).ShouldNot(gomega.BeEmpty())
+
+	attached, err := sb.AttachExec(p.ExecId, modal.ExecOptions{})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	output, err := io.ReadAll(attached.Stdout)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(string(output)).Should(gomega.Equal("first\nsecond\n"))
+	exitCode, err := attached.Wait()
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(exitCode).Should(gomega.Equal(3))
+
+	_, err = attached.Stdin.Write([]byte("input"))
+	g.Expect(err).Should(gomega.BeAssignableToTypeOf(modal.InvalidError{}))
+
+	_, err = sb.AttachExec("ce-missing", modal.ExecOptions{})
+	g.Expect(err).Should(gomega.HaveOccurred())
+}
+
+func TestSandboxRPC(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	app, err := modal.AppLookup(context.Background(), "libmodal-test", &modal.LookupOptions{CreateIfMissing: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	image, err := app.ImageFromRegistry("alpine:3.21", nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	sb, err := app.CreateSandbox(image, nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer sb.Terminate()
+
+	// An echo server answers each request with itself.
+	rpc, err := sb.StartRPC([]string{"sh", "-c", "while read -r line; do echo \"$line\"; done"}, &modal.SandboxRPCOptions{Timeout: time.Minute})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	var resp struct {
+		Method string `json:"method"`
+	}
+	g.Expect(rpc.Call(map[string]string{"method": "ping"}, &resp)).Should(gomega.Succeed())
+	g.Expect(resp.Method).Should(gomega.Equal("ping"))
+	g.Expect(rpc.Close()).Should(gomega.Succeed())
+}
+
+func TestSandboxLifecycleHooks(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	app, err := modal.AppLookup(context.Background(), "libmodal-test", &modal.LookupOptions{CreateIfMissing: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	image, err := app.ImageFromRegistry("alpine:3.21", nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	sb, err := app.CreateSandbox(image, &modal.SandboxOptions{Command: []string{"sh", "-c", "sleep 1; exit 3"}})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	started := make(chan modal.SandboxStartEvent, 1)
+	exited := make(chan modal.SandboxExitEvent, 1)
+	sb.OnStart(func(e modal.SandboxStartEvent) { started <- e })
+	sb.OnExit(func(e modal.SandboxExitEvent) { exited <- e })
+
+	g.Eventually(started, 2*time.Minute).Should(gomega.Receive())
+	var exit modal.SandboxExitEvent
+	g.Eventually(exited, 2*time.Minute).Should(gomega.Receive(&exit))
+	g.Expect(exit.ExitCode).To(gomega.Equal(3))
+	g.Expect(exit.OOM).To(gomega.BeFalse())
+}
+
+func TestSandboxFailureArtifacts(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	app, err := modal.AppLookup(context.Background(), "libmodal-test", &modal.LookupOptions{CreateIfMissing: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	image, err := app.ImageFromRegistry("alpine:3.21", nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	sb, err := app.CreateSandbox(image, &modal.SandboxOptions{Command: []string{"sh", "-c", "sleep 10; echo boom >&2; exit 1"}})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	_, err = sb.FailureArtifacts()
+	g.Expect(err).Should(gomega.BeAssignableToTypeOf(modal.InvalidError{}))
+
+	exitCode, err := sb.Wait()
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(exitCode).To(gomega.Equal(1))
+	artifacts, err := sb.FailureArtifacts()
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(artifacts).To(gomega.ContainElement(modal.FailureArtifact{Name: modal.ArtifactStderrLog, Data: []byte("boom\n")}))
+}
+
+func TestPipeStreamBetweenSandboxes(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	app, err := modal.AppLookup(context.Background(), "libmodal-test", &modal.LookupOptions{CreateIfMissing: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	image, err := app.ImageFromRegistry("alpine:3.21", nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	producerSb, err := app.CreateSandbox(image, nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer producerSb.Terminate()
+	consumerSb, err := app.CreateSandbox(image, nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer consumerSb.Terminate()
+
+	producer, err := producerSb.Exec([]string{"seq", "1", "100000"}, modal.ExecOptions{})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	consumer, err := consumerSb.Exec([]string{"wc", "-l"}, modal.ExecOptions{})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	n, err := modal.PipeStream(consumer.Stdin, producer.Stdout, nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(n).Should(gomega.BeNumerically(">", 100000))
+
+	output, err := io.ReadAll(consumer.Stdout)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(strings.TrimSpace(string(output))).Should(gomega.Equal("100000"))
+}
+
+func TestDetachAndAdoptSandbox(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	app, err := modal.AppLookup(context.Background(), "libmodal-test", &modal.LookupOptions{CreateIfMissing: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	image, err := app.ImageFromRegistry("alpine:3.21", nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	// The Sandbox outlives the context it was created with.
+	ctx, cancel := context.WithCancel(context.Background())
+	scoped, err := app.WithContext(ctx)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	sb, err := scoped.CreateSandbox(image, &modal.SandboxOptions{
+		Command: []string{"sh", "-c", "echo started; sleep 60"},
+		Timeout: 2 * time.Minute,
+		Detach:  true,
+	})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	cancel()
+
+	adopted, err := modal.AdoptSandbox(context.Background(), sb.SandboxId)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer adopted.Terminate()
+	exitCode, err := adopted.Poll()
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(exitCode).To(gomega.BeNil())
+
+	line := make([]byte, len("started\n"))
+	_, err = io.ReadFull(adopted.Stdout, line)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(string(line)).To(gomega.Equal("started\n"))
+}
+
+func TestExecCleanEnv(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	app, err := modal.AppLookup(context.Background(), "libmodal-test", &modal.LookupOptions{CreateIfMissing: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	image, err := app.ImageFromRegistry("alpine:3.21", nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	sb, err := app.CreateSandbox(image, &modal.SandboxOptions{EnvVars: map[string]string{"SANDBOX_TOKEN": "secret"}})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer sb.Terminate()
+
+	result, err := sb.Run([]string{"sh", "-c", "echo ${SANDBOX_TOKEN:-unset} $PLUGIN_MODE"}, modal.ExecOptions{})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(string(result.Stdout)).To(gomega.Equal("secret \n"))
+
+	result, err = sb.Run([]string{"sh", "-c", "echo ${SANDBOX_TOKEN:-unset} $PLUGIN_MODE"}, modal.ExecOptions{
+		CleanEnv: true,
+		EnvVars:  map[string]string{"PLUGIN_MODE": "strict"},
+	})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(string(result.Stdout)).To(gomega.Equal("unset strict\n"))
+}
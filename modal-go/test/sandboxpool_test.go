@@ -0,0 +1,58 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/modal-labs/libmodal/modal-go"
+	"github.com/modal-labs/libmodal/modal-go/sandboxpool"
+	"github.com/onsi/gomega"
+)
+
+func TestSandboxPool(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	app, err := modal.AppLookup(context.Background(), "libmodal-test", &modal.LookupOptions{CreateIfMissing: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	image, err := app.ImageFromRegistry("alpine:3.21", nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	pool, err := sandboxpool.New(app, image, &sandboxpool.Options{
+		Min:            1,
+		Max:            2,
+		SandboxOptions: &modal.SandboxOptions{Command: []string{"sleep", "infinity"}},
+	})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer pool.Close()
+
+	sb1, err := pool.Acquire(context.Background())
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	sb2, err := pool.Acquire(context.Background())
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(sb2.SandboxId).ShouldNot(gomega.Equal(sb1.SandboxId))
+
+	// The pool is at Max, so a third Acquire blocks until the context is done.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err = pool.Acquire(ctx)
+	g.Expect(err).Should(gomega.MatchError(context.DeadlineExceeded))
+
+	// A released Sandbox is handed out again.
+	g.Expect(pool.Release(sb1)).To(gomega.Succeed())
+	sb3, err := pool.Acquire(context.Background())
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(sb3.SandboxId).Should(gomega.Equal(sb1.SandboxId))
+
+	// A terminated Sandbox is replaced rather than reused.
+	g.Expect(sb2.Terminate()).ShouldNot(gomega.HaveOccurred())
+	g.Expect(pool.Release(sb2)).To(gomega.Succeed())
+	sb4, err := pool.Acquire(context.Background())
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(sb4.SandboxId).ShouldNot(gomega.Equal(sb2.SandboxId))
+
+	g.Expect(pool.Release(sb3)).To(gomega.Succeed())
+	g.Expect(pool.Release(sb4)).To(gomega.Succeed())
+}
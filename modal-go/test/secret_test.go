@@ -2,6 +2,7 @@ package test
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/modal-labs/libmodal/modal-go"
@@ -32,4 +33,27 @@ func TestSecretFromNameWithRequiredKeys(t *testing.T) {
 		RequiredKeys: []string{"a", "b", "c", "missing-key"},
 	})
 	g.Expect(err).Should(gomega.MatchError(gomega.ContainSubstring("Secret is missing key(s): missing-key")))
+
+	var missingKeysErr modal.SecretMissingKeysError
+	g.Expect(errors.As(err, &missingKeysErr)).To(gomega.BeTrue())
+	g.Expect(missingKeysErr.MissingKeys).To(gomega.Equal([]string{"missing-key"}))
+}
+
+func TestSecretInfo(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	secret, err := modal.SecretFromName(context.Background(), "libmodal-test-secret", nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	info, err := secret.Info()
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(info.SecretId).To(gomega.Equal(secret.SecretId))
+	g.Expect(info.Name).To(gomega.Equal("libmodal-test-secret"))
+	g.Expect(info.CreatedAt.IsZero()).To(gomega.BeFalse())
+
+	ephemeral, err := modal.SecretFromMap(context.Background(), map[string]string{"key": "value"}, nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	_, err = ephemeral.Info()
+	var notFound modal.NotFoundError
+	g.Expect(errors.As(err, &notFound)).To(gomega.BeTrue())
 }
@@ -1,8 +1,13 @@
 package test
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/modal-labs/libmodal/modal-go"
 	"github.com/onsi/gomega"
@@ -22,3 +27,235 @@ func TestVolumeFromName(t *testing.T) {
 	_, err = modal.VolumeFromName(context.Background(), "missing-volume", nil)
 	g.Expect(err).Should(gomega.MatchError(gomega.ContainSubstring("Volume 'missing-volume' not found")))
 }
+
+func TestVolumeRenameAndDelete(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	ctx := context.Background()
+
+	name := fmt.Sprintf("libmodal-test-rename-%d", time.Now().UnixNano())
+	volume, err := modal.VolumeFromName(ctx, name, &modal.VolumeFromNameOptions{CreateIfMissing: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	newName := name + "-renamed"
+	g.Expect(volume.Rename(newName)).ShouldNot(gomega.HaveOccurred())
+
+	renamed, err := modal.VolumeFromName(ctx, newName, nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(renamed.VolumeId).Should(gomega.Equal(volume.VolumeId))
+
+	g.Expect(modal.VolumeDelete(ctx, newName, nil)).ShouldNot(gomega.HaveOccurred())
+	_, err = modal.VolumeFromName(ctx, newName, nil)
+	g.Expect(err).Should(gomega.MatchError(gomega.ContainSubstring("not found")))
+}
+
+func TestVolumeTempDir(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	ctx := context.Background()
+
+	name := fmt.Sprintf("libmodal-test-tempdir-%d", time.Now().UnixNano())
+	volume, err := modal.VolumeFromName(ctx, name, &modal.VolumeFromNameOptions{CreateIfMissing: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer modal.VolumeDelete(ctx, name, nil)
+
+	dir1, err := volume.TempDir("scratch", &modal.VolumeTempDirOptions{TTL: time.Minute})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(dir1).Should(gomega.HavePrefix("/.modal-tmp/scratch-"))
+
+	dir2, err := volume.TempDir("scratch", nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(dir2).ShouldNot(gomega.Equal(dir1))
+
+	_, err = volume.TempDir("a/b", nil)
+	g.Expect(err).Should(gomega.BeAssignableToTypeOf(modal.InvalidError{}))
+}
+
+func TestVolumeStat(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	ctx := context.Background()
+
+	name := fmt.Sprintf("libmodal-test-stat-%d", time.Now().UnixNano())
+	volume, err := modal.VolumeFromName(ctx, name, &modal.VolumeFromNameOptions{CreateIfMissing: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer modal.VolumeDelete(ctx, name, nil)
+
+	// Ensures the Volume has at least one file.
+	_, err = volume.TempDir("stat", nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	stat, err := volume.Stat()
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(stat.Files).Should(gomega.BeNumerically(">=", 1))
+	g.Expect(stat.Directories).Should(gomega.BeNumerically(">=", 1))
+	g.Expect(stat.Size).Should(gomega.BeNumerically(">", 0))
+	g.Expect(stat.LastModified).ShouldNot(gomega.BeZero())
+}
+
+func TestVolumeUpload(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	ctx := context.Background()
+
+	local := t.TempDir()
+	g.Expect(os.MkdirAll(filepath.Join(local, "bin"), 0o755)).To(gomega.Succeed())
+	g.Expect(os.WriteFile(filepath.Join(local, "bin", "run.sh"), []byte("#!/bin/sh\necho ok\n"), 0o755)).To(gomega.Succeed())
+	g.Expect(os.WriteFile(filepath.Join(local, "notes.txt"), []byte("hello"), 0o644)).To(gomega.Succeed())
+	g.Expect(os.WriteFile(filepath.Join(local, "cache.pyc"), []byte("skip"), 0o644)).To(gomega.Succeed())
+	g.Expect(os.Symlink("notes.txt", filepath.Join(local, "link.txt"))).To(gomega.Succeed())
+
+	name := fmt.Sprintf("libmodal-test-upload-%d", time.Now().UnixNano())
+	volume, err := modal.VolumeFromName(ctx, name, &modal.VolumeFromNameOptions{CreateIfMissing: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer modal.VolumeDelete(ctx, name, nil)
+
+	remote := "/upload"
+	err = volume.Upload(local, remote, &modal.UploadOptions{PreserveMode: true, Exclude: []string{"*.pyc"}})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	app, err := modal.AppLookup(ctx, "libmodal-test", &modal.LookupOptions{CreateIfMissing: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	image, err := app.ImageFromRegistry("alpine:3.21", nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	sb, err := app.CreateSandbox(image, &modal.SandboxOptions{Volumes: map[string]*modal.Volume{"/vol": volume}})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer sb.Terminate()
+
+	result, err := sb.Run([]string{"sh", "-c", "cd /vol" + remote + " && find . -type f | sort && ./bin/run.sh"}, modal.ExecOptions{})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(result.ExitCode).To(gomega.Equal(0))
+	g.Expect(string(result.Stdout)).To(gomega.Equal("./bin/run.sh\n./notes.txt\nok\n"))
+}
+
+func TestCopyVolume(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	ctx := context.Background()
+
+	name := fmt.Sprintf("libmodal-test-copy-%d", time.Now().UnixNano())
+	src, err := modal.VolumeFromName(ctx, name+"-src", &modal.VolumeFromNameOptions{CreateIfMissing: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer modal.VolumeDelete(ctx, name+"-src", nil)
+	dst, err := modal.VolumeFromName(ctx, name+"-dst", &modal.VolumeFromNameOptions{CreateIfMissing: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer modal.VolumeDelete(ctx, name+"-dst", nil)
+
+	local := t.TempDir()
+	g.Expect(os.MkdirAll(filepath.Join(local, "dir"), 0o755)).To(gomega.Succeed())
+	g.Expect(os.WriteFile(filepath.Join(local, "a.txt"), []byte("hello"), 0o644)).To(gomega.Succeed())
+	g.Expect(os.WriteFile(filepath.Join(local, "dir", "b.txt"), []byte("world!"), 0o644)).To(gomega.Succeed())
+	g.Expect(src.Upload(local, "/", nil)).Should(gomega.Succeed())
+
+	g.Expect(modal.CopyVolume(ctx, src, dst)).Should(gomega.Succeed())
+
+	stat, err := dst.Stat()
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(stat.Files).Should(gomega.Equal(2))
+	g.Expect(stat.Size).Should(gomega.Equal(int64(11)))
+
+	err = modal.CopyVolume(ctx, src, src)
+	g.Expect(err).Should(gomega.BeAssignableToTypeOf(modal.InvalidError{}))
+}
+
+func TestVolumeDownloadFile(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	ctx := context.Background()
+
+	content := []byte("0123456789abcdefghij")
+	local := filepath.Join(t.TempDir(), "weights.bin")
+	g.Expect(os.WriteFile(local, content, 0o644)).To(gomega.Succeed())
+
+	name := fmt.Sprintf("libmodal-test-download-%d", time.Now().UnixNano())
+	volume, err := modal.VolumeFromName(ctx, name, &modal.VolumeFromNameOptions{CreateIfMissing: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer modal.VolumeDelete(ctx, name, nil)
+	g.Expect(volume.Upload(local, "/weights.bin", nil)).To(gomega.Succeed())
+
+	data, err := volume.ReadFileRange("/weights.bin", 5, 4)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(string(data)).To(gomega.Equal("5678"))
+
+	data, err = volume.ReadFileRange("/weights.bin", 18, 10)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(string(data)).To(gomega.Equal("ij"))
+
+	var buf bytes.Buffer
+	n, err := volume.DownloadFile("/weights.bin", &buf, &modal.DownloadOptions{ChunkSize: 3})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(n).To(gomega.Equal(int64(len(content))))
+	g.Expect(buf.Bytes()).To(gomega.Equal(content))
+
+	// Resume a partial download.
+	buf.Reset()
+	_, err = volume.DownloadFile("/weights.bin", &buf, &modal.DownloadOptions{Offset: 12, ChunkSize: 5})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(buf.String()).To(gomega.Equal("cdefghij"))
+}
+
+func TestVolumeListAndListFiles(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	ctx := context.Background()
+
+	local := t.TempDir()
+	g.Expect(os.MkdirAll(filepath.Join(local, "dir"), 0o755)).To(gomega.Succeed())
+	g.Expect(os.WriteFile(filepath.Join(local, "a.txt"), []byte("hello"), 0o644)).To(gomega.Succeed())
+	g.Expect(os.WriteFile(filepath.Join(local, "dir", "b.txt"), []byte("world!"), 0o644)).To(gomega.Succeed())
+
+	name := fmt.Sprintf("libmodal-test-list-%d", time.Now().UnixNano())
+	volume, err := modal.VolumeFromName(ctx, name, &modal.VolumeFromNameOptions{CreateIfMissing: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer modal.VolumeDelete(ctx, name, nil)
+	g.Expect(volume.Upload(local, "/", nil)).To(gomega.Succeed())
+
+	found := false
+	for info, err := range modal.VolumeList(ctx, nil) {
+		g.Expect(err).ShouldNot(gomega.HaveOccurred())
+		if info.Name == name {
+			g.Expect(info.VolumeId).Should(gomega.Equal(volume.VolumeId))
+			found = true
+			break
+		}
+	}
+	g.Expect(found).Should(gomega.BeTrue())
+
+	sizes := map[string]uint64{}
+	for entry, err := range volume.ListFiles("/", &modal.ListFilesOptions{Recursive: true}) {
+		g.Expect(err).ShouldNot(gomega.HaveOccurred())
+		if entry.Type == "file" {
+			sizes[entry.Path] = entry.Size
+		}
+	}
+	g.Expect(sizes).Should(gomega.Equal(map[string]uint64{"a.txt": 5, "dir/b.txt": 6}))
+}
+
+func TestVolumeBatchUploadAndRemove(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	ctx := context.Background()
+
+	local := t.TempDir()
+	g.Expect(os.WriteFile(filepath.Join(local, "a.txt"), []byte("hello"), 0o644)).To(gomega.Succeed())
+	g.Expect(os.WriteFile(filepath.Join(local, "cache.pyc"), []byte("skip"), 0o644)).To(gomega.Succeed())
+
+	name := fmt.Sprintf("libmodal-test-batch-%d", time.Now().UnixNano())
+	volume, err := modal.VolumeFromName(ctx, name, &modal.VolumeFromNameOptions{CreateIfMissing: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer modal.VolumeDelete(ctx, name, nil)
+
+	results, err := volume.BatchUpload(local, "/", &modal.UploadOptions{Exclude: []string{"*.pyc"}})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(results).To(gomega.Equal([]modal.FileResult{
+		{Path: "/a.txt", Status: modal.FileSucceeded},
+		{Path: "/cache.pyc", Status: modal.FileSkipped, Reason: "excluded"},
+	}))
+
+	results, err = volume.BatchRemove([]string{"/a.txt", "/missing.txt"}, &modal.BatchRemoveOptions{ContinueOnError: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(results).To(gomega.Equal([]modal.FileResult{
+		{Path: "/a.txt", Status: modal.FileSucceeded},
+		{Path: "/missing.txt", Status: modal.FileSkipped, Reason: "not found"},
+	}))
+}
@@ -0,0 +1,54 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/modal-labs/libmodal/modal-go"
+	"github.com/modal-labs/libmodal/modal-go/volumesync"
+	"github.com/onsi/gomega"
+)
+
+func TestVolumeSync(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+	ctx := context.Background()
+
+	name := fmt.Sprintf("libmodal-test-sync-%d", time.Now().UnixNano())
+	volume, err := modal.VolumeFromName(ctx, name, &modal.VolumeFromNameOptions{CreateIfMissing: true})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	defer modal.VolumeDelete(ctx, name, nil)
+
+	local := t.TempDir()
+	g.Expect(os.WriteFile(filepath.Join(local, "main.py"), []byte("print(1)"), 0o644)).To(gomega.Succeed())
+	g.Expect(os.WriteFile(filepath.Join(local, "main.pyc"), []byte("skip"), 0o644)).To(gomega.Succeed())
+
+	s := volumesync.New(volume, local, &volumesync.Options{Remote: "/src", Pull: true, Exclude: []string{"*.pyc"}})
+	changes, err := s.SyncOnce()
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(changes).To(gomega.Equal([]volumesync.Change{{Path: "main.py", Op: volumesync.Uploaded}}))
+
+	changes, err = s.SyncOnce()
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(changes).To(gomega.BeEmpty())
+
+	// Changes made in the Volume are pulled back.
+	other := filepath.Join(t.TempDir(), "util.py")
+	g.Expect(os.WriteFile(other, []byte("x = 2"), 0o644)).To(gomega.Succeed())
+	g.Expect(volume.Upload(other, "/src/util.py", nil)).To(gomega.Succeed())
+	g.Expect(os.Remove(filepath.Join(local, "main.py"))).To(gomega.Succeed())
+
+	changes, err = s.SyncOnce()
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(changes).To(gomega.Equal([]volumesync.Change{
+		{Path: "main.py", Op: volumesync.RemovedRemote},
+		{Path: "util.py", Op: volumesync.Downloaded},
+	}))
+	data, err := os.ReadFile(filepath.Join(local, "util.py"))
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(string(data)).To(gomega.Equal("x = 2"))
+}
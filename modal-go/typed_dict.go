@@ -0,0 +1,98 @@
+package modal
+
+// Type-safe wrapper around Dict.
+
+import (
+	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+)
+
+// TypedDict wraps a Dict so that values are of type T, encoded and decoded
+// with a Codec. Keys are pickled as with Dict.
+type TypedDict[T any] struct {
+	Dict  *Dict
+	codec Codec[T]
+}
+
+// NewTypedDict returns a TypedDict backed by d. If codec is nil, values are
+// stored with PickleCodec.
+func NewTypedDict[T any](d *Dict, codec Codec[T]) *TypedDict[T] {
+	if codec == nil {
+		codec = PickleCodec[T]()
+	}
+	return &TypedDict[T]{Dict: d, codec: codec}
+}
+
+func (td *TypedDict[T]) decode(raw []byte, found bool, err error) (T, bool, error) {
+	var zero T
+	if err != nil || !found {
+		return zero, false, err
+	}
+	v, err := td.codec.Decode(raw)
+	if err != nil {
+		return zero, false, err
+	}
+	return v, true, nil
+}
+
+// Get returns the value for a key, see Dict.Get.
+func (td *TypedDict[T]) Get(key any) (T, bool, error) {
+	return td.decode(td.Dict.getRaw(key))
+}
+
+// Put sets the value for a key, see Dict.Put.
+func (td *TypedDict[T]) Put(key any, value T, options *DictPutOptions) (bool, error) {
+	if options == nil {
+		options = &DictPutOptions{}
+	}
+	k, err := serializeDictKey(key)
+	if err != nil {
+		return false, err
+	}
+	v, err := td.codec.Encode(value)
+	if err != nil {
+		return false, err
+	}
+	created, err := td.Dict.putRaw([]*pb.DictEntry{pb.DictEntry_builder{Key: k, Value: v}.Build()}, options.SkipIfExists)
+	if err != nil {
+		return false, err
+	}
+	return created || !options.SkipIfExists, nil
+}
+
+// Update sets the values for several keys at once, see Dict.Update.
+func (td *TypedDict[T]) Update(values map[any]T) error {
+	entries := make([]*pb.DictEntry, 0, len(values))
+	for key, value := range values {
+		k, err := serializeDictKey(key)
+		if err != nil {
+			return err
+		}
+		v, err := td.codec.Encode(value)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, pb.DictEntry_builder{Key: k, Value: v}.Build())
+	}
+	_, err := td.Dict.putRaw(entries, false)
+	return err
+}
+
+// Pop removes a key and returns its value, see Dict.Pop.
+func (td *TypedDict[T]) Pop(key any) (T, bool, error) {
+	return td.decode(td.Dict.popRaw(key))
+}
+
+// Contains returns whether a key is present, see Dict.Contains.
+func (td *TypedDict[T]) Contains(key any) (bool, error) {
+	return td.Dict.Contains(key)
+}
+
+// Len returns the number of keys, see Dict.Len.
+func (td *TypedDict[T]) Len() (int, error) {
+	return td.Dict.Len()
+}
+
+// Clear removes all keys, see Dict.Clear.
+func (td *TypedDict[T]) Clear() error {
+	return td.Dict.Clear()
+}
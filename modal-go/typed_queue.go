@@ -0,0 +1,103 @@
+package modal
+
+// Type-safe wrapper around Queue.
+
+import (
+	"iter"
+)
+
+// TypedQueue wraps a Queue so that items are values of type T, encoded and
+// decoded with a Codec.
+type TypedQueue[T any] struct {
+	Queue *Queue
+	codec Codec[T]
+}
+
+// NewTypedQueue returns a TypedQueue backed by q. If codec is nil, items are
+// stored with PickleCodec.
+func NewTypedQueue[T any](q *Queue, codec Codec[T]) *TypedQueue[T] {
+	if codec == nil {
+		codec = PickleCodec[T]()
+	}
+	return &TypedQueue[T]{Queue: q, codec: codec}
+}
+
+func (tq *TypedQueue[T]) decodeAll(raws [][]byte) ([]T, error) {
+	out := make([]T, len(raws))
+	for i, raw := range raws {
+		v, err := tq.codec.Decode(raw)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+// Get removes and returns one item, see Queue.Get.
+func (tq *TypedQueue[T]) Get(options *QueueGetOptions) (T, error) {
+	var zero T
+	raws, err := tq.Queue.getRaw(1, options)
+	if err != nil {
+		return zero, err
+	}
+	return tq.codec.Decode(raws[0]) // guaranteed len>=1
+}
+
+// GetMany removes up to n items, see Queue.GetMany.
+func (tq *TypedQueue[T]) GetMany(n int, options *QueueGetOptions) ([]T, error) {
+	raws, err := tq.Queue.getRaw(n, options)
+	if err != nil {
+		return nil, err
+	}
+	return tq.decodeAll(raws)
+}
+
+// Put adds a single item to the end of the queue, see Queue.Put.
+func (tq *TypedQueue[T]) Put(v T, options *QueuePutOptions) error {
+	return tq.PutMany([]T{v}, options)
+}
+
+// PutMany adds multiple items to the end of the queue, see Queue.PutMany.
+func (tq *TypedQueue[T]) PutMany(values []T, options *QueuePutOptions) error {
+	valuesEncoded := make([][]byte, len(values))
+	for i, v := range values {
+		b, err := tq.codec.Encode(v)
+		if err != nil {
+			return err
+		}
+		valuesEncoded[i] = b
+	}
+	return tq.Queue.putRaw(valuesEncoded, options)
+}
+
+// Len returns the number of objects in the queue, see Queue.Len.
+func (tq *TypedQueue[T]) Len(options *QueueLenOptions) (int, error) {
+	return tq.Queue.Len(options)
+}
+
+// Clear removes all objects from a queue partition, see Queue.Clear.
+func (tq *TypedQueue[T]) Clear(options *QueueClearOptions) error {
+	return tq.Queue.Clear(options)
+}
+
+// Iterate yields items from the queue until it is empty, see Queue.Iterate.
+func (tq *TypedQueue[T]) Iterate(options *QueueIterateOptions) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		for raw, err := range tq.Queue.iterateRaw(options) {
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			v, err := tq.codec.Decode(raw)
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			if !yield(v, nil) {
+				return
+			}
+		}
+	}
+}
@@ -3,6 +3,8 @@ package modal
 import (
 	"context"
 	"fmt"
+	"iter"
+	"time"
 
 	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
 	"google.golang.org/grpc/codes"
@@ -13,8 +15,47 @@ import (
 type Volume struct {
 	VolumeId string
 
-	//lint:ignore U1000 may be used in future
-	ctx context.Context
+	ctx         context.Context
+	name        string
+	environment string
+}
+
+// ObjectId returns the Volume's ID, see Object.
+func (v *Volume) ObjectId() string {
+	return v.VolumeId
+}
+
+// WithContext returns a copy of the Volume whose calls use ctx, for example to
+// apply a request's deadline and cancellation.
+func (v *Volume) WithContext(ctx context.Context) (*Volume, error) {
+	ctx, err := handleContext(ctx, v.ctx)
+	if err != nil {
+		return nil, err
+	}
+	c := *v
+	c.ctx = ctx
+	return &c, nil
+}
+
+// Name returns the name the Volume was looked up by.
+func (v *Volume) Name() string {
+	return v.name
+}
+
+// Environment returns the name of the environment the Volume is in, see
+// Object.
+func (v *Volume) Environment() string {
+	return firstNonEmpty(v.environment, environmentName(v.ctx, ""))
+}
+
+// IsHydrated reports whether the Volume has an ID, see Object.
+func (v *Volume) IsHydrated() bool {
+	return v.VolumeId != ""
+}
+
+// Hydrate makes the Volume ready for use, see Object.
+func (v *Volume) Hydrate(ctx context.Context) error {
+	return hydrate(ctx, "Volume", v.VolumeId, &v.ctx)
 }
 
 // VolumeFromNameOptions are options for finding Modal volumes.
@@ -34,6 +75,11 @@ func VolumeFromName(ctx context.Context, name string, options *VolumeFromNameOpt
 	if options == nil {
 		options = &VolumeFromNameOptions{}
 	}
+	var environment string
+	name, environment, err = resolveObjectRef(name, "Volume", options.Environment)
+	if err != nil {
+		return nil, err
+	}
 
 	creationType := pb.ObjectCreationType_OBJECT_CREATION_TYPE_UNSPECIFIED
 	if options.CreateIfMissing {
@@ -42,7 +88,7 @@ func VolumeFromName(ctx context.Context, name string, options *VolumeFromNameOpt
 
 	resp, err := client.VolumeGetOrCreate(ctx, pb.VolumeGetOrCreateRequest_builder{
 		DeploymentName:     name,
-		EnvironmentName:    environmentName(options.Environment),
+		EnvironmentName:    environmentName(ctx, environment),
 		ObjectCreationType: creationType,
 	}.Build())
 
@@ -53,5 +99,155 @@ func VolumeFromName(ctx context.Context, name string, options *VolumeFromNameOpt
 		return nil, err
 	}
 
-	return &Volume{VolumeId: resp.GetVolumeId(), ctx: ctx}, nil
+	return &Volume{
+		VolumeId:    resp.GetVolumeId(),
+		ctx:         context.WithoutCancel(ctx),
+		name:        name,
+		environment: environmentName(ctx, environment),
+	}, nil
+}
+
+// VolumeInfo describes a Volume in a listing, see VolumeList.
+type VolumeInfo struct {
+	VolumeId  string
+	Name      string
+	CreatedAt time.Time
+}
+
+// VolumeList returns an iterator over the named Volumes in an environment.
+// The listing is fetched when iteration begins.
+func VolumeList(ctx context.Context, options *ListOptions) iter.Seq2[VolumeInfo, error] {
+	if options == nil {
+		options = &ListOptions{}
+	}
+	return func(yield func(VolumeInfo, error) bool) {
+		ctx, err := clientContext(ctx)
+		if err != nil {
+			yield(VolumeInfo{}, err)
+			return
+		}
+		resp, err := client.VolumeList(ctx, pb.VolumeListRequest_builder{
+			EnvironmentName: environmentName(ctx, options.Environment),
+		}.Build())
+		if err != nil {
+			yield(VolumeInfo{}, err)
+			return
+		}
+		for _, item := range resp.GetItems() {
+			info := VolumeInfo{
+				VolumeId:  item.GetVolumeId(),
+				Name:      item.GetLabel(),
+				CreatedAt: time.Unix(0, int64(item.GetCreatedAt()*1e9)),
+			}
+			if !yield(info, nil) {
+				return
+			}
+		}
+	}
+}
+
+// VolumeDelete deletes a named Volume and all of its data.
+func VolumeDelete(ctx context.Context, name string, options *DeleteOptions) error {
+	if options == nil {
+		options = &DeleteOptions{}
+	}
+	v, err := VolumeFromName(ctx, name, &VolumeFromNameOptions{Environment: options.Environment})
+	if err != nil {
+		return err
+	}
+	_, err = client.VolumeDelete(v.ctx, pb.VolumeDeleteRequest_builder{
+		VolumeId: v.VolumeId,
+	}.Build())
+	return err
+}
+
+// Rename changes the name of the Volume. The Volume keeps its ID and data.
+func (v *Volume) Rename(newName string) error {
+	if err := validateObjectName(newName, "Volume"); err != nil {
+		return err
+	}
+	_, err := client.VolumeRename(v.ctx, pb.VolumeRenameRequest_builder{
+		VolumeId: v.VolumeId,
+		Name:     newName,
+	}.Build())
+	return err
+}
+
+// CopyVolume copies all files from the src Volume into the dst Volume,
+// overwriting files at the same paths.
+//
+// Files are transferred one at a time through the client, so only one file is
+// held in memory at once, and they are added to dst in batches as the copy
+// progresses. If the copy fails part way, the files of completed batches
+// remain in dst.
+func CopyVolume(ctx context.Context, src, dst *Volume) error {
+	if src.VolumeId == dst.VolumeId {
+		return InvalidError{"source and destination Volumes must be different"}
+	}
+	var err error
+	ctx, err = clientContext(ctx)
+	if err != nil {
+		return err
+	}
+	entries, err := src.listFiles(ctx, "/", true)
+	if err != nil {
+		return err
+	}
+
+	var files []*pb.MountFile
+	for _, entry := range entries {
+		if entry.GetType() != pb.FileEntry_FILE {
+			continue
+		}
+		data, err := src.readFile(ctx, entry.GetPath())
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", entry.GetPath(), err)
+		}
+		file, err := uploadVolumeFile(ctx, entry.GetPath(), data)
+		if err != nil {
+			return err
+		}
+		files = append(files, file)
+		if len(files) == volumePutFilesBatchSize {
+			if err := dst.putFiles(ctx, files); err != nil {
+				return err
+			}
+			files = files[:0]
+		}
+	}
+	return dst.putFiles(ctx, files)
+}
+
+// VolumeStat describes the contents of a Volume.
+type VolumeStat struct {
+	Files        int       // Number of files.
+	Directories  int       // Number of directories.
+	Size         int64     // Total size of all files, in bytes.
+	LastModified time.Time // Latest modification time of any entry, zero if empty.
+}
+
+// Stat returns the total size, entry counts, and last modification time of
+// the Volume. It is computed from a recursive listing of the Volume, so it may
+// take a while for Volumes with many files.
+func (v *Volume) Stat() (*VolumeStat, error) {
+	entries, err := v.listFiles(v.ctx, "/", true)
+	if err != nil {
+		return nil, err
+	}
+	stat := &VolumeStat{}
+	var lastModified uint64
+	for _, entry := range entries {
+		switch entry.GetType() {
+		case pb.FileEntry_FILE:
+			stat.Files++
+			stat.Size += int64(entry.GetSize())
+		case pb.FileEntry_DIRECTORY:
+			stat.Directories++
+		}
+		lastModified = max(lastModified, entry.GetMtime())
+	}
+	if lastModified > 0 {
+		stat.LastModified = time.Unix(int64(lastModified), 0)
+	}
+	return stat, nil
 }
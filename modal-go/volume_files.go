@@ -0,0 +1,560 @@
+package modal
+
+// Reading and writing files in Modal Volumes.
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"iter"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Default permissions for files written to a Volume.
+const volumeFileMode uint32 = 0o644
+
+// Maximum number of files added to a Volume per VolumePutFiles request.
+const volumePutFilesBatchSize = 1000
+
+// listFiles returns the entries under path in the Volume.
+func (v *Volume) listFiles(ctx context.Context, path string, recursive bool) ([]*pb.FileEntry, error) {
+	var entries []*pb.FileEntry
+	for entry, err := range v.fileEntries(ctx, path, recursive) {
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// fileEntries returns an iterator over the entries under path in the Volume,
+// receiving them from the server in batches as iteration proceeds.
+func (v *Volume) fileEntries(ctx context.Context, path string, recursive bool) iter.Seq2[*pb.FileEntry, error] {
+	return func(yield func(*pb.FileEntry, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		stream, err := client.VolumeListFiles(ctx, pb.VolumeListFilesRequest_builder{
+			VolumeId:  v.VolumeId,
+			Path:      path,
+			Recursive: recursive,
+		}.Build())
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		for {
+			batch, err := stream.Recv()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			for _, entry := range batch.GetEntries() {
+				if !yield(entry, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// FileEntry describes a file in a Volume, see Volume.ListFiles.
+type FileEntry struct {
+	Path    string    // Path relative to the root of the Volume.
+	Type    string    // Such as "file", "directory", or "symlink".
+	Size    uint64    // Size in bytes.
+	ModTime time.Time // Last modification time.
+}
+
+// ListFilesOptions are options for Volume.ListFiles.
+type ListFilesOptions struct {
+	Recursive bool // List the contents of subdirectories too.
+}
+
+// ListFiles returns an iterator over the files under path in the Volume. The
+// listing is received in batches as iteration proceeds, so it can be stopped
+// early without fetching every entry of a large Volume.
+func (v *Volume) ListFiles(path string, options *ListFilesOptions) iter.Seq2[FileEntry, error] {
+	if options == nil {
+		options = &ListFilesOptions{}
+	}
+	return func(yield func(FileEntry, error) bool) {
+		for entry, err := range v.fileEntries(v.ctx, path, options.Recursive) {
+			if err != nil {
+				yield(FileEntry{}, err)
+				return
+			}
+			file := FileEntry{
+				Path:    entry.GetPath(),
+				Type:    strings.ToLower(entry.GetType().String()),
+				Size:    entry.GetSize(),
+				ModTime: time.Unix(int64(entry.GetMtime()), 0),
+			}
+			if !yield(file, nil) {
+				return
+			}
+		}
+	}
+}
+
+// readFile returns the contents of a file in the Volume.
+func (v *Volume) readFile(ctx context.Context, path string) ([]byte, error) {
+	resp, err := client.VolumeGetFile(ctx, pb.VolumeGetFileRequest_builder{
+		VolumeId: v.VolumeId,
+		Path:     path,
+	}.Build())
+	if err != nil {
+		return nil, err
+	}
+	if resp.HasDataBlobId() {
+		return blobDownload(ctx, resp.GetDataBlobId())
+	}
+	return resp.GetData(), nil
+}
+
+// readFileRange returns up to length bytes of a file in the Volume starting
+// at offset, or the rest of the file if length is 0, and the file's size.
+func (v *Volume) readFileRange(ctx context.Context, path string, offset, length int64) ([]byte, int64, error) {
+	resp, err := client.VolumeGetFile(ctx, pb.VolumeGetFileRequest_builder{
+		VolumeId: v.VolumeId,
+		Path:     path,
+		Start:    uint64(offset),
+		Len:      uint64(length),
+	}.Build())
+	if err != nil {
+		return nil, 0, err
+	}
+	data := resp.GetData()
+	if resp.HasDataBlobId() {
+		if data, err = blobDownload(ctx, resp.GetDataBlobId()); err != nil {
+			return nil, 0, err
+		}
+	}
+	return data, int64(resp.GetSize()), nil
+}
+
+// ReadFileRange returns up to length bytes of the file at path in the Volume,
+// starting at offset. Fewer bytes are returned at the end of the file.
+func (v *Volume) ReadFileRange(path string, offset, length int64) ([]byte, error) {
+	if offset < 0 || length <= 0 {
+		return nil, InvalidError{fmt.Sprintf("invalid range: offset %d, length %d", offset, length)}
+	}
+	data, _, err := v.readFileRange(v.ctx, path, offset, length)
+	return data, err
+}
+
+// DownloadOptions are options for Volume.DownloadFile.
+type DownloadOptions struct {
+	// Offset is the position in the file to start from, such as the size of
+	// a partial download to resume.
+	Offset int64
+	// ChunkSize is the number of bytes read per request, defaults to 8 MiB.
+	ChunkSize int64
+	// Retries is the number of times a failed chunk is retried, with
+	// exponential backoff, before giving up. Defaults to 5, negative disables
+	// retries. These replace the SDK's retries of the chunk's RPCs.
+	Retries int
+}
+
+// Default size of the chunks read by Volume.DownloadFile.
+const defaultDownloadChunkSize = 8 * 1024 * 1024
+
+// DownloadFile copies the file at path in the Volume to w, in chunks that are
+// retried independently after network errors, and returns the number of bytes
+// written. If an error is returned, a later call can resume from
+// options.Offset plus the bytes written so far.
+func (v *Volume) DownloadFile(path string, w io.Writer, options *DownloadOptions) (int64, error) {
+	if options == nil {
+		options = &DownloadOptions{}
+	}
+	if options.Offset < 0 || options.ChunkSize < 0 {
+		return 0, InvalidError{"Offset and ChunkSize must not be negative"}
+	}
+	chunkSize := cmp.Or(options.ChunkSize, defaultDownloadChunkSize)
+	retries := cmp.Or(options.Retries, 5) // negative is passed on to disable retries
+
+	// Chunks are retried here, so their RPCs are not retried by the client.
+	ctx := WithRetryPolicy(v.ctx, RetryPolicy{MaxRetries: -1})
+	offset := options.Offset
+	var written int64
+	for {
+		var data []byte
+		var size int64
+		err := Retry(ctx, &RetryPolicy{MaxRetries: retries, Retryable: isRetryableDownload}, func(ctx context.Context) error {
+			var err error
+			data, size, err = v.readFileRange(ctx, path, offset, chunkSize)
+			return err
+		})
+		if err != nil {
+			return written, fmt.Errorf("failed to read %s at offset %d: %w", path, offset, err)
+		}
+		n, err := w.Write(data)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+		offset += int64(n)
+		if offset >= size || len(data) == 0 {
+			return written, nil
+		}
+	}
+}
+
+// isRetryableDownload reports whether reading a chunk may succeed if retried:
+// transient gRPC errors, and errors downloading blobs over HTTP.
+func isRetryableDownload(err error) bool {
+	if _, ok := status.FromError(err); !ok {
+		return true
+	}
+	return isRetryableGrpc(err)
+}
+
+// uploadVolumeFile stores data so it can be added to a Volume, and returns its
+// entry for VolumePutFiles.
+func uploadVolumeFile(ctx context.Context, filename string, data []byte) (*pb.MountFile, error) {
+	return uploadVolumeFileWithMode(ctx, filename, data, volumeFileMode)
+}
+
+// uploadVolumeFileWithMode is like uploadVolumeFile, with explicit permissions.
+func uploadVolumeFileWithMode(ctx context.Context, filename string, data []byte, mode uint32) (*pb.MountFile, error) {
+	sum := sha256.Sum256(data)
+	sha256Hex := hex.EncodeToString(sum[:])
+
+	// Content-addressed: skip the upload if Modal already has this data.
+	resp, err := client.MountPutFile(ctx, pb.MountPutFileRequest_builder{
+		Sha256Hex: sha256Hex,
+	}.Build())
+	if err != nil {
+		return nil, err
+	}
+	if !resp.GetExists() {
+		req := pb.MountPutFileRequest_builder{Sha256Hex: sha256Hex}.Build()
+		if len(data) > maxObjectSizeBytes {
+			blobId, err := blobUpload(ctx, data)
+			if err != nil {
+				return nil, err
+			}
+			req.SetDataBlobId(blobId)
+		} else {
+			req.SetData(data)
+		}
+		if _, err := client.MountPutFile(ctx, req); err != nil {
+			return nil, fmt.Errorf("failed to upload %s: %w", filename, err)
+		}
+	}
+
+	size := uint64(len(data))
+	return pb.MountFile_builder{
+		Filename:  filename,
+		Sha256Hex: sha256Hex,
+		Size:      &size,
+		Mode:      &mode,
+	}.Build(), nil
+}
+
+// putFiles adds previously uploaded files to the Volume.
+func (v *Volume) putFiles(ctx context.Context, files []*pb.MountFile) error {
+	if len(files) == 0 {
+		return nil
+	}
+	_, err := client.VolumePutFiles(ctx, pb.VolumePutFilesRequest_builder{
+		VolumeId: v.VolumeId,
+		Files:    files,
+	}.Build())
+	return err
+}
+
+// removeFile deletes a file, or a directory if recursive is set, from the Volume.
+func (v *Volume) removeFile(path string, recursive bool) error {
+	_, err := client.VolumeRemoveFile(v.ctx, pb.VolumeRemoveFileRequest_builder{
+		VolumeId:  v.VolumeId,
+		Path:      path,
+		Recursive: recursive,
+	}.Build())
+	return err
+}
+
+// FileStatus is the outcome for one file of a batch operation on a Volume.
+type FileStatus string
+
+// Outcomes of batch operations.
+const (
+	FileSucceeded FileStatus = "succeeded"
+	FileSkipped   FileStatus = "skipped"
+	FileFailed    FileStatus = "failed"
+)
+
+// FileResult is the outcome for one file of Volume.BatchUpload or
+// Volume.BatchRemove.
+type FileResult struct {
+	Path   string // Path in the Volume.
+	Status FileStatus
+	Reason string // Why the file was skipped or failed.
+	Err    error  // The error, for FileFailed.
+}
+
+// UploadOptions are options for uploading local files to a Volume.
+type UploadOptions struct {
+	// FollowSymlinks uploads the targets of symbolic links. Volumes cannot
+	// store symbolic links, so they are skipped otherwise.
+	FollowSymlinks bool
+	// PreserveMode keeps the local permission bits of files, such as the
+	// executable bit. Otherwise files are written with mode 0644.
+	PreserveMode bool
+	// Exclude skips files and directories whose base name, or slash-separated
+	// path relative to the uploaded directory, matches any of these
+	// path.Match patterns, such as "*.pyc" or "build/*". On Windows, patterns
+	// may also use backslashes as separators.
+	Exclude []string
+	// ContinueOnError records files that fail to upload in the results of
+	// BatchUpload and carries on with the others, instead of stopping at the
+	// first failure.
+	ContinueOnError bool
+}
+
+// Upload copies a local file or directory into the Volume at remotePath,
+// overwriting existing files. Local paths may use the platform's separators,
+// and are stored with forward slashes. With options.ContinueOnError, it
+// uploads the files it can, and returns a FileBatchError if any failed.
+func (v *Volume) Upload(localPath, remotePath string, options *UploadOptions) error {
+	results, err := v.BatchUpload(localPath, remotePath, options)
+	if err != nil {
+		return err
+	}
+	return fileBatchError("upload", results)
+}
+
+// fileBatchError returns a FileBatchError for the failed results of a batch
+// operation, or nil if none failed.
+func fileBatchError(operation string, results []FileResult) error {
+	var failed []FileResult
+	for _, result := range results {
+		if result.Status == FileFailed {
+			failed = append(failed, result)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return FileBatchError{
+		Exception: fmt.Sprintf("failed to %s %d of %d files, first %s: %s", operation, len(failed), len(results), failed[0].Path, failed[0].Reason),
+		Failed:    failed,
+	}
+}
+
+// BatchUpload is like Upload, and returns the outcome for each file found,
+// including those skipped by options.
+//
+// Files are added to the Volume in batches, so a failure to add a batch fails
+// all of its files. Without options.ContinueOnError, BatchUpload returns the
+// results up to and including the first failure, along with its error.
+func (v *Volume) BatchUpload(localPath, remotePath string, options *UploadOptions) ([]FileResult, error) {
+	if options == nil {
+		options = &UploadOptions{}
+	}
+	exclude, err := excludePatterns(options.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	opts := *options
+	opts.Exclude = exclude
+	options = &opts
+	remotePath = path.Clean("/" + strings.ReplaceAll(remotePath, "\\", "/"))
+
+	u := &volumeUploader{ctx: v.ctx, options: options, visited: map[string]bool{}}
+	if err := u.add(localPath, remotePath, ""); err != nil {
+		return u.results, err
+	}
+	for len(u.files) > 0 {
+		n := min(len(u.files), volumePutFilesBatchSize)
+		err := v.putFiles(v.ctx, u.files[:n])
+		for _, i := range u.pending[:n] {
+			if err != nil {
+				u.results[i] = failedFile(u.results[i].Path, err)
+			} else {
+				u.results[i].Status = FileSucceeded
+			}
+		}
+		if err != nil && !options.ContinueOnError {
+			return u.results, err
+		}
+		u.files, u.pending = u.files[n:], u.pending[n:]
+	}
+	return u.results, nil
+}
+
+func failedFile(path string, err error) FileResult {
+	return FileResult{Path: path, Status: FileFailed, Reason: err.Error(), Err: err}
+}
+
+// BatchRemoveOptions are options for Volume.BatchRemove.
+type BatchRemoveOptions struct {
+	Recursive bool // Remove directories and their contents.
+	// ContinueOnError records paths that fail to be removed in the results
+	// and carries on with the others, instead of stopping at the first
+	// failure.
+	ContinueOnError bool
+}
+
+// BatchRemove deletes files, or directories with options.Recursive, from the
+// Volume, and returns the outcome for each path. Paths that don't exist are
+// skipped. Without options.ContinueOnError, BatchRemove returns the results up
+// to and including the first failure, along with its error.
+func (v *Volume) BatchRemove(paths []string, options *BatchRemoveOptions) ([]FileResult, error) {
+	if options == nil {
+		options = &BatchRemoveOptions{}
+	}
+	results := make([]FileResult, 0, len(paths))
+	for _, p := range paths {
+		err := v.removeFile(p, options.Recursive)
+		switch {
+		case err == nil:
+			results = append(results, FileResult{Path: p, Status: FileSucceeded})
+		case status.Code(err) == codes.NotFound:
+			results = append(results, FileResult{Path: p, Status: FileSkipped, Reason: "not found"})
+		default:
+			results = append(results, failedFile(p, err))
+			if !options.ContinueOnError {
+				return results, err
+			}
+		}
+	}
+	return results, nil
+}
+
+// volumeUploader collects local files for Volume.BatchUpload.
+type volumeUploader struct {
+	ctx     context.Context
+	options *UploadOptions
+	visited map[string]bool // resolved directories, to avoid symlink cycles
+	files   []*pb.MountFile
+	pending []int // index in results of each of files
+	results []FileResult
+}
+
+// skip records a file that is not uploaded.
+func (u *volumeUploader) skip(remotePath, reason string) {
+	u.results = append(u.results, FileResult{Path: remotePath, Status: FileSkipped, Reason: reason})
+}
+
+// fail records a file that failed, and returns err unless the upload
+// continues on errors.
+func (u *volumeUploader) fail(remotePath string, err error) error {
+	u.results = append(u.results, failedFile(remotePath, err))
+	if u.options.ContinueOnError {
+		return nil
+	}
+	return err
+}
+
+// add uploads the file or directory at localPath to remotePath. rel is its
+// path relative to the root of the upload, used for exclude patterns.
+func (u *volumeUploader) add(localPath, remotePath, rel string) error {
+	info, err := os.Lstat(localPath)
+	if err != nil {
+		return u.fail(remotePath, err)
+	}
+	if rel != "" && u.excluded(rel) {
+		u.skip(remotePath, "excluded")
+		return nil
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		if !u.options.FollowSymlinks {
+			u.skip(remotePath, "symbolic link")
+			return nil
+		}
+		if info, err = os.Stat(localPath); err != nil {
+			return u.fail(remotePath, err)
+		}
+	}
+
+	if info.IsDir() {
+		real, err := filepath.EvalSymlinks(localPath)
+		if err != nil {
+			return u.fail(remotePath, err)
+		}
+		if u.visited[real] {
+			return nil
+		}
+		u.visited[real] = true
+
+		entries, err := os.ReadDir(localPath)
+		if err != nil {
+			return u.fail(remotePath, err)
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if err := u.add(filepath.Join(localPath, name), path.Join(remotePath, name), path.Join(rel, name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if !info.Mode().IsRegular() {
+		u.skip(remotePath, "not a regular file") // devices, sockets, etc.
+		return nil
+	}
+
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return u.fail(remotePath, err)
+	}
+	mode := volumeFileMode
+	if u.options.PreserveMode {
+		mode = uint32(info.Mode().Perm())
+	}
+	file, err := uploadVolumeFileWithMode(u.ctx, remotePath, data, mode)
+	if err != nil {
+		return u.fail(remotePath, err)
+	}
+	u.files = append(u.files, file)
+	u.pending = append(u.pending, len(u.results))
+	u.results = append(u.results, FileResult{Path: remotePath})
+	return nil
+}
+
+func (u *volumeUploader) excluded(rel string) bool {
+	return excludedPath(u.options.Exclude, rel)
+}
+
+// excludePatterns returns exclude patterns with slash separators, or an
+// InvalidError for a malformed pattern.
+func excludePatterns(patterns []string) ([]string, error) {
+	exclude := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		exclude[i] = filepath.ToSlash(pattern)
+		if _, err := path.Match(exclude[i], ""); err != nil {
+			return nil, InvalidError{fmt.Sprintf("invalid exclude pattern %q: %v", pattern, err)}
+		}
+	}
+	return exclude, nil
+}
+
+// excludedPath reports whether the slash-separated relative path rel, or its
+// base name, matches any of patterns.
+func excludedPath(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, path.Base(rel)); ok {
+			return true
+		}
+	}
+	return false
+}
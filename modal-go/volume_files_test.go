@@ -0,0 +1,90 @@
+package modal
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestIsRetryableDownload(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	g.Expect(isRetryableDownload(errors.New("failed to download blob: status 503"))).To(gomega.BeTrue())
+	g.Expect(isRetryableDownload(status.Error(codes.Unavailable, "connection reset"))).To(gomega.BeTrue())
+	g.Expect(isRetryableDownload(status.Error(codes.NotFound, "no such file"))).To(gomega.BeFalse())
+}
+
+func TestVolumeUploaderResults(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	local := t.TempDir()
+	g.Expect(os.WriteFile(filepath.Join(local, "cache.pyc"), []byte("skip"), 0o644)).To(gomega.Succeed())
+	g.Expect(os.Symlink("cache.pyc", filepath.Join(local, "link"))).To(gomega.Succeed())
+
+	options := &UploadOptions{Exclude: []string{"*.pyc"}, ContinueOnError: true}
+	u := &volumeUploader{options: options, visited: map[string]bool{}}
+	g.Expect(u.add(local, "/dst", "")).To(gomega.Succeed())
+	g.Expect(u.add(filepath.Join(local, "missing"), "/missing", "")).To(gomega.Succeed())
+	g.Expect(u.files).To(gomega.BeEmpty())
+	g.Expect(u.results).To(gomega.HaveLen(3))
+	g.Expect(u.results[0]).To(gomega.Equal(FileResult{Path: "/dst/cache.pyc", Status: FileSkipped, Reason: "excluded"}))
+	g.Expect(u.results[1]).To(gomega.Equal(FileResult{Path: "/dst/link", Status: FileSkipped, Reason: "symbolic link"}))
+	g.Expect(u.results[2].Status).To(gomega.Equal(FileFailed))
+	g.Expect(u.results[2].Err).To(gomega.MatchError(os.ErrNotExist))
+
+	// Without ContinueOnError, the first failure stops the upload.
+	options.ContinueOnError = false
+	g.Expect(u.add(filepath.Join(local, "missing"), "/missing", "")).To(gomega.MatchError(os.ErrNotExist))
+	g.Expect(u.results).To(gomega.HaveLen(4))
+}
+
+func TestVolumeUploadReportsFailures(t *testing.T) {
+	t.Parallel()
+	g := gomega.NewWithT(t)
+
+	// No file is found, so no RPC is made.
+	volume := &Volume{VolumeId: "vo-123"}
+	missing := filepath.Join(t.TempDir(), "missing")
+	err := volume.Upload(missing, "/dst", &UploadOptions{ContinueOnError: true})
+	var batchErr FileBatchError
+	g.Expect(errors.As(err, &batchErr)).To(gomega.BeTrue())
+	g.Expect(batchErr.Failed).To(gomega.HaveLen(1))
+	g.Expect(batchErr.Failed[0].Path).To(gomega.Equal("/dst"))
+	g.Expect(err).To(gomega.MatchError(os.ErrNotExist))
+
+	g.Expect(fileBatchError("upload", []FileResult{{Path: "/a", Status: FileSucceeded}})).To(gomega.Succeed())
+}
+
+func TestVolumeDownloadFileRetries(t *testing.T) {
+	g := gomega.NewWithT(t)
+	calls := 0
+	fake := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if method == "/modal.client.ModalClient/VolumeGetFile" {
+			calls++
+		}
+		return status.Error(codes.Unavailable, "connection reset")
+	}
+	useFakeClient(t, fake)
+	volume := &Volume{VolumeId: "vo-123", ctx: context.Background()}
+
+	// Each chunk is retried by DownloadFile alone, not also by the client.
+	_, err := volume.DownloadFile("/data", io.Discard, &DownloadOptions{Retries: 2})
+	g.Expect(status.Code(errors.Unwrap(err))).To(gomega.Equal(codes.Unavailable))
+	g.Expect(calls).To(gomega.Equal(3))
+
+	// Negative Retries disables them.
+	calls = 0
+	_, err = volume.DownloadFile("/data", io.Discard, &DownloadOptions{Retries: -1})
+	g.Expect(err).To(gomega.HaveOccurred())
+	g.Expect(calls).To(gomega.Equal(1))
+}
@@ -0,0 +1,110 @@
+package modal
+
+// Scratch directories on Modal Volumes, removed lazily once their TTL passes.
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	pb "github.com/modal-labs/libmodal/modal-go/proto/modal_proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// Directory in the Volume that holds temporary directories.
+	volumeTempRoot = "/.modal-tmp"
+	// File in each temporary directory that records when it expires.
+	volumeTempExpiryFile = ".expires"
+	// Default lifetime of a temporary directory.
+	defaultVolumeTempTTL = 24 * time.Hour
+)
+
+// VolumeTempDirOptions are options for creating temporary directories on a Volume.
+type VolumeTempDirOptions struct {
+	TTL time.Duration // Time after which a later TempDir call may remove the directory, defaults to 24 hours.
+}
+
+// TempDir creates a uniquely named directory on the Volume and returns its
+// path, relative to the root of the Volume. Programs that mount the Volume,
+// such as Python Functions, can use it to exchange scratch data.
+//
+// The TTL is not enforced by Modal, which does not expire Volume files on its
+// own. Instead, each call to TempDir first removes the Volume's temporary
+// directories whose TTL has passed, so an expired directory stays on the
+// Volume until the next TempDir call on the same Volume.
+func (v *Volume) TempDir(prefix string, options *VolumeTempDirOptions) (string, error) {
+	if options == nil {
+		options = &VolumeTempDirOptions{}
+	}
+	if strings.Contains(prefix, "/") {
+		return "", InvalidError{fmt.Sprintf("temporary directory prefix must not contain '/': %q", prefix)}
+	}
+	ttl := options.TTL
+	if ttl == 0 {
+		ttl = defaultVolumeTempTTL
+	}
+	if ttl < 0 {
+		return "", InvalidError{fmt.Sprintf("TTL must be positive, got %v", ttl)}
+	}
+
+	if err := v.removeExpiredTempDirs(); err != nil {
+		return "", fmt.Errorf("failed to clean up expired temporary directories: %w", err)
+	}
+
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	name := hex.EncodeToString(suffix)
+	if prefix != "" {
+		name = prefix + "-" + name
+	}
+	dir := path.Join(volumeTempRoot, name)
+
+	expiry := time.Now().Add(ttl).UTC().Format(time.RFC3339)
+	file, err := uploadVolumeFile(v.ctx, path.Join(dir, volumeTempExpiryFile), []byte(expiry))
+	if err != nil {
+		return "", err
+	}
+	if err := v.putFiles(v.ctx, []*pb.MountFile{file}); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// removeExpiredTempDirs deletes temporary directories whose TTL has passed.
+// Directories without a readable expiry are left alone.
+func (v *Volume) removeExpiredTempDirs() error {
+	entries, err := v.listFiles(v.ctx, volumeTempRoot, false)
+	if status.Code(err) == codes.NotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, entry := range entries {
+		if entry.GetType() != pb.FileEntry_DIRECTORY {
+			continue
+		}
+		dir := path.Join(volumeTempRoot, path.Base(entry.GetPath()))
+		data, err := v.readFile(v.ctx, path.Join(dir, volumeTempExpiryFile))
+		if err != nil {
+			continue
+		}
+		expiry, err := time.Parse(time.RFC3339, strings.TrimSpace(string(data)))
+		if err != nil || now.Before(expiry) {
+			continue
+		}
+		if err := v.removeFile(dir, true); err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,434 @@
+// Package volumesync mirrors a local directory to a Modal Volume, and
+// optionally pulls changes made to the Volume back, for hot-reload development
+// loops where code edited locally runs in Sandboxes that mount the Volume.
+//
+// A Syncer polls both sides for changes, since the Go standard library has no
+// portable file watcher:
+//
+//	s := volumesync.New(volume, "./src", &volumesync.Options{Remote: "/src"})
+//	err := s.Run(ctx) // until ctx is done
+//
+// Files are compared by size and modification time. A file changed on both
+// sides since the last sync is a conflict, resolved by Options.Conflict. Empty
+// directories are not synced.
+//
+// On case-insensitive filesystems, the default on Windows and macOS, Volume
+// files whose paths differ only in case would overwrite each other locally.
+// Such files, and files whose names are not valid local paths, are not pulled
+// and are reported as conflicts instead.
+package volumesync
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/modal-labs/libmodal/modal-go"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const defaultInterval = time.Second
+
+// ConflictPolicy decides which side wins when a file changed both locally and
+// in the Volume since the last sync.
+type ConflictPolicy int
+
+const (
+	// PreferLocal overwrites the Volume's copy with the local one.
+	PreferLocal ConflictPolicy = iota
+	// PreferRemote overwrites the local copy with the Volume's one.
+	PreferRemote
+	// SkipConflicts leaves both copies alone and reports the conflict on
+	// every sync until one side is changed to match the other.
+	SkipConflicts
+)
+
+// Options are options for creating a Syncer.
+type Options struct {
+	Remote   string        // Directory in the Volume to mirror to, defaults to "/".
+	Interval time.Duration // Time between polls in Run, defaults to 1 second.
+	// Pull also copies files changed in the Volume to the local directory,
+	// and deletes local files deleted from the Volume.
+	Pull     bool
+	Conflict ConflictPolicy // Resolution of conflicts, only used with Pull.
+	// Exclude skips files whose base name, or slash-separated path relative
+	// to the synced directory, matches any of these path.Match patterns, such
+	// as ".git" or "*.pyc". On Windows, patterns may also use backslashes as
+	// separators.
+	Exclude []string
+	// OnChange, if set, is called for each change applied by Run, for
+	// logging.
+	OnChange func(Change)
+}
+
+// Op is the kind of a Change.
+type Op string
+
+// Kinds of changes applied by a sync.
+const (
+	Uploaded      Op = "uploaded"       // Local file copied to the Volume.
+	RemovedRemote Op = "removed-remote" // File deleted from the Volume.
+	Downloaded    Op = "downloaded"     // Volume file copied to the local directory.
+	RemovedLocal  Op = "removed-local"  // Local file deleted.
+	Conflict      Op = "conflict"       // File skipped under SkipConflicts, or that cannot be pulled.
+)
+
+// Change is a change applied by a sync to one file.
+type Change struct {
+	Path string // Slash-separated path relative to the synced directories.
+	Op   Op
+}
+
+// fileState is the size and modification time of a file on one side.
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// syncedState is the state of a file on both sides after it was last synced.
+// A zero remote state after an upload is filled in by the next listing.
+type syncedState struct {
+	local, remote fileState
+	localOk       bool
+	remoteOk      bool
+}
+
+// Syncer mirrors a local directory to a Volume. It is not safe for concurrent
+// use.
+type Syncer struct {
+	volume  *modal.Volume
+	local   string
+	remote  string
+	options Options
+	synced  map[string]syncedState // by relative path
+
+	foldCase *bool // whether the local filesystem is case-insensitive, once probed
+}
+
+// New returns a Syncer between the local directory and options.Remote in the
+// Volume. Nothing is synced until SyncOnce or Run is called.
+func New(volume *modal.Volume, localDir string, options *Options) *Syncer {
+	if options == nil {
+		options = &Options{}
+	}
+	s := &Syncer{
+		volume:  volume,
+		local:   localDir,
+		remote:  path.Clean("/" + options.Remote),
+		options: *options,
+		synced:  map[string]syncedState{},
+	}
+	s.options.Exclude = make([]string, len(options.Exclude))
+	for i, pattern := range options.Exclude {
+		s.options.Exclude[i] = filepath.ToSlash(pattern)
+	}
+	if s.options.Interval <= 0 {
+		s.options.Interval = defaultInterval
+	}
+	if !s.options.Pull {
+		s.options.Conflict = PreferLocal
+	}
+	return s
+}
+
+// Run syncs every Options.Interval until ctx is done, and returns ctx.Err(),
+// or the first error of a sync.
+func (s *Syncer) Run(ctx context.Context) error {
+	for {
+		changes, err := s.SyncOnce()
+		if err != nil {
+			return err
+		}
+		if s.options.OnChange != nil {
+			for _, change := range changes {
+				s.options.OnChange(change)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(s.options.Interval):
+		}
+	}
+}
+
+// SyncOnce compares both sides with the state of the last sync, applies the
+// changes, and returns them. The first call uploads all local files, and with
+// Options.Pull, downloads all files of the Volume, resolving files present on
+// both sides as conflicts.
+func (s *Syncer) SyncOnce() ([]Change, error) {
+	local, err := s.scanLocal()
+	if err != nil {
+		return nil, err
+	}
+	remote, err := s.listRemote()
+	if err != nil {
+		return nil, err
+	}
+	// Files uploaded by the last sync have their remote state listed now.
+	for rel, state := range s.synced {
+		if r, ok := remote[rel]; ok && state.remoteOk && state.remote == (fileState{}) {
+			state.remote = r
+			s.synced[rel] = state
+		}
+	}
+	changes := plan(s.synced, local, remote, s.options.Pull, s.options.Conflict)
+	if s.options.Pull {
+		if s.foldCase == nil {
+			foldCase, err := caseInsensitive(s.local)
+			if err != nil {
+				return nil, err
+			}
+			s.foldCase = &foldCase
+		}
+		unsafe := unsafeLocalPaths(local, remote, *s.foldCase)
+		for i, change := range changes {
+			if unsafe[change.Path] && (change.Op == Downloaded || change.Op == RemovedLocal) {
+				changes[i].Op = Conflict
+			}
+		}
+	}
+	for _, change := range changes {
+		if err := s.apply(change, local, remote); err != nil {
+			return nil, err
+		}
+	}
+	return changes, nil
+}
+
+// unsafeLocalPaths returns the Volume files that must not be written or
+// deleted locally: those whose path is not a valid local path, such as one
+// with a backslash on Windows, and with foldCase, those whose path differs
+// only in case from another file on either side.
+func unsafeLocalPaths(local, remote map[string]fileState, foldCase bool) map[string]bool {
+	unsafe := map[string]bool{}
+	spellings := map[string][]string{} // by folded path
+	for rel := range local {
+		spellings[strings.ToLower(rel)] = append(spellings[strings.ToLower(rel)], rel)
+	}
+	for rel := range remote {
+		localRel := filepath.FromSlash(rel)
+		if !filepath.IsLocal(localRel) || filepath.ToSlash(localRel) != rel {
+			unsafe[rel] = true
+		}
+		if _, ok := local[rel]; !ok {
+			spellings[strings.ToLower(rel)] = append(spellings[strings.ToLower(rel)], rel)
+		}
+	}
+	if foldCase {
+		for _, paths := range spellings {
+			if len(paths) > 1 {
+				for _, rel := range paths {
+					unsafe[rel] = true
+				}
+			}
+		}
+	}
+	return unsafe
+}
+
+// caseInsensitive reports whether the filesystem of dir is case-insensitive,
+// by looking up a temporary file under another case.
+func caseInsensitive(dir string) (bool, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return false, err
+	}
+	f, err := os.CreateTemp(dir, ".volumesync-case-")
+	if err != nil {
+		return false, err
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	_, err = os.Stat(filepath.Join(dir, strings.ToUpper(filepath.Base(f.Name()))))
+	return err == nil, nil
+}
+
+// plan returns the changes that bring both sides in sync, given the state of
+// the last sync. Without pull, remote changes are overwritten. Files deleted on
+// both sides are removed from synced.
+func plan(synced map[string]syncedState, local, remote map[string]fileState, pull bool, policy ConflictPolicy) []Change {
+	paths := map[string]bool{}
+	for rel := range local {
+		paths[rel] = true
+	}
+	for rel := range synced {
+		paths[rel] = true
+	}
+	if pull {
+		for rel := range remote {
+			paths[rel] = true
+		}
+	}
+
+	var changes []Change
+	for _, rel := range slices.Sorted(maps.Keys(paths)) {
+		base := synced[rel]
+		l, lok := local[rel]
+		r, rok := remote[rel]
+		localChanged := lok != base.localOk || (lok && l != base.local)
+		// A remote state is unknown right after an upload, until it's listed.
+		remoteChanged := pull && (rok != base.remoteOk || (rok && base.remote != fileState{} && r != base.remote))
+		if (localChanged || remoteChanged) && !lok && !rok {
+			delete(synced, rel) // deleted on both sides
+			continue
+		}
+
+		if localChanged && remoteChanged {
+			switch policy {
+			case PreferRemote:
+				localChanged = false
+			case SkipConflicts:
+				changes = append(changes, Change{Path: rel, Op: Conflict})
+				continue
+			default:
+				remoteChanged = false
+			}
+		}
+		switch {
+		case localChanged && lok:
+			changes = append(changes, Change{Path: rel, Op: Uploaded})
+		case localChanged && rok:
+			changes = append(changes, Change{Path: rel, Op: RemovedRemote})
+		case remoteChanged && rok:
+			changes = append(changes, Change{Path: rel, Op: Downloaded})
+		case remoteChanged && lok:
+			changes = append(changes, Change{Path: rel, Op: RemovedLocal})
+		}
+	}
+	return changes
+}
+
+// apply makes a change, and records the resulting state of the file.
+func (s *Syncer) apply(change Change, local, remote map[string]fileState) error {
+	localPath := filepath.Join(s.local, filepath.FromSlash(change.Path))
+	remotePath := path.Join(s.remote, change.Path)
+	switch change.Op {
+	case Uploaded:
+		if err := s.volume.Upload(localPath, remotePath, nil); err != nil {
+			return err
+		}
+		s.synced[change.Path] = syncedState{local: local[change.Path], localOk: true, remoteOk: true}
+	case RemovedRemote:
+		if _, err := s.volume.BatchRemove([]string{remotePath}, nil); err != nil {
+			return err
+		}
+		delete(s.synced, change.Path)
+	case Downloaded:
+		state, err := s.download(remotePath, localPath)
+		if err != nil {
+			return err
+		}
+		s.synced[change.Path] = syncedState{local: state, remote: remote[change.Path], localOk: true, remoteOk: true}
+	case RemovedLocal:
+		if err := os.Remove(localPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		delete(s.synced, change.Path)
+	}
+	return nil
+}
+
+// download copies a file from the Volume through a temporary file, so readers
+// never see it partially written, and returns its local state.
+func (s *Syncer) download(remotePath, localPath string) (fileState, error) {
+	if err := os.MkdirAll(filepath.Dir(localPath), 0o755); err != nil {
+		return fileState{}, err
+	}
+	f, err := os.CreateTemp(filepath.Dir(localPath), ".volumesync-*")
+	if err != nil {
+		return fileState{}, err
+	}
+	defer os.Remove(f.Name())
+	_, err = s.volume.DownloadFile(remotePath, f, nil)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fileState{}, err
+	}
+	if err := os.Rename(f.Name(), localPath); err != nil {
+		return fileState{}, err
+	}
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return fileState{}, err
+	}
+	return fileState{size: info.Size(), modTime: info.ModTime()}, nil
+}
+
+// scanLocal returns the regular files in the local directory.
+func (s *Syncer) scanLocal() (map[string]fileState, error) {
+	files := map[string]fileState{}
+	err := filepath.WalkDir(s.local, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(s.local, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if s.excluded(rel) || strings.HasPrefix(d.Name(), ".volumesync-") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files[rel] = fileState{size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+	return files, err
+}
+
+// listRemote returns the files under the remote directory in the Volume.
+func (s *Syncer) listRemote() (map[string]fileState, error) {
+	files := map[string]fileState{}
+	prefix := strings.TrimPrefix(s.remote, "/")
+	for entry, err := range s.volume.ListFiles(s.remote, &modal.ListFilesOptions{Recursive: true}) {
+		if status.Code(err) == codes.NotFound {
+			return files, nil // not created yet
+		}
+		if err != nil {
+			return nil, err
+		}
+		if entry.Type != "file" {
+			continue
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(entry.Path, "/"), prefix)
+		rel = strings.TrimPrefix(rel, "/")
+		if s.excluded(rel) {
+			continue
+		}
+		files[rel] = fileState{size: int64(entry.Size), modTime: entry.ModTime}
+	}
+	return files, nil
+}
+
+func (s *Syncer) excluded(rel string) bool {
+	for _, pattern := range s.options.Exclude {
+		for dir := rel; dir != "."; dir = path.Dir(dir) {
+			if ok, _ := path.Match(pattern, dir); ok {
+				return true
+			}
+			if ok, _ := path.Match(pattern, path.Base(dir)); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
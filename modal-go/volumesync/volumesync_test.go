@@ -0,0 +1,112 @@
+package volumesync
+
+import (
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+)
+
+func TestPlan(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	old := fileState{size: 1, modTime: time.Unix(100, 0)}
+	edited := fileState{size: 2, modTime: time.Unix(200, 0)}
+	remoteOld := fileState{size: 1, modTime: time.Unix(150, 0)}
+	remoteEdited := fileState{size: 3, modTime: time.Unix(250, 0)}
+	synced := func() map[string]syncedState {
+		return map[string]syncedState{
+			"same":           {local: old, remote: remoteOld, localOk: true, remoteOk: true},
+			"edited":         {local: old, remote: remoteOld, localOk: true, remoteOk: true},
+			"deleted":        {local: old, remote: remoteOld, localOk: true, remoteOk: true},
+			"remote-edited":  {local: old, remote: remoteOld, localOk: true, remoteOk: true},
+			"remote-deleted": {local: old, remote: remoteOld, localOk: true, remoteOk: true},
+			"both-edited":    {local: old, remote: remoteOld, localOk: true, remoteOk: true},
+			"both-deleted":   {local: old, remote: remoteOld, localOk: true, remoteOk: true},
+			"uploaded":       {local: old, localOk: true, remoteOk: true}, // remote state not listed yet
+		}
+	}
+	local := map[string]fileState{
+		"same":           old,
+		"edited":         edited,
+		"remote-edited":  old,
+		"remote-deleted": old,
+		"both-edited":    edited,
+		"uploaded":       old,
+		"new":            edited,
+	}
+	remote := map[string]fileState{
+		"same":          remoteOld,
+		"edited":        remoteOld,
+		"deleted":       remoteOld,
+		"remote-edited": remoteEdited,
+		"both-edited":   remoteEdited,
+		"uploaded":      remoteEdited,
+		"remote-new":    remoteEdited,
+	}
+
+	state := synced()
+	g.Expect(plan(state, local, remote, false, PreferLocal)).To(gomega.Equal([]Change{
+		{Path: "both-edited", Op: Uploaded},
+		{Path: "deleted", Op: RemovedRemote},
+		{Path: "edited", Op: Uploaded},
+		{Path: "new", Op: Uploaded},
+	}))
+	g.Expect(state).NotTo(gomega.HaveKey("both-deleted"))
+
+	g.Expect(plan(synced(), local, remote, true, PreferRemote)).To(gomega.Equal([]Change{
+		{Path: "both-edited", Op: Downloaded},
+		{Path: "deleted", Op: RemovedRemote},
+		{Path: "edited", Op: Uploaded},
+		{Path: "new", Op: Uploaded},
+		{Path: "remote-deleted", Op: RemovedLocal},
+		{Path: "remote-edited", Op: Downloaded},
+		{Path: "remote-new", Op: Downloaded},
+	}))
+
+	changes := plan(synced(), local, remote, true, SkipConflicts)
+	g.Expect(changes).To(gomega.ContainElement(Change{Path: "both-edited", Op: Conflict}))
+	g.Expect(changes).To(gomega.HaveLen(7))
+}
+
+func TestUnsafeLocalPaths(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	state := fileState{size: 1, modTime: time.Unix(100, 0)}
+	local := map[string]fileState{"Readme.md": state, "src/main.go": state}
+	remote := map[string]fileState{
+		"README.md":   state, // renamed locally by case only
+		"src/main.go": state,
+		"docs/a.txt":  state,
+		"docs/A.txt":  state,
+		"docs/b.txt":  state,
+	}
+	g.Expect(unsafeLocalPaths(local, remote, false)).To(gomega.BeEmpty())
+	g.Expect(unsafeLocalPaths(local, remote, true)).To(gomega.Equal(map[string]bool{
+		"README.md":  true,
+		"Readme.md":  true,
+		"docs/a.txt": true,
+		"docs/A.txt": true,
+	}))
+
+	if runtime.GOOS == "windows" {
+		remote := map[string]fileState{`a\b.txt`: state, "c:d.txt": state, "NUL": state}
+		g.Expect(unsafeLocalPaths(nil, remote, true)).To(gomega.HaveLen(3))
+	}
+}
+
+func TestCaseInsensitive(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	dir := t.TempDir()
+	foldCase, err := caseInsensitive(dir)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	if runtime.GOOS == "linux" {
+		g.Expect(foldCase).To(gomega.BeFalse())
+	}
+	entries, err := os.ReadDir(dir)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(entries).To(gomega.BeEmpty())
+}
@@ -20,6 +20,12 @@ def bytelength(buf: bytes) -> int:
     return len(buf)
 
 
+@app.function(min_containers=1)
+def byte_chunks(n: int, size: int):
+    for i in range(n):
+        yield bytes([i % 256]) * size
+
+
 @app.function(min_containers=1, experimental_options={"input_plane_region": "us-west"})
 def input_plane(s: str) -> str:
     return "output: " + s